@@ -3,6 +3,8 @@
 package actr
 
 import (
+	"strings"
+
 	"github.com/asmaloney/gactar/actr/buffer"
 	"github.com/asmaloney/gactar/actr/modules"
 	"github.com/asmaloney/gactar/actr/params"
@@ -16,6 +18,9 @@ type options struct {
 	// "trace_activations": output detailed info about activations
 	TraceActivations bool
 
+	// "report_memory": output the contents of declarative memory at the end of a run
+	ReportMemory bool
+
 	// "random_seed": the seed to use for generating pseudo-random numbers (allows for reproducible runs)
 	// For all frameworks, if it is not set it uses current system time.
 	// Use a uint32 because pyactr uses numpy and that's what its random number seed uses.
@@ -54,6 +59,8 @@ type Model struct {
 
 	Productions []*Production
 
+	Tests []*TestCase
+
 	options
 }
 
@@ -265,6 +272,14 @@ func (model *Model) SetParam(param *params.Param) (err error) {
 
 		model.TraceActivations = boolVal
 
+	case "report_memory":
+		boolVal, err := value.AsBool()
+		if err != nil {
+			return err
+		}
+
+		model.ReportMemory = boolVal
+
 	case "random_seed":
 		if value.Number == nil {
 			return params.ErrInvalidType{ExpectedType: params.Number}
@@ -280,3 +295,29 @@ func (model *Model) SetParam(param *params.Param) (err error) {
 
 	return
 }
+
+// SetParamPath sets a parameter using a path of the form "module.param" (e.g. "memory.decay").
+// The "gactar" prefix may be used to set the model's options (e.g. "gactar.random_seed").
+// Parameters are set using the same SetParam methods that are used when parsing the config section.
+func (model *Model) SetParamPath(path string, value params.Value) (err error) {
+	moduleName, key, found := strings.Cut(path, ".")
+	if !found || moduleName == "" || key == "" {
+		return params.ErrInvalidPath{Path: path}
+	}
+
+	param := &params.Param{
+		Key:   key,
+		Value: value,
+	}
+
+	if moduleName == "gactar" {
+		return model.SetParam(param)
+	}
+
+	module := model.LookupModule(moduleName)
+	if module == nil {
+		return params.ErrModuleNotFound{Name: moduleName}
+	}
+
+	return module.SetParam(param)
+}
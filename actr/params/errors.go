@@ -64,3 +64,19 @@ type ErrOutOfRange struct {
 func (e ErrOutOfRange) Error() string {
 	return fmt.Sprintf("is out of range (%s-%s)", numbers.Float64Str(e.Min), numbers.Float64Str(e.Max))
 }
+
+type ErrInvalidPath struct {
+	Path string
+}
+
+func (e ErrInvalidPath) Error() string {
+	return fmt.Sprintf("invalid parameter path %q (expected <module>.<parameter>)", e.Path)
+}
+
+type ErrModuleNotFound struct {
+	Name string
+}
+
+func (e ErrModuleNotFound) Error() string {
+	return fmt.Sprintf("module %q not found in model", e.Name)
+}
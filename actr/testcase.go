@@ -0,0 +1,54 @@
+package actr
+
+// TestCase is a test of the model from the "tests" section of the amod file. The model is run
+// using the initial goal and the results are compared with what is expected.
+type TestCase struct {
+	Name        string
+	Description *string
+
+	Goal *Pattern // initial contents of the goal buffer
+
+	Prints  []string         // text of each print statement in the order they happen
+	Buffers []*BufferContent // contents of buffers at the end of the run
+
+	MinEndTime *float64 // run must end at or after this time (in seconds)
+	MaxEndTime *float64 // run must end at or before this time (in seconds)
+
+	Fired    []string // productions which must fire
+	NotFired []string // productions which must not fire
+
+	AMODLineNumber int
+}
+
+// BufferContent is the expected content of a buffer at the end of a test.
+type BufferContent struct {
+	BufferName string
+	Pattern    *Pattern // nil if the buffer should be empty
+}
+
+// HasTests returns whether the model contains any test cases.
+func (model Model) HasTests() bool {
+	return len(model.Tests) > 0
+}
+
+// LookupTest looks up the test case by name and returns it (or nil if it does not exist).
+func (model Model) LookupTest(name string) *TestCase {
+	for _, test := range model.Tests {
+		if test.Name == name {
+			return test
+		}
+	}
+
+	return nil
+}
+
+// LookupProduction looks up the production by name and returns it (or nil if it does not exist).
+func (model Model) LookupProduction(name string) *Production {
+	for _, production := range model.Productions {
+		if production.Name == name {
+			return production
+		}
+	}
+
+	return nil
+}
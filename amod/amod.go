@@ -32,6 +32,15 @@ func (e ErrParseChunk) Error() string {
 	return fmt.Sprintf("cannot parse chunk: %s", e.Message)
 }
 
+type ErrCannotSetParam struct {
+	Key     string
+	Message string
+}
+
+func (e ErrCannotSetParam) Error() string {
+	return fmt.Sprintf("cannot set parameter %q: %s", e.Key, e.Message)
+}
+
 // SetDebug turns debugging on and off. This will output the tokens as they are generated.
 func SetDebug(debug bool) {
 	debugging = debug
@@ -159,6 +168,7 @@ func generateModel(amod *amodFile, log *issueLog) (model *actr.Model, err error)
 	addExamples(model, log, amod.Model.Examples)
 	addInit(model, log, amod.Init)
 	addProductions(model, log, amod.Productions)
+	addTests(model, log, amod.Tests)
 
 	if log.HasError() {
 		return nil, ErrCompile
@@ -494,6 +504,65 @@ func addProductions(model *actr.Model, log *issueLog, productions *productionSec
 	}
 }
 
+func addTests(model *actr.Model, log *issueLog, tests *testSection) {
+	if tests == nil {
+		return
+	}
+
+	for _, test := range tests.Cases {
+		err := validateTestCase(model, log, test)
+		if err != nil {
+			continue
+		}
+
+		goal, err := createChunkPattern(model, log, test.Goal)
+		if err != nil {
+			continue
+		}
+
+		testCase := actr.TestCase{
+			Name:           test.Name,
+			Description:    test.Description,
+			Goal:           goal,
+			Fired:          test.Fired,
+			NotFired:       test.NotFired,
+			AMODLineNumber: test.Tokens[0].Pos.Line,
+		}
+
+		for _, print := range test.Prints {
+			switch {
+			case print.Str != nil:
+				testCase.Prints = append(testCase.Prints, *print.Str)
+
+			case print.Number != nil:
+				testCase.Prints = append(testCase.Prints, *print.Number)
+			}
+		}
+
+		for _, buffer := range test.Buffers {
+			content := actr.BufferContent{
+				BufferName: buffer.BufferName,
+			}
+
+			if buffer.Pattern != nil {
+				content.Pattern, err = createChunkPattern(model, log, buffer.Pattern)
+				if err != nil {
+					continue
+				}
+			}
+
+			testCase.Buffers = append(testCase.Buffers, &content)
+		}
+
+		if test.EndTime != nil {
+			testCase.MinEndTime = test.EndTime.Min
+			testCase.MaxEndTime = test.EndTime.Max
+		}
+
+		model.Tests = append(model.Tests, &testCase)
+	}
+}
+
 func fieldToParam(f *field) *params.Param {
 	value := f.Value
 
@@ -104,6 +104,31 @@ func Example_gactarTraceActivationsNonBool() {
 	// ERROR: 'trace_activations' must be 'true' or 'false' (line 5, col 29)
 }
 
+func Example_gactarReportMemory() {
+	generateToStdout(`
+	~~ model ~~
+	name: Test
+	~~ config ~~
+	gactar { report_memory: true }
+	~~ init ~~
+	~~ productions ~~`)
+
+	// Output:
+}
+
+func Example_gactarReportMemoryNonBool() {
+	generateToStdout(`
+	~~ model ~~
+	name: Test
+	~~ config ~~
+	gactar { report_memory: 'yes' }
+	~~ init ~~
+	~~ productions ~~`)
+
+	// Output:
+	// ERROR: 'report_memory' must be 'true' or 'false' (line 5, col 25)
+}
+
 func Example_chunkInternalType() {
 	generateToStdout(`
 	~~ model ~~
@@ -0,0 +1,162 @@
+package amod
+
+func Example_tests() {
+	generateToStdout(`
+	~~ model ~~
+	name: Test
+	~~ config ~~
+	chunks { [count: first second] }
+	~~ init ~~
+	~~ productions ~~
+	start {
+		match { goal [count: 1 nil] }
+		do {
+			print 'Start'
+			set goal.second to 2
+		}
+	}
+	~~ tests ~~
+	counts {
+		description: 'counts to two'
+		goal [count: 1 nil]
+		prints { 'Start' }
+		buffers {
+			goal [count: 1 2]
+			retrieval nil
+		}
+		end_time { min: 0.0 max: 1.5 }
+		fired { start }
+	}`)
+
+	// Output:
+}
+
+func Example_testsDuplicateName() {
+	generateToStdout(`
+	~~ model ~~
+	name: Test
+	~~ config ~~
+	chunks { [count: first] }
+	~~ init ~~
+	~~ productions ~~
+	start {
+		match { goal [count: 1] }
+		do { stop }
+	}
+	~~ tests ~~
+	stops { goal [count: 1] }
+	stops { goal [count: 1] }`)
+
+	// Output:
+	// ERROR: duplicate test name: 'stops' (line 14, col 1)
+}
+
+func Example_testsBadBuffer() {
+	generateToStdout(`
+	~~ model ~~
+	name: Test
+	~~ config ~~
+	chunks { [count: first] }
+	~~ init ~~
+	~~ productions ~~
+	start {
+		match { goal [count: 1] }
+		do { stop }
+	}
+	~~ tests ~~
+	stops {
+		goal [count: 1]
+		buffers { imaginal nil }
+	}`)
+
+	// Output:
+	// ERROR: buffer 'imaginal' not found in test 'stops' (line 15, col 12)
+}
+
+func Example_testsVariable() {
+	generateToStdout(`
+	~~ model ~~
+	name: Test
+	~~ config ~~
+	chunks { [count: first] }
+	~~ init ~~
+	~~ productions ~~
+	start {
+		match { goal [count: 1] }
+		do { stop }
+	}
+	~~ tests ~~
+	stops {
+		goal [count: 1]
+		buffers { goal [count: ?x] }
+	}`)
+
+	// Output:
+	// ERROR: cannot use variable '?x' in test 'stops' (line 15, col 25)
+}
+
+func Example_testsBadPrint() {
+	generateToStdout(`
+	~~ model ~~
+	name: Test
+	~~ config ~~
+	chunks { [count: first] }
+	~~ init ~~
+	~~ productions ~~
+	start {
+		match { goal [count: 1] }
+		do { stop }
+	}
+	~~ tests ~~
+	stops {
+		goal [count: 1]
+		prints { nil }
+	}`)
+
+	// Output:
+	// ERROR: cannot use nil in prints of test 'stops' (line 15, col 11)
+}
+
+func Example_testsUnknownProduction() {
+	generateToStdout(`
+	~~ model ~~
+	name: Test
+	~~ config ~~
+	chunks { [count: first] }
+	~~ init ~~
+	~~ productions ~~
+	start {
+		match { goal [count: 1] }
+		do { stop }
+	}
+	~~ tests ~~
+	stops {
+		goal [count: 1]
+		not_fired { finish }
+	}`)
+
+	// Output:
+	// ERROR: production 'finish' not found in test 'stops' (line 13, col 1)
+}
+
+func Example_testsEndTime() {
+	generateToStdout(`
+	~~ model ~~
+	name: Test
+	~~ config ~~
+	chunks { [count: first] }
+	~~ init ~~
+	~~ productions ~~
+	start {
+		match { goal [count: 1] }
+		do { stop }
+	}
+	~~ tests ~~
+	stops {
+		goal [count: 1]
+		end_time { min: 2 max: 1 }
+	}`)
+
+	// Output:
+	// ERROR: end_time min is greater than max in test 'stops' (line 15, col 11)
+}
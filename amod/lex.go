@@ -93,10 +93,11 @@ func (l lexemeType) String() string {
 }
 
 type lexeme struct {
-	typ   lexemeType
-	value string
-	line  int // line number this lexeme is on
-	pos   int // position within the line
+	typ    lexemeType
+	value  string
+	line   int // line number this lexeme is on
+	pos    int // position within the line
+	offset int // offset from beginning of file
 }
 
 // lexer_amod tracks our lexing and provides a channel to emit lexemes
@@ -205,7 +206,7 @@ func (l *lexer_amod) Next() (tok lexer.Token, err error) {
 
 	pos := lexer.Position{
 		Filename: l.name,
-		Offset:   l.pos,
+		Offset:   next.offset,
 		Line:     next.line,
 		Column:   next.pos,
 	}
@@ -298,10 +299,11 @@ func (l *lexer_amod) acceptRun(valid string) {
 func (l *lexer_amod) emit(t lexemeType) {
 	value := l.input[l.start:l.pos]
 	l.lexemes <- lexeme{
-		typ:   t,
-		value: value,
-		line:  l.line,
-		pos:   l.start - l.lastNewlinePos + 1,
+		typ:    t,
+		value:  value,
+		line:   l.line,
+		pos:    l.start - l.lastNewlinePos + 1,
+		offset: l.start,
 	}
 
 	l.start = l.pos
@@ -314,6 +316,7 @@ func (l *lexer_amod) errorf(format string, args ...interface{}) stateFn {
 		fmt.Sprintf(format, args...),
 		l.line,
 		l.pos - l.lastNewlinePos,
+		l.pos,
 	}
 
 	return nil
@@ -37,6 +37,8 @@ type amodFile struct {
 	ProductionsHeader string             `parser:"'~~':SectionDelim 'productions' '~~':SectionDelim"`
 	Productions       *productionSection `parser:"(@@)?"`
 
+	Tests *testSection `parser:"(@@)?"`
+
 	Tokens []lexer.Token
 }
 
@@ -304,6 +306,44 @@ type productionSection struct {
 	Tokens []lexer.Token
 }
 
+type testBuffer struct {
+	BufferName string   `parser:"@Ident"`
+	Nil        *bool    `parser:"( @('nil':Keyword)"`
+	Pattern    *pattern `parser:"| @@ )"`
+
+	Tokens []lexer.Token
+}
+
+type testEndTime struct {
+	OpenBrace  string   `parser:"'{'"` // not used - must be set for parse
+	Min        *float64 `parser:"('min' ':' @Number)?"`
+	Max        *float64 `parser:"('max' ':' @Number)?"`
+	CloseBrace string   `parser:"'}'"` // not used - must be set for parse
+
+	Tokens []lexer.Token
+}
+
+type testCase struct {
+	Name        string        `parser:"@Ident '{'"`
+	Description *string       `parser:"('description' ':' @String)?"`
+	Goal        *pattern      `parser:"'goal' @@"`
+	Prints      []*arg        `parser:"('prints' '{' @@* '}')?"`
+	Buffers     []*testBuffer `parser:"('buffers' '{' @@* '}')?"`
+	EndTime     *testEndTime  `parser:"('end_time' @@)?"`
+	Fired       []string      `parser:"('fired' '{' @Ident* '}')?"`
+	NotFired    []string      `parser:"('not_fired' '{' @Ident* '}')?"`
+	End         string        `parser:"'}'"` // not used, but must be visible for parse to work
+
+	Tokens []lexer.Token
+}
+
+type testSection struct {
+	Header string      `parser:"'~~':SectionDelim 'tests' '~~':SectionDelim"`
+	Cases  []*testCase `parser:"@@*"`
+
+	Tokens []lexer.Token
+}
+
 var amodParser = participle.MustBuild[amodFile](
 	participle.Lexer(LexerDefinition),
 	participle.Elide("Comment", "Whitespace"),
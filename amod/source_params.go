@@ -0,0 +1,260 @@
+package amod
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+
+	"github.com/asmaloney/gactar/actr/params"
+)
+
+// sourceEdit is a single change to amod source text.
+type sourceEdit struct {
+	offset    int    // where to make the change
+	deleteLen int    // number of bytes to remove at offset
+	insert    string // text to insert at offset
+}
+
+// SetParamsInSource takes the amod source and returns a copy with the parameters set.
+// Parameters are specified using paths of the form "module.param" (e.g. "memory.decay") or
+// "gactar.param" (see actr.Model.SetParamPath). Values are written as-is.
+//
+// Existing values in the config section are replaced, and missing ones are added, so the rest
+// of the file (including comments and formatting) is left untouched.
+func SetParamsInSource(source string, values map[string]string) (result string, err error) {
+	paths := make([]string, 0, len(values))
+	for path := range values {
+		paths = append(paths, path)
+	}
+
+	// Apply in a consistent order so the output is reproducible
+	sort.Strings(paths)
+
+	result = source
+
+	for _, path := range paths {
+		// Re-parse each time since the previous edit will have changed the offsets.
+		amod, parseErr := parse(strings.NewReader(result))
+		if parseErr != nil {
+			return "", fmt.Errorf("%w: %s", ErrParse, parseErr.Error())
+		}
+
+		edit, editErr := paramEdit(result, amod.Tokens, path, values[path])
+		if editErr != nil {
+			return "", editErr
+		}
+
+		result = result[:edit.offset] + edit.insert + result[edit.offset+edit.deleteLen:]
+	}
+
+	return
+}
+
+// paramEdit works out how to change the source to set the parameter at "path".
+func paramEdit(source string, tokens []lexer.Token, path, value string) (edit *sourceEdit, err error) {
+	moduleName, key, found := strings.Cut(path, ".")
+	if !found || moduleName == "" || key == "" {
+		return nil, params.ErrInvalidPath{Path: path}
+	}
+
+	configStart, configEnd := findSection(tokens, "config")
+	if configStart == -1 {
+		return nil, ErrParse
+	}
+
+	// Options in the gactar section
+	if moduleName == "gactar" {
+		open, close := findBlock(tokens, configStart, configEnd, "gactar")
+		if open == -1 {
+			return insertAfterToken(source, tokens[configStart-1], fmt.Sprintf("gactar { %s: %s }", key, value)), nil
+		}
+
+		return fieldEdit(source, tokens, open, close, key, value)
+	}
+
+	// Modules
+	modulesOpen, modulesClose := findBlock(tokens, configStart, configEnd, "modules")
+	if modulesOpen == -1 {
+		text := fmt.Sprintf("modules {\n    %s { %s: %s }\n}", moduleName, key, value)
+
+		// The modules section comes after the gactar section if there is one
+		_, gactarClose := findBlock(tokens, configStart, configEnd, "gactar")
+		if gactarClose != -1 {
+			return insertAfterToken(source, tokens[gactarClose], text), nil
+		}
+
+		return insertAfterToken(source, tokens[configStart-1], text), nil
+	}
+
+	open, close := findBlock(tokens, modulesOpen+1, modulesClose, moduleName)
+	if open == -1 {
+		return insertInBlock(source, tokens, modulesOpen, modulesClose, fmt.Sprintf("%s { %s: %s }", moduleName, key, value)), nil
+	}
+
+	return fieldEdit(source, tokens, open, close, key, value)
+}
+
+// fieldEdit replaces the value of the field "key" in the block or adds it if it does not exist.
+func fieldEdit(source string, tokens []lexer.Token, open, close int, key, value string) (edit *sourceEdit, err error) {
+	depth := 0
+
+	for i := open + 1; i < close; i++ {
+		tok := tokens[i]
+
+		switch {
+		case isChar(tok, "{"):
+			depth++
+			continue
+
+		case isChar(tok, "}"):
+			depth--
+			continue
+		}
+
+		if depth != 0 || tok.Value != key || i+2 >= close || !isChar(tokens[i+1], ":") {
+			continue
+		}
+
+		valueToken := tokens[i+2]
+		if isChar(valueToken, "{") {
+			return nil, &ErrCannotSetParam{Key: key, Message: "it contains nested fields"}
+		}
+
+		edit = &sourceEdit{
+			offset:    valueToken.Pos.Offset,
+			deleteLen: tokenLen(valueToken),
+			insert:    value,
+		}
+
+		return
+	}
+
+	return insertInBlock(source, tokens, open, close, fmt.Sprintf("%s: %s", key, value)), nil
+}
+
+// insertInBlock adds "text" as a new item at the end of the block delimited by tokens[open] & tokens[close].
+func insertInBlock(source string, tokens []lexer.Token, open, close int, text string) *sourceEdit {
+	closeTok := tokens[close]
+	closeOffset := closeTok.Pos.Offset
+
+	lineStart := strings.LastIndex(source[:closeOffset], "\n") + 1
+	closeIndent := source[lineStart:closeOffset]
+
+	// If the closing brace is not on its own line, insert on the same line.
+	if strings.TrimSpace(closeIndent) != "" || tokens[open].Pos.Line == closeTok.Pos.Line {
+		insert := text + " "
+		if closeOffset > 0 && source[closeOffset-1] != ' ' {
+			insert = " " + insert
+		}
+
+		return &sourceEdit{offset: closeOffset, insert: insert}
+	}
+
+	// Use the indentation of the first item in the block if there is one.
+	indent := closeIndent + "    "
+	if open+1 < close {
+		firstOffset := tokens[open+1].Pos.Offset
+		firstLineStart := strings.LastIndex(source[:firstOffset], "\n") + 1
+		firstIndent := source[firstLineStart:firstOffset]
+
+		if strings.TrimSpace(firstIndent) == "" {
+			indent = firstIndent
+		}
+	}
+
+	return &sourceEdit{offset: lineStart, insert: indent + text + "\n"}
+}
+
+// insertAfterToken adds "text" on a new line after the token.
+func insertAfterToken(source string, tok lexer.Token, text string) *sourceEdit {
+	return &sourceEdit{
+		offset: tok.Pos.Offset + tokenLen(tok),
+		insert: "\n\n" + text,
+	}
+}
+
+// findSection returns the range of token indices for the contents of the named section.
+// It returns -1 if the section was not found.
+func findSection(tokens []lexer.Token, name string) (start, end int) {
+	start, end = -1, len(tokens)
+
+	for i := 0; i+2 < len(tokens); i++ {
+		if !isSectionDelim(tokens[i]) || !isSectionDelim(tokens[i+2]) {
+			continue
+		}
+
+		if start != -1 {
+			end = i
+			return
+		}
+
+		if tokens[i+1].Value == name {
+			start = i + 3
+			i += 2
+		}
+	}
+
+	return
+}
+
+// findBlock looks for "name {" at the top level between start & end, and returns the indices
+// of its braces. It returns -1 if the block was not found.
+func findBlock(tokens []lexer.Token, start, end int, name string) (open, close int) {
+	depth := 0
+
+	for i := start; i < end; i++ {
+		tok := tokens[i]
+
+		switch {
+		case isChar(tok, "{"):
+			depth++
+
+		case isChar(tok, "}"):
+			depth--
+
+		case depth == 0 && tok.Value == name && !isString(tok) && i+1 < end && isChar(tokens[i+1], "{"):
+			open = i + 1
+
+			for j, blockDepth := open, 0; j < end; j++ {
+				switch {
+				case isChar(tokens[j], "{"):
+					blockDepth++
+
+				case isChar(tokens[j], "}"):
+					blockDepth--
+					if blockDepth == 0 {
+						return open, j
+					}
+				}
+			}
+
+			return -1, -1
+		}
+	}
+
+	return -1, -1
+}
+
+func isChar(tok lexer.Token, char string) bool {
+	return tok.Type == lexer.TokenType(lexemeChar) && tok.Value == char
+}
+
+func isString(tok lexer.Token) bool {
+	return tok.Type == lexer.TokenType(lexemeString)
+}
+
+func isSectionDelim(tok lexer.Token) bool {
+	return tok.Type == lexer.TokenType(lexemeSectionDelim)
+}
+
+// tokenLen returns the length of the token in the source. Strings have been unquoted by the parser,
+// so we add the quotes back.
+func tokenLen(tok lexer.Token) int {
+	if isString(tok) {
+		return len(tok.Value) + 2
+	}
+
+	return len(tok.Value)
+}
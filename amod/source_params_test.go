@@ -0,0 +1,160 @@
+package amod
+
+import (
+	"testing"
+)
+
+func TestSetParamsInSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		values   map[string]string
+		expected string
+	}{
+		{
+			name: "replace existing",
+			source: `~~ model ~~
+name: Test
+~~ config ~~
+modules {
+    memory {
+        decay: 0.5 // comment stays
+    }
+}
+~~ init ~~
+~~ productions ~~`,
+			values: map[string]string{"memory.decay": "0.25"},
+			expected: `~~ model ~~
+name: Test
+~~ config ~~
+modules {
+    memory {
+        decay: 0.25 // comment stays
+    }
+}
+~~ init ~~
+~~ productions ~~`,
+		},
+		{
+			name: "add field to module",
+			source: `~~ model ~~
+name: Test
+~~ config ~~
+modules {
+    memory {
+        decay: 0.5
+    }
+}
+~~ init ~~
+~~ productions ~~`,
+			values: map[string]string{
+				"memory.latency_factor":      "0.2",
+				"memory.retrieval_threshold": "-1",
+			},
+			expected: `~~ model ~~
+name: Test
+~~ config ~~
+modules {
+    memory {
+        decay: 0.5
+        latency_factor: 0.2
+        retrieval_threshold: -1
+    }
+}
+~~ init ~~
+~~ productions ~~`,
+		},
+		{
+			name: "add module",
+			source: `~~ model ~~
+name: Test
+~~ config ~~
+modules {
+    goal { spreading_activation: 1 }
+}
+~~ init ~~
+~~ productions ~~`,
+			values: map[string]string{"memory.decay": "0.5"},
+			expected: `~~ model ~~
+name: Test
+~~ config ~~
+modules {
+    goal { spreading_activation: 1 }
+    memory { decay: 0.5 }
+}
+~~ init ~~
+~~ productions ~~`,
+		},
+		{
+			name: "add modules section",
+			source: `~~ model ~~
+name: Test
+~~ config ~~
+gactar { log_level: 'min' }
+~~ init ~~
+~~ productions ~~`,
+			values: map[string]string{"memory.decay": "0.5"},
+			expected: `~~ model ~~
+name: Test
+~~ config ~~
+gactar { log_level: 'min' }
+
+modules {
+    memory { decay: 0.5 }
+}
+~~ init ~~
+~~ productions ~~`,
+		},
+		{
+			name: "replace string and single line",
+			source: `~~ model ~~
+name: Test
+~~ config ~~
+gactar { log_level: 'min' }
+~~ init ~~
+~~ productions ~~`,
+			values: map[string]string{
+				"gactar.log_level":   "'detail'",
+				"gactar.random_seed": "42",
+			},
+			expected: `~~ model ~~
+name: Test
+~~ config ~~
+gactar { log_level: 'detail' random_seed: 42 }
+~~ init ~~
+~~ productions ~~`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := SetParamsInSource(test.source, test.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result != test.expected {
+				t.Errorf("unexpected result:\n--- expected\n%s\n--- got\n%s", test.expected, result)
+			}
+
+			// Make sure we still have a valid model
+			_, log, err := GenerateModel(result)
+			if err != nil {
+				t.Errorf("result does not compile: %s", log)
+			}
+		})
+	}
+}
+
+func TestSetParamsInSourceInvalidPath(t *testing.T) {
+	source := `~~ model ~~
+name: Test
+~~ config ~~
+~~ init ~~
+~~ productions ~~`
+
+	_, err := SetParamsInSource(source, map[string]string{"decay": "0.5"})
+	if err == nil {
+		t.Errorf("expected error for invalid path")
+	}
+}
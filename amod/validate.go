@@ -5,6 +5,7 @@ import (
 	"github.com/asmaloney/gactar/actr"
 	"github.com/asmaloney/gactar/actr/buffer"
 
+	"github.com/asmaloney/gactar/util/container"
 	"github.com/asmaloney/gactar/util/issues"
 )
 
@@ -349,6 +350,91 @@ func validatePrintStatement(print *printStatement, model *actr.Model, log *issue
 	return
 }
 
+// validateTestCase checks that a test case refers to things which exist in the model.
+func validateTestCase(model *actr.Model, log *issueLog, test *testCase) (err error) {
+	if model.LookupTest(test.Name) != nil {
+		log.errorTR(test.Tokens, 0, 1, "duplicate test name: '%s'", test.Name)
+		err = ErrCompile
+	}
+
+	if validateTestPattern(model, log, test.Name, test.Goal) != nil {
+		err = ErrCompile
+	}
+
+	for _, print := range test.Prints {
+		switch {
+		case print.Nil != nil:
+			log.errorT(print.Tokens, "cannot use nil in prints of test '%s'", test.Name)
+			err = ErrCompile
+
+		case print.Var != nil:
+			log.errorT(print.Tokens, "cannot use variable '%s' in prints of test '%s'", *print.Var, test.Name)
+			err = ErrCompile
+
+		case print.ID != nil:
+			log.errorT(print.Tokens, "cannot use ID '%s' in prints of test '%s' (use a string)", *print.ID, test.Name)
+			err = ErrCompile
+		}
+	}
+
+	for _, buffer := range test.Buffers {
+		if model.LookupBuffer(buffer.BufferName) == nil {
+			log.errorTR(buffer.Tokens, 0, 1, "buffer '%s' not found in test '%s'", buffer.BufferName, test.Name)
+			err = ErrCompile
+			continue
+		}
+
+		if buffer.Pattern != nil && validateTestPattern(model, log, test.Name, buffer.Pattern) != nil {
+			err = ErrCompile
+		}
+	}
+
+	if test.EndTime != nil {
+		min, max := test.EndTime.Min, test.EndTime.Max
+
+		if (min != nil && *min < 0) || (max != nil && *max < 0) {
+			log.errorT(test.EndTime.Tokens, "end_time cannot be negative in test '%s'", test.Name)
+			err = ErrCompile
+		} else if min != nil && max != nil && *min > *max {
+			log.errorT(test.EndTime.Tokens, "end_time min is greater than max in test '%s'", test.Name)
+			err = ErrCompile
+		}
+	}
+
+	for _, name := range append(append([]string{}, test.Fired...), test.NotFired...) {
+		if model.LookupProduction(name) == nil {
+			log.errorT(test.Tokens, "production '%s' not found in test '%s'", name, test.Name)
+			err = ErrCompile
+		}
+	}
+
+	for _, name := range test.Fired {
+		if container.Contains(name, test.NotFired) {
+			log.errorT(test.Tokens, "production '%s' cannot be in both fired and not_fired in test '%s'", name, test.Name)
+			err = ErrCompile
+		}
+	}
+
+	return
+}
+
+// validateTestPattern checks a pattern used in a test. Tests may use wildcards, but not variables.
+func validateTestPattern(model *actr.Model, log *issueLog, testName string, pattern *pattern) (err error) {
+	err = validatePattern(model, log, pattern)
+	if err != nil {
+		return
+	}
+
+	for _, slot := range pattern.Slots {
+		if slot.Var != nil {
+			log.errorT(slot.Tokens, "cannot use variable '%s' in test '%s'", *slot.Var, testName)
+			err = ErrCompile
+		}
+	}
+
+	return
+}
+
 // validateVariableUsage verifies variable usage by counting how many times they are referenced.
 func validateVariableUsage(log *issueLog, match *match, do *do) {
 	type ref struct {
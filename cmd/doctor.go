@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/asmaloney/gactar/framework/plugin"
 	"github.com/asmaloney/gactar/util/chalk"
+	"github.com/asmaloney/gactar/util/checksum"
 	"github.com/asmaloney/gactar/util/cli"
 	"github.com/asmaloney/gactar/util/executil"
 	"github.com/asmaloney/gactar/util/filesystem"
@@ -21,6 +26,46 @@ var (
 	ErrHealthCheckFailed = errors.New("gactar health check failed")
 )
 
+// Status of a doctor check.
+const (
+	checkOK      = "ok"
+	checkWarning = "warning" // something is missing, but we can still run some frameworks
+	checkError   = "error"
+)
+
+// doctorCheck is the result of checking one part of an environment.
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+
+	section string                // section to output it in
+	fix     func(io.Writer) error // remediation if the check fails (nil if there isn't one) - progress is written to the writer
+}
+
+// doctorFix is the result of trying to fix a failed check.
+type doctorFix struct {
+	Check string `json:"check"`
+	Error string `json:"error,omitempty"` // empty if the fix succeeded
+}
+
+// doctorReport is output by "env doctor --json".
+type doctorReport struct {
+	GactarVersion string         `json:"gactarVersion"`
+	Environment   string         `json:"environment"`
+	Manifest      *envManifest   `json:"manifest,omitempty"` // nil if the environment has no manifest
+	Checks        []*doctorCheck `json:"checks"`
+	Fixes         []doctorFix    `json:"fixes,omitempty"`
+	Passed        bool           `json:"passed"`
+}
+
+// doctorOptions control what "env doctor" does.
+type doctorOptions struct {
+	JSON       bool   // output the report as JSON
+	Fix        bool   // try to fix failed checks
+	SystemPATH string // PATH before it was restricted to the environment (for finding the system python)
+}
+
 var doctorCmd = &cobra.Command{
 	Use:   "doctor",
 	Short: "Check an environment for problems",
@@ -30,12 +75,28 @@ var doctorCmd = &cobra.Command{
 			return
 		}
 
+		options := doctorOptions{SystemPATH: os.Getenv("PATH")}
+
+		options.JSON, err = cmd.Flags().GetBool("json")
+		if err != nil {
+			return
+		}
+
+		options.Fix, err = cmd.Flags().GetBool("fix")
+		if err != nil {
+			return
+		}
+
 		err = cli.SetupPaths(envPath)
 		if err != nil {
 			return
 		}
 
-		err = runDoctor(envPath)
+		err = runDoctor(envPath, options)
+		if options.JSON {
+			return
+		}
+
 		fmt.Println()
 		if err != nil {
 			return
@@ -52,6 +113,8 @@ func init() {
 	envCmd.AddCommand(doctorCmd)
 
 	doctorCmd.Flags().StringP("path", "p", "./env", "environment to check")
+	doctorCmd.Flags().Bool("json", false, "output the results of the checks as JSON")
+	doctorCmd.Flags().Bool("fix", false, "try to fix any failed checks, then check again")
 }
 
 func outputSectionHeader(text string) {
@@ -61,7 +124,71 @@ func outputSectionHeader(text string) {
 	}
 }
 
-func runDoctor(envPath string) (err error) {
+func runDoctor(envPath string, options doctorOptions) (err error) {
+	// Keep stdout for the JSON - progress from any fixes goes to stderr
+	var progress io.Writer = os.Stdout
+	if options.JSON {
+		progress = os.Stderr
+	}
+
+	report := &doctorReport{
+		GactarVersion: version.BuildVersion,
+		Environment:   envPath,
+	}
+
+	if !options.JSON {
+		outputDoctorHeader(envPath)
+	}
+
+	if !filesystem.DirExists(envPath) {
+		err = &filesystem.ErrDirDoesNotExist{DirName: envPath}
+		if options.JSON {
+			report.Checks = []*doctorCheck{{Name: "environment", Status: checkError, Detail: err.Error()}}
+			writeDoctorReport(os.Stdout, report)
+			return ErrHealthCheckFailed
+		}
+
+		return
+	}
+
+	report.Manifest, _ = readEnvManifest(envPath)
+
+	if !options.JSON {
+		outputEnvManifest(envPath)
+	}
+
+	// Resolve these before we change directories
+	plugins := pluginDirs(envPath)
+
+	err = os.Chdir(envPath)
+	if err != nil {
+		return
+	}
+
+	report.Checks = runChecks(envPath, plugins, options)
+
+	if options.Fix && checksFixable(report.Checks) {
+		report.Fixes = fixChecks(progress, envPath, report.Checks)
+		report.Checks = runChecks(envPath, plugins, options)
+		report.Manifest, _ = readEnvManifest(envPath)
+	}
+
+	report.Passed = checksPassed(report.Checks)
+
+	if options.JSON {
+		writeDoctorReport(os.Stdout, report)
+	} else {
+		outputChecks(report.Checks)
+	}
+
+	if !report.Passed {
+		err = ErrHealthCheckFailed
+	}
+
+	return
+}
+
+func outputDoctorHeader(envPath string) {
 	fmt.Println(chalk.BlueBoldUnderline("gactar Environment Doctor"))
 
 	if !chalk.HasColor() {
@@ -76,113 +203,370 @@ func runDoctor(envPath string) (err error) {
 
 	fmt.Print(chalk.Header("Environment: "))
 	fmt.Println(envPath)
+}
 
-	if !filesystem.DirExists(envPath) {
-		return &filesystem.ErrDirDoesNotExist{DirName: envPath}
+func writeDoctorReport(w io.Writer, report *doctorReport) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		chalk.PrintErr(err)
+		return
 	}
 
-	e := os.Chdir(envPath)
-	if e != nil {
-		chalk.PrintErrLight(e)
-		return ErrHealthCheckFailed
+	fmt.Fprintln(w, string(data))
+}
+
+// runChecks checks each part of the environment.
+func runChecks(envPath string, plugins []string, options doctorOptions) (checks []*doctorCheck) {
+	pythonCheck, pythonPath := checkPython(envPath, options)
+	checks = append(checks, pythonCheck)
+
+	checks = append(checks, checkCCL(envPath))
+
+	frameworkChecks := []*doctorCheck{
+		checkForPythonPackage(envPath, "python_actr", pythonPath),
+		checkForPythonPackage(envPath, "pyactr", pythonPath),
+		checkACTR(envPath),
 	}
 
-	pythonPath, e := checkPython()
-	if e != nil {
-		chalk.PrintErrLight(e)
-		err = ErrHealthCheckFailed
+	checks = append(checks, frameworkChecks...)
+	checks = append(checks, checkFrameworks(frameworkChecks))
+	checks = append(checks, checkPlugins(plugins)...)
+
+	return
+}
+
+func checksPassed(checks []*doctorCheck) bool {
+	for _, check := range checks {
+		if check.Status == checkError {
+			return false
+		}
 	}
 
-	e = checkCLL()
-	if e != nil {
-		chalk.PrintErrLight(e)
-		err = ErrHealthCheckFailed
+	return true
+}
+
+// checksFixable returns true if any check which is not ok (including warnings) has a remediation.
+func checksFixable(checks []*doctorCheck) bool {
+	for _, check := range checks {
+		if check.Status != checkOK && check.fix != nil {
+			return true
+		}
 	}
 
-	e = checkFrameworks(envPath, pythonPath)
-	if e != nil {
-		err = ErrHealthCheckFailed
+	return false
+}
+
+// fixChecks runs the remediation for each check which is not ok and has one. Progress is written
+// to out.
+func fixChecks(out io.Writer, envPath string, checks []*doctorCheck) (fixes []doctorFix) {
+	for _, check := range checks {
+		if check.Status == checkOK || check.fix == nil {
+			continue
+		}
+
+		fmt.Fprintf(out, "\n> Fixing %s...\n", check.Name)
+
+		fix := doctorFix{Check: check.Name}
+
+		err := check.fix(out)
+		if err != nil {
+			chalk.PrintErrLight(err)
+			fix.Error = err.Error()
+		}
+
+		fixes = append(fixes, fix)
+	}
+
+	if len(fixes) > 0 {
+		actions := []string{}
+		for _, fix := range fixes {
+			if fix.Error == "" {
+				actions = append(actions, fmt.Sprintf("fixed %s", fix.Check))
+			} else {
+				actions = append(actions, fmt.Sprintf("could not fix %s", fix.Check))
+			}
+		}
+
+		err := writeEnvManifest(envPath, actions)
+		if err != nil {
+			chalk.PrintErrLight(err)
+		}
 	}
 
 	return
 }
 
-func checkPython() (path string, err error) {
-	fmt.Println()
-	outputSectionHeader("Python")
+// outputChecks outputs the checks grouped by section.
+func outputChecks(checks []*doctorCheck) {
+	section := ""
+
+	for _, check := range checks {
+		if check.section != section {
+			section = check.section
+
+			fmt.Println()
+			outputSectionHeader(section)
+		}
+
+		detail := strings.ReplaceAll(check.Detail, "\n", "\n>   ")
 
-	fmt.Printf("> Looking for %s...\n", chalk.Italic("python 3.x"))
+		switch check.Status {
+		case checkOK:
+			fmt.Printf("> %s: %s\n", chalk.Italic(check.Name), detail)
+
+		case checkWarning:
+			chalk.PrintWarningStr(fmt.Sprintf("> NOTE: %s: %s", check.Name, detail))
+
+		default:
+			chalk.PrintErrStr(fmt.Sprintf("%s: %s", check.Name, detail))
+		}
+	}
+}
+
+// loadChecksums loads the pinned checksums used to verify what the fixes install.
+func loadChecksums(envPath string) (checksum.Manifest, error) {
+	return checksum.Load(installFile(envPath, checksumsFileName))
+}
+
+func checkPython(envPath string, options doctorOptions) (check *doctorCheck, path string) {
+	check = &doctorCheck{
+		Name:    "python",
+		section: "Python",
+		fix: func(out io.Writer) (err error) {
+			// Look for the system python to create the virtual environment
+			err = os.Setenv("PATH", options.SystemPATH)
+			if err != nil {
+				return
+			}
+
+			_, err = updatePython(out, envPath, setupOptions{}, nil)
+			return
+		},
+	}
 
-	path, err = python.FindPython3(true)
+	path, err := python.FindPython3(nil)
 	if err != nil {
+		check.Status = checkError
+		check.Detail = err.Error()
 		return
 	}
 
+	check.Status = checkOK
+	check.Detail = path
+
+	output, err := executil.ExecCommand(path, "--version")
+	if err == nil {
+		check.Detail += " (" + strings.TrimSpace(output) + ")"
+	}
+
 	return
 }
 
-func checkCLL() (err error) {
-	fmt.Println()
-	outputSectionHeader("Clozure Common Lisp (ccl) compiler")
+func checkCCL(envPath string) (check *doctorCheck) {
+	check = &doctorCheck{
+		Name:    "ccl",
+		section: "Clozure Common Lisp (ccl) compiler",
+		fix: func(out io.Writer) (err error) {
+			manifest, err := loadChecksums(envPath)
+			if err != nil {
+				return
+			}
+
+			ccl, err := cclArchive()
+			if err != nil {
+				return
+			}
+
+			return ccl.install(out, "", manifest)
+		},
+	}
 
 	cclExecutableName, err := lisp.GetExecutableName()
 	if err != nil {
+		check.Status = checkError
+		check.Detail = err.Error()
+		check.fix = nil
 		return
 	}
 
-	fmt.Printf("> Looking for %s...\n", chalk.Italic(cclExecutableName))
-
 	exePath, err := filesystem.CheckForExecutable(cclExecutableName)
 	if err != nil {
+		check.Status = checkError
+		check.Detail = err.Error()
 		return
 	}
 
-	fmt.Printf("> Found ccl: %s\n", exePath)
-
 	output, err := executil.ExecCommand(exePath, "--version")
 	if err != nil {
-		return err
+		check.Status = checkError
+		check.Detail = err.Error()
+		return
 	}
-	fmt.Printf(">   %s", output)
+
+	check.Status = checkOK
+	check.Detail = fmt.Sprintf("%s (%s)", exePath, strings.TrimSpace(output))
 
 	return
 }
 
-func checkForPythonPackage(packageName, pythonPath string) bool {
-	fmt.Printf("> Checking for %s package...\n", chalk.Italic(packageName))
+// checkForPythonPackage checks for one of the python packages from our requirements.
+func checkForPythonPackage(envPath, packageName, pythonPath string) (check *doctorCheck) {
+	check = &doctorCheck{
+		Name:    fmt.Sprintf("python package %s", packageName),
+		section: "Frameworks",
+		fix: func(out io.Writer) (err error) {
+			requirements, err := python.ReadRequirements(installFile(envPath, "requirements.txt"))
+			if err != nil {
+				return
+			}
+
+			for _, requirement := range requirements {
+				if python.NormalizePackageName(requirement.Name) == python.NormalizePackageName(packageName) {
+					// pip can only check the hashes if we install all the pinned packages, so this
+					// installs any others which are missing too
+					return installPackages(out, hashedRequirementsFile(envPath, false), nil)
+				}
+			}
+
+			return &python.ErrPythonPackageNotFound{PackageName: packageName}
+		},
+	}
+
+	if pythonPath == "" {
+		check.Status = checkWarning
+		check.Detail = "python not found"
+		check.fix = nil
+		return
+	}
+
 	err := python.CheckForPackage(pythonPath, packageName)
 	if err != nil {
-		chalk.PrintErrLight(err)
-		chalk.PrintWarningStr(fmt.Sprintf("> NOTE: %s not available\n", packageName))
-		return false
+		check.Status = checkWarning
+		check.Detail = err.Error()
+		return
 	}
 
-	fmt.Println(">   ...found")
-	return true
-}
+	check.Status = checkOK
+	check.Detail = "found"
 
-func checkFrameworks(envPath, pythonPath string) (err error) {
-	fmt.Println()
-	outputSectionHeader("Frameworks")
+	return
+}
 
-	atLeastOne := checkForPythonPackage("python_actr", pythonPath)
-	atLeastOne = checkForPythonPackage("pyactr", pythonPath) || atLeastOne
+func checkACTR(envPath string) (check *doctorCheck) {
+	check = &doctorCheck{
+		Name:    "ACT-R source",
+		section: "Frameworks",
+		fix: func(out io.Writer) (err error) {
+			manifest, err := loadChecksums(envPath)
+			if err != nil {
+				return
+			}
+
+			return actrArchive().install(out, "", manifest)
+		},
+	}
 
-	fmt.Printf("> Checking for %s source...\n", chalk.Italic("ACT-R"))
 	actrDir := filepath.Join(envPath, "actr")
 	if !filesystem.DirExists(actrDir) {
-		e := &filesystem.ErrDirDoesNotExist{DirName: actrDir}
-		chalk.PrintErrLight(e)
-		chalk.PrintWarningStr("> NOTE: vanilla ACT-R not available")
-	} else {
-		atLeastOne = true
-		fmt.Printf(">   ...found: %s\n", actrDir)
+		check.Status = checkWarning
+		check.Detail = fmt.Sprintf("vanilla ACT-R not available: %s", (&filesystem.ErrDirDoesNotExist{DirName: actrDir}).Error())
+		return
 	}
 
-	if !atLeastOne {
-		chalk.PrintErrStr("could not find any frameworks")
-		err = ErrHealthCheckFailed
+	check.Status = checkOK
+	check.Detail = actrDir
+
+	return
+}
+
+// checkFrameworks checks that at least one framework is available.
+func checkFrameworks(frameworkChecks []*doctorCheck) (check *doctorCheck) {
+	check = &doctorCheck{
+		Name:    "frameworks",
+		section: "Frameworks",
+	}
+
+	for _, c := range frameworkChecks {
+		if c.Status == checkOK {
+			check.Status = checkOK
+			check.Detail = "at least one framework is available"
+			return
+		}
+	}
+
+	check.Status = checkError
+	check.Detail = "could not find any frameworks"
+
+	return
+}
+
+func checkPlugins(dirs []string) (checks []*doctorCheck) {
+	for _, dir := range dirs {
+		if !filesystem.DirExists(dir) {
+			continue
+		}
+
+		manifests, errs := plugin.LoadManifests(dir)
+		for _, e := range errs {
+			checks = append(checks, &doctorCheck{
+				Name:    fmt.Sprintf("plugins in %s", dir),
+				Status:  checkError,
+				Detail:  e.Error(),
+				section: "Plugins",
+			})
+		}
+
+		for _, manifest := range manifests {
+			checks = append(checks, checkPlugin(manifest))
+		}
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, &doctorCheck{
+			Name:    "plugins",
+			Status:  checkOK,
+			Detail:  fmt.Sprintf("no plugins installed (looked in %s)", strings.Join(dirs, ", ")),
+			section: "Plugins",
+		})
+	}
+
+	return
+}
+
+// checkPlugin checks that everything a plugin needs is available.
+func checkPlugin(manifest *plugin.Manifest) (check *doctorCheck) {
+	check = &doctorCheck{
+		Name:    fmt.Sprintf("plugin %s (%s)", manifest.Name, manifest.Language),
+		section: "Plugins",
 	}
 
+	problems := []string{}
+
+	exePath, err := filesystem.CheckForExecutable(manifest.Executable)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	err = manifest.CheckGenerator()
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	for _, packageName := range manifest.PythonRequiredPackages {
+		err = python.CheckForPackage(manifest.Executable, packageName)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		check.Status = checkError
+		check.Detail = strings.Join(problems, "\n")
+		return
+	}
+
+	check.Status = checkOK
+	check.Detail = fmt.Sprintf("found %s: %s", manifest.Executable, exePath)
+
 	return
 }
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFixChecksFixesWarnings(t *testing.T) {
+	fixed := []string{}
+
+	fixFunc := func(name string) func(io.Writer) error {
+		return func(out io.Writer) error {
+			fmt.Fprintf(out, "fixing %s\n", name)
+			fixed = append(fixed, name)
+			return nil
+		}
+	}
+
+	checks := []*doctorCheck{
+		{Name: "ok", Status: checkOK, fix: fixFunc("ok")},
+		{Name: "package", Status: checkWarning, fix: fixFunc("package")},
+		{Name: "ccl", Status: checkError, fix: func(io.Writer) error { return errors.New("no ccl") }},
+		{Name: "frameworks", Status: checkOK},
+	}
+
+	if !checksPassed(checks[:2]) || !checksFixable(checks[:2]) {
+		t.Fatal("expected a passing check with a warning to be fixable")
+	}
+
+	var out bytes.Buffer
+
+	fixes := fixChecks(&out, t.TempDir(), checks)
+
+	if len(fixes) != 2 || fixes[0].Check != "package" || fixes[0].Error != "" || fixes[1].Error != "no ccl" {
+		t.Errorf("unexpected fixes: %+v", fixes)
+	}
+
+	if len(fixed) != 1 || fixed[0] != "package" {
+		t.Errorf("expected only the warning to be fixed, got %v", fixed)
+	}
+
+	if !strings.Contains(out.String(), "> Fixing package...") || !strings.Contains(out.String(), "fixing package") {
+		t.Errorf("expected progress to be written to the writer, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/asmaloney/gactar/modes/fit"
+)
+
+var (
+	flagFitData      = ""
+	flagFitParams    = []string{}
+	flagFitObjective = string(fit.RMSE)
+	flagFitSigma     = 0.0
+	flagFitRuns      = 10
+	flagFitMaxEvals  = 100
+	flagFitWorkers   = runtime.NumCPU()
+	flagFitOutput    = ""
+)
+
+var fitCmd = &cobra.Command{
+	Use:   "fit [amod file]",
+	Short: "Fit model parameters to observed data",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		objective, err := fit.ParseObjective(flagFitObjective)
+		if err != nil {
+			return err
+		}
+
+		freeParams := make([]fit.FreeParam, len(flagFitParams))
+		for i, spec := range flagFitParams {
+			param, err := fit.ParseFreeParam(spec)
+			if err != nil {
+				return err
+			}
+
+			freeParams[i] = param
+		}
+
+		settings, err := setupForRun(cmd)
+		if err != nil {
+			return err
+		}
+
+		f, err := fit.Initialize(settings, args[0], fit.Options{
+			DataFile:         flagFitData,
+			FreeParams:       freeParams,
+			Objective:        objective,
+			Sigma:            flagFitSigma,
+			RunsPerCondition: flagFitRuns,
+			MaxEvaluations:   flagFitMaxEvals,
+			NumWorkers:       flagFitWorkers,
+			OutputFile:       flagFitOutput,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = f.Start()
+		if err != nil {
+			return err
+		}
+
+		return
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fitCmd)
+
+	fitCmd.Flags().StringVar(&flagFitData, "data", flagFitData, "CSV file containing the observed data for each condition")
+	fitCmd.Flags().StringArrayVarP(&flagFitParams, "param", "p", flagFitParams, "parameter to fit: path=min:max[:start] (e.g. memory.latency_factor=0.1:1.0)")
+	fitCmd.Flags().StringVar(&flagFitObjective, "objective", flagFitObjective, "objective to minimize: rmse or loglik")
+	fitCmd.Flags().Float64Var(&flagFitSigma, "sigma", flagFitSigma, "standard deviation of the error for loglik (0 = estimate from residuals)")
+	fitCmd.Flags().IntVar(&flagFitRuns, "runs", flagFitRuns, "number of runs per condition for each evaluation")
+	fitCmd.Flags().IntVar(&flagFitMaxEvals, "max-evals", flagFitMaxEvals, "maximum number of evaluations")
+	fitCmd.Flags().IntVar(&flagFitWorkers, "workers", flagFitWorkers, "number of runs to execute in parallel")
+	fitCmd.Flags().StringVarP(&flagFitOutput, "output", "o", flagFitOutput, "file to write the fitted model to (default <model>_fit.amod)")
+
+	_ = fitCmd.MarkFlagRequired("data")
+	_ = fitCmd.MarkFlagRequired("param")
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/asmaloney/gactar/framework"
+	"github.com/asmaloney/gactar/framework/plugin"
+	"github.com/asmaloney/gactar/util/chalk"
+	"github.com/asmaloney/gactar/util/frameworkutil"
+)
+
+var (
+	flagFrameworksFeatures = false
+)
+
+var frameworksCmd = &cobra.Command{
+	Use:   "frameworks",
+	Short: "List the available frameworks and the features they support",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		envPath, err := expandPathFlag(cmd.Flags(), "env")
+		if err != nil {
+			return
+		}
+
+		loadPlugins(envPath)
+
+		infoList := frameworkutil.InfoList()
+
+		if flagFrameworksFeatures {
+			outputFeatures(infoList)
+		} else {
+			outputFrameworks(infoList)
+		}
+
+		return
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(frameworksCmd)
+
+	frameworksCmd.Flags().BoolVar(&flagFrameworksFeatures, "features", flagFrameworksFeatures, "output which features each framework supports")
+}
+
+// outputFrameworks outputs a table of the frameworks.
+func outputFrameworks(infoList framework.InfoList) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, chalk.Bold("name\tlanguage\texecutable\tplugin"))
+
+	for _, info := range infoList {
+		isPlugin := "no"
+		if plugin.Lookup(info.Name) != nil {
+			isPlugin = "yes"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", info.Name, info.Language, info.ExecutableName, isPlugin)
+	}
+
+	w.Flush()
+}
+
+// outputFeatures outputs a table showing how each framework handles each feature.
+func outputFeatures(infoList framework.InfoList) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	header := []string{"feature"}
+	for _, info := range infoList {
+		header = append(header, info.Name)
+	}
+
+	fmt.Fprintln(w, chalk.Bold(strings.Join(header, "\t")))
+
+	for _, feature := range framework.Features {
+		row := []string{feature.Name}
+		for _, info := range infoList {
+			row = append(row, string(info.Capabilities.Support(feature.Name)))
+		}
+
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	w.Flush()
+
+	fmt.Println()
+	fmt.Println("Emulated features are provided by code gactar generates. Unsupported features are ignored and produce warnings.")
+}
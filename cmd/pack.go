@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/asmaloney/gactar/util/chalk"
+	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/executil"
+	"github.com/asmaloney/gactar/util/filesystem"
+	"github.com/asmaloney/gactar/util/version"
+)
+
+const (
+	// bundleManifestFileName describes a bundle created using "env pack".
+	bundleManifestFileName = "bundle.json"
+
+	// bundleWheelsDir is the directory containing the python packages.
+	bundleWheelsDir = "wheels"
+)
+
+// bundleManifest is stored in a bundle to describe what it contains.
+type bundleManifest struct {
+	GactarVersion string   `json:"gactarVersion"` // version of gactar which created the bundle
+	System        string   `json:"system"`        // OS the bundle was created for (the ccl archive is specific to it)
+	Archives      []string `json:"archives"`      // file names of the archives
+}
+
+type ErrBundleSystem struct {
+	BundleSystem string
+	System       string
+}
+
+func (e ErrBundleSystem) Error() string {
+	return fmt.Sprintf("bundle was created for %q, but this system is %q", e.BundleSystem, e.System)
+}
+
+type ErrBundleIncomplete struct {
+	Dir     string
+	Missing []string
+}
+
+func (e ErrBundleIncomplete) Error() string {
+	return fmt.Sprintf("bundle %q is missing:\n  %s", e.Dir, strings.Join(e.Missing, "\n  "))
+}
+
+var packCmd = &cobra.Command{
+	Use:   "pack [bundle directory]",
+	Short: "Create a bundle from an environment to use with 'env setup --offline'",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		envPath, err := expandPathFlag(cmd.Flags(), "path")
+		if err != nil {
+			return
+		}
+
+		bundleDir, err := filepath.Abs(args[0])
+		if err != nil {
+			return
+		}
+
+		return runPack(envPath, bundleDir)
+	},
+}
+
+func init() {
+	envCmd.AddCommand(packCmd)
+
+	packCmd.Flags().StringP("path", "p", "./env", "environment to create the bundle from")
+}
+
+// runPack creates a bundle in bundleDir containing everything needed to set up an environment
+// without network access. The python packages are the ones pinned in the install directory beside
+// the environment at envPath, which is also used to run pip.
+func runPack(envPath, bundleDir string) (err error) {
+	fmt.Println("gactar Environment Pack\n---")
+	fmt.Printf("Creating a bundle from environment %q in: %q\n", envPath, bundleDir)
+
+	if !filesystem.DirExists(envPath) {
+		return &filesystem.ErrDirDoesNotExist{DirName: envPath}
+	}
+
+	err = cli.SetupPaths(envPath)
+	if err != nil {
+		return
+	}
+
+	err = filesystem.CreateDir(bundleDir)
+	if err != nil {
+		return
+	}
+
+	err = os.Chdir(bundleDir)
+	if err != nil {
+		return
+	}
+
+	// Download the pinned packages - including the dev ones so the bundle can be used with "--dev".
+	// pip checks them against the hashes, and setup checks them again when it installs them.
+	fmt.Println("> Downloading pip packages...")
+	output, err := executil.ExecCommand("pip", "download", "--require-hashes", "--dest", bundleWheelsDir, "-r", hashedRequirementsFile(envPath, true))
+	if err != nil {
+		return
+	}
+
+	fmt.Print(output)
+
+	// Use the archives from the environment if they are still there, otherwise download them
+	ccl, err := cclArchive()
+	if err != nil {
+		return
+	}
+
+	archives := []archive{actrArchive(), ccl}
+	manifest := bundleManifest{
+		GactarVersion: version.BuildVersion,
+		System:        runtime.GOOS,
+	}
+
+	for _, a := range archives {
+		envArchive := filepath.Join(envPath, a.FileName)
+
+		if _, statErr := os.Stat(envArchive); statErr == nil {
+			fmt.Printf("> Copying %s %s from: %q\n", a.Name, a.Version, envArchive)
+
+			err = filesystem.CopyFile(envArchive, a.FileName)
+		} else {
+			err = a.fetch(os.Stdout, "")
+		}
+
+		if err != nil {
+			return
+		}
+
+		manifest.Archives = append(manifest.Archives, a.FileName)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return
+	}
+
+	err = os.WriteFile(bundleManifestFileName, data, 0644)
+	if err != nil {
+		return
+	}
+
+	err = checkBundle(bundleDir)
+	if err != nil {
+		return
+	}
+
+	fmt.Println(chalk.Success("Bundle created."))
+	fmt.Printf("Use it with: gactar env setup --offline --from %q\n", bundleDir)
+
+	return
+}
+
+// checkBundle verifies that a bundle contains everything we need to set up an environment on this system.
+func checkBundle(bundleDir string) (err error) {
+	data, err := os.ReadFile(filepath.Join(bundleDir, bundleManifestFileName))
+	if err != nil {
+		return &filesystem.ErrFileDoesNotExist{FileName: filepath.Join(bundleDir, bundleManifestFileName)}
+	}
+
+	var manifest bundleManifest
+
+	err = json.Unmarshal(data, &manifest)
+	if err != nil {
+		return fmt.Errorf("could not read %q: %w", bundleManifestFileName, err)
+	}
+
+	if manifest.System != runtime.GOOS {
+		return &ErrBundleSystem{BundleSystem: manifest.System, System: runtime.GOOS}
+	}
+
+	ccl, err := cclArchive()
+	if err != nil {
+		return
+	}
+
+	missing := []string{}
+
+	for _, fileName := range []string{actrArchive().FileName, ccl.FileName} {
+		if _, statErr := os.Stat(filepath.Join(bundleDir, fileName)); statErr != nil {
+			missing = append(missing, fileName)
+		}
+	}
+
+	wheels, readErr := os.ReadDir(filepath.Join(bundleDir, bundleWheelsDir))
+	if readErr != nil || len(wheels) == 0 {
+		missing = append(missing, bundleWheelsDir+string(filepath.Separator))
+	}
+
+	if len(missing) > 0 {
+		return &ErrBundleIncomplete{Dir: bundleDir, Missing: missing}
+	}
+
+	return
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/asmaloney/gactar/framework/plugin"
+	"github.com/asmaloney/gactar/util/chalk"
+	"github.com/asmaloney/gactar/util/filesystem"
+)
+
+// pluginDirName is the directory in the environment where plugin manifests are found.
+const pluginDirName = "plugins"
+
+// pluginDirs returns the directories to search for plugin manifests: <env>/plugins and any
+// directories from the command line.
+func pluginDirs(envPath string) (dirs []string) {
+	dirs = append(dirs, filepath.Join(envPath, pluginDirName))
+
+	for _, dir := range flagPluginDirs {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			absDir = dir
+		}
+
+		dirs = append(dirs, absDir)
+	}
+
+	return
+}
+
+// loadPlugins loads the plugin manifests from the plugin directories and registers them.
+// Problems are reported as warnings so one bad plugin does not prevent gactar from running.
+func loadPlugins(envPath string) {
+	for _, dir := range pluginDirs(envPath) {
+		if !filesystem.DirExists(dir) {
+			continue
+		}
+
+		manifests, errs := plugin.LoadManifests(dir)
+		for _, err := range errs {
+			chalk.PrintWarningStr(fmt.Sprintf("plugin not loaded: %s", err.Error()))
+		}
+
+		for _, manifest := range manifests {
+			err := plugin.Register(manifest)
+			if err != nil {
+				chalk.PrintWarningStr(fmt.Sprintf("plugin not loaded: %s", err.Error()))
+			}
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jwalton/gchalk"
 	"github.com/spf13/cobra"
@@ -20,6 +21,7 @@ import (
 	"github.com/asmaloney/gactar/util/filesystem"
 	"github.com/asmaloney/gactar/util/frameworkutil"
 	"github.com/asmaloney/gactar/util/version"
+	"github.com/asmaloney/gactar/util/workspace"
 )
 
 var (
@@ -32,6 +34,12 @@ var (
 	flagFrameworks = []string{"all"}
 	flagDebug      = false
 	flagNoColour   = false
+	flagTimeout    = time.Duration(0)
+	flagStats      = false
+	flagPluginDirs = []string{}
+
+	flagWorkspaceMaxAge   = workspace.DefaultRetention.MaxAge
+	flagWorkspaceMaxCount = workspace.DefaultRetention.MaxCount
 
 	flagRun     = false
 	flagVersion = false
@@ -112,9 +120,14 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&flagEnv, "env", flagEnv, "directory where ACT-R, pyactr, and other necessary files are installed")
 	rootCmd.PersistentFlags().StringVar(&flagTemp, "temp", flagTemp, "directory for generated files (it will be created if it does not exist - defaults to <env>/gactar-temp)")
 	rootCmd.PersistentFlags().StringSliceVarP(&flagFrameworks, "framework", "f", flagFrameworks,
-		fmt.Sprintf("add framework - valid frameworks: %s", strings.Join(framework.ValidFrameworks, ", ")))
+		fmt.Sprintf("add framework - valid frameworks: %s, or the name of a plugin", strings.Join(framework.ValidFrameworks(), ", ")))
+	rootCmd.PersistentFlags().DurationVar(&flagWorkspaceMaxAge, "workspace-max-age", flagWorkspaceMaxAge, "remove run workspaces older than this (0 means keep them)")
+	rootCmd.PersistentFlags().IntVar(&flagWorkspaceMaxCount, "workspace-max-count", flagWorkspaceMaxCount, "maximum number of run workspaces to keep (0 means no limit)")
+	rootCmd.PersistentFlags().StringSliceVar(&flagPluginDirs, "plugin-dir", flagPluginDirs, "additional directory containing plugin framework manifests (<env>/plugins is always searched)")
 	rootCmd.PersistentFlags().BoolVarP(&flagDebug, "debug", "d", false, "turn on debugging output")
 	rootCmd.PersistentFlags().BoolVar(&flagNoColour, "no-colour", false, "do not use colour output on command line")
+	rootCmd.PersistentFlags().DurationVar(&flagTimeout, "timeout", flagTimeout, "maximum time to allow for each model run (e.g. 30s, 5m - 0 means no limit)")
+	rootCmd.PersistentFlags().BoolVar(&flagStats, "stats", flagStats, "output production firing & retrieval statistics after each run")
 
 	// Local flags - only run when this action is called directly.
 	rootCmd.Flags().BoolVarP(&flagRun, "run", "r", false, "run the models after generating the code")
@@ -135,8 +148,15 @@ func setupForRun(cmd *cobra.Command) (settings *cli.Settings, err error) {
 	outputVersion()
 
 	settings = &cli.Settings{
-		Version: fmt.Sprintf("gactar %s %s", "version", version.BuildVersion),
-		Debug:   flagDebug,
+		Version:   fmt.Sprintf("gactar %s %s", "version", version.BuildVersion),
+		Debug:     flagDebug,
+		Timeout:   flagTimeout,
+		ShowStats: flagStats,
+
+		WorkspaceRetention: workspace.Retention{
+			MaxAge:   flagWorkspaceMaxAge,
+			MaxCount: flagWorkspaceMaxCount,
+		},
 	}
 
 	envPath, err := setupVirtualEnvironment(cmd.Flags())
@@ -146,6 +166,8 @@ func setupForRun(cmd *cobra.Command) (settings *cli.Settings, err error) {
 
 	settings.EnvPath = envPath
 
+	loadPlugins(envPath)
+
 	// Create our temp dir. If it wasn't set, use <env>/gactar-temp.
 	// createTempDirFromFlag() will expand our "temp" to an absolute path.
 	tempPath, err := createTempDirFromFlag(cmd.Flags())
@@ -155,6 +177,12 @@ func setupForRun(cmd *cobra.Command) (settings *cli.Settings, err error) {
 
 	settings.TempPath = tempPath
 
+	// Remove any old workspaces from previous runs
+	err = settings.CleanWorkspaces()
+	if err != nil {
+		return
+	}
+
 	frameworks, err := createFrameworks(settings, cmd.Flags())
 	if err != nil {
 		return
@@ -3,24 +3,33 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/asmaloney/gactar/util/chalk"
+	"github.com/asmaloney/gactar/util/checksum"
 	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/container"
 	"github.com/asmaloney/gactar/util/decompress"
 	"github.com/asmaloney/gactar/util/executil"
 	"github.com/asmaloney/gactar/util/filesystem"
+	"github.com/asmaloney/gactar/util/lisp"
 	"github.com/asmaloney/gactar/util/python"
 )
 
 var (
 	ErrPathExists = errors.New("path already exists")
 
-	flagSetupDev = false
+	ErrInvalidMirror = errors.New("mirror must be an http, https, or file URL")
+
+	flagSetupDev     = false
+	flagSetupOffline = false
 )
 
 var setupCmd = &cobra.Command{
@@ -33,7 +42,29 @@ var setupCmd = &cobra.Command{
 			return
 		}
 
-		err = runSetup(envPath, flagSetupDev)
+		// If we are offline, this is the bundle created using "env pack"
+		fromDir := ""
+		if flagSetupOffline {
+			fromDir, err = expandPathFlag(cmd.Flags(), "from")
+			if err != nil {
+				chalk.PrintErr(err)
+				return
+			}
+		}
+
+		mirror, err := cmd.Flags().GetString("mirror")
+		if err != nil {
+			chalk.PrintErr(err)
+			return
+		}
+
+		options := setupOptions{
+			Dev:     flagSetupDev,
+			FromDir: fromDir,
+			Mirror:  mirror,
+		}
+
+		err = runSetup(envPath, options)
 		if err != nil {
 			chalk.PrintErr(err)
 			return
@@ -49,6 +80,14 @@ func (e ErrCCLSystem) Error() string {
 	return fmt.Sprintf("no CCL compiler available for system %q", e.OSName)
 }
 
+type ErrSetupIncomplete struct {
+	Problems []string
+}
+
+func (e ErrSetupIncomplete) Error() string {
+	return fmt.Sprintf("setup is incomplete:\n  %s", strings.Join(e.Problems, "\n  "))
+}
+
 type ErrExecuteCommand struct {
 	Output []byte
 }
@@ -62,12 +101,209 @@ func init() {
 
 	setupCmd.Flags().StringP("path", "p", "./env", "directory for env files (it will be created if it does not exist)")
 	setupCmd.Flags().BoolVar(&flagSetupDev, "dev", false, "install dev packages")
+	setupCmd.Flags().BoolVar(&flagSetupOffline, "offline", false, "install from a local bundle instead of downloading (requires --from)")
+	setupCmd.Flags().String("from", "", "directory containing a bundle created using 'env pack'")
+
+	setupCmd.Flags().String("mirror", "", "base URL (http, https, or file) to download from instead of the official sources")
+
+	setupCmd.MarkFlagsRequiredTogether("offline", "from")
+	setupCmd.MarkFlagsMutuallyExclusive("offline", "mirror")
+}
+
+// checksumsFileName is the manifest of pinned SHA-256 hashes of the archives in the install directory.
+const checksumsFileName = "checksums.txt"
+
+// hashedRequirementsFile returns the requirements file in the install directory which pins every
+// python package we install (including their dependencies) along with the hashes of its files.
+// pip checks each package against them when we install using "--require-hashes".
+func hashedRequirementsFile(envPath string, dev bool) string {
+	if dev {
+		return installFile(envPath, "requirements-dev-hashed.txt")
+	}
+
+	return installFile(envPath, "requirements-hashed.txt")
+}
+
+// setupOptions control where we get things from when setting up an environment.
+type setupOptions struct {
+	Dev     bool   // install the dev packages
+	FromDir string // install from the bundle in this directory (see "env pack") instead of downloading
+	Mirror  string // download from this base URL instead of the official sources
+}
+
+// installFile returns the path to a file in the install directory which sits beside the environment.
+func installFile(envPath, fileName string) string {
+	return filepath.Join(envPath, "..", "install", fileName)
+}
+
+// mirrorURL validates the mirror and returns it as a URL.
+func mirrorURL(mirror string) (u *url.URL, err error) {
+	u, err = url.Parse(strings.TrimSuffix(mirror, "/"))
+	if err != nil {
+		return
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file" {
+		return nil, fmt.Errorf("%q: %w", mirror, ErrInvalidMirror)
+	}
+
+	return
+}
+
+// archive is a file we download and unpack during setup.
+type archive struct {
+	Name     string // name for output (e.g. "ACT-R")
+	Version  string
+	FileName string // name of the archive file
+	URL      string // where to download it from
+	Dir      string // directory to unpack into ("" means the archive contains its own directory)
+}
+
+// actrArchive returns the archive containing the vanilla ACT-R source.
+func actrArchive() archive {
+	repo := "github.com/asmaloney/ACT-R"
+	version := "v7.27.0"
+	fileName := fmt.Sprintf("actr-super-slim-%s.zip", version)
+
+	return archive{
+		Name:     "ACT-R",
+		Version:  version,
+		FileName: fileName,
+		URL:      fmt.Sprintf("https://%s/releases/download/%s/%s", repo, version, fileName),
+		Dir:      "actr",
+	}
+}
+
+// cclArchive returns the archive containing the Clozure Common Lisp compiler (CCL) for this system.
+func cclArchive() (a archive, err error) {
+	return cclArchiveFor(runtime.GOOS)
+}
+
+// cclSystems are the systems we can install CCL on.
+var cclSystems = []string{"darwin", "linux", "windows"}
+
+// cclArchiveFor returns the archive containing CCL for the system (a runtime.GOOS value).
+func cclArchiveFor(system string) (a archive, err error) {
+	if !container.Contains(system, cclSystems) {
+		err = &ErrCCLSystem{OSName: system}
+		return
+	}
+
+	repo := "github.com/Clozure/ccl"
+	extension := "tar.gz"
+	version := "1.12.1"
+
+	if system == "windows" {
+		extension = "zip"
+		version = "1.12" // version 1.12.1 is not compressed properly, so use older version
+	}
+
+	fileName := fmt.Sprintf("ccl-%s-%sx86.%s", version, system, extension)
+
+	a = archive{
+		Name:     "Clozure Common Lisp (ccl)",
+		Version:  version,
+		FileName: fileName,
+		URL:      fmt.Sprintf("https://%s/releases/download/v%s/%s", repo, version, fileName),
+	}
+
+	return
+}
+
+// withMirror returns the archive with its URL changed to point at the mirror. The mirror holds
+// the archives at its top level.
+func (a archive) withMirror(mirror *url.URL) archive {
+	if mirror != nil {
+		a.URL = mirror.String() + "/" + a.FileName
+	}
+
+	return a
 }
 
-func runSetup(envPath string, dev bool) (err error) {
+// fetch gets the archive file into the current directory. If fromDir is set, it is copied
+// from there, otherwise it is downloaded.
+func (a archive) fetch(out io.Writer, fromDir string) (err error) {
+	if fromDir != "" {
+		source := filepath.Join(fromDir, a.FileName)
+		fmt.Fprintf(out, "> Copying %s %s from: %q\n", a.Name, a.Version, source)
+
+		return filesystem.CopyFile(source, a.FileName)
+	}
+
+	archiveURL, err := url.Parse(a.URL)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintf(out, "> Getting %s %s from: %q\n", a.Name, a.Version, archiveURL.String())
+
+	return filesystem.DownloadFile(archiveURL, a.FileName)
+}
+
+// verify checks the archive file in the current directory against the pinned checksums.
+func (a archive) verify(out io.Writer, manifest checksum.Manifest) error {
+	fmt.Fprintf(out, "> Verifying %s %s...\n", a.Name, a.Version)
+
+	return manifest.Verify(a.FileName)
+}
+
+// unpack decompresses the archive file in the current directory.
+func (a archive) unpack(out io.Writer) (err error) {
+	fmt.Fprintf(out, "> Unpacking %s...\n", a.Name)
+
+	if strings.HasSuffix(a.FileName, ".zip") {
+		return decompress.Unzip(a.FileName, a.Dir)
+	}
+
+	return decompress.UntarFile(a.FileName, a.Dir)
+}
+
+// install fetches, verifies, and unpacks the archive in the current directory. Progress is written
+// to out.
+func (a archive) install(out io.Writer, fromDir string, manifest checksum.Manifest) (err error) {
+	err = a.fetch(out, fromDir)
+	if err != nil {
+		return
+	}
+
+	err = a.verify(out, manifest)
+	if err != nil {
+		return
+	}
+
+	return a.unpack(out)
+}
+
+// runSetup creates a new environment. Everything we fetch is verified against the pinned checksums
+// before it is installed.
+func runSetup(envPath string, options setupOptions) (err error) {
 	fmt.Println("gactar Environment Setup\n---")
 	fmt.Printf("Setting up an environment: %q\n", envPath)
 
+	var mirror *url.URL
+	if options.Mirror != "" {
+		mirror, err = mirrorURL(options.Mirror)
+		if err != nil {
+			return
+		}
+
+		fmt.Printf("Downloading from mirror: %q\n", mirror.String())
+	}
+
+	if options.FromDir != "" {
+		fmt.Printf("Installing from bundle: %q\n", options.FromDir)
+
+		err = checkBundle(options.FromDir)
+		if err != nil {
+			return
+		}
+	}
+
+	manifest, err := checksum.Load(installFile(envPath, checksumsFileName))
+	if err != nil {
+		return
+	}
+
 	// Check if it already exists and error out
 	if filesystem.DirExists(envPath) {
 		err = fmt.Errorf("cannot set environment path to %q: %w", envPath, ErrPathExists)
@@ -87,33 +323,38 @@ func runSetup(envPath string, dev bool) (err error) {
 		return err
 	}
 
-	err = setupPython(envPath, dev)
+	err = setupPython(os.Stdout, envPath, options, mirror)
 	if err != nil {
 		fmt.Println(err.Error())
 		err = nil
 		// Don't return - we can still try to set up the Lisp compiler
 	}
 
-	err = setupLisp()
+	err = setupLisp(os.Stdout, options.FromDir, mirror, manifest)
 	if err != nil {
 		fmt.Println(err.Error())
 		err = nil
 	}
 
-	return
+	err = writeEnvManifest(envPath, []string{"created environment"})
+	if err != nil {
+		return
+	}
+
+	return verifySetup(envPath)
 }
 
-func setupPython(envPath string, dev bool) (err error) {
-	fmt.Println()
-	fmt.Println("Setting up Python\n---")
+func setupPython(out io.Writer, envPath string, options setupOptions, mirror *url.URL) (err error) {
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Setting up Python\n---")
 
-	path, err := python.FindPython3(true)
+	path, err := python.FindPython3(out)
 	if err != nil {
 		return
 	}
 
 	// Set up virtual environment
-	fmt.Printf("> Setting up virtual environment: %q\n", envPath)
+	fmt.Fprintf(out, "> Setting up virtual environment: %q\n", envPath)
 	_, err = executil.ExecCommand(path, "-m", "venv", envPath)
 	if err != nil {
 		return
@@ -124,114 +365,111 @@ func setupPython(envPath string, dev bool) (err error) {
 		return
 	}
 
-	fmt.Printf("> Reset PATH: %q\n", os.Getenv("PATH"))
+	fmt.Fprintf(out, "> Reset PATH: %q\n", os.Getenv("PATH"))
 
-	// Upgrade pip & install wheel
-	var output string
-	var errInstall error
-	if runtime.GOOS == "windows" {
-		// Windows fails on the pip upgrade for some reason, so leave it out
-		fmt.Println("> Installing wheel...")
-		output, errInstall = executil.ExecCommand("pip", "install", "wheel")
+	source := packageSource(mirror)
+	if options.FromDir != "" {
+		source = []string{"--no-index", "--find-links", filepath.Join(options.FromDir, bundleWheelsDir)}
+	}
 
-	} else {
-		fmt.Println("> Upgrading pip & installing wheel...")
-		output, errInstall = executil.ExecCommand("pip", "install", "--upgrade", "pip", "wheel")
+	return installPackages(out, hashedRequirementsFile(envPath, options.Dev), source)
+}
+
+// packageSource returns the pip options to get packages from the mirror (nil means PyPI).
+// An http(s) mirror is a package index (PEP 503) under "simple/".
+// A file mirror is a directory containing the packages.
+func packageSource(mirror *url.URL) []string {
+	if mirror == nil {
+		return nil
 	}
-	if errInstall != nil {
-		return errInstall
+
+	if mirror.Scheme == "file" {
+		return []string{"--no-index", "--find-links", mirror.Path}
 	}
 
-	fmt.Print(output)
+	return []string{"--index-url", mirror.String() + "/simple/"}
+}
 
-	// Install our requirements
-	fmt.Println("> Installing pip packages...")
+// installPackages installs (or upgrades to) the python packages in the hashed requirements file
+// using the pip options in source to find them. Using "--require-hashes", pip checks every package
+// it installs - including dependencies - against the hashes in the file and refuses to install
+// anything which is not listed or does not match.
+func installPackages(out io.Writer, requirementsFile string, source []string) (err error) {
+	fmt.Fprintln(out, "> Installing pip packages...")
 
-	requirementsFile := "requirements.txt"
-	if dev {
-		requirementsFile = "requirements-dev.txt"
-	}
+	args := append([]string{"install", "--require-hashes", "-r", requirementsFile}, source...)
 
-	output, err = executil.ExecCommand(
-		"pip", "install", "-r",
-		fmt.Sprintf("../install/%s", requirementsFile),
-	)
+	output, err := executil.ExecCommand("pip", args...)
 	if err != nil {
 		return
 	}
 
-	fmt.Print(output)
+	fmt.Fprint(out, output)
 
 	return
 }
 
-func setupLisp() (err error) {
-	fmt.Println()
-	fmt.Println("Setting up Lisp\n---")
+func setupLisp(out io.Writer, fromDir string, mirror *url.URL, manifest checksum.Manifest) (err error) {
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Setting up Lisp\n---")
 
-	// Download vanilla ACT-R
-	repo := "github.com/asmaloney/ACT-R"
-	version := "v7.27.0"
-	archiveFile := fmt.Sprintf("actr-super-slim-%s.zip", version)
-	urlStr := fmt.Sprintf("https://%s/releases/download/%s/%s", repo, version, archiveFile)
-	actrURL, err := url.Parse(urlStr)
+	// Vanilla ACT-R
+	err = actrArchive().withMirror(mirror).install(out, fromDir, manifest)
 	if err != nil {
 		return
 	}
 
-	fmt.Printf("> Getting ACT-R %s from: %q\n", version, actrURL.String())
-
-	err = filesystem.DownloadFile(actrURL, archiveFile)
+	// Clozure Common Lisp compiler (CCL)
+	ccl, err := cclArchive()
 	if err != nil {
 		return
 	}
 
-	// Decompress ACT-R
-	fmt.Println("> Unpacking ACT-R...")
-	err = decompress.Unzip(archiveFile, "actr")
-	if err != nil {
-		return
-	}
-
-	// Download Clozure Common Lisp compiler (CCL)
-	system := runtime.GOOS
-	if system != "darwin" && system != "linux" && system != "windows" {
-		return &ErrCCLSystem{OSName: system}
-	}
+	return ccl.withMirror(mirror).install(out, fromDir, manifest)
+}
 
-	repo = "github.com/Clozure/ccl"
-	extension := "tar.gz"
-	version = "1.12.1"
+// verifySetup checks that the python packages, ACT-R, and CCL were installed in the environment.
+func verifySetup(envPath string) (err error) {
+	fmt.Println()
+	fmt.Println("Verifying setup\n---")
 
-	if system == "windows" {
-		extension = "zip"
-		version = "1.12" // version 1.12.1 is not compressed properly, so use older version
-	}
+	problems := []string{}
 
-	dirName := fmt.Sprintf("ccl-%s-%sx86", version, system)
-	archiveFile = fmt.Sprintf("%s.%s", dirName, extension)
-	urlStr = fmt.Sprintf("https://%s/releases/download/v%s/%s", repo, version, archiveFile)
-	cclURL, err := url.Parse(urlStr)
+	pythonPath, err := python.FindPython3(nil)
 	if err != nil {
-		return
+		problems = append(problems, err.Error())
+	} else {
+		for _, packageName := range []string{"python_actr", "pyactr"} {
+			fmt.Printf("> Checking for %s package...\n", packageName)
+
+			e := python.CheckForPackage(pythonPath, packageName)
+			if e != nil {
+				problems = append(problems, e.Error())
+			}
+		}
 	}
 
-	fmt.Printf("> Getting Clozure Common Lisp (ccl) v%s for %s from: %q\n", version, system, cclURL.String())
-	err = filesystem.DownloadFile(cclURL, archiveFile)
-	if err != nil {
-		return
+	fmt.Println("> Checking for ACT-R source...")
+	actrDir := filepath.Join(envPath, "actr")
+	if !filesystem.DirExists(actrDir) {
+		problems = append(problems, (&filesystem.ErrDirDoesNotExist{DirName: actrDir}).Error())
 	}
 
-	// Decompress CCL
-	fmt.Println("> Unpacking CCL...")
-	if extension == "zip" {
-		err = decompress.Unzip(archiveFile, "")
+	fmt.Println("> Checking for ccl...")
+	cclExecutableName, err := lisp.GetExecutableName()
+	if err != nil {
+		problems = append(problems, err.Error())
 	} else {
-		err = decompress.UntarFile(archiveFile, "")
+		_, e := filesystem.CheckForExecutable(cclExecutableName)
+		if e != nil {
+			problems = append(problems, e.Error())
+		}
 	}
-	if err != nil {
-		return
+
+	if len(problems) > 0 {
+		return &ErrSetupIncomplete{Problems: problems}
 	}
 
-	return
+	fmt.Println(">   ...everything was installed")
+	return nil
 }
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/asmaloney/gactar/util/checksum"
+	"github.com/asmaloney/gactar/util/python"
+)
+
+// TestPinnedChecksums checks that everything setup installs has a pinned checksum or hash. Without
+// one, setup, "env update", and "env doctor --fix" refuse to install it.
+func TestPinnedChecksums(t *testing.T) {
+	manifest, err := checksum.Load("../install/" + checksumsFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts := []string{actrArchive().FileName}
+
+	for _, system := range cclSystems {
+		ccl, err := cclArchiveFor(system)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		artifacts = append(artifacts, ccl.FileName)
+	}
+
+	for _, artifact := range artifacts {
+		if _, ok := manifest[artifact]; !ok {
+			t.Errorf("%s: missing checksum for %q", checksumsFileName, artifact)
+		}
+	}
+
+	// every package is pinned with its hashes, including the dependencies pip-compile adds
+	for _, dev := range []bool{false, true} {
+		checkHashedRequirements(t, dev)
+	}
+
+	_, err = cclArchiveFor("plan9")
+	if err == nil {
+		t.Errorf("expected error for a system without CCL")
+	}
+}
+
+// checkHashedRequirements checks that each of our requirements is in the hashed requirements file
+// with the same version and that every package in it has hashes.
+func checkHashedRequirements(t *testing.T, dev bool) {
+	t.Helper()
+
+	fileName := "requirements.txt"
+	if dev {
+		fileName = "requirements-dev.txt"
+	}
+
+	requirements, err := python.ReadRequirements("../install/" + fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the install directory is beside the environment
+	hashedFileName := hashedRequirementsFile("../env", dev)
+
+	hashed, err := python.ReadRequirements(hashedFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pinned := map[string]python.Requirement{}
+	for _, requirement := range hashed {
+		pinned[python.NormalizePackageName(requirement.Name)] = requirement
+
+		if len(requirement.Hashes) == 0 {
+			t.Errorf("%s: missing hashes for python package %q", filepath.Base(hashedFileName), requirement.Spec())
+		}
+	}
+
+	for _, requirement := range requirements {
+		found, ok := pinned[python.NormalizePackageName(requirement.Name)]
+		if !ok || found.Version != requirement.Version {
+			t.Errorf("%s: python package %q from %s is not pinned", filepath.Base(hashedFileName), requirement.Spec(), fileName)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/asmaloney/gactar/modes/sweep"
+)
+
+var (
+	flagSweepSpec    = ""
+	flagSweepWorkers = runtime.NumCPU()
+	flagSweepCSV     = ""
+)
+
+var sweepCmd = &cobra.Command{
+	Use:   "sweep [amod file]",
+	Short: "Run a model over a grid of parameter values",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		settings, err := setupForRun(cmd)
+		if err != nil {
+			return err
+		}
+
+		s, err := sweep.Initialize(settings, args[0], flagSweepSpec, flagSweepWorkers, flagSweepCSV)
+		if err != nil {
+			return err
+		}
+
+		err = s.Start()
+		if err != nil {
+			return err
+		}
+
+		return
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sweepCmd)
+
+	sweepCmd.Flags().StringVarP(&flagSweepSpec, "spec", "s", flagSweepSpec, "JSON file containing the sweep specification")
+	sweepCmd.Flags().IntVar(&flagSweepWorkers, "workers", flagSweepWorkers, "number of runs to execute in parallel")
+	sweepCmd.Flags().StringVar(&flagSweepCSV, "csv", flagSweepCSV, "write the results to this CSV file")
+
+	_ = sweepCmd.MarkFlagRequired("spec")
+}
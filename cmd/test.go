@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/asmaloney/gactar/modes/modeltest"
+)
+
+var (
+	flagTestWorkers = runtime.NumCPU()
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test [amod files...]",
+	Short: "Run the tests in amod files on the selected frameworks",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		settings, err := setupForRun(cmd)
+		if err != nil {
+			return err
+		}
+
+		m, err := modeltest.Initialize(settings, args, flagTestWorkers)
+		if err != nil {
+			return err
+		}
+
+		err = m.Start()
+		if err != nil {
+			return err
+		}
+
+		return
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+
+	testCmd.Flags().IntVar(&flagTestWorkers, "workers", flagTestWorkers, "number of tests to run in parallel")
+}
@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/asmaloney/gactar/util/chalk"
+	"github.com/asmaloney/gactar/util/checksum"
+	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/executil"
+	"github.com/asmaloney/gactar/util/filesystem"
+	"github.com/asmaloney/gactar/util/lisp"
+	"github.com/asmaloney/gactar/util/python"
+	"github.com/asmaloney/gactar/util/version"
+)
+
+// envManifestFileName records how an environment was set up. It is written to the environment
+// directory by "env setup" & "env update" and read by "env doctor".
+const envManifestFileName = "gactar-env.json"
+
+// envManifest describes what is installed in an environment.
+type envManifest struct {
+	GactarVersion string            `json:"gactarVersion"` // version of gactar which last set up or updated the environment
+	Updated       time.Time         `json:"updated"`
+	Archives      []string          `json:"archives"` // file names of the installed ACT-R & ccl archives
+	Packages      map[string]string `json:"packages"` // installed pip packages & their versions
+	Actions       []string          `json:"actions"`  // what the last setup or update did
+}
+
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Upgrade or repair an existing environment",
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		envPath, err := expandPathFlag(cmd.Flags(), "path")
+		if err != nil {
+			return
+		}
+
+		dev, err := cmd.Flags().GetBool("dev")
+		if err != nil {
+			return
+		}
+
+		mirror, err := cmd.Flags().GetString("mirror")
+		if err != nil {
+			return
+		}
+
+		return runUpdate(envPath, setupOptions{Dev: dev, Mirror: mirror})
+	},
+}
+
+func init() {
+	envCmd.AddCommand(updateCmd)
+
+	updateCmd.Flags().StringP("path", "p", "./env", "environment to update")
+	updateCmd.Flags().Bool("dev", false, "install dev packages")
+	updateCmd.Flags().String("mirror", "", "base URL (http, https, or file) to download from instead of the official sources")
+}
+
+// venvPython returns the path to the python executable in the environment's virtual environment.
+func venvPython(envPath string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(envPath, "Scripts", "python.exe")
+	}
+
+	return filepath.Join(envPath, "bin", "python")
+}
+
+// runUpdate brings an existing environment up to date. Only the things which are missing or
+// outdated are installed, so it is safe to run at any time.
+func runUpdate(envPath string, options setupOptions) (err error) {
+	fmt.Println("gactar Environment Update\n---")
+	fmt.Printf("Updating environment: %q\n", envPath)
+
+	if !filesystem.DirExists(envPath) {
+		return &filesystem.ErrDirDoesNotExist{DirName: envPath}
+	}
+
+	var mirror *url.URL
+	if options.Mirror != "" {
+		mirror, err = mirrorURL(options.Mirror)
+		if err != nil {
+			return
+		}
+
+		fmt.Printf("Downloading from mirror: %q\n", mirror.String())
+	}
+
+	manifest, err := checksum.Load(installFile(envPath, checksumsFileName))
+	if err != nil {
+		return
+	}
+
+	err = os.Chdir(envPath)
+	if err != nil {
+		return
+	}
+
+	actions := []string{}
+
+	pythonActions, err := updatePython(os.Stdout, envPath, options, mirror)
+	actions = append(actions, pythonActions...)
+	if err != nil {
+		fmt.Println(err.Error())
+		err = nil
+		// Don't return - we can still try to update the Lisp side
+	}
+
+	lispActions, err := updateLisp(os.Stdout, envPath, mirror, manifest)
+	actions = append(actions, lispActions...)
+	if err != nil {
+		fmt.Println(err.Error())
+		err = nil
+	}
+
+	if len(actions) == 0 {
+		actions = append(actions, "nothing to update")
+	}
+
+	err = writeEnvManifest(envPath, actions)
+	if err != nil {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Updates\n---")
+	for _, action := range actions {
+		fmt.Printf("> %s\n", action)
+	}
+
+	return verifySetup(envPath)
+}
+
+// updatePython creates the virtual environment if it is missing and installs any pip packages from
+// the requirements which are missing or are not the pinned version.
+func updatePython(out io.Writer, envPath string, options setupOptions, mirror *url.URL) (actions []string, err error) {
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Updating Python\n---")
+
+	if _, statErr := os.Stat(filepath.Join(envPath, "pyvenv.cfg")); statErr != nil {
+		// Find the system python before we restrict the PATH to the environment
+		path, err := python.FindPython3(out)
+		if err != nil {
+			return actions, err
+		}
+
+		fmt.Fprintf(out, "> Setting up virtual environment: %q\n", envPath)
+		_, err = executil.ExecCommand(path, "-m", "venv", envPath)
+		if err != nil {
+			return actions, err
+		}
+
+		actions = append(actions, "created python virtual environment")
+	}
+
+	err = cli.SetupPaths(envPath)
+	if err != nil {
+		return
+	}
+
+	requirementsFile := installFile(envPath, "requirements.txt")
+	if options.Dev {
+		requirementsFile = installFile(envPath, "requirements-dev.txt")
+	}
+
+	requirements, err := python.ReadRequirements(requirementsFile)
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintln(out, "> Checking pip packages...")
+	installed, err := python.InstalledPackages(venvPython(envPath))
+	if err != nil {
+		return
+	}
+
+	changes := []string{}
+
+	for _, requirement := range requirements {
+		current, ok := installed[python.NormalizePackageName(requirement.Name)]
+
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("installed %s", requirement.Spec()))
+
+		case requirement.Version != "" && current != requirement.Version:
+			changes = append(changes, fmt.Sprintf("upgraded %s %s -> %s", requirement.Name, current, requirement.Version))
+		}
+	}
+
+	if len(changes) == 0 {
+		fmt.Fprintln(out, ">   ...all pip packages are up to date")
+		return
+	}
+
+	// pip installs the pinned version of anything which is missing or different
+	err = installPackages(out, hashedRequirementsFile(envPath, options.Dev), packageSource(mirror))
+	if err != nil {
+		return
+	}
+
+	actions = append(actions, changes...)
+
+	return
+}
+
+// updateLisp installs ACT-R & ccl if they are missing or if the environment has a different
+// version than this version of gactar uses.
+func updateLisp(out io.Writer, envPath string, mirror *url.URL, manifest checksum.Manifest) (actions []string, err error) {
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Updating Lisp\n---")
+
+	previous, _ := readEnvManifest(envPath)
+
+	ccl, err := cclArchive()
+	if err != nil {
+		return
+	}
+
+	cclExecutableName, err := lisp.GetExecutableName()
+	if err != nil {
+		return
+	}
+
+	archives := []struct {
+		archive archive
+		dir     string // directory it unpacks into
+		missing bool
+	}{
+		{
+			archive: actrArchive(),
+			dir:     filepath.Join(envPath, "actr"),
+			missing: !filesystem.DirExists(filepath.Join(envPath, "actr")),
+		},
+		{
+			archive: ccl,
+			dir:     filepath.Join(envPath, "ccl"),
+			missing: !fileExists(filepath.Join(envPath, "ccl", cclExecutableName)),
+		},
+	}
+
+	for _, a := range archives {
+		outdated := previous != nil && !previous.hasArchive(a.archive.FileName)
+
+		switch {
+		case a.missing:
+			fmt.Fprintf(out, "> %s is missing\n", a.archive.Name)
+
+		case outdated:
+			fmt.Fprintf(out, "> %s is outdated\n", a.archive.Name)
+
+			err = os.RemoveAll(a.dir)
+			if err != nil {
+				return
+			}
+
+		default:
+			fmt.Fprintf(out, "> %s %s is up to date\n", a.archive.Name, a.archive.Version)
+			continue
+		}
+
+		err = a.archive.withMirror(mirror).install(out, "", manifest)
+		if err != nil {
+			return
+		}
+
+		actions = append(actions, fmt.Sprintf("installed %s %s", a.archive.Name, a.archive.Version))
+	}
+
+	return
+}
+
+func fileExists(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && !stat.IsDir()
+}
+
+// readEnvManifest reads the manifest from the environment directory.
+func readEnvManifest(envPath string) (manifest *envManifest, err error) {
+	data, err := os.ReadFile(filepath.Join(envPath, envManifestFileName))
+	if err != nil {
+		return
+	}
+
+	manifest = &envManifest{}
+
+	err = json.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// writeEnvManifest records what is currently installed in the environment & the actions taken.
+func writeEnvManifest(envPath string, actions []string) (err error) {
+	manifest := envManifest{
+		GactarVersion: version.BuildVersion,
+		Updated:       time.Now().UTC(),
+		Archives:      []string{},
+		Packages:      map[string]string{},
+		Actions:       actions,
+	}
+
+	if filesystem.DirExists(filepath.Join(envPath, "actr")) {
+		manifest.Archives = append(manifest.Archives, actrArchive().FileName)
+	}
+
+	ccl, err := cclArchive()
+	if err == nil && filesystem.DirExists(filepath.Join(envPath, "ccl")) {
+		manifest.Archives = append(manifest.Archives, ccl.FileName)
+	}
+
+	packages, err := python.InstalledPackages(venvPython(envPath))
+	if err == nil {
+		manifest.Packages = packages
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return
+	}
+
+	return os.WriteFile(filepath.Join(envPath, envManifestFileName), data, 0644)
+}
+
+func (m envManifest) hasArchive(fileName string) bool {
+	for _, archive := range m.Archives {
+		if archive == fileName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// outputEnvManifest outputs the environment's manifest for "env doctor". An environment created
+// before we wrote manifests will not have one, so this is not an error.
+func outputEnvManifest(envPath string) {
+	fmt.Println()
+	outputSectionHeader("Environment Manifest")
+
+	manifest, err := readEnvManifest(envPath)
+	if err != nil {
+		fmt.Println("> No manifest found - run 'gactar env update' to create one")
+		return
+	}
+
+	fmt.Printf("> Last set up or updated by gactar %s on %s\n", manifest.GactarVersion, manifest.Updated.Format(time.RFC1123))
+
+	for _, action := range manifest.Actions {
+		fmt.Printf(">   %s\n", action)
+	}
+
+	if manifest.GactarVersion != version.BuildVersion {
+		chalk.PrintWarningStr(fmt.Sprintf("> NOTE: this is gactar %s - run 'gactar env update' to update the environment", version.BuildVersion))
+	}
+}
@@ -1,14 +1,21 @@
 package cmd
 
 import (
+	"os"
+	"path/filepath"
+
 	"github.com/spf13/cobra"
 
 	"github.com/asmaloney/gactar/examples"
 	"github.com/asmaloney/gactar/modes/web"
 )
 
+// adminTokenEnvVar may be used instead of --admin-token so the token is not visible in the
+// process list.
+const adminTokenEnvVar = "GACTAR_ADMIN_TOKEN"
+
 var (
-	flagPort = 8181
+	flagWebOptions = web.DefaultOptions
 )
 
 var webCmd = &cobra.Command{
@@ -20,7 +27,18 @@ var webCmd = &cobra.Command{
 			return err
 		}
 
-		w, err := web.Initialize(settings, flagPort, &examples.AMODExamples)
+		if flagWebOptions.DataDir != "" {
+			flagWebOptions.DataDir, err = filepath.Abs(flagWebOptions.DataDir)
+			if err != nil {
+				return err
+			}
+		}
+
+		if flagWebOptions.AdminToken == "" {
+			flagWebOptions.AdminToken = os.Getenv(adminTokenEnvVar)
+		}
+
+		w, err := web.Initialize(settings, flagWebOptions, &examples.AMODExamples)
 		if err != nil {
 			return err
 		}
@@ -37,5 +55,28 @@ var webCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(webCmd)
 
-	webCmd.Flags().IntVarP(&flagPort, "port", "p", flagPort, "port to run the web server on")
+	webCmd.Flags().StringVar(&flagWebOptions.Host, "host", "", "host name or IP address to listen on (all interfaces if not set)")
+	webCmd.Flags().IntVarP(&flagWebOptions.Port, "port", "p", flagWebOptions.Port, "port to run the web server on")
+	webCmd.Flags().StringVar(&flagWebOptions.BasePath, "base-path", "", "path prefix for all routes (e.g. /gactar when behind a reverse proxy)")
+	webCmd.Flags().StringVar(&flagWebOptions.CertFile, "cert", "", "TLS certificate file (requires --key)")
+	webCmd.Flags().StringVar(&flagWebOptions.KeyFile, "key", "", "TLS private key file (requires --cert)")
+	webCmd.Flags().StringSliceVar(&flagWebOptions.CORSOrigins, "cors-origin", nil, "origin which may use the API from a browser (may be repeated - \"*\" allows any)")
+	webCmd.Flags().StringVar(&flagWebOptions.AdminToken, "admin-token", "", "token which turns on the admin endpoints (e.g. /session/list) - clients send it as \"Authorization: Bearer <token>\" (or set "+adminTokenEnvVar+")")
+	webCmd.Flags().DurationVar(&flagWebOptions.SessionIdleTimeout, "session-timeout", flagWebOptions.SessionIdleTimeout, "remove sessions which are idle for this long (0 means never)")
+	webCmd.Flags().IntVar(&flagWebOptions.MaxSessions, "max-sessions", flagWebOptions.MaxSessions, "maximum number of sessions (0 means no limit)")
+	webCmd.Flags().IntVar(&flagWebOptions.MaxModelsPerSession, "max-models", flagWebOptions.MaxModelsPerSession, "maximum number of models in each session (0 means no limit)")
+	webCmd.Flags().StringVar(&flagWebOptions.DataDir, "data-dir", "", "directory to store sessions & models in so they survive a restart")
+	webCmd.Flags().StringArrayVar(&flagWebOptions.ExampleDirs, "examples", nil, "directory of amod files to offer as examples along with the built-in ones (may be repeated)")
+	webCmd.Flags().BoolVar(&flagWebOptions.ReloadExamples, "examples-reload", false, "reload the example directories when their files change")
+	webCmd.Flags().IntVar(&flagWebOptions.RunHistory, "run-history", flagWebOptions.RunHistory, "number of runs kept in each session's history (0 means none)")
+	webCmd.Flags().IntVar(&flagWebOptions.JobWorkers, "job-workers", flagWebOptions.JobWorkers, "number of framework runs from the jobs API which may happen at once")
+	webCmd.Flags().IntVar(&flagWebOptions.MaxJobs, "max-jobs", flagWebOptions.MaxJobs, "maximum number of jobs which are queued or running (0 means no limit)")
+	webCmd.Flags().IntVar(&flagWebOptions.MaxRuns, "max-runs", flagWebOptions.MaxRuns, "maximum number of framework runs which may happen at once (0 means no limit)")
+	webCmd.Flags().IntVar(&flagWebOptions.MaxQueuedRuns, "max-queued-runs", flagWebOptions.MaxQueuedRuns, "maximum number of framework runs waiting to start (0 means no limit)")
+	webCmd.Flags().Float64Var(&flagWebOptions.RateLimit, "rate-limit", flagWebOptions.RateLimit, "runs each client (IP address or session) may request per minute (0 means no limit)")
+	webCmd.Flags().IntVar(&flagWebOptions.RateBurst, "rate-burst", flagWebOptions.RateBurst, "runs each client may request at once before --rate-limit applies")
+	webCmd.Flags().StringSliceVar(&flagWebOptions.TrustedProxies, "trusted-proxy", nil, "IP address or CIDR network of a proxy whose X-Forwarded-For header identifies the client (may be repeated)")
+	webCmd.Flags().Int64Var(&flagWebOptions.MaxRequestSize, "max-request-size", flagWebOptions.MaxRequestSize, "maximum size of a request in bytes (0 means no limit)")
+
+	webCmd.MarkFlagsRequiredTogether("cert", "key")
 }
@@ -0,0 +1,203 @@
+package framework
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/asmaloney/gactar/actr"
+
+	"github.com/asmaloney/gactar/util/issues"
+)
+
+// Support describes how a framework handles a feature.
+type Support string
+
+const (
+	Supported   Support = "supported"   // the framework provides the feature
+	Emulated    Support = "emulated"    // gactar generates extra code to provide the feature
+	Unsupported Support = "unsupported" // the feature is ignored
+)
+
+// Feature is something a model may use which not all frameworks support.
+type Feature struct {
+	Name        string `json:"name"`        // e.g. "memory.finst_time" or "statement.stop"
+	Description string `json:"description"` // used in messages (e.g. "memory module's finst_time")
+}
+
+// Features lists all the features which are checked when validating a model.
+// Module parameters use the same paths as parameter sweeps ("module.param").
+var Features = []Feature{
+	{"gactar.log_level", "gactar's log_level"},
+	{"gactar.random_seed", "gactar's random_seed"},
+	{"gactar.trace_activations", "gactar's trace_activations"},
+	{"gactar.report_memory", "gactar's report_memory"},
+
+	{"memory.latency_factor", "memory module's latency_factor"},
+	{"memory.latency_exponent", "memory module's latency_exponent"},
+	{"memory.retrieval_threshold", "memory module's retrieval_threshold"},
+	{"memory.finst_size", "memory module's finst_size"},
+	{"memory.finst_time", "memory module's finst_time"},
+	{"memory.decay", "memory module's decay"},
+	{"memory.max_spread_strength", "memory module's max_spread_strength"},
+	{"memory.instantaneous_noise", "memory module's instantaneous_noise"},
+	{"memory.mismatch_penalty", "memory module's mismatch_penalty"},
+	{"goal.spreading_activation", "goal module's spreading_activation"},
+	{"imaginal.delay", "imaginal module's delay"},
+	{"imaginal.do_not_harvest", "imaginal module's do-not-harvest"},
+	{"procedural.default_action_time", "procedural module's default_action_time"},
+
+	{"module.imaginal", "the imaginal module"},
+	{"module.extra_buffers", "extra buffers"},
+	{"similarities", "similarities"},
+
+	{"statement.clear", "the clear statement"},
+	{"statement.print", "the print statement"},
+	{"statement.print_multiple", "more than one print statement per production"},
+	{"statement.recall", "the recall statement"},
+	{"statement.set", "the set statement"},
+	{"statement.stop", "the stop statement"},
+}
+
+// LookupFeature returns the named feature or nil if it does not exist.
+func LookupFeature(name string) *Feature {
+	for i := range Features {
+		if Features[i].Name == name {
+			return &Features[i]
+		}
+	}
+
+	return nil
+}
+
+// Capabilities maps feature names to how a framework handles them.
+type Capabilities map[string]Support
+
+// Support returns how the feature is handled. Features which are not listed are unsupported.
+func (c Capabilities) Support(feature string) Support {
+	support, ok := c[feature]
+	if !ok {
+		return Unsupported
+	}
+
+	return support
+}
+
+// FeatureNames returns the sorted names of the features in the table.
+func (c Capabilities) FeatureNames() (names []string) {
+	for name := range c {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return
+}
+
+// FeatureUse records where a model uses a feature.
+type FeatureUse struct {
+	Feature    string
+	Production string           // name of the production if the feature is used in one
+	Location   *issues.Location // location in the amod file (if known)
+}
+
+// UsedFeatures returns the features the model uses.
+func UsedFeatures(model *actr.Model) (used []FeatureUse) {
+	add := func(feature string, isSet bool) {
+		if isSet {
+			used = append(used, FeatureUse{Feature: feature})
+		}
+	}
+
+	// log_level defaults to "info" so we only consider it used if it's been changed
+	add("gactar.log_level", model.LogLevel != "info")
+	add("gactar.random_seed", model.RandomSeed != nil)
+	add("gactar.trace_activations", model.TraceActivations)
+	add("gactar.report_memory", model.ReportMemory)
+
+	memory := model.Memory
+	add("memory.latency_factor", memory.LatencyFactor != nil)
+	add("memory.latency_exponent", memory.LatencyExponent != nil)
+	add("memory.retrieval_threshold", memory.RetrievalThreshold != nil)
+	add("memory.finst_size", memory.FinstSize != nil)
+	add("memory.finst_time", memory.FinstTime != nil)
+	add("memory.decay", memory.Decay != nil)
+	add("memory.max_spread_strength", memory.MaxSpreadStrength != nil)
+	add("memory.instantaneous_noise", memory.InstantaneousNoise != nil)
+	add("memory.mismatch_penalty", memory.MismatchPenalty != nil)
+
+	add("goal.spreading_activation", model.Goal.SpreadingActivation != nil)
+	add("procedural.default_action_time", model.Procedural.DefaultActionTime != nil)
+
+	imaginal := model.ImaginalModule()
+	add("module.imaginal", imaginal != nil)
+	add("imaginal.delay", imaginal != nil && imaginal.Delay != nil)
+	add("imaginal.do_not_harvest", imaginal != nil)
+
+	add("module.extra_buffers", model.LookupModule("extra_buffers") != nil)
+	add("similarities", len(model.Similarities) > 0)
+
+	for _, production := range model.Productions {
+		location := &issues.Location{
+			Line:        production.AMODLineNumber,
+			ColumnStart: 0,
+			ColumnEnd:   0,
+		}
+
+		use := func(feature string) {
+			used = append(used, FeatureUse{
+				Feature:    feature,
+				Production: production.Name,
+				Location:   location,
+			})
+		}
+
+		numPrintStatements := 0
+
+		for _, statement := range production.DoStatements {
+			switch {
+			case statement.Clear != nil:
+				use("statement.clear")
+
+			case statement.Print != nil:
+				numPrintStatements++
+				if numPrintStatements == 1 {
+					use("statement.print")
+				} else if numPrintStatements == 2 {
+					use("statement.print_multiple")
+				}
+
+			case statement.Recall != nil:
+				use("statement.recall")
+
+			case statement.Set != nil:
+				use("statement.set")
+
+			case statement.Stop != nil:
+				use("statement.stop")
+			}
+		}
+	}
+
+	return
+}
+
+// ValidateCapabilities adds a warning to the log for each feature the model uses which the
+// framework does not support.
+func ValidateCapabilities(info *Info, model *actr.Model, log *issues.Log) {
+	for _, use := range UsedFeatures(model) {
+		if info.Capabilities.Support(use.Feature) != Unsupported {
+			continue
+		}
+
+		description := use.Feature
+		if feature := LookupFeature(use.Feature); feature != nil {
+			description = feature.Description
+		}
+
+		message := fmt.Sprintf("%s does not support %s", info.Name, description)
+		if use.Production != "" {
+			message += fmt.Sprintf(" (in '%s')", use.Production)
+		}
+
+		log.Warning(use.Location, "%s", message)
+	}
+}
@@ -0,0 +1,97 @@
+package framework
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/asmaloney/gactar/amod"
+
+	"github.com/asmaloney/gactar/util/issues"
+)
+
+const capabilitiesTestModel = `
+~~ model ~~
+name: Test
+~~ config ~~
+modules {
+    memory { finst_time: 2.0 }
+}
+chunks {
+    [ count: first second ]
+}
+~~ init ~~
+~~ productions ~~
+start {
+    match { goal [count: * *] }
+    do {
+        print 'first'
+        print 'second'
+        stop
+    }
+}
+`
+
+func TestUsedFeatures(t *testing.T) {
+	model, log, err := amod.GenerateModel(capabilitiesTestModel)
+	if err != nil {
+		t.Fatal(log)
+	}
+
+	used := map[string]bool{}
+	for _, use := range UsedFeatures(model) {
+		used[use.Feature] = true
+
+		if LookupFeature(use.Feature) == nil {
+			t.Errorf("used feature %q is not in Features", use.Feature)
+		}
+	}
+
+	for _, name := range []string{"memory.finst_time", "statement.print", "statement.print_multiple", "statement.stop"} {
+		if !used[name] {
+			t.Errorf("expected %q to be used", name)
+		}
+	}
+
+	for _, name := range []string{"gactar.log_level", "memory.decay", "module.imaginal", "imaginal.do_not_harvest", "statement.clear"} {
+		if used[name] {
+			t.Errorf("did not expect %q to be used", name)
+		}
+	}
+}
+
+func TestValidateCapabilities(t *testing.T) {
+	model, log, err := amod.GenerateModel(capabilitiesTestModel)
+	if err != nil {
+		t.Fatal(log)
+	}
+
+	info := &Info{
+		Name: "test",
+		Capabilities: Capabilities{
+			"memory.finst_time": Unsupported,
+			"statement.print":   Emulated,
+			"statement.stop":    Supported,
+			// "statement.print_multiple" is not listed, so it is unsupported
+		},
+	}
+
+	log = issues.New()
+	ValidateCapabilities(info, model, log)
+
+	output := log.String()
+
+	expected := []string{
+		"test does not support memory module's finst_time",
+		"test does not support more than one print statement per production (in 'start')",
+	}
+
+	for _, message := range expected {
+		if !strings.Contains(output, message) {
+			t.Errorf("expected warning %q in:\n%s", message, output)
+		}
+	}
+
+	if strings.Contains(output, "print statement (in") || strings.Contains(output, "stop") {
+		t.Errorf("unexpected warnings in:\n%s", output)
+	}
+}
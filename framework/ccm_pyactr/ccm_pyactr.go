@@ -3,8 +3,10 @@
 package ccm_pyactr
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -16,6 +18,7 @@ import (
 	"github.com/asmaloney/gactar/util/filesystem"
 	"github.com/asmaloney/gactar/util/issues"
 	"github.com/asmaloney/gactar/util/numbers"
+	"github.com/asmaloney/gactar/util/workspace"
 )
 
 //go:embed gactar_ccm_activate_trace.py
@@ -23,6 +26,11 @@ var gactarActivateTraceFile string
 
 const gactarActivateTraceFileName = "gactar_ccm_activate_trace"
 
+//go:embed gactar_ccm_memory.py
+var gactarMemoryFile string
+
+const gactarMemoryFileName = "gactar_ccm_memory"
+
 var Info framework.Info = framework.Info{
 	Name:           "ccm",
 	Language:       "python",
@@ -30,16 +38,54 @@ var Info framework.Info = framework.Info{
 	ExecutableName: "python",
 
 	PythonRequiredPackages: []string{"python_actr"},
+
+	Capabilities: framework.Capabilities{
+		"gactar.log_level":               framework.Supported,
+		"gactar.random_seed":             framework.Supported,
+		"gactar.trace_activations":       framework.Emulated,
+		"gactar.report_memory":           framework.Supported,
+		"memory.latency_factor":          framework.Supported,
+		"memory.latency_exponent":        framework.Unsupported,
+		"memory.retrieval_threshold":     framework.Supported,
+		"memory.finst_size":              framework.Supported,
+		"memory.finst_time":              framework.Supported,
+		"memory.decay":                   framework.Supported,
+		"memory.max_spread_strength":     framework.Supported,
+		"memory.instantaneous_noise":     framework.Supported,
+		"memory.mismatch_penalty":        framework.Supported,
+		"goal.spreading_activation":      framework.Supported,
+		"imaginal.delay":                 framework.Unsupported,
+		"imaginal.do_not_harvest":        framework.Supported,
+		"procedural.default_action_time": framework.Supported,
+		"module.imaginal":                framework.Emulated,
+		"module.extra_buffers":           framework.Supported,
+		"similarities":                   framework.Supported,
+		"statement.clear":                framework.Supported,
+		"statement.print":                framework.Supported,
+		"statement.print_multiple":       framework.Supported,
+		"statement.recall":               framework.Supported,
+		"statement.set":                  framework.Supported,
+		"statement.stop":                 framework.Supported,
+	},
 }
 
+// CCMPyACTR generates & runs code for the ccm framework. It does not store any state
+// about a model, so it may be shared by goroutines.
 type CCMPyACTR struct {
-	framework.Framework
-	framework.WriterHelper
-
 	tmpPath string
+}
+
+// generator holds the state used to generate the code for one model.
+type generator struct {
+	framework.WriterHelper
 
 	model     *actr.Model
 	className string
+
+	// instrument adds the output gactar uses to collect the events of a run (see
+	// framework.EventPrefix). It is only used when we run the model so it is not in the code
+	// we give to users.
+	instrument bool
 }
 
 // New simply creates a new CCMPyACTR instance and sets the tmp path.
@@ -62,58 +108,112 @@ func (CCMPyACTR) Info() *framework.Info {
 func (CCMPyACTR) ValidateModel(model *actr.Model) (log *issues.Log) {
 	log = issues.New()
 
-	if model.Memory.LatencyExponent != nil {
-		log.Warning(nil, "ccm does not support memory module's latency_exponent")
-	}
+	framework.ValidateCapabilities(&Info, model, log)
 
 	return
 }
 
-// SetModel sets our model and saves the python class name we are going to use.
-func (c *CCMPyACTR) SetModel(model *actr.Model) (err error) {
+// newGenerator creates a generator for the model and sets the python class name we are going to use.
+func newGenerator(model *actr.Model) (c *generator, err error) {
 	if model.Name == "" {
 		err = framework.ErrModelMissingName
 		return
 	}
 
-	c.model = model
-	c.className = fmt.Sprintf("ccm_%s", c.model.Name)
+	c = &generator{
+		model:     model,
+		className: fmt.Sprintf("ccm_%s", model.Name),
+	}
 
 	return
 }
 
-func (c CCMPyACTR) Model() (model *actr.Model) {
-	return c.model
-}
-
 // Run generates the python code from the amod file, writes it to disk, creates a "run" file
 // to actually run the model, and returns the output (stdout and stderr combined).
-func (c *CCMPyACTR) Run(initialBuffers framework.InitialBuffers) (result *framework.RunResult, err error) {
-	runFile, err := c.WriteModel(c.tmpPath, initialBuffers)
+func (c CCMPyACTR) Run(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers) (result *framework.RunResult, err error) {
+	return c.RunStreaming(ctx, model, initialBuffers, nil)
+}
+
+// RunStreaming is the same as Run, but it also writes the output line-by-line to "output" as it arrives.
+func (c CCMPyACTR) RunStreaming(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers, output io.Writer) (result *framework.RunResult, err error) {
+	gen, err := newGenerator(model)
+	if err != nil {
+		return
+	}
+
+	workspacePath, err := workspace.Create(ctx, c.tmpPath)
+	if err != nil {
+		return
+	}
+	defer workspace.Release(workspacePath)
+
+	code, err := gen.generateCode(initialBuffers)
+	if err != nil {
+		return
+	}
+
+	gen.instrument = true
+
+	runFile, err := gen.writeModel(workspacePath, initialBuffers)
 	if err != nil {
 		return
 	}
 
 	result = &framework.RunResult{
 		FileName:      runFile,
-		GeneratedCode: c.GetContents(),
+		WorkspacePath: workspacePath,
+		GeneratedCode: code,
 	}
 
-	output, err := executil.ExecCommand(Info.ExecutableName, runFile)
+	stream := framework.NewOutputStream(output, nil)
+
+	runOutput, err := executil.ExecCommandStream(ctx, stream, Info.ExecutableName, runFile)
+	stream.Close()
 	if err != nil {
+		if executil.IsInterrupted(err) {
+			result.SetOutput(model, runOutput)
+		}
 		return
 	}
 
-	result.Output = []byte(output)
+	result.SetOutput(model, runOutput)
 
 	return
 }
 
-// WriteModel converts the internal actr.Model to Python and writes it to a file.
-func (c *CCMPyACTR) WriteModel(path string, initialBuffers framework.InitialBuffers) (outputFileName string, err error) {
+// WriteModel converts the model to Python and writes it to a file.
+func (CCMPyACTR) WriteModel(model *actr.Model, path string, initialBuffers framework.InitialBuffers) (outputFileName string, err error) {
+	gen, err := newGenerator(model)
+	if err != nil {
+		return
+	}
+
+	return gen.writeModel(path, initialBuffers)
+}
+
+// GenerateCode converts the model to Python code.
+func (CCMPyACTR) GenerateCode(_ context.Context, model *actr.Model, initialBuffers framework.InitialBuffers) (code []byte, err error) {
+	gen, err := newGenerator(model)
+	if err != nil {
+		return
+	}
+
+	return gen.generateCode(initialBuffers)
+}
+
+// writeModel generates the code and writes it to a file in "path".
+func (c *generator) writeModel(path string, initialBuffers framework.InitialBuffers) (outputFileName string, err error) {
+	// Our memory reports retrievals, so we need its support file to run the model
+	if c.instrument {
+		err = writeSupportFile(path, gactarMemoryFileName, gactarMemoryFile)
+		if err != nil {
+			return
+		}
+	}
+
 	// If our model is tracing activations, then write out our support file
 	if c.model.TraceActivations {
-		err = writeTraceSupportFile(path)
+		err = writeSupportFile(path, gactarActivateTraceFileName, gactarActivateTraceFile)
 		if err != nil {
 			return
 		}
@@ -129,7 +229,7 @@ func (c *CCMPyACTR) WriteModel(path string, initialBuffers framework.InitialBuff
 		return "", err
 	}
 
-	_, err = c.GenerateCode(initialBuffers)
+	_, err = c.generateCode(initialBuffers)
 	if err != nil {
 		return
 	}
@@ -142,8 +242,8 @@ func (c *CCMPyACTR) WriteModel(path string, initialBuffers framework.InitialBuff
 	return
 }
 
-// GenerateCode converts the internal actr.Model to Python code.
-func (c *CCMPyACTR) GenerateCode(initialBuffers framework.InitialBuffers) (code []byte, err error) {
+// generateCode converts the model to Python code.
+func (c *generator) generateCode(initialBuffers framework.InitialBuffers) (code []byte, err error) {
 	patterns, err := framework.ParseInitialBuffers(c.model, initialBuffers)
 	if err != nil {
 		return
@@ -196,10 +296,15 @@ func (c *CCMPyACTR) GenerateCode(initialBuffers framework.InitialBuffers) (code
 		additionalInit = append(additionalInit, fmt.Sprintf("finst_time=%s", numbers.Float64Str(*memory.FinstTime)))
 	}
 
+	memoryClass := "Memory"
+	if c.instrument {
+		memoryClass = "GactarMemory"
+	}
+
 	if len(additionalInit) > 0 {
-		c.Writeln("    %s = Memory(%s, %s)", memory.ModuleName(), memory.BufferName(), strings.Join(additionalInit, ", "))
+		c.Writeln("    %s = %s(%s, %s)", memory.ModuleName(), memoryClass, memory.BufferName(), strings.Join(additionalInit, ", "))
 	} else {
-		c.Writeln("    %s = Memory(%s)", memory.ModuleName(), memory.BufferName())
+		c.Writeln("    %s = %s(%s)", memory.ModuleName(), memoryClass, memory.BufferName())
 	}
 
 	if c.model.TraceActivations {
@@ -274,9 +379,9 @@ func (c *CCMPyACTR) GenerateCode(initialBuffers framework.InitialBuffers) (code
 	return
 }
 
-// writeTraceSupportFile will write out a Python file to add minimal activation trace support.
-func writeTraceSupportFile(path string) (err error) {
-	supportFileName := fmt.Sprintf("%s.py", gactarActivateTraceFileName)
+// writeSupportFile will write out one of our Python support files (e.g. to add minimal activation trace support).
+func writeSupportFile(path, name, contents string) (err error) {
+	supportFileName := fmt.Sprintf("%s.py", name)
 	if path != "" {
 		supportFileName = fmt.Sprintf("%s/%s", path, supportFileName)
 	}
@@ -287,7 +392,7 @@ func writeTraceSupportFile(path string) (err error) {
 	}
 	defer file.Close()
 
-	_, err = file.WriteString(gactarActivateTraceFile)
+	_, err = file.WriteString(contents)
 	if err != nil {
 		return
 	}
@@ -295,7 +400,7 @@ func writeTraceSupportFile(path string) (err error) {
 	return
 }
 
-func (c CCMPyACTR) writeHeader() {
+func (c generator) writeHeader() {
 	c.Writeln("# Generated by gactar %s", framework.GactarVersion)
 	c.Writeln("#           on %s", framework.TimeNow().Format("2006-01-02 @ 15:04:05"))
 	c.Writeln("#   https://github.com/asmaloney/gactar")
@@ -310,7 +415,7 @@ func (c CCMPyACTR) writeHeader() {
 	c.writeAuthors()
 }
 
-func (c CCMPyACTR) writeAuthors() {
+func (c generator) writeAuthors() {
 	if len(c.model.Authors) == 0 {
 		return
 	}
@@ -324,14 +429,17 @@ func (c CCMPyACTR) writeAuthors() {
 	c.Writeln("")
 }
 
-func (c CCMPyACTR) writeImports() {
+func (c generator) writeImports() {
 	if c.model.RandomSeed != nil {
 		c.Writeln("import random")
 	}
 
 	memory := c.model.Memory
 
-	imports := []string{"ACTR", "Buffer", "Memory"}
+	imports := []string{"ACTR", "Buffer"}
+	if !c.instrument {
+		imports = append(imports, "Memory")
+	}
 
 	c.Write("from python_actr import %s\n", strings.Join(imports, ", "))
 
@@ -361,13 +469,17 @@ func (c CCMPyACTR) writeImports() {
 		c.Writeln("from python_actr import log, log_everything")
 	}
 
-	if c.model.TraceActivations {
+	if c.instrument {
 		c.Writeln("")
+		c.Writeln("from %s import GactarMemory", gactarMemoryFileName)
+	}
+
+	if c.model.TraceActivations {
 		c.Writeln(fmt.Sprintf("from %s import ActivateTrace", gactarActivateTraceFileName))
 	}
 }
 
-func (c CCMPyACTR) writeInitializers(goal *actr.Pattern) {
+func (c generator) writeInitializers(goal *actr.Pattern) {
 	if len(c.model.Initializers) == 0 {
 		return
 	}
@@ -400,7 +512,7 @@ func (c CCMPyACTR) writeInitializers(goal *actr.Pattern) {
 	}
 }
 
-func (c CCMPyACTR) writeSimilarities(partialName string) {
+func (c generator) writeSimilarities(partialName string) {
 	if len(c.model.Similarities) == 0 {
 		return
 	}
@@ -411,7 +523,7 @@ func (c CCMPyACTR) writeSimilarities(partialName string) {
 	}
 }
 
-func (c CCMPyACTR) writeProductions() {
+func (c generator) writeProductions() {
 	for _, production := range c.model.Productions {
 		if production.Description != nil {
 			c.Writeln("    # %s", *production.Description)
@@ -432,6 +544,10 @@ func (c CCMPyACTR) writeProductions() {
 
 		c.Writeln("):")
 
+		if c.instrument {
+			c.Writeln("        print('%sfired', self.now(), '%s')", framework.EventPrefix, production.Name)
+		}
+
 		if production.DoStatements != nil {
 			for _, statement := range production.DoStatements {
 				c.outputStatement(statement)
@@ -442,7 +558,7 @@ func (c CCMPyACTR) writeProductions() {
 	}
 }
 
-func (c CCMPyACTR) writeMain() {
+func (c generator) writeMain() {
 	c.Writeln("if __name__ == \"__main__\":")
 	c.Writeln(fmt.Sprintf("    model = %s()", c.className))
 
@@ -452,9 +568,49 @@ func (c CCMPyACTR) writeMain() {
 	}
 
 	c.Writeln("    model.run()")
+
+	if c.instrument {
+		c.writeFinalState()
+	}
+}
+
+// writeFinalState outputs the end time, the contents of the buffers, and (optionally) the contents
+// of memory as events for gactar.
+func (c generator) writeFinalState() {
+	c.Writeln("")
+	c.Writeln("    # Output the final state for gactar")
+	c.Writeln("    print('%send', model.now())", framework.EventPrefix)
+
+	slotLists := []string{}
+	for _, chunk := range c.model.Chunks {
+		if chunk.IsInternal() {
+			continue
+		}
+
+		slotLists = append(slotLists, fmt.Sprintf("'%s': ['%s']", chunk.TypeName, strings.Join(chunk.SlotNames, "', '")))
+	}
+
+	c.Writeln("    chunk_slots = {%s}", strings.Join(slotLists, ", "))
+	c.Writeln("")
+	c.Writeln("    def chunk_fields(chunk):")
+	c.Writeln("        values = [str(chunk.get(i)) for i in range(len(chunk))]")
+	c.Writeln("        slots = zip(chunk_slots.get(values[0], []), values[1:])")
+	c.Writeln("        return [values[0]] + ['%%s=%%s' %% slot for slot in slots]")
+	c.Writeln("")
+	c.Writeln("    for name in ['%s']:", strings.Join(c.model.BufferNames(), "', '"))
+	c.Writeln("        chunk = getattr(model, name).chunk")
+	c.Writeln("        if chunk is None:")
+	c.Writeln("            print('%sbuffer', name, 'nil')", framework.EventPrefix)
+	c.Writeln("        else:")
+	c.Writeln("            print('%sbuffer', name, *chunk_fields(chunk))", framework.EventPrefix)
+
+	if c.model.ReportMemory {
+		c.Writeln("    for chunk in model.%s.dm:", c.model.Memory.ModuleName())
+		c.Writeln("        print('%smemory', *chunk_fields(chunk))", framework.EventPrefix)
+	}
 }
 
-func (c CCMPyACTR) outputPattern(pattern *actr.Pattern) {
+func (c generator) outputPattern(pattern *actr.Pattern) {
 	str := fmt.Sprintf("'%s ", pattern.Chunk.TypeName)
 
 	for i, slot := range pattern.Slots {
@@ -470,7 +626,7 @@ func (c CCMPyACTR) outputPattern(pattern *actr.Pattern) {
 	c.Write(str)
 }
 
-func (c CCMPyACTR) outputMatch(match *actr.Match) {
+func (c generator) outputMatch(match *actr.Match) {
 	var name string
 	if match.Buffer != nil {
 		name = match.Buffer.BufferName()
@@ -534,7 +690,7 @@ func patternSlotString(slot *actr.PatternSlot) string {
 	return str
 }
 
-func (c CCMPyACTR) outputStatement(s *actr.Statement) {
+func (c generator) outputStatement(s *actr.Statement) {
 	switch {
 	case s.Set != nil:
 		if s.Set.Slots != nil {
@@ -563,6 +719,9 @@ func (c CCMPyACTR) outputStatement(s *actr.Statement) {
 	case s.Print != nil:
 		values := pythonValuesToStrings(s.Print.Values, true)
 		c.Writeln("        print(%s, sep='')", strings.Join(values, ", "))
+		if c.instrument {
+			c.Writeln("        print('%sprint ', %s, sep='')", framework.EventPrefix, strings.Join(values, ", "))
+		}
 
 	case s.Stop != nil:
 		c.Writeln("        self.stop()")
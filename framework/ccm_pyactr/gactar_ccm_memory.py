@@ -0,0 +1,41 @@
+"""
+gactar_ccm_memory adds reporting of retrievals to python_actr's Memory.
+
+When a retrieval finishes, it outputs an event for gactar with the time,
+the latency, and whether it succeeded:
+
+    @gactar retrieval 0.1 0.05 success
+
+To use it, create a GactarMemory instead of a Memory in an ACTR instance:
+
+class foo(ACTR):
+    retrieval = Buffer()
+    goal = Buffer()
+    memory = GactarMemory(retrieval)
+    ...
+"""
+
+from python_actr import Memory
+
+
+class GactarMemory(Memory):
+    def request(self, *args, **kwargs):
+        self.gactar_request_time = self.now()
+        Memory.request(self, *args, **kwargs)
+
+    def recall(self, chunk, matches, request_number):
+        yield from Memory.recall(self, chunk, matches, request_number)
+
+        # A newer request replaces this one, so only report the current one
+        if request_number == self._request_count:
+            self.gactar_report('success')
+
+    def fail(self, request_number):
+        yield from Memory.fail(self, request_number)
+
+        if request_number == self._request_count:
+            self.gactar_report('failure')
+
+    def gactar_report(self, status: str):
+        latency = self.now() - self.gactar_request_time
+        print('@gactar retrieval', self.now(), latency, status)
@@ -8,7 +8,8 @@ import (
 )
 
 var (
-	ErrModelMissingName = errors.New("model missing name")
+	ErrModelMissingName       = errors.New("model missing name")
+	ErrConcurrentCodeMismatch = errors.New("code generated concurrently does not match")
 )
 
 type ErrBufferNotFound struct {
@@ -20,6 +21,14 @@ func (e ErrBufferNotFound) Error() string {
 	return fmt.Sprintf("buffer %q not found in model %q", e.BufferName, e.ModelName)
 }
 
+type ErrFrameworkExists struct {
+	Name string
+}
+
+func (e ErrFrameworkExists) Error() string {
+	return fmt.Sprintf("framework %q already exists", e.Name)
+}
+
 type ErrExecutableNotSet struct {
 	Name string
 }
@@ -0,0 +1,257 @@
+package framework
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EventPrefix starts the lines the generated code outputs to report what happens during a run.
+// These lines are parsed into RunResult.Events and are removed from the output. They look like this:
+//
+//	@gactar print Yes
+//	@gactar fired 0.050 initialRetrieval
+//	@gactar retrieval 0.100 0.050 success
+//	@gactar end 0.300
+//	@gactar buffer goal isMember object=shark category=animal judgment=yes
+//	@gactar buffer retrieval nil
+//	@gactar memory property object=shark attribute=category value=fish
+//
+// If the framework does not know the chunk type of a chunk, it outputs "-" instead.
+// Memory is only output if the model sets the "report_memory" option.
+const EventPrefix = "@gactar "
+
+// ProductionFired records a production firing.
+type ProductionFired struct {
+	Time float64 // simulated time in seconds
+	Name string
+}
+
+// Retrieval records the end of a retrieval from declarative memory.
+type Retrieval struct {
+	Time    float64 // simulated time the retrieval finished
+	Latency float64 // time from the request until it finished
+	Success bool    // false if the retrieval failed
+}
+
+// SlotValue is the value of one slot of a chunk.
+type SlotValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"` // "nil" if the slot is empty
+}
+
+// ChunkContents is a chunk reported by a framework.
+type ChunkContents struct {
+	ChunkType string      `json:"chunkType,omitempty"` // empty if the framework does not know the chunk type
+	Slots     []SlotValue `json:"slots"`
+}
+
+// FinalState is the state of the model at the end of a run.
+type FinalState struct {
+	EndTime *float64                  `json:"endTime,omitempty"` // simulated time the run ended (nil if not reported)
+	Buffers map[string]*ChunkContents `json:"buffers"`           // contents of the buffers (nil if empty)
+	Memory  []*ChunkContents          `json:"memory,omitempty"`  // contents of declarative memory (if reported)
+}
+
+// Events are the things which happened during a run.
+type Events struct {
+	Prints     []string          // the text of each print statement in order
+	Fired      []ProductionFired // productions in the order they fired
+	Retrievals []Retrieval       // retrievals in the order they finished
+
+	FinalState
+}
+
+// IsEventLine returns whether the line of output is an event.
+func IsEventLine(line string) bool {
+	return strings.HasPrefix(line, EventPrefix)
+}
+
+// ParseEvents collects the events from the output of a run. It returns the events and the
+// output with the event lines removed.
+func ParseEvents(output string) (events *Events, remaining string) {
+	events = &Events{
+		FinalState: FinalState{
+			Buffers: map[string]*ChunkContents{},
+		},
+	}
+
+	lines := strings.SplitAfter(output, "\n")
+	kept := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		text := strings.TrimRight(line, "\r\n")
+		if !IsEventLine(text) {
+			kept = append(kept, line)
+			continue
+		}
+
+		events.parseLine(strings.TrimPrefix(text, EventPrefix))
+	}
+
+	remaining = strings.Join(kept, "")
+	return
+}
+
+// Lookup returns the value of the named slot and whether it exists. Slot names are compared
+// case-insensitively since some frameworks change their case.
+func (b ChunkContents) Lookup(slotName string) (value string, ok bool) {
+	for _, slot := range b.Slots {
+		if strings.EqualFold(slot.Name, slotName) {
+			return slot.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// parseLine parses an event line (without the prefix). Lines we do not understand are ignored.
+func (e *Events) parseLine(line string) {
+	kind, rest, _ := strings.Cut(line, " ")
+
+	switch kind {
+	case "print":
+		e.Prints = append(e.Prints, rest)
+
+	case "fired":
+		timeStr, name, _ := strings.Cut(rest, " ")
+
+		time, err := strconv.ParseFloat(timeStr, 64)
+		if err != nil || name == "" {
+			return
+		}
+
+		e.Fired = append(e.Fired, ProductionFired{Time: time, Name: strings.TrimSpace(name)})
+
+	case "retrieval":
+		fields := strings.Fields(rest)
+		if len(fields) != 3 {
+			return
+		}
+
+		time, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return
+		}
+
+		latency, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return
+		}
+
+		e.Retrievals = append(e.Retrievals, Retrieval{Time: time, Latency: latency, Success: fields[2] == "success"})
+
+	case "end":
+		time, err := strconv.ParseFloat(strings.TrimSpace(rest), 64)
+		if err != nil {
+			return
+		}
+
+		e.EndTime = &time
+
+	case "buffer":
+		fields := strings.Fields(rest)
+		if len(fields) < 2 {
+			return
+		}
+
+		name := fields[0]
+
+		if fields[1] == "nil" {
+			e.Buffers[name] = nil
+			return
+		}
+
+		e.Buffers[name] = parseChunk(fields[1:])
+
+	case "memory":
+		fields := strings.Fields(rest)
+		if len(fields) < 1 {
+			return
+		}
+
+		e.Memory = append(e.Memory, parseChunk(fields))
+	}
+}
+
+// parseChunk parses a chunk type (or "-") followed by slot=value fields.
+func parseChunk(fields []string) (contents *ChunkContents) {
+	contents = &ChunkContents{}
+	if fields[0] != "-" {
+		contents.ChunkType = fields[0]
+	}
+
+	for _, field := range fields[1:] {
+		slotName, value, found := strings.Cut(field, "=")
+		if !found {
+			continue
+		}
+
+		contents.Slots = append(contents.Slots, SlotValue{Name: slotName, Value: normalizeValue(value)})
+	}
+
+	return
+}
+
+// normalizeValue converts the ways frameworks output empty slots to "nil".
+func normalizeValue(value string) string {
+	switch strings.ToLower(value) {
+	case "none", "nil", "":
+		return "nil"
+	}
+
+	return value
+}
+
+// String returns the chunk in a form similar to an amod pattern (e.g. "[count: first=1 second=2]").
+func (b ChunkContents) String() string {
+	values := make([]string, len(b.Slots))
+	for i, slot := range b.Slots {
+		values[i] = fmt.Sprintf("%s=%s", slot.Name, slot.Value)
+	}
+
+	if b.ChunkType == "" {
+		return fmt.Sprintf("[%s]", strings.Join(values, " "))
+	}
+
+	return fmt.Sprintf("[%s: %s]", b.ChunkType, strings.Join(values, " "))
+}
+
+// HasState returns whether the framework reported any of the final state.
+func (s FinalState) HasState() bool {
+	return s.EndTime != nil || len(s.Buffers) > 0 || len(s.Memory) > 0
+}
+
+// Write outputs the final state in a readable form.
+func (s FinalState) Write(w io.Writer) {
+	if s.EndTime != nil {
+		fmt.Fprintf(w, "end time: %.3f\n", *s.EndTime)
+	}
+
+	if len(s.Buffers) > 0 {
+		names := make([]string, 0, len(s.Buffers))
+		for name := range s.Buffers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintln(w, "buffers:")
+		for _, name := range names {
+			contents := "nil"
+			if s.Buffers[name] != nil {
+				contents = s.Buffers[name].String()
+			}
+
+			fmt.Fprintf(w, "  %s: %s\n", name, contents)
+		}
+	}
+
+	if len(s.Memory) > 0 {
+		fmt.Fprintln(w, "memory:")
+		for _, chunk := range s.Memory {
+			fmt.Fprintf(w, "  %s\n", chunk)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package framework
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseEvents(t *testing.T) {
+	output := "Start\n" +
+		"@gactar print Start\n" +
+		"@gactar fired 0.050 start\n" +
+		"    0.050   PROCEDURAL   PRODUCTION-FIRED START\n" +
+		"@gactar end 0.1\n" +
+		"@gactar buffer goal count first=1 second=None\n" +
+		"@gactar buffer retrieval nil\n" +
+		"@gactar unknown event\n"
+
+	events, remaining := ParseEvents(output)
+
+	expectedOutput := "Start\n    0.050   PROCEDURAL   PRODUCTION-FIRED START\n"
+	if remaining != expectedOutput {
+		t.Errorf("incorrect remaining output: %q", remaining)
+	}
+
+	if len(events.Prints) != 1 || events.Prints[0] != "Start" {
+		t.Errorf("incorrect prints: %v", events.Prints)
+	}
+
+	if len(events.Fired) != 1 || events.Fired[0].Name != "start" || events.Fired[0].Time != 0.05 {
+		t.Errorf("incorrect fired productions: %v", events.Fired)
+	}
+
+	if events.EndTime == nil || *events.EndTime != 0.1 {
+		t.Errorf("incorrect end time: %v", events.EndTime)
+	}
+
+	retrieval, ok := events.Buffers["retrieval"]
+	if !ok || retrieval != nil {
+		t.Errorf("expected retrieval buffer to be reported empty")
+	}
+
+	goal := events.Buffers["goal"]
+	if goal == nil || goal.ChunkType != "count" {
+		t.Fatalf("incorrect goal buffer: %v", goal)
+	}
+
+	value, ok := goal.Lookup("FIRST")
+	if !ok || value != "1" {
+		t.Errorf("incorrect value for slot 'first': %q", value)
+	}
+
+	value, _ = goal.Lookup("second")
+	if value != "nil" {
+		t.Errorf("expected 'second' to be nil, got %q", value)
+	}
+}
+
+func TestFinalStateWrite(t *testing.T) {
+	events, _ := ParseEvents(`@gactar end 0.25
+@gactar buffer retrieval nil
+@gactar buffer goal - FIRST=1 SECOND=2
+@gactar memory count first=1 second=2
+@gactar memory count first=2 second=NIL
+`)
+
+	if len(events.Memory) != 2 || events.Memory[1].Slots[1].Value != "nil" {
+		t.Fatalf("incorrect memory: %v", events.Memory)
+	}
+
+	var output strings.Builder
+	events.FinalState.Write(&output)
+
+	expected := `end time: 0.250
+buffers:
+  goal: [FIRST=1 SECOND=2]
+  retrieval: nil
+memory:
+  [count: first=1 second=2]
+  [count: first=2 second=nil]
+`
+
+	if output.String() != expected {
+		t.Errorf("incorrect final state:\n%s", output.String())
+	}
+}
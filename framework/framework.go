@@ -1,6 +1,9 @@
 package framework
 
 import (
+	"context"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/asmaloney/gactar/actr"
@@ -11,9 +14,11 @@ import (
 )
 
 var (
-	// ValidFrameworks lists the valid options for choosing frameworks on the command line and in the
+	// validFrameworks lists the valid options for choosing frameworks on the command line and in the
 	// interactive case. Make sure "all" is the first entry as we use [1:] to get the rest.
-	ValidFrameworks = []string{"all", "ccm", "pyactr", "vanilla"}
+	// Plugins are added while handlers may be reading it, so it is guarded by validFrameworksMutex.
+	validFrameworks      = []string{"all", "ccm", "pyactr", "vanilla"}
+	validFrameworksMutex sync.RWMutex
 
 	// GactarVersion stores the current build version. It is a var so we can replace it in testing.
 	GactarVersion = version.BuildVersion
@@ -32,27 +37,59 @@ type Info struct {
 	ExecutableName string `json:"executableName"` // name of the executable to run
 
 	PythonRequiredPackages []string `json:"pythonRequiredPackages,omitempty"` // (Python only) List of packages this framework requires
+
+	Capabilities Capabilities `json:"capabilities,omitempty"` // how the framework handles each of our Features
 }
 
 type InfoList = []Info
 
 // RunResult is the result of a Run() call which runs the code using the framework's executable.
 type RunResult struct {
-	FileName      string // full path to the intermediate file
-	GeneratedCode []byte // code which was run
-	Output        []byte // resulting output (stdout + stderr)
+	FileName      string    // full path to the intermediate file
+	WorkspacePath string    // full path to the directory containing the files for this run
+	GeneratedCode []byte    // code which was run
+	Output        []byte    // resulting output (stdout + stderr) - partial output if the run timed out or was cancelled
+	Events        *Events   // what happened during the run (parsed from the output)
+	Stats         *RunStats // statistics calculated from the events
+}
+
+// SetOutput parses the events from the output of a run and stores the output without them.
+// It also calculates the statistics of the run of the model.
+func (r *RunResult) SetOutput(model *actr.Model, output string) {
+	events, remaining := ParseEvents(output)
+
+	r.Events = events
+	r.Output = []byte(remaining)
+
+	r.UpdateStats(model)
 }
 
+// UpdateStats calculates the statistics of the run from the events. Frameworks which change the
+// events after calling SetOutput should call this.
+func (r *RunResult) UpdateStats(model *actr.Model) {
+	r.Stats = NewRunStats(model, r.Events)
+}
+
+// Framework generates code for a model and runs it. Frameworks do not store any state about
+// the models they are given, so one instance may be used by several goroutines at once.
 type Framework interface {
 	Info() *Info
 
 	ValidateModel(model *actr.Model) (log *issues.Log)
-	SetModel(model *actr.Model) (err error)
-	Model() (model *actr.Model)
 
-	Run(initialBuffers InitialBuffers) (result *RunResult, err error)
-	WriteModel(path string, initialBuffers InitialBuffers) (outputFileName string, err error)
-	GenerateCode(initialBuffers InitialBuffers) (code []byte, err error)
+	// Run runs the model. If the context is done before the run is finished, the run is
+	// killed and the result contains the output so far along with an executil.ErrTimeout
+	// or executil.ErrCancelled error.
+	Run(ctx context.Context, model *actr.Model, initialBuffers InitialBuffers) (result *RunResult, err error)
+
+	// RunStreaming is the same as Run, but it also writes the output to "output" line-by-line as
+	// it arrives. Use NewLineWriter() to receive the lines using a function.
+	RunStreaming(ctx context.Context, model *actr.Model, initialBuffers InitialBuffers, output io.Writer) (result *RunResult, err error)
+	WriteModel(model *actr.Model, path string, initialBuffers InitialBuffers) (outputFileName string, err error)
+
+	// GenerateCode generates the code to run the model. Frameworks which generate the code using
+	// another program (e.g. plugins) kill it if the context is done before it finishes.
+	GenerateCode(ctx context.Context, model *actr.Model, initialBuffers InitialBuffers) (code []byte, err error)
 }
 
 type List map[string]Framework
@@ -91,10 +128,37 @@ func (l List) Exists(framework string) bool {
 
 // IsValidFramework returns if the framework name is in our list of valid ones or not.
 func IsValidFramework(frameworkName string) bool {
-	return container.Contains(frameworkName, ValidFrameworks)
+	validFrameworksMutex.RLock()
+	defer validFrameworksMutex.RUnlock()
+
+	return container.Contains(frameworkName, validFrameworks)
+}
+
+// AddValidFramework adds the name of a framework which is not built in (e.g. a plugin)
+// to the list of valid frameworks.
+func AddValidFramework(name string) (err error) {
+	validFrameworksMutex.Lock()
+	defer validFrameworksMutex.Unlock()
+
+	if container.Contains(name, validFrameworks) {
+		return &ErrFrameworkExists{Name: name}
+	}
+
+	validFrameworks = append(validFrameworks, name)
+
+	return
+}
+
+// ValidFrameworks returns the valid options for choosing frameworks on the command line and in the
+// interactive case, starting with "all".
+func ValidFrameworks() []string {
+	validFrameworksMutex.RLock()
+	defer validFrameworksMutex.RUnlock()
+
+	return append([]string{}, validFrameworks...)
 }
 
 // ValidNamedFrameworks returns the list of all valid framework names without "all".
 func ValidNamedFrameworks() []string {
-	return ValidFrameworks[1:]
+	return ValidFrameworks()[1:]
 }
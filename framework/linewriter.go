@@ -0,0 +1,123 @@
+package framework
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// LineWriter is an io.Writer which splits what is written to it into lines and calls a function
+// with each complete line (without the line ending). This may be passed to RunStreaming() to
+// receive the output of a run line-by-line as it arrives.
+type LineWriter struct {
+	lineFunc func(line string)
+	partial  []byte
+	mutex    sync.Mutex
+}
+
+// NewLineWriter creates a LineWriter which calls "lineFunc" for each line.
+func NewLineWriter(lineFunc func(line string)) *LineWriter {
+	return &LineWriter{lineFunc: lineFunc}
+}
+
+func (w *LineWriter) Write(p []byte) (n int, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.partial = append(w.partial, p...)
+
+	for {
+		index := bytes.IndexByte(w.partial, '\n')
+		if index == -1 {
+			break
+		}
+
+		line := strings.TrimSuffix(string(w.partial[:index]), "\r")
+		w.partial = w.partial[index+1:]
+
+		w.lineFunc(line)
+	}
+
+	return len(p), nil
+}
+
+// Flush calls the line function with any remaining text which did not end in a newline.
+func (w *LineWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.partial) == 0 {
+		return
+	}
+
+	line := strings.TrimSuffix(string(w.partial), "\r")
+	w.partial = nil
+
+	w.lineFunc(line)
+}
+
+// OutputStream is used by frameworks to pass the output of a run on to an io.Writer line-by-line.
+// The framework may filter out lines (e.g. warnings) it does not want to pass on, or hold lines
+// back until it knows whether they should be passed on.
+type OutputStream struct {
+	*LineWriter
+
+	output io.Writer
+	held   []string
+}
+
+// NewOutputStream creates an OutputStream which writes to "output". The filter function is called
+// for each line and returns what to do with it. If output is nil, nothing is written.
+// Event lines (see EventPrefix) are never passed on.
+func NewOutputStream(output io.Writer, filter func(stream *OutputStream, line string)) (stream *OutputStream) {
+	stream = &OutputStream{output: output}
+
+	stream.LineWriter = NewLineWriter(func(line string) {
+		if stream.output == nil || IsEventLine(line) {
+			return
+		}
+
+		if filter == nil {
+			stream.Emit(line)
+			return
+		}
+
+		filter(stream, line)
+	})
+
+	return
+}
+
+// Emit writes the line to the output.
+func (s *OutputStream) Emit(line string) {
+	_, _ = io.WriteString(s.output, line+"\n")
+}
+
+// Hold keeps the line to be emitted or discarded later.
+func (s *OutputStream) Hold(line string) {
+	s.held = append(s.held, line)
+}
+
+// EmitHeld writes any held lines to the output.
+func (s *OutputStream) EmitHeld() {
+	for _, line := range s.held {
+		s.Emit(line)
+	}
+
+	s.held = nil
+}
+
+// DiscardHeld throws away any held lines.
+func (s *OutputStream) DiscardHeld() {
+	s.held = nil
+}
+
+// Close flushes any partial line and emits any lines which are still held.
+func (s *OutputStream) Close() {
+	s.Flush()
+
+	if s.output != nil {
+		s.EmitHeld()
+	}
+}
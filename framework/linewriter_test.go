@@ -0,0 +1,80 @@
+package framework
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestLineWriter(t *testing.T) {
+	lines := []string{}
+
+	w := NewLineWriter(func(line string) {
+		lines = append(lines, line)
+	})
+
+	_, _ = w.Write([]byte("one\ntw"))
+	_, _ = w.Write([]byte("o\r\nthr"))
+	_, _ = w.Write([]byte("ee"))
+
+	expected := []string{"one", "two"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+
+	w.Flush()
+
+	expected = append(expected, "three")
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q after flush, got %q", expected, lines)
+	}
+}
+
+func TestOutputStreamFilter(t *testing.T) {
+	var output bytes.Buffer
+
+	// Hold everything until "start" and then discard what was held
+	started := false
+	stream := NewOutputStream(&output, func(stream *OutputStream, line string) {
+		switch {
+		case started:
+			stream.Emit(line)
+
+		case line == "start":
+			started = true
+			stream.DiscardHeld()
+
+		default:
+			stream.Hold(line)
+		}
+	})
+
+	_, _ = stream.Write([]byte("preamble\nstart\nline 1\nline 2"))
+	stream.Close()
+
+	expected := "line 1\nline 2\n"
+	if output.String() != expected {
+		t.Errorf("expected %q, got %q", expected, output.String())
+	}
+}
+
+func TestOutputStreamHeldAtClose(t *testing.T) {
+	var output bytes.Buffer
+
+	stream := NewOutputStream(&output, func(stream *OutputStream, line string) {
+		stream.Hold(line)
+	})
+
+	_, _ = stream.Write([]byte("one\ntwo\n"))
+
+	if output.Len() != 0 {
+		t.Errorf("expected no output before close, got %q", output.String())
+	}
+
+	stream.Close()
+
+	expected := "one\ntwo\n"
+	if output.String() != expected {
+		t.Errorf("expected %q, got %q", expected, output.String())
+	}
+}
@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrTemplatesOrGenerator = errors.New("exactly one of 'templates' or 'generator' must be set")
+)
+
+type ErrInvalidManifest struct {
+	FileName string
+	Message  string
+}
+
+func (e ErrInvalidManifest) Error() string {
+	return fmt.Sprintf("invalid plugin manifest %q: %s", e.FileName, e.Message)
+}
+
+type ErrMissingField struct {
+	Field string
+}
+
+func (e ErrMissingField) Error() string {
+	return fmt.Sprintf("missing %q", e.Field)
+}
+
+type ErrInvalidName struct {
+	Name string
+}
+
+func (e ErrInvalidName) Error() string {
+	return fmt.Sprintf("invalid framework name %q", e.Name)
+}
+
+type ErrUnknownFeature struct {
+	Name string
+}
+
+func (e ErrUnknownFeature) Error() string {
+	return fmt.Sprintf("unknown feature %q in capabilities", e.Name)
+}
+
+type ErrInvalidSupport struct {
+	Feature string
+	Support string
+}
+
+func (e ErrInvalidSupport) Error() string {
+	return fmt.Sprintf("invalid support %q for feature %q (expected 'supported', 'emulated', or 'unsupported')", e.Support, e.Feature)
+}
+
+type ErrGeneratorFailed struct {
+	Name string
+	Err  error
+}
+
+func (e ErrGeneratorFailed) Error() string {
+	return fmt.Sprintf("%s: code generation failed: %s", e.Name, e.Err.Error())
+}
+
+func (e ErrGeneratorFailed) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,222 @@
+package plugin
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/asmaloney/gactar/framework"
+
+	"github.com/asmaloney/gactar/util/filesystem"
+)
+
+// ManifestFileExtension is the extension used for plugin manifests.
+const ManifestFileExtension = ".json"
+
+// Manifest describes a framework provided by a plugin. Code for the framework is generated either
+// using a set of Go text/templates or by running an external generator command which receives the
+// model (see ModelJSON) on stdin and writes the code to stdout.
+type Manifest struct {
+	Name          string `json:"name"`          // name of the framework (used with --framework)
+	Language      string `json:"language"`      // language the framework uses
+	FileExtension string `json:"fileExtension"` // file extension of the generated file
+
+	Executable  string   `json:"executable"`            // name of the executable to run the generated file
+	RunArgs     []string `json:"runArgs,omitempty"`     // arguments passed to the executable before the generated file
+	VersionArgs []string `json:"versionArgs,omitempty"` // (optional) arguments to get the executable's version
+
+	// Only one of these may be set.
+	Templates []string `json:"templates,omitempty"` // template files - the first one is executed
+	Generator []string `json:"generator,omitempty"` // generator command & its arguments
+
+	PythonRequiredPackages []string `json:"pythonRequiredPackages,omitempty"` // (Python only) packages the framework requires
+
+	// Capabilities lists how the plugin handles features (see framework.Features).
+	// Features which are not listed are assumed to be supported.
+	Capabilities framework.Capabilities `json:"capabilities,omitempty"`
+
+	// Dir is the directory containing the manifest. Relative paths to templates and the
+	// generator are relative to this.
+	Dir string `json:"-"`
+}
+
+// LoadManifest reads and checks a plugin manifest.
+func LoadManifest(fileName string) (manifest *Manifest, err error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+
+	manifest = &Manifest{}
+
+	err = json.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, &ErrInvalidManifest{FileName: fileName, Message: err.Error()}
+	}
+
+	manifest.Dir, err = filepath.Abs(filepath.Dir(fileName))
+	if err != nil {
+		return nil, err
+	}
+
+	err = manifest.validate()
+	if err != nil {
+		return nil, &ErrInvalidManifest{FileName: fileName, Message: err.Error()}
+	}
+
+	return
+}
+
+// LoadManifests loads all the manifests in a directory. Manifests with errors are returned in
+// the list of errors so the others may still be used.
+func LoadManifests(dir string) (manifests []*Manifest, errs []error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+ManifestFileExtension))
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		manifest, err := LoadManifest(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		manifests = append(manifests, manifest)
+	}
+
+	return
+}
+
+// Info returns the framework info for the plugin.
+func (m Manifest) Info() framework.Info {
+	capabilities := framework.Capabilities{}
+	for _, feature := range framework.Features {
+		support, ok := m.Capabilities[feature.Name]
+		if !ok {
+			support = framework.Supported
+		}
+
+		capabilities[feature.Name] = support
+	}
+
+	return framework.Info{
+		Name:                   m.Name,
+		Language:               m.Language,
+		FileExtension:          m.FileExtension,
+		ExecutableName:         m.Executable,
+		PythonRequiredPackages: m.PythonRequiredPackages,
+		Capabilities:           capabilities,
+	}
+}
+
+// UsesTemplates returns true if code is generated using templates (rather than a generator).
+func (m Manifest) UsesTemplates() bool {
+	return len(m.Templates) > 0
+}
+
+// TemplatePaths returns the full paths to the template files.
+func (m Manifest) TemplatePaths() (paths []string) {
+	for _, name := range m.Templates {
+		paths = append(paths, m.resolve(name))
+	}
+
+	return
+}
+
+// GeneratorCommand returns the generator command (with its path resolved) and its arguments.
+func (m Manifest) GeneratorCommand() (name string, args []string) {
+	if len(m.Generator) == 0 {
+		return
+	}
+
+	name = m.Generator[0]
+
+	// If it's a path (rather than just a name to look up in PATH), make it relative to the manifest
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		name = m.resolve(name)
+	}
+
+	return name, m.Generator[1:]
+}
+
+// ParseTemplates parses the plugin's templates.
+func (m Manifest) ParseTemplates() (tmpl *template.Template, err error) {
+	paths := m.TemplatePaths()
+
+	tmpl, err = template.New(filepath.Base(paths[0])).Funcs(templateFuncs).ParseFiles(paths...)
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// CheckGenerator checks that the templates can be parsed or that the generator command exists.
+func (m Manifest) CheckGenerator() (err error) {
+	if m.UsesTemplates() {
+		_, err = m.ParseTemplates()
+		return
+	}
+
+	name, _ := m.GeneratorCommand()
+
+	_, err = exec.LookPath(name)
+	if err != nil {
+		return &filesystem.ErrExeNotFound{ExeName: name, Path: os.Getenv("PATH")}
+	}
+
+	return
+}
+
+func (m Manifest) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(m.Dir, path)
+}
+
+func (m Manifest) validate() (err error) {
+	switch {
+	case m.Name == "":
+		return &ErrMissingField{Field: "name"}
+
+	case m.Language == "":
+		return &ErrMissingField{Field: "language"}
+
+	case m.FileExtension == "":
+		return &ErrMissingField{Field: "fileExtension"}
+
+	case m.Executable == "":
+		return &ErrMissingField{Field: "executable"}
+	}
+
+	if m.Name == "all" || strings.ContainsAny(m.Name, " ,/\\") {
+		return &ErrInvalidName{Name: m.Name}
+	}
+
+	if m.UsesTemplates() == (len(m.Generator) > 0) {
+		return ErrTemplatesOrGenerator
+	}
+
+	for name, support := range m.Capabilities {
+		if framework.LookupFeature(name) == nil {
+			return &ErrUnknownFeature{Name: name}
+		}
+
+		switch support {
+		case framework.Supported, framework.Emulated, framework.Unsupported:
+		default:
+			return &ErrInvalidSupport{Feature: name, Support: string(support)}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,102 @@
+package plugin
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/asmaloney/gactar/framework"
+)
+
+func TestLoadManifest(t *testing.T) {
+	manifest, err := LoadManifest("testdata/template.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.Name != "text" {
+		t.Errorf("expected name %q, got %q", "text", manifest.Name)
+	}
+
+	if !manifest.UsesTemplates() {
+		t.Error("expected manifest to use templates")
+	}
+
+	expected, _ := filepath.Abs("testdata/model.tmpl")
+	paths := manifest.TemplatePaths()
+	if len(paths) != 1 || paths[0] != expected {
+		t.Errorf("expected template paths [%q], got %q", expected, paths)
+	}
+}
+
+func TestManifestCapabilities(t *testing.T) {
+	manifest, err := LoadManifest("testdata/template.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := manifest.Info()
+
+	if info.Capabilities.Support("memory.finst_time") != framework.Unsupported {
+		t.Error("expected memory.finst_time to be unsupported")
+	}
+
+	// Features which are not listed are assumed to be supported
+	if info.Capabilities.Support("statement.print") != framework.Supported {
+		t.Error("expected statement.print to be supported")
+	}
+}
+
+func TestLoadInvalidManifest(t *testing.T) {
+	files := []string{
+		"testdata/both.json",
+		"testdata/badname.json",
+		"testdata/missing.json",
+		"testdata/badcapability.json",
+	}
+
+	for _, file := range files {
+		_, err := LoadManifest(file)
+
+		var e *ErrInvalidManifest
+		if !errors.As(err, &e) {
+			t.Errorf("%s: expected ErrInvalidManifest, got %v", file, err)
+		}
+	}
+}
+
+func TestLoadManifests(t *testing.T) {
+	manifests, errs := LoadManifests("testdata")
+
+	if len(manifests) != 2 {
+		t.Errorf("expected 2 valid manifests, got %d", len(manifests))
+	}
+
+	if len(errs) != 4 {
+		t.Errorf("expected 4 invalid manifests, got %d: %v", len(errs), errs)
+	}
+}
+
+func TestGeneratorCommand(t *testing.T) {
+	manifest := Manifest{
+		Generator: []string{"./gen.sh", "--flag"},
+		Dir:       "/plugins",
+	}
+
+	name, args := manifest.GeneratorCommand()
+	if name != filepath.Join("/plugins", "gen.sh") {
+		t.Errorf("expected generator path to be resolved, got %q", name)
+	}
+
+	if len(args) != 1 || args[0] != "--flag" {
+		t.Errorf("expected args [--flag], got %q", args)
+	}
+
+	// Names without a path are looked up in PATH
+	manifest.Generator = []string{"python3", "gen.py"}
+
+	name, _ = manifest.GeneratorCommand()
+	if name != "python3" {
+		t.Errorf("expected %q, got %q", "python3", name)
+	}
+}
@@ -0,0 +1,391 @@
+package plugin
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"github.com/asmaloney/gactar/actr"
+	"github.com/asmaloney/gactar/actr/modules"
+	"github.com/asmaloney/gactar/framework"
+)
+
+// ModelJSON is the model as it is passed to plugins. It is written as JSON to the stdin of
+// generator commands and is the data passed to templates.
+type ModelJSON struct {
+	GactarVersion string `json:"gactarVersion"`
+	Framework     string `json:"framework"` // name of the plugin
+
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Authors     []string `json:"authors,omitempty"`
+
+	Options OptionsJSON `json:"options"`
+
+	Modules        []ModuleJSON      `json:"modules"`
+	Chunks         []ChunkJSON       `json:"chunks"`
+	ImplicitChunks []string          `json:"implicitChunks,omitempty"`
+	Initializers   []InitializerJSON `json:"initializers,omitempty"`
+	Similarities   []SimilarityJSON  `json:"similarities,omitempty"`
+	Productions    []ProductionJSON  `json:"productions"`
+
+	// InitialBuffers are the buffer contents passed in when running (e.g. the goal).
+	InitialBuffers map[string]PatternJSON `json:"initialBuffers,omitempty"`
+}
+
+type OptionsJSON struct {
+	LogLevel         string  `json:"logLevel"`
+	TraceActivations bool    `json:"traceActivations"`
+	ReportMemory     bool    `json:"reportMemory"`
+	RandomSeed       *uint32 `json:"randomSeed,omitempty"`
+}
+
+type ModuleJSON struct {
+	Name    string                 `json:"name"`
+	Buffers []string               `json:"buffers,omitempty"`
+	Params  map[string]interface{} `json:"params,omitempty"` // only the params which are set, using amod names
+}
+
+type ChunkJSON struct {
+	Type     string   `json:"type"`
+	Slots    []string `json:"slots"`
+	Internal bool     `json:"internal,omitempty"` // internal chunk types (e.g. "_status") start with "_"
+}
+
+type InitializerJSON struct {
+	Module    string      `json:"module"`
+	Buffer    string      `json:"buffer,omitempty"`
+	ChunkName *string     `json:"chunkName,omitempty"`
+	Pattern   PatternJSON `json:"pattern"`
+}
+
+type SimilarityJSON struct {
+	ChunkOne string  `json:"chunkOne"`
+	ChunkTwo string  `json:"chunkTwo"`
+	Value    float64 `json:"value"`
+}
+
+// PatternJSON is a pattern such as "[count: ?first ?second]".
+type PatternJSON struct {
+	Chunk string     `json:"chunk"`
+	Slots []SlotJSON `json:"slots"`
+	Text  string     `json:"text"` // the pattern in amod format
+}
+
+// SlotJSON is one item in a pattern. Kind is one of "nil", "wildcard", "id", "str", "num", or "var".
+type SlotJSON struct {
+	Kind        string           `json:"kind"`
+	Value       string           `json:"value,omitempty"`
+	Negated     bool             `json:"negated,omitempty"`
+	Constraints []ConstraintJSON `json:"constraints,omitempty"` // (vars only) from "when" clauses
+}
+
+type ConstraintJSON struct {
+	Comparison string    `json:"comparison"` // "==" or "!="
+	Value      ValueJSON `json:"value"`
+}
+
+// ValueJSON is a value used in statements. Kind is one of "nil", "var", "id", "str", or "num".
+type ValueJSON struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value,omitempty"`
+}
+
+type ProductionJSON struct {
+	Name        string          `json:"name"`
+	Description *string         `json:"description,omitempty"`
+	Matches     []MatchJSON     `json:"matches"`
+	Do          []StatementJSON `json:"do"`
+}
+
+type MatchJSON struct {
+	Buffer  string      `json:"buffer"`
+	Pattern PatternJSON `json:"pattern"`
+}
+
+// StatementJSON is a statement in a production's "do" section. Type is one of "clear",
+// "print", "recall", "set", or "stop". Only the fields relevant to the type are set.
+type StatementJSON struct {
+	Type string `json:"type"`
+
+	Buffers []string      `json:"buffers,omitempty"` // clear
+	Values  []ValueJSON   `json:"values,omitempty"`  // print
+	Buffer  string        `json:"buffer,omitempty"`  // set
+	Slots   []SetSlotJSON `json:"slots,omitempty"`   // set (slots)
+	Pattern *PatternJSON  `json:"pattern,omitempty"` // recall, set (pattern)
+}
+
+type SetSlotJSON struct {
+	Name  string    `json:"name"`
+	Value ValueJSON `json:"value"`
+}
+
+// NewModelJSON converts the model and the initial buffers to the format passed to plugins.
+func NewModelJSON(pluginName string, model *actr.Model, initialBuffers framework.ParsedInitialBuffers) (m *ModelJSON) {
+	m = &ModelJSON{
+		GactarVersion: framework.GactarVersion,
+		Framework:     pluginName,
+		Name:          model.Name,
+		Description:   model.Description,
+		Authors:       model.Authors,
+		Options: OptionsJSON{
+			LogLevel:         string(model.LogLevel),
+			TraceActivations: model.TraceActivations,
+			ReportMemory:     model.ReportMemory,
+			RandomSeed:       model.RandomSeed,
+		},
+		Modules:        []ModuleJSON{},
+		Chunks:         []ChunkJSON{},
+		ImplicitChunks: model.ImplicitChunks,
+		Productions:    []ProductionJSON{},
+	}
+
+	for _, module := range model.Modules {
+		m.Modules = append(m.Modules, ModuleJSON{
+			Name:    module.ModuleName(),
+			Buffers: module.BufferNames(),
+			Params:  moduleParams(module),
+		})
+	}
+
+	for _, chunk := range model.Chunks {
+		m.Chunks = append(m.Chunks, ChunkJSON{
+			Type:     chunk.TypeName,
+			Slots:    chunk.SlotNames,
+			Internal: actr.IsInternalChunkType(chunk.TypeName),
+		})
+	}
+
+	for _, init := range model.Initializers {
+		initializer := InitializerJSON{
+			Module:    init.Module.ModuleName(),
+			ChunkName: init.ChunkName,
+			Pattern:   newPatternJSON(init.Pattern),
+		}
+
+		if init.Buffer != nil {
+			initializer.Buffer = init.Buffer.BufferName()
+		}
+
+		m.Initializers = append(m.Initializers, initializer)
+	}
+
+	for _, similar := range model.Similarities {
+		m.Similarities = append(m.Similarities, SimilarityJSON{
+			ChunkOne: similar.ChunkOne,
+			ChunkTwo: similar.ChunkTwo,
+			Value:    similar.Value,
+		})
+	}
+
+	for _, production := range model.Productions {
+		m.Productions = append(m.Productions, newProductionJSON(production))
+	}
+
+	if len(initialBuffers) > 0 {
+		m.InitialBuffers = map[string]PatternJSON{}
+
+		for name, pattern := range initialBuffers {
+			m.InitialBuffers[name] = newPatternJSON(pattern)
+		}
+	}
+
+	return
+}
+
+// moduleParams returns the params which have been set on the module. We convert the module to
+// JSON and use its fields (other than name & buffers) converted to their amod names.
+func moduleParams(module modules.ModuleInterface) (params map[string]interface{}) {
+	encoded, err := json.Marshal(module)
+	if err != nil {
+		return nil
+	}
+
+	fields := map[string]interface{}{}
+
+	err = json.Unmarshal(encoded, &fields)
+	if err != nil {
+		return nil
+	}
+
+	for key, value := range fields {
+		if key == "Name" || key == "Buffers" || value == nil {
+			continue
+		}
+
+		if params == nil {
+			params = map[string]interface{}{}
+		}
+
+		params[snakeCase(key)] = value
+	}
+
+	return
+}
+
+// snakeCase converts a Go field name to the amod param name (e.g. "LatencyFactor" -> "latency_factor").
+func snakeCase(name string) string {
+	var builder strings.Builder
+
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				builder.WriteRune('_')
+			}
+
+			r = unicode.ToLower(r)
+		}
+
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
+
+func newPatternJSON(pattern *actr.Pattern) (p PatternJSON) {
+	if pattern == nil {
+		return
+	}
+
+	p = PatternJSON{
+		Chunk: pattern.Chunk.TypeName,
+		Slots: []SlotJSON{},
+		Text:  pattern.String(),
+	}
+
+	for _, slot := range pattern.Slots {
+		s := SlotJSON{Negated: slot.Negated}
+
+		switch {
+		case slot.Wildcard:
+			s.Kind = "wildcard"
+
+		case slot.Nil:
+			s.Kind = "nil"
+
+		case slot.ID != nil:
+			s.Kind = "id"
+			s.Value = *slot.ID
+
+		case slot.Str != nil:
+			s.Kind = "str"
+			s.Value = *slot.Str
+
+		case slot.Var != nil:
+			s.Kind = "var"
+			s.Value = *slot.Var.Name
+
+			for _, constraint := range slot.Var.Constraints {
+				s.Constraints = append(s.Constraints, ConstraintJSON{
+					Comparison: constraint.Comparison.String(),
+					Value:      newValueJSON(constraint.RHS),
+				})
+			}
+
+		case slot.Num != nil:
+			s.Kind = "num"
+			s.Value = *slot.Num
+		}
+
+		p.Slots = append(p.Slots, s)
+	}
+
+	return
+}
+
+func newValueJSON(value *actr.Value) (v ValueJSON) {
+	if value == nil {
+		return
+	}
+
+	switch {
+	case value.Nil != nil:
+		v.Kind = "nil"
+
+	case value.Var != nil:
+		v.Kind = "var"
+		v.Value = *value.Var
+
+	case value.ID != nil:
+		v.Kind = "id"
+		v.Value = *value.ID
+
+	case value.Str != nil:
+		v.Kind = "str"
+		v.Value = *value.Str
+
+	case value.Number != nil:
+		v.Kind = "num"
+		v.Value = *value.Number
+	}
+
+	return
+}
+
+func newProductionJSON(production *actr.Production) (p ProductionJSON) {
+	p = ProductionJSON{
+		Name:        production.Name,
+		Description: production.Description,
+		Matches:     []MatchJSON{},
+		Do:          []StatementJSON{},
+	}
+
+	for _, match := range production.Matches {
+		p.Matches = append(p.Matches, MatchJSON{
+			Buffer:  match.Buffer.BufferName(),
+			Pattern: newPatternJSON(match.Pattern),
+		})
+	}
+
+	for _, statement := range production.DoStatements {
+		p.Do = append(p.Do, newStatementJSON(statement))
+	}
+
+	return
+}
+
+func newStatementJSON(statement *actr.Statement) (s StatementJSON) {
+	switch {
+	case statement.Clear != nil:
+		s.Type = "clear"
+		s.Buffers = statement.Clear.BufferNames
+
+	case statement.Print != nil:
+		s.Type = "print"
+
+		if statement.Print.Values != nil {
+			for _, value := range *statement.Print.Values {
+				s.Values = append(s.Values, newValueJSON(value))
+			}
+		}
+
+	case statement.Recall != nil:
+		s.Type = "recall"
+
+		pattern := newPatternJSON(statement.Recall.Pattern)
+		s.Pattern = &pattern
+
+	case statement.Set != nil:
+		s.Type = "set"
+		s.Buffer = statement.Set.Buffer.BufferName()
+
+		if statement.Set.Slots != nil {
+			for _, slot := range *statement.Set.Slots {
+				s.Slots = append(s.Slots, SetSlotJSON{
+					Name:  slot.Name,
+					Value: newValueJSON(slot.Value),
+				})
+			}
+		}
+
+		if statement.Set.Pattern != nil {
+			pattern := newPatternJSON(statement.Set.Pattern)
+			s.Pattern = &pattern
+		}
+
+	case statement.Stop != nil:
+		s.Type = "stop"
+	}
+
+	return
+}
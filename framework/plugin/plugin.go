@@ -0,0 +1,239 @@
+// Package plugin provides frameworks which are not built in to gactar. Each one is described
+// by a manifest (see Manifest) and generates code using either templates or an external command.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/asmaloney/gactar/actr"
+	"github.com/asmaloney/gactar/framework"
+
+	"github.com/asmaloney/gactar/util/chalk"
+	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/executil"
+	"github.com/asmaloney/gactar/util/filesystem"
+	"github.com/asmaloney/gactar/util/issues"
+	"github.com/asmaloney/gactar/util/python"
+	"github.com/asmaloney/gactar/util/workspace"
+)
+
+// templateFuncs are available to plugin templates in addition to the built-in ones.
+var templateFuncs = template.FuncMap{
+	"join":    strings.Join,
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"replace": strings.ReplaceAll,
+	"quote":   strconv.Quote,
+	"json": func(v interface{}) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+}
+
+// Plugin is a framework described by a manifest. It does not store any state about a model,
+// so it may be shared by goroutines.
+type Plugin struct {
+	manifest *Manifest
+	info     framework.Info
+
+	tmpPath  string
+	template *template.Template // nil if we are using a generator
+}
+
+// New creates a framework from the plugin's manifest. It checks that the executable, the
+// generator or templates, and any required python packages are available.
+func New(settings *cli.Settings, manifest *Manifest) (p *Plugin, err error) {
+	p = &Plugin{
+		manifest: manifest,
+		info:     manifest.Info(),
+		tmpPath:  settings.TempPath,
+	}
+
+	err = p.setup()
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func (p *Plugin) setup() (err error) {
+	_, err = filesystem.CheckForExecutable(p.manifest.Executable)
+	if err != nil {
+		return
+	}
+
+	if p.manifest.UsesTemplates() {
+		p.template, err = p.manifest.ParseTemplates()
+	} else {
+		err = p.manifest.CheckGenerator()
+	}
+	if err != nil {
+		return
+	}
+
+	for _, packageName := range p.manifest.PythonRequiredPackages {
+		err = python.CheckForPackage(p.manifest.Executable, packageName)
+		if err != nil {
+			return
+		}
+	}
+
+	version := p.manifest.Executable
+	if len(p.manifest.VersionArgs) > 0 {
+		output, versionErr := executil.ExecCommand(p.manifest.Executable, p.manifest.VersionArgs...)
+		if versionErr == nil {
+			version = strings.TrimSpace(output)
+		}
+	}
+
+	fmt.Print(chalk.Header(p.info.Name + ": "))
+	fmt.Printf("Using %s (plugin)\n", version)
+
+	return
+}
+
+func (p Plugin) Info() *framework.Info {
+	return &p.info
+}
+
+func (p Plugin) ValidateModel(model *actr.Model) (log *issues.Log) {
+	log = issues.New()
+
+	framework.ValidateCapabilities(&p.info, model, log)
+
+	return
+}
+
+func (p *Plugin) Run(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers) (result *framework.RunResult, err error) {
+	return p.RunStreaming(ctx, model, initialBuffers, nil)
+}
+
+// RunStreaming generates the code, writes it to disk, and runs it using the plugin's executable.
+func (p *Plugin) RunStreaming(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers, output io.Writer) (result *framework.RunResult, err error) {
+	if model.Name == "" {
+		err = framework.ErrModelMissingName
+		return
+	}
+
+	workspacePath, err := workspace.Create(ctx, p.tmpPath)
+	if err != nil {
+		return
+	}
+	defer workspace.Release(workspacePath)
+
+	runFile, code, err := p.writeModel(ctx, model, workspacePath, initialBuffers)
+	if err != nil {
+		return
+	}
+
+	result = &framework.RunResult{
+		FileName:      runFile,
+		WorkspacePath: workspacePath,
+		GeneratedCode: code,
+	}
+
+	args := append(append([]string{}, p.manifest.RunArgs...), runFile)
+
+	stream := framework.NewOutputStream(output, nil)
+
+	runOutput, err := executil.ExecCommandStream(ctx, stream, p.manifest.Executable, args...)
+	stream.Close()
+	if err != nil {
+		if executil.IsInterrupted(err) {
+			result.SetOutput(model, runOutput)
+		}
+		return
+	}
+
+	result.SetOutput(model, runOutput)
+
+	return
+}
+
+// WriteModel generates the code and writes it to a file.
+func (p *Plugin) WriteModel(model *actr.Model, path string, initialBuffers framework.InitialBuffers) (outputFileName string, err error) {
+	if model.Name == "" {
+		err = framework.ErrModelMissingName
+		return
+	}
+
+	outputFileName, _, err = p.writeModel(context.Background(), model, path, initialBuffers)
+	return
+}
+
+// writeModel generates the code and writes it to a file. It returns the file name and the code.
+func (p *Plugin) writeModel(ctx context.Context, model *actr.Model, path string, initialBuffers framework.InitialBuffers) (outputFileName string, code []byte, err error) {
+	outputFileName = fmt.Sprintf("%s_%s.%s", p.info.Name, model.Name, p.info.FileExtension)
+	if path != "" {
+		outputFileName = fmt.Sprintf("%s/%s", path, outputFileName)
+	}
+
+	err = filesystem.RemoveFile(outputFileName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	code, err = p.GenerateCode(ctx, model, initialBuffers)
+	if err != nil {
+		return
+	}
+
+	err = os.WriteFile(outputFileName, code, 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// GenerateCode generates the code using the plugin's templates or generator. If the context is done
+// before the generator finishes, it is killed.
+func (p *Plugin) GenerateCode(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers) (code []byte, err error) {
+	if model.Name == "" {
+		err = framework.ErrModelMissingName
+		return
+	}
+
+	patterns, err := framework.ParseInitialBuffers(model, initialBuffers)
+	if err != nil {
+		return
+	}
+
+	data := NewModelJSON(p.info.Name, model, patterns)
+
+	if p.template != nil {
+		var buffer bytes.Buffer
+
+		err = p.template.Execute(&buffer, data)
+		if err != nil {
+			return
+		}
+
+		code = buffer.Bytes()
+	} else {
+		input, marshalErr := json.Marshal(data)
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+
+		name, args := p.manifest.GeneratorCommand()
+
+		output, genErr := executil.ExecCommandWithInput(ctx, input, name, args...)
+		if genErr != nil {
+			return nil, &ErrGeneratorFailed{Name: p.info.Name, Err: genErr}
+		}
+
+		code = []byte(output)
+	}
+
+	return
+}
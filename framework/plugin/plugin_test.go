@@ -0,0 +1,174 @@
+//go:build !windows
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asmaloney/gactar/amod"
+	"github.com/asmaloney/gactar/framework"
+	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/executil"
+)
+
+func newTestPlugin(t *testing.T, manifestFile string) *Plugin {
+	t.Helper()
+
+	manifest, err := LoadManifest(manifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(&cli.Settings{TempPath: t.TempDir()}, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestTemplateCodeGeneration(t *testing.T) {
+	p := newTestPlugin(t, "testdata/template.json")
+
+	code, err := framework.GenerateCodeFromFile(p, "../testdata/semantic.amod", framework.InitialBuffers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := string(code)
+
+	if !strings.HasPrefix(output, "model: semantic\n") {
+		t.Errorf("unexpected output:\n%s", output)
+	}
+
+	if !strings.Contains(output, "production: INITIALRETRIEVAL") {
+		t.Errorf("expected upper-case production name in output:\n%s", output)
+	}
+}
+
+func TestGeneratorCodeGeneration(t *testing.T) {
+	p := newTestPlugin(t, "testdata/generator.json")
+
+	initialBuffers := framework.InitialBuffers{
+		"goal": "[isMember: shark fish nil]",
+	}
+
+	code, err := framework.GenerateCodeFromFile(p, "../testdata/semantic.amod", initialBuffers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The generator is "cat" so we should get back the model JSON
+	var model ModelJSON
+
+	err = json.Unmarshal(code, &model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if model.Name != "semantic" || model.Framework != "echo" {
+		t.Errorf("unexpected model name/framework: %q/%q", model.Name, model.Framework)
+	}
+
+	goal, ok := model.InitialBuffers["goal"]
+	if !ok || goal.Chunk != "isMember" {
+		t.Errorf("expected initial goal of type isMember, got %+v", model.InitialBuffers)
+	}
+}
+
+func TestRun(t *testing.T) {
+	p := newTestPlugin(t, "testdata/template.json")
+
+	model, _, err := amod.GenerateModelFromFile("../testdata/semantic.amod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The executable is "cat" so the output is the generated code
+	result, err := p.Run(context.Background(), model, framework.InitialBuffers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(result.Output) != string(result.GeneratedCode) {
+		t.Errorf("expected output to match generated code, got:\n%s", result.Output)
+	}
+}
+
+func TestGeneratorTimeout(t *testing.T) {
+	p := newTestPlugin(t, "testdata/hang/manifest.json")
+
+	model, _, err := amod.GenerateModelFromFile("../testdata/semantic.amod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	_, err = p.Run(ctx, model, framework.InitialBuffers{})
+
+	var timeoutErr *executil.ErrTimeout
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected the generator to time out, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the generator to be killed, but the run took %s", elapsed)
+	}
+}
+
+func TestConcurrentRuns(t *testing.T) {
+	p := newTestPlugin(t, "testdata/template.json")
+
+	model, _, err := amod.GenerateModelFromFile("../testdata/semantic.amod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const numRuns = 8
+
+	results := make([]*framework.RunResult, numRuns)
+	errs := make([]error, numRuns)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < numRuns; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			results[i], errs[i] = p.RunStreaming(context.Background(), model, framework.InitialBuffers{}, io.Discard)
+		}(i)
+	}
+
+	wg.Wait()
+
+	workspaces := map[string]bool{}
+
+	for i := 0; i < numRuns; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+
+		if string(results[i].Output) != string(results[0].GeneratedCode) {
+			t.Errorf("run %d: expected output to match generated code, got:\n%s", i, results[i].Output)
+		}
+
+		workspaces[results[i].WorkspacePath] = true
+	}
+
+	if len(workspaces) != numRuns {
+		t.Errorf("expected each run to have its own workspace, got %d workspaces", len(workspaces))
+	}
+}
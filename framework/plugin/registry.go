@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/asmaloney/gactar/framework"
+)
+
+var (
+	// registered maps plugin names to their manifests. Plugins may be registered while handlers
+	// are looking them up, so it is guarded by registeredMutex.
+	registered      = map[string]*Manifest{}
+	registeredMutex sync.RWMutex
+)
+
+// Register adds the plugin to the list of valid frameworks so it may be created by name.
+func Register(manifest *Manifest) (err error) {
+	registeredMutex.Lock()
+	defer registeredMutex.Unlock()
+
+	err = framework.AddValidFramework(manifest.Name)
+	if err != nil {
+		return
+	}
+
+	registered[manifest.Name] = manifest
+
+	return
+}
+
+// Lookup returns the manifest of the named plugin or nil if it has not been registered.
+func Lookup(name string) *Manifest {
+	registeredMutex.RLock()
+	defer registeredMutex.RUnlock()
+
+	return registered[name]
+}
+
+// Registered returns the manifests of all registered plugins sorted by name.
+func Registered() (manifests []*Manifest) {
+	registeredMutex.RLock()
+	for _, manifest := range registered {
+		manifests = append(manifests, manifest)
+	}
+	registeredMutex.RUnlock()
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].Name < manifests[j].Name
+	})
+
+	return
+}
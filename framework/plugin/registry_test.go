@@ -0,0 +1,46 @@
+package plugin
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/asmaloney/gactar/framework"
+)
+
+func TestConcurrentRegistration(t *testing.T) {
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("registry-test-%d", i)
+
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			err := Register(&Manifest{Name: name})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			_ = Lookup(name)
+			_ = Registered()
+			_ = framework.IsValidFramework(name)
+		}()
+	}
+
+	wg.Wait()
+
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("registry-test-%d", i)
+
+		if Lookup(name) == nil || !framework.IsValidFramework(name) {
+			t.Errorf("expected %q to be registered", name)
+		}
+	}
+}
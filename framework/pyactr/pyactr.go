@@ -3,10 +3,13 @@
 package pyactr
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/asmaloney/gactar/actr"
@@ -17,6 +20,7 @@ import (
 	"github.com/asmaloney/gactar/util/filesystem"
 	"github.com/asmaloney/gactar/util/issues"
 	"github.com/asmaloney/gactar/util/numbers"
+	"github.com/asmaloney/gactar/util/workspace"
 )
 
 //go:embed pyactr_print.py
@@ -29,16 +33,54 @@ var Info framework.Info = framework.Info{
 	ExecutableName: "python",
 
 	PythonRequiredPackages: []string{"pyactr"},
+
+	Capabilities: framework.Capabilities{
+		"gactar.log_level":               framework.Unsupported,
+		"gactar.random_seed":             framework.Supported,
+		"gactar.trace_activations":       framework.Supported,
+		"gactar.report_memory":           framework.Supported,
+		"memory.latency_factor":          framework.Supported,
+		"memory.latency_exponent":        framework.Supported,
+		"memory.retrieval_threshold":     framework.Supported,
+		"memory.finst_size":              framework.Supported,
+		"memory.finst_time":              framework.Unsupported,
+		"memory.decay":                   framework.Supported,
+		"memory.max_spread_strength":     framework.Supported,
+		"memory.instantaneous_noise":     framework.Supported,
+		"memory.mismatch_penalty":        framework.Supported,
+		"goal.spreading_activation":      framework.Supported,
+		"imaginal.delay":                 framework.Supported,
+		"imaginal.do_not_harvest":        framework.Supported,
+		"procedural.default_action_time": framework.Supported,
+		"module.imaginal":                framework.Emulated,
+		"module.extra_buffers":           framework.Emulated,
+		"similarities":                   framework.Supported,
+		"statement.clear":                framework.Supported,
+		"statement.print":                framework.Emulated,
+		"statement.print_multiple":       framework.Unsupported,
+		"statement.recall":               framework.Supported,
+		"statement.set":                  framework.Supported,
+		"statement.stop":                 framework.Emulated,
+	},
 }
 
+// PyACTR generates & runs code for the pyactr framework. It does not store any state
+// about a model, so it may be shared by goroutines.
 type PyACTR struct {
-	framework.Framework
-	framework.WriterHelper
-
 	tmpPath string
+}
+
+// generator holds the state used to generate the code for one model.
+type generator struct {
+	framework.WriterHelper
 
 	model     *actr.Model
 	className string
+
+	// instrument adds the output gactar uses to collect the events of a run (see
+	// framework.EventPrefix). It is only used when we run the model so it is not in the code
+	// we give to users.
+	instrument bool
 }
 
 // New simply creates a new PyACTR instance and sets the tmp path from the context.
@@ -61,76 +103,108 @@ func (PyACTR) Info() *framework.Info {
 func (PyACTR) ValidateModel(model *actr.Model) (log *issues.Log) {
 	log = issues.New()
 
-	if model.Memory.FinstTime != nil {
-		log.Warning(nil, "pyactr does not support memory module's finst_time")
-	}
-
-	for _, production := range model.Productions {
-		numPrintStatements := 0
-		if production.DoStatements != nil {
-			for _, statement := range production.DoStatements {
-				if statement.Print == nil {
-					continue
-				}
-
-				numPrintStatements++
-				if numPrintStatements > 1 {
-					location := issues.Location{
-						Line:        production.AMODLineNumber,
-						ColumnStart: 0,
-						ColumnEnd:   0,
-					}
-					log.Warning(&location, "pyactr currently only supports one print statement per production (in '%s')", production.Name)
-				}
-			}
-		}
-	}
+	framework.ValidateCapabilities(&Info, model, log)
 
 	return
 }
 
-func (p *PyACTR) SetModel(model *actr.Model) (err error) {
+// newGenerator creates a generator for the model and sets the python class name we are going to use.
+func newGenerator(model *actr.Model) (p *generator, err error) {
 	if model.Name == "" {
 		err = framework.ErrModelMissingName
 		return
 	}
 
-	p.model = model
-	p.className = fmt.Sprintf("pyactr_%s", p.model.Name)
+	p = &generator{
+		model:     model,
+		className: fmt.Sprintf("pyactr_%s", model.Name),
+	}
 
 	return
 }
 
-func (p PyACTR) Model() (model *actr.Model) {
-	return p.model
+func (p PyACTR) Run(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers) (result *framework.RunResult, err error) {
+	return p.RunStreaming(ctx, model, initialBuffers, nil)
 }
 
-func (p *PyACTR) Run(initialBuffers framework.InitialBuffers) (result *framework.RunResult, err error) {
-	runFile, err := p.WriteModel(p.tmpPath, initialBuffers)
+// RunStreaming is the same as Run, but it also writes the output line-by-line to "output" as it arrives.
+func (p PyACTR) RunStreaming(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers, output io.Writer) (result *framework.RunResult, err error) {
+	gen, err := newGenerator(model)
+	if err != nil {
+		return
+	}
+
+	workspacePath, err := workspace.Create(ctx, p.tmpPath)
+	if err != nil {
+		return
+	}
+	defer workspace.Release(workspacePath)
+
+	code, err := gen.generateCode(initialBuffers)
+	if err != nil {
+		return
+	}
+
+	gen.instrument = true
+
+	runFile, err := gen.writeModel(workspacePath, initialBuffers)
 	if err != nil {
 		return
 	}
 
 	result = &framework.RunResult{
 		FileName:      runFile,
-		GeneratedCode: p.GetContents(),
+		WorkspacePath: workspacePath,
+		GeneratedCode: code,
 	}
 
+	stream := framework.NewOutputStream(output, warningFilter)
+
 	// run it!
-	output, err := executil.ExecCommand(Info.ExecutableName, runFile)
-	output = removeWarning(output)
+	runOutput, err := executil.ExecCommandStream(ctx, stream, Info.ExecutableName, runFile)
+	stream.Close()
+
+	runOutput = removeWarning(runOutput)
+	result.SetOutput(model, runOutput)
+
+	// pyactr productions cannot output events, so we get them from the trace
+	result.Events.Fired, result.Events.Retrievals = parseTrace(string(result.Output))
+	result.UpdateStats(model)
+
 	if err != nil {
-		err = &executil.ErrExecuteCommand{Output: output}
+		if executil.IsInterrupted(err) {
+			return
+		}
+
+		err = &executil.ErrExecuteCommand{Output: string(result.Output)}
 		return
 	}
 
-	result.Output = []byte(output)
-
 	return
 }
 
-// WriteModel converts the internal actr.Model to Python and writes it to a file.
-func (p *PyACTR) WriteModel(path string, initialBuffers framework.InitialBuffers) (outputFileName string, err error) {
+// WriteModel converts the model to Python and writes it to a file.
+func (PyACTR) WriteModel(model *actr.Model, path string, initialBuffers framework.InitialBuffers) (outputFileName string, err error) {
+	gen, err := newGenerator(model)
+	if err != nil {
+		return
+	}
+
+	return gen.writeModel(path, initialBuffers)
+}
+
+// GenerateCode converts the model to Python code.
+func (PyACTR) GenerateCode(_ context.Context, model *actr.Model, initialBuffers framework.InitialBuffers) (code []byte, err error) {
+	gen, err := newGenerator(model)
+	if err != nil {
+		return
+	}
+
+	return gen.generateCode(initialBuffers)
+}
+
+// writeModel generates the code and writes it to a file in "path".
+func (p *generator) writeModel(path string, initialBuffers framework.InitialBuffers) (outputFileName string, err error) {
 	// If our model has a print statement, then write out our support file
 	if p.model.HasPrintStatement() {
 		err = writePrintSupportFile(path, "pyactr_print.py")
@@ -149,7 +223,7 @@ func (p *PyACTR) WriteModel(path string, initialBuffers framework.InitialBuffers
 		return "", err
 	}
 
-	_, err = p.GenerateCode(initialBuffers)
+	_, err = p.generateCode(initialBuffers)
 	if err != nil {
 		return
 	}
@@ -162,8 +236,8 @@ func (p *PyACTR) WriteModel(path string, initialBuffers framework.InitialBuffers
 	return
 }
 
-// GenerateCode converts the internal actr.Model to Python code.
-func (p *PyACTR) GenerateCode(initialBuffers framework.InitialBuffers) (code []byte, err error) {
+// generateCode converts the model to Python code.
+func (p *generator) generateCode(initialBuffers framework.InitialBuffers) (code []byte, err error) {
 	patterns, err := framework.ParseInitialBuffers(p.model, initialBuffers)
 	if err != nil {
 		return
@@ -329,7 +403,7 @@ func writePrintSupportFile(path, supportFileName string) (err error) {
 	return
 }
 
-func (p PyACTR) writeHeader() {
+func (p generator) writeHeader() {
 	p.Writeln("# Generated by gactar %s", framework.GactarVersion)
 	p.Writeln("#           on %s", framework.TimeNow().Format("2006-01-02 @ 15:04:05"))
 	p.Writeln("#   https://github.com/asmaloney/gactar")
@@ -344,7 +418,7 @@ func (p PyACTR) writeHeader() {
 	p.writeAuthors()
 }
 
-func (p PyACTR) writeAuthors() {
+func (p generator) writeAuthors() {
 	if len(p.model.Authors) == 0 {
 		return
 	}
@@ -358,7 +432,7 @@ func (p PyACTR) writeAuthors() {
 	p.Writeln("")
 }
 
-func (p PyACTR) writeImports() {
+func (p generator) writeImports() {
 	if p.model.RandomSeed != nil {
 		p.Writeln("import numpy")
 	}
@@ -371,7 +445,7 @@ func (p PyACTR) writeImports() {
 	}
 }
 
-func (p PyACTR) writeImplicitChunks() {
+func (p generator) writeImplicitChunks() {
 	if !p.model.HasImplicitChunks() {
 		return
 	}
@@ -385,7 +459,7 @@ func (p PyACTR) writeImplicitChunks() {
 	p.Writeln("")
 }
 
-func (p PyACTR) writeInitializers(goal *actr.Pattern) {
+func (p generator) writeInitializers(goal *actr.Pattern) {
 	p.writeImplicitChunks()
 
 	for _, init := range p.model.Initializers {
@@ -414,7 +488,7 @@ func (p PyACTR) writeInitializers(goal *actr.Pattern) {
 	p.Writeln("")
 }
 
-func (p PyACTR) writeSimilarities() {
+func (p generator) writeSimilarities() {
 	if len(p.model.Similarities) == 0 {
 		return
 	}
@@ -427,7 +501,7 @@ func (p PyACTR) writeSimilarities() {
 	p.Writeln("")
 }
 
-func (p PyACTR) writeProductions() {
+func (p generator) writeProductions() {
 	for _, production := range p.model.Productions {
 		if production.Description != nil {
 			p.Writeln("# %s", *production.Description)
@@ -452,17 +526,72 @@ func (p PyACTR) writeProductions() {
 	}
 }
 
-func (p PyACTR) writeMain() {
+func (p generator) writeMain() {
 	p.Writeln("# Main")
 	p.Writeln("if __name__ == '__main__':")
 	p.Writeln("    sim = %s.simulation()", p.className)
 	p.Writeln("    sim.run()")
+
+	if p.instrument {
+		p.writeFinalState()
+	}
+
 	// TODO: Add some intelligent output when logging level is info or detail
 	p.Writeln("    if goal.test_buffer('full') is True:")
 	p.Writeln("        print('final goal: ' + str(goal.pop()))")
 }
 
-func (p PyACTR) outputPattern(pattern *actr.Pattern, tabs int) {
+// writeFinalState outputs the end time, the contents of the buffers, and (optionally) the contents
+// of memory as events for gactar.
+func (p generator) writeFinalState() {
+	p.Writeln("")
+	p.Writeln("    # Output the final state for gactar")
+	p.Writeln("    print('%send', sim.show_time())", framework.EventPrefix)
+
+	slotLists := []string{}
+	for _, chunk := range p.model.Chunks {
+		if chunk.IsInternal() {
+			continue
+		}
+
+		slotLists = append(slotLists, fmt.Sprintf("'%s': ['%s']", chunk.TypeName, strings.Join(chunk.SlotNames, "', '")))
+	}
+
+	buffers := []string{}
+	for _, name := range p.model.BufferNames() {
+		if name == p.model.Memory.BufferName() {
+			buffers = append(buffers, fmt.Sprintf("'%s': %s.%s", name, p.className, name))
+		} else {
+			buffers = append(buffers, fmt.Sprintf("'%[1]s': %[1]s", name))
+		}
+	}
+
+	p.Writeln("    chunk_slots = {%s}", strings.Join(slotLists, ", "))
+	p.Writeln("")
+	p.Writeln("    def chunk_fields(chunk):")
+	p.Writeln("        fields = [chunk.typename]")
+	p.Writeln("        for slot in chunk_slots.get(chunk.typename, []):")
+	p.Writeln("            value = getattr(chunk, slot)")
+	p.Writeln("            # implicit chunks store their name in 'value'")
+	p.Writeln("            fields.append('%%s=%%s' %% (slot, getattr(value, 'value', value)))")
+	p.Writeln("        return fields")
+	p.Writeln("")
+	p.Writeln("    buffers = {%s}", strings.Join(buffers, ", "))
+	p.Writeln("    for name, buffer in buffers.items():")
+	p.Writeln("        if len(buffer) == 0:")
+	p.Writeln("            print('%sbuffer', name, 'nil')", framework.EventPrefix)
+	p.Writeln("        else:")
+	p.Writeln("            print('%sbuffer', name, *chunk_fields(next(iter(buffer))))", framework.EventPrefix)
+
+	if p.model.ReportMemory {
+		p.Writeln("    for chunk in %s:", p.model.Memory.ModuleName())
+		p.Writeln("        print('%smemory', *chunk_fields(chunk))", framework.EventPrefix)
+	}
+
+	p.Writeln("")
+}
+
+func (p generator) outputPattern(pattern *actr.Pattern, tabs int) {
 	tabbedItems := framework.KeyValueList{}
 	tabbedItems.Add("isa", pattern.Chunk.TypeName)
 
@@ -474,7 +603,7 @@ func (p PyACTR) outputPattern(pattern *actr.Pattern, tabs int) {
 	p.TabWrite(tabs, tabbedItems)
 }
 
-func (p PyACTR) outputMatch(match *actr.Match) {
+func (p generator) outputMatch(match *actr.Match) {
 	bufferName := match.Buffer.BufferName()
 	chunkName := match.Pattern.Chunk.TypeName
 
@@ -551,7 +680,7 @@ func addPatternSlot(tabbedItems *framework.KeyValueList, slotName string, slot *
 	}
 }
 
-func (p PyACTR) outputStatement(production *actr.Production, s *actr.Statement) {
+func (p generator) outputStatement(production *actr.Production, s *actr.Statement) {
 	switch {
 	case s.Set != nil:
 		buffer := s.Set.Buffer
@@ -629,6 +758,15 @@ func (p PyACTR) outputStatement(production *actr.Production, s *actr.Statement)
 	}
 }
 
+// warningFilter removes the warning from streamed output whenever pyactr is run without tkinter.
+func warningFilter(stream *framework.OutputStream, line string) {
+	if strings.Contains(line, "Simulation GUI is set to False.") {
+		return
+	}
+
+	stream.Emit(line)
+}
+
 // removeWarning will remove the long warning whenever pyactr is run without tkinter.
 func removeWarning(text string) string {
 	r := regexp.MustCompile(`(?s).+warnings.warn\("Simulation GUI is set to False."\)(.+)`)
@@ -639,3 +777,54 @@ func removeWarning(text string) string {
 
 	return text
 }
+
+// ruleFiredRegex matches the trace lines pyactr outputs when a production fires, e.g.:
+//
+//	(0.05, 'PROCEDURAL', 'RULE FIRED: initialRetrieval')
+var ruleFiredRegex = regexp.MustCompile(`^\(([0-9.eE+-]+), 'PROCEDURAL', 'RULE FIRED: ([^']+)'\)`)
+
+// retrievalRegex matches the trace lines pyactr outputs when a retrieval starts or finishes, e.g.:
+//
+//	(0.05, 'retrieval', 'START RETRIEVAL')
+//	(0.1, 'retrieval', 'RETRIEVED: property(attribute= category, object= shark, value= fish)')
+//	(1.05, 'retrieval', 'RETRIEVED: None')
+var retrievalRegex = regexp.MustCompile(`^\(([0-9.eE+-]+), '[^']+', '(START RETRIEVAL|RETRIEVED: .*|RETRIEVAL FAILED.*)'\)`)
+
+// parseTrace finds the productions which fired and the retrievals in pyactr's trace.
+func parseTrace(output string) (fired []framework.ProductionFired, retrievals []framework.Retrieval) {
+	var retrievalStart float64
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+
+		if match := ruleFiredRegex.FindStringSubmatch(line); match != nil {
+			time, err := strconv.ParseFloat(match[1], 64)
+			if err != nil {
+				continue
+			}
+
+			fired = append(fired, framework.ProductionFired{Time: time, Name: match[2]})
+			continue
+		}
+
+		if match := retrievalRegex.FindStringSubmatch(line); match != nil {
+			time, err := strconv.ParseFloat(match[1], 64)
+			if err != nil {
+				continue
+			}
+
+			if match[2] == "START RETRIEVAL" {
+				retrievalStart = time
+				continue
+			}
+
+			retrievals = append(retrievals, framework.Retrieval{
+				Time:    time,
+				Latency: time - retrievalStart,
+				Success: match[2] != "RETRIEVED: None" && !strings.HasPrefix(match[2], "RETRIEVAL FAILED"),
+			})
+		}
+	}
+
+	return
+}
@@ -103,6 +103,9 @@ def print_text(*args):
 
     print(output)
 
+    # Let gactar know what was printed
+    print('@gactar print ' + output)
+
 
 # Monkey patch Buffer to add a new methods.
 Buffer.get_slot_contents = get_slot_contents
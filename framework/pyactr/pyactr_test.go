@@ -3,6 +3,7 @@ package pyactr
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/kylelemons/godebug/diff"
 
+	"github.com/asmaloney/gactar/amod"
 	"github.com/asmaloney/gactar/framework"
 	"github.com/asmaloney/gactar/util/cli"
 )
@@ -84,3 +86,78 @@ func runCodeGenerationTest(t *testing.T, fw framework.Framework, input, output s
 		t.Errorf("code does not match %s file:\n%s", output, diffs)
 	}
 }
+
+// TestConcurrentCodeGeneration checks that one instance may generate code on several goroutines.
+// It does not need the framework's executable, so it runs even if the framework is not active.
+func TestConcurrentCodeGeneration(t *testing.T) {
+	fw := &PyACTR{}
+
+	_, err := framework.GenerateCodeConcurrently(fw, "../testdata/semantic.amod", framework.InitialBuffers{}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTrace(t *testing.T) {
+	trace := `(0, 'PROCEDURAL', 'CONFLICT RESOLUTION')
+(0, 'PROCEDURAL', 'RULE SELECTED: initialRetrieval')
+(0.05, 'PROCEDURAL', 'RULE FIRED: initialRetrieval')
+(0.05, 'retrieval', 'START RETRIEVAL')
+(0.1, 'retrieval', 'RETRIEVED: property(attribute= category, object= shark, value= fish)')
+(0.15, 'PROCEDURAL', 'RULE FIRED: chainCategory')
+(0.15, 'retrieval', 'START RETRIEVAL')
+(1.15, 'retrieval', 'RETRIEVED: None')
+`
+
+	fired, retrievals := parseTrace(trace)
+
+	if len(fired) != 2 || fired[0].Name != "initialRetrieval" || fired[1].Name != "chainCategory" || fired[1].Time != 0.15 {
+		t.Errorf("incorrect fired productions: %v", fired)
+	}
+
+	if len(retrievals) != 2 {
+		t.Fatalf("expected 2 retrievals, got %v", retrievals)
+	}
+
+	if !retrievals[0].Success || retrievals[0].Time != 0.1 || math.Abs(retrievals[0].Latency-0.05) > 1e-9 {
+		t.Errorf("incorrect first retrieval: %v", retrievals[0])
+	}
+
+	if retrievals[1].Success || math.Abs(retrievals[1].Latency-1.0) > 1e-9 {
+		t.Errorf("incorrect second retrieval: %v", retrievals[1])
+	}
+}
+
+// TestInstrumentation checks that the output gactar uses to collect the events of a run is only
+// added when we run the model.
+func TestInstrumentation(t *testing.T) {
+	model, _, err := amod.GenerateModelFromFile("../testdata/semantic.amod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen, err := newGenerator(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := gen.generateCode(framework.InitialBuffers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(code, []byte(framework.EventPrefix)) {
+		t.Errorf("expected generated code not to output events")
+	}
+
+	gen.instrument = true
+
+	code, err = gen.generateCode(framework.InitialBuffers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(code, []byte(framework.EventPrefix+"end")) {
+		t.Errorf("expected the code we run to output events")
+	}
+}
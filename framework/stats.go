@@ -0,0 +1,155 @@
+package framework
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/asmaloney/gactar/actr"
+)
+
+// ProductionStats counts the firings of one production.
+type ProductionStats struct {
+	Name       string   `json:"name"`
+	Count      int      `json:"count"`                // number of times it fired
+	FirstFired *float64 `json:"firstFired,omitempty"` // simulated time it first fired (nil if it did not fire)
+	LastFired  *float64 `json:"lastFired,omitempty"`  // simulated time it last fired (nil if it did not fire)
+}
+
+// LatencyStats summarizes the latencies (in seconds) of a set of retrievals.
+type LatencyStats struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+}
+
+// RetrievalStats counts the retrievals from declarative memory.
+type RetrievalStats struct {
+	Successes      int           `json:"successes"`
+	Failures       int           `json:"failures"`
+	SuccessLatency *LatencyStats `json:"successLatency,omitempty"` // nil if there were no successes
+	FailureLatency *LatencyStats `json:"failureLatency,omitempty"` // nil if there were no failures
+}
+
+// RunStats are statistics about a run calculated from its events.
+type RunStats struct {
+	Productions []ProductionStats `json:"productions"` // in the order they are declared in the model
+	Retrievals  RetrievalStats    `json:"retrievals"`
+}
+
+// NewRunStats calculates the statistics of a run of the model from its events. Every production in
+// the model is included even if it did not fire.
+func NewRunStats(model *actr.Model, events *Events) (stats *RunStats) {
+	stats = &RunStats{}
+
+	if events == nil {
+		events = &Events{}
+	}
+
+	indices := map[string]int{}
+
+	lookup := func(name string) *ProductionStats {
+		key := strings.ToLower(name) // some frameworks change the case of names
+		index, ok := indices[key]
+		if !ok {
+			index = len(stats.Productions)
+			indices[key] = index
+			stats.Productions = append(stats.Productions, ProductionStats{Name: name})
+		}
+
+		return &stats.Productions[index]
+	}
+
+	if model != nil {
+		for _, production := range model.Productions {
+			lookup(production.Name)
+		}
+	}
+
+	for _, fired := range events.Fired {
+		production := lookup(fired.Name)
+		production.Count++
+
+		time := fired.Time
+		if production.FirstFired == nil {
+			production.FirstFired = &time
+		}
+		production.LastFired = &time
+	}
+
+	successes := []float64{}
+	failures := []float64{}
+
+	for _, retrieval := range events.Retrievals {
+		if retrieval.Success {
+			successes = append(successes, retrieval.Latency)
+		} else {
+			failures = append(failures, retrieval.Latency)
+		}
+	}
+
+	stats.Retrievals = RetrievalStats{
+		Successes:      len(successes),
+		Failures:       len(failures),
+		SuccessLatency: newLatencyStats(successes),
+		FailureLatency: newLatencyStats(failures),
+	}
+
+	return
+}
+
+func newLatencyStats(latencies []float64) *LatencyStats {
+	if len(latencies) == 0 {
+		return nil
+	}
+
+	stats := &LatencyStats{
+		Min: math.Inf(1),
+		Max: math.Inf(-1),
+	}
+
+	sum := 0.0
+	for _, latency := range latencies {
+		sum += latency
+		stats.Min = math.Min(stats.Min, latency)
+		stats.Max = math.Max(stats.Max, latency)
+	}
+
+	stats.Mean = sum / float64(len(latencies))
+
+	return stats
+}
+
+// Write outputs the statistics as a table.
+func (s RunStats) Write(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "production\tfired\tfirst\tlast")
+
+	for _, production := range s.Productions {
+		first, last := "-", "-"
+		if production.FirstFired != nil {
+			first = fmt.Sprintf("%.3f", *production.FirstFired)
+			last = fmt.Sprintf("%.3f", *production.LastFired)
+		}
+
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", production.Name, production.Count, first, last)
+	}
+
+	tw.Flush()
+
+	fmt.Fprintf(w, "retrievals: %d succeeded%s, %d failed%s\n",
+		s.Retrievals.Successes, s.Retrievals.SuccessLatency.summary(),
+		s.Retrievals.Failures, s.Retrievals.FailureLatency.summary())
+}
+
+// summary returns the latencies in a form suitable for appending to a count.
+func (l *LatencyStats) summary() string {
+	if l == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(" (latency mean %.3f min %.3f max %.3f)", l.Mean, l.Min, l.Max)
+}
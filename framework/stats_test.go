@@ -0,0 +1,51 @@
+package framework
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/asmaloney/gactar/actr"
+)
+
+func TestRunStats(t *testing.T) {
+	model := &actr.Model{
+		Productions: []*actr.Production{
+			{Name: "start"},
+			{Name: "increment"},
+			{Name: "unused"},
+		},
+	}
+
+	events, _ := ParseEvents(`@gactar fired 0.050 START
+@gactar retrieval 0.100 0.050 success
+@gactar fired 0.150 increment
+@gactar retrieval 0.200 0.050 success
+@gactar fired 0.250 increment
+@gactar retrieval 1.250 1.000 failure
+@gactar retrieval 1.400 0.100 success
+`)
+
+	stats := NewRunStats(model, events)
+
+	var output strings.Builder
+	stats.Write(&output)
+
+	expected := `production  fired  first  last
+start       1      0.050  0.050
+increment   2      0.150  0.250
+unused      0      -      -
+retrievals: 3 succeeded (latency mean 0.067 min 0.050 max 0.100), 1 failed (latency mean 1.000 min 1.000 max 1.000)
+`
+
+	if output.String() != expected {
+		t.Errorf("incorrect statistics:\n%s", output.String())
+	}
+}
+
+func TestRunStatsNoEvents(t *testing.T) {
+	stats := NewRunStats(nil, nil)
+
+	if len(stats.Productions) != 0 || stats.Retrievals.Successes != 0 || stats.Retrievals.SuccessLatency != nil {
+		t.Errorf("expected empty statistics, got %+v", stats)
+	}
+}
@@ -1,10 +1,13 @@
 package framework
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 
 	"github.com/asmaloney/gactar/actr"
 	"github.com/asmaloney/gactar/amod"
@@ -19,35 +22,83 @@ import (
 // Reads a file, generates a model, validates it, and generates code from it for a given framework.
 // This is useful for testing.
 func GenerateCodeFromFile(fw Framework, inputFile string, initialBuffers InitialBuffers) (code []byte, err error) {
-	amodCode, err := os.ReadFile(inputFile)
+	model, err := validModelFromFile(fw, inputFile)
 	if err != nil {
 		return
 	}
 
-	model, log, err := amod.GenerateModel(string(amodCode))
+	code, err = fw.GenerateCode(context.Background(), model, initialBuffers)
 	if err != nil {
-		fmt.Print(log)
 		return
 	}
 
-	log = fw.ValidateModel(model)
-	if log.HasIssues() {
-		if log.HasError() {
-			err = &ErrModelValidationFailed{Log: log}
-			return
+	return
+}
+
+// GenerateCodeConcurrently is like GenerateCodeFromFile, but it generates the code "count" times at once
+// using the same framework. It returns an error if the results differ. This is useful for testing (with
+// the race detector) that a framework may be shared by goroutines.
+func GenerateCodeConcurrently(fw Framework, inputFile string, initialBuffers InitialBuffers, count int) (code []byte, err error) {
+	model, err := validModelFromFile(fw, inputFile)
+	if err != nil {
+		return
+	}
+
+	codes := make([][]byte, count)
+	errs := make([]error, count)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			codes[i], errs[i] = fw.GenerateCode(context.Background(), model, initialBuffers)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := range codes {
+		if errs[i] != nil {
+			return nil, errs[i]
 		}
+
+		if !bytes.Equal(codes[i], codes[0]) {
+			return nil, ErrConcurrentCodeMismatch
+		}
+	}
+
+	if count > 0 {
+		code = codes[0]
 	}
 
-	err = fw.SetModel(model)
+	return
+}
+
+// validModelFromFile reads a file, generates a model, and validates it using the framework.
+func validModelFromFile(fw Framework, inputFile string) (model *actr.Model, err error) {
+	amodCode, err := os.ReadFile(inputFile)
 	if err != nil {
 		return
 	}
 
-	code, err = fw.GenerateCode(initialBuffers)
+	model, log, err := amod.GenerateModel(string(amodCode))
 	if err != nil {
+		fmt.Print(log)
 		return
 	}
 
+	log = fw.ValidateModel(model)
+	if log.HasIssues() {
+		if log.HasError() {
+			err = &ErrModelValidationFailed{Log: log}
+			return
+		}
+	}
+
 	return
 }
 
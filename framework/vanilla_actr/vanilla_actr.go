@@ -3,7 +3,9 @@
 package vanilla_actr
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"runtime"
@@ -18,6 +20,7 @@ import (
 	"github.com/asmaloney/gactar/util/issues"
 	"github.com/asmaloney/gactar/util/lisp"
 	"github.com/asmaloney/gactar/util/numbers"
+	"github.com/asmaloney/gactar/util/workspace"
 )
 
 func init() {
@@ -43,15 +46,55 @@ var Info framework.Info = framework.Info{
 	Language:      "commonlisp",
 	FileExtension: "lisp",
 	// ExecutableName: have to set this in init()
+
+	Capabilities: framework.Capabilities{
+		"gactar.log_level":               framework.Supported,
+		"gactar.random_seed":             framework.Supported,
+		"gactar.trace_activations":       framework.Supported,
+		"gactar.report_memory":           framework.Supported,
+		"memory.latency_factor":          framework.Supported,
+		"memory.latency_exponent":        framework.Supported,
+		"memory.retrieval_threshold":     framework.Supported,
+		"memory.finst_size":              framework.Supported,
+		"memory.finst_time":              framework.Supported,
+		"memory.decay":                   framework.Supported,
+		"memory.max_spread_strength":     framework.Supported,
+		"memory.instantaneous_noise":     framework.Supported,
+		"memory.mismatch_penalty":        framework.Supported,
+		"goal.spreading_activation":      framework.Supported,
+		"imaginal.delay":                 framework.Supported,
+		"imaginal.do_not_harvest":        framework.Supported,
+		"procedural.default_action_time": framework.Supported,
+		"module.imaginal":                framework.Supported,
+		"module.extra_buffers":           framework.Emulated,
+		"similarities":                   framework.Supported,
+		"statement.clear":                framework.Supported,
+		"statement.print":                framework.Supported,
+		"statement.print_multiple":       framework.Supported,
+		"statement.recall":               framework.Supported,
+		"statement.set":                  framework.Supported,
+		"statement.stop":                 framework.Supported,
+	},
 }
 
+// VanillaACTR generates & runs code for the vanilla ACT-R framework. It does not store any state
+// about a model, so it may be shared by goroutines.
 type VanillaACTR struct {
-	framework.Framework
+	tmpPath string
+	envPath string
+}
+
+// generator holds the state used to generate the code for one model.
+type generator struct {
 	framework.WriterHelper
+
 	model     *actr.Model
 	modelName string
-	tmpPath   string
-	envPath   string
+
+	// instrument adds the output gactar uses to collect the events of a run (see
+	// framework.EventPrefix). It is only used when we run the model so it is not in the code
+	// we give to users.
+	instrument bool
 }
 
 // New simply creates a new VanillaACTR instance and sets some paths from the context.
@@ -76,38 +119,63 @@ func (VanillaACTR) Info() *framework.Info {
 
 func (VanillaACTR) ValidateModel(model *actr.Model) (log *issues.Log) {
 	log = issues.New()
+
+	framework.ValidateCapabilities(&Info, model, log)
+
 	return
 }
 
-func (v *VanillaACTR) SetModel(model *actr.Model) (err error) {
+// newGenerator creates a generator for the model and sets the lisp model name we are going to use.
+func newGenerator(model *actr.Model) (v *generator, err error) {
 	if model.Name == "" {
 		err = framework.ErrModelMissingName
 		return
 	}
 
-	v.model = model
-	v.modelName = fmt.Sprintf("vanilla_%s", v.model.Name)
+	v = &generator{
+		model:     model,
+		modelName: fmt.Sprintf("vanilla_%s", model.Name),
+	}
 
 	return
 }
 
-func (c VanillaACTR) Model() (model *actr.Model) {
-	return c.model
+func (v VanillaACTR) Run(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers) (result *framework.RunResult, err error) {
+	return v.RunStreaming(ctx, model, initialBuffers, nil)
 }
 
-func (v *VanillaACTR) Run(initialBuffers framework.InitialBuffers) (result *framework.RunResult, err error) {
-	modelFile, err := v.WriteModel(v.tmpPath, initialBuffers)
+// RunStreaming is the same as Run, but it also writes the output line-by-line to "output" as it arrives.
+func (v VanillaACTR) RunStreaming(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers, output io.Writer) (result *framework.RunResult, err error) {
+	gen, err := newGenerator(model)
+	if err != nil {
+		return
+	}
+
+	workspacePath, err := workspace.Create(ctx, v.tmpPath)
+	if err != nil {
+		return
+	}
+	defer workspace.Release(workspacePath)
+
+	code, err := gen.generateCode(initialBuffers)
+	if err != nil {
+		return
+	}
+
+	gen.instrument = true
+
+	modelFile, err := gen.writeModel(workspacePath, initialBuffers)
 	if err != nil {
 		return
 	}
 
-	// Save the current code for our result
 	result = &framework.RunResult{
 		FileName:      modelFile,
-		GeneratedCode: v.GetContents(),
+		WorkspacePath: workspacePath,
+		GeneratedCode: code,
 	}
 
-	runFile, err := v.createRunFile(modelFile)
+	runFile, err := gen.createRunFile(v.envPath, workspacePath, modelFile)
 	if err != nil {
 		return
 	}
@@ -117,21 +185,49 @@ func (v *VanillaACTR) Run(initialBuffers framework.InitialBuffers) (result *fram
 		return
 	}
 
+	stream := framework.NewOutputStream(output, preambleFilter())
+
 	// run it!
-	output, err := executil.ExecCommand(Info.ExecutableName, "--batch", "--quiet", "--load", runFile)
-	output = removePreamble(output)
+	runOutput, err := executil.ExecCommandStream(ctx, stream, Info.ExecutableName, "--batch", "--quiet", "--load", runFile)
+	stream.Close()
+
+	runOutput = removePreamble(runOutput)
+	result.SetOutput(model, runOutput)
+
 	if err != nil {
-		err = &executil.ErrExecuteCommand{Output: output}
+		if executil.IsInterrupted(err) {
+			return
+		}
+
+		err = &executil.ErrExecuteCommand{Output: string(result.Output)}
 		return
 	}
 
-	result.Output = []byte(output)
-
 	return
 }
 
-// WriteModel converts the internal actr.Model to Lisp and writes it to a file.
-func (v *VanillaACTR) WriteModel(path string, initialBuffers framework.InitialBuffers) (outputFileName string, err error) {
+// WriteModel converts the model to Lisp and writes it to a file.
+func (VanillaACTR) WriteModel(model *actr.Model, path string, initialBuffers framework.InitialBuffers) (outputFileName string, err error) {
+	gen, err := newGenerator(model)
+	if err != nil {
+		return
+	}
+
+	return gen.writeModel(path, initialBuffers)
+}
+
+// GenerateCode converts the model to Lisp code.
+func (VanillaACTR) GenerateCode(_ context.Context, model *actr.Model, initialBuffers framework.InitialBuffers) (code []byte, err error) {
+	gen, err := newGenerator(model)
+	if err != nil {
+		return
+	}
+
+	return gen.generateCode(initialBuffers)
+}
+
+// writeModel generates the code and writes it to a file in "path".
+func (v *generator) writeModel(path string, initialBuffers framework.InitialBuffers) (outputFileName string, err error) {
 	outputFileName = fmt.Sprintf("%s.lisp", v.modelName)
 	if path != "" {
 		outputFileName = fmt.Sprintf("%s/%s", path, outputFileName)
@@ -142,7 +238,7 @@ func (v *VanillaACTR) WriteModel(path string, initialBuffers framework.InitialBu
 		return "", err
 	}
 
-	_, err = v.GenerateCode(initialBuffers)
+	_, err = v.generateCode(initialBuffers)
 	if err != nil {
 		return
 	}
@@ -155,8 +251,8 @@ func (v *VanillaACTR) WriteModel(path string, initialBuffers framework.InitialBu
 	return
 }
 
-// GenerateCode converts the internal actr.Model to Lisp code.
-func (v *VanillaACTR) GenerateCode(initialBuffers framework.InitialBuffers) (code []byte, err error) {
+// generateCode converts the model to Lisp code.
+func (v *generator) generateCode(initialBuffers framework.InitialBuffers) (code []byte, err error) {
 	patterns, err := framework.ParseInitialBuffers(v.model, initialBuffers)
 	if err != nil {
 		return
@@ -262,7 +358,10 @@ func (v *VanillaACTR) GenerateCode(initialBuffers framework.InitialBuffers) (cod
 
 	imaginal := v.model.ImaginalModule()
 	if imaginal != nil {
-		v.Writeln("\t:do-not-harvest imaginal")
+		if Info.Capabilities.Support("imaginal.do_not_harvest") == framework.Supported {
+			v.Writeln("\t:do-not-harvest imaginal")
+		}
+
 		if imaginal.Delay != nil {
 			v.Writeln("\t:imaginal-delay %s", numbers.Float64Str(*imaginal.Delay))
 		}
@@ -303,7 +402,7 @@ func (v *VanillaACTR) GenerateCode(initialBuffers framework.InitialBuffers) (cod
 	return
 }
 
-func (v VanillaACTR) writeHeader() {
+func (v generator) writeHeader() {
 	v.Writeln(";;; Generated by gactar %s", framework.GactarVersion)
 	v.Writeln(";;;           on %s", framework.TimeNow().Format("2006-01-02 @ 15:04:05"))
 	v.Writeln(";;;   https://github.com/asmaloney/gactar")
@@ -318,7 +417,7 @@ func (v VanillaACTR) writeHeader() {
 	v.writeAuthors()
 }
 
-func (v VanillaACTR) writeAuthors() {
+func (v generator) writeAuthors() {
 	if len(v.model.Authors) == 0 {
 		return
 	}
@@ -332,7 +431,7 @@ func (v VanillaACTR) writeAuthors() {
 	v.Writeln("")
 }
 
-func (v VanillaACTR) writeImplicitChunks() {
+func (v generator) writeImplicitChunks() {
 	if !v.model.HasImplicitChunks() {
 		return
 	}
@@ -347,7 +446,7 @@ func (v VanillaACTR) writeImplicitChunks() {
 	v.Writeln("\n")
 }
 
-func (v VanillaACTR) writeBufferInitializer(bufferName string, lineNumber int, pattern *actr.Pattern) {
+func (v generator) writeBufferInitializer(bufferName string, lineNumber int, pattern *actr.Pattern) {
 	v.Writeln(";; initialize our %q buffer", bufferName)
 	if lineNumber != 0 {
 		v.Writeln(";; amod line %d", lineNumber)
@@ -358,7 +457,7 @@ func (v VanillaACTR) writeBufferInitializer(bufferName string, lineNumber int, p
 	v.Writeln("")
 }
 
-func (v VanillaACTR) writeInitializers(goal *actr.Pattern) {
+func (v generator) writeInitializers(goal *actr.Pattern) {
 	// First write out our declarative memory
 	v.Writeln(";; initialize our declarative memory")
 	v.Writeln("(add-dm")
@@ -420,7 +519,7 @@ func (v VanillaACTR) writeInitializers(goal *actr.Pattern) {
 	}
 }
 
-func (v VanillaACTR) writeSimilarities() {
+func (v generator) writeSimilarities() {
 	if len(v.model.Similarities) == 0 {
 		return
 	}
@@ -435,7 +534,7 @@ func (v VanillaACTR) writeSimilarities() {
 	v.Writeln(")\n")
 }
 
-func (v VanillaACTR) writeProductions() {
+func (v generator) writeProductions() {
 	for _, production := range v.model.Productions {
 		v.Writeln(";; amod line %d", production.AMODLineNumber)
 
@@ -450,6 +549,10 @@ func (v VanillaACTR) writeProductions() {
 
 		v.Writeln("\t==>")
 
+		if v.instrument {
+			v.Writeln("\t!eval!\t(format t \"%sfired ~,3F ~A~%%\" (mp-time) \"%s\")", framework.EventPrefix, production.Name)
+		}
+
 		if production.DoStatements != nil {
 			for _, statement := range production.DoStatements {
 				v.outputStatement(statement)
@@ -460,7 +563,7 @@ func (v VanillaACTR) writeProductions() {
 	}
 }
 
-func (v VanillaACTR) outputPattern(pattern *actr.Pattern, tabs int) {
+func (v generator) outputPattern(pattern *actr.Pattern, tabs int) {
 	tabbedItems := framework.KeyValueList{}
 	tabbedItems.Add("isa", pattern.Chunk.TypeName)
 
@@ -472,7 +575,7 @@ func (v VanillaACTR) outputPattern(pattern *actr.Pattern, tabs int) {
 	v.TabWrite(tabs, tabbedItems)
 }
 
-func (v VanillaACTR) outputMatch(match *actr.Match) {
+func (v generator) outputMatch(match *actr.Match) {
 	bufferName := match.Buffer.BufferName()
 	chunkTypeName := match.Pattern.Chunk.TypeName
 
@@ -552,7 +655,7 @@ func addPatternSlot(tabbedItems *framework.KeyValueList, slotName string, slot *
 	}
 }
 
-func (v VanillaACTR) outputStatement(s *actr.Statement) {
+func (v generator) outputStatement(s *actr.Statement) {
 	switch {
 	case s.Set != nil:
 		buffer := s.Set.Buffer
@@ -596,6 +699,12 @@ func (v VanillaACTR) outputStatement(s *actr.Statement) {
 		outputArgs := createOutputArgs(s.Print.Values)
 		v.Write("\t!output!\t(%s)\n", outputArgs)
 
+		// Let gactar know what was printed
+		if v.instrument {
+			format, args := outputFormat(s.Print.Values)
+			v.Write("\t!eval!\t(format t \"%sprint %s~%%\"%s)\n", framework.EventPrefix, format, strings.Join(args, ""))
+		}
+
 	case s.Clear != nil:
 		for _, name := range s.Clear.BufferNames {
 			v.Writeln("\t-%s>", name)
@@ -611,57 +720,61 @@ func (v VanillaACTR) outputStatement(s *actr.Statement) {
 //
 //	ACT-R 7.21+ Reference Manual pg. 235
 func createOutputArgs(values *[]*actr.Value) string {
-	formatStr := `"`
-	args := []string{}
+	format, args := outputFormat(values)
 
+	formatStr := `"` + format + `"` + strings.Join(args, "")
+
+	var argStr string
+	if len(args) > 0 {
+		argStr += " "
+	}
+
+	return formatStr + argStr
+}
+
+// outputFormat creates a lisp format string (without quotes) for the values and a list of
+// the variables to use as its arguments (each with a leading space).
+func outputFormat(values *[]*actr.Value) (format string, args []string) {
 	for _, v := range *values {
 		switch {
 		case v.Var != nil:
-			formatStr += "~a"
+			format += "~a"
 			varName := strings.TrimPrefix(*v.Var, "?")
-			args = append(args, fmt.Sprintf("=%s", varName))
+			args = append(args, fmt.Sprintf(" =%s", varName))
 
 		case v.Str != nil:
-			formatStr += *v.Str
+			format += *v.Str
 
 		case v.Number != nil:
-			formatStr += *v.Number
+			format += *v.Number
 		}
 		// v.ID should not be possible because of validation
 	}
 
-	formatStr += `"`
-
-	var argStr string
-	if len(args) > 0 {
-		argStr += " "
-
-		for _, arg := range args {
-			formatStr += " "
-			formatStr += arg
-		}
-	}
-
-	return formatStr + argStr
+	return
 }
 
-// createRunFile creates a lisp program to load ACTR and our model and then run them.
-func (v VanillaACTR) createRunFile(modelFile string) (outputFile string, err error) {
+// createRunFile creates a lisp program in "path" to load ACTR and our model and then run them.
+func (v *generator) createRunFile(envPath, path, modelFile string) (outputFile string, err error) {
 	err = v.InitWriterHelper()
 	if err != nil {
 		return
 	}
 
-	v.Writeln(`(load "%s/actr/load-single-threaded-act-r.lisp")`, v.envPath)
+	v.Writeln(`(load "%s/actr/load-single-threaded-act-r.lisp")`, envPath)
 	v.Writeln(`(load "%s")`, modelFile)
 
+	v.writeRetrievalHook()
+
 	// TODO: We should be able to set this somewhere.
 	// 10.0 is an arbitrary length of time.
 	v.Writeln(`(run 10.0)`)
 
+	v.writeFinalState()
+
 	outputFile = fmt.Sprintf("%s_run.lisp", v.modelName)
-	if v.tmpPath != "" {
-		outputFile = fmt.Sprintf("%s/%s", v.tmpPath, outputFile)
+	if path != "" {
+		outputFile = fmt.Sprintf("%s/%s", path, outputFile)
 	}
 
 	err = v.WriteFile(outputFile)
@@ -672,9 +785,78 @@ func (v VanillaACTR) createRunFile(modelFile string) (outputFile string, err err
 	return
 }
 
+// writeRetrievalHook adds an event hook to output an event for gactar when each retrieval finishes.
+func (v *generator) writeRetrievalHook() {
+	v.Writeln("")
+	v.Writeln(";; Report retrievals to gactar")
+	v.Writeln("(defvar *gactar-retrieval-start* 0)")
+	v.Writeln("(defun gactar-retrieval-hook (event)")
+	v.Writeln(`  (let ((action (format nil "~A" (evt-action event)))`)
+	v.Writeln("        (time (evt-time event)))")
+	v.Writeln(`    (cond ((string-equal action "start-retrieval")`)
+	v.Writeln("           (setf *gactar-retrieval-start* time))")
+	v.Writeln(`          ((string-equal action "retrieved-chunk")`)
+	v.Writeln(`           (format t "%sretrieval ~,3F ~,3F success~%%" time (- time *gactar-retrieval-start*)))`, framework.EventPrefix)
+	v.Writeln(`          ((string-equal action "retrieval-failure")`)
+	v.Writeln(`           (format t "%sretrieval ~,3F ~,3F failure~%%" time (- time *gactar-retrieval-start*))))))`, framework.EventPrefix)
+	v.Writeln("(add-post-event-hook 'gactar-retrieval-hook)")
+	v.Writeln("")
+}
+
+// writeFinalState outputs the end time, the contents of the buffers, and (optionally) the contents
+// of memory as events for gactar.
+// ACT-R does not keep track of chunk types, so we can only output the slots.
+func (v *generator) writeFinalState() {
+	v.Writeln("")
+	v.Writeln(";; Output the final state for gactar")
+	v.Writeln(`(format t "%send ~,3F~%%" (mp-time))`, framework.EventPrefix)
+	v.Writeln("(dolist (name '(%s))", strings.Join(v.model.BufferNames(), " "))
+	v.Writeln("  (let ((chunk (buffer-read name)))")
+	v.Writeln("    (if chunk")
+	v.Writeln(`        (format t "%sbuffer ~(~A~) -~{ ~A~}~%%" name`, framework.EventPrefix)
+	v.Writeln("                (mapcar (lambda (slot)")
+	v.Writeln("                          (let ((value (chunk-slot-value-fct chunk slot)))")
+	v.Writeln(`                            (format nil "~(~A~)=~A" slot (if (eq value 'empty) "nil" value))))`)
+	v.Writeln("                        (chunk-filled-slots-list chunk)))")
+	v.Writeln(`        (format t "%sbuffer ~(~A~) nil~%%" name))))`, framework.EventPrefix)
+
+	if v.model.ReportMemory {
+		v.Writeln("(dolist (chunk (no-output (sdm)))")
+		v.Writeln(`  (format t "%smemory -~{ ~A~}~%%"`, framework.EventPrefix)
+		v.Writeln("          (mapcar (lambda (slot)")
+		v.Writeln("                    (let ((value (chunk-slot-value-fct chunk slot)))")
+		v.Writeln(`                      (format nil "~(~A~)=~A" slot (if (eq value 'empty) "nil" value))))`)
+		v.Writeln("                  (chunk-filled-slots-list chunk))))")
+	}
+}
+
+// preambleEnd is the last line of the preamble which is output whenever ACT-R is loaded.
+const preambleEnd = "######### This is a single threaded build #########"
+
+// preambleFilter holds back streamed lines until the end of the preamble and then discards them.
+// If we never see the end of the preamble, the lines are output when the stream is closed.
+func preambleFilter() func(stream *framework.OutputStream, line string) {
+	inPreamble := true
+
+	return func(stream *framework.OutputStream, line string) {
+		if !inPreamble {
+			stream.Emit(line)
+			return
+		}
+
+		if strings.Contains(line, preambleEnd) {
+			inPreamble = false
+			stream.DiscardHeld()
+			return
+		}
+
+		stream.Hold(line)
+	}
+}
+
 // removePreamble will remove the long preamble whenever ACT-R is loaded.
 func removePreamble(text string) string {
-	r := regexp.MustCompile(`(?s).+######### This is a single threaded build #########(.+)`)
+	r := regexp.MustCompile(`(?s).+` + preambleEnd + `(.+)`)
 	matches := r.FindAllStringSubmatch(text, -1)
 	if len(matches) == 1 {
 		text = strings.TrimSpace(matches[0][1])
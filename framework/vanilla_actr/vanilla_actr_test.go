@@ -10,6 +10,7 @@ import (
 
 	"github.com/kylelemons/godebug/diff"
 
+	"github.com/asmaloney/gactar/amod"
 	"github.com/asmaloney/gactar/framework"
 	"github.com/asmaloney/gactar/util/cli"
 )
@@ -83,3 +84,48 @@ func runCodeGenerationTest(t *testing.T, fw framework.Framework, input, output s
 		t.Errorf("code does not match %s file:\n%s", output, diffs)
 	}
 }
+
+// TestConcurrentCodeGeneration checks that one instance may generate code on several goroutines.
+// It does not need the framework's executable, so it runs even if the framework is not active.
+func TestConcurrentCodeGeneration(t *testing.T) {
+	fw := &VanillaACTR{}
+
+	_, err := framework.GenerateCodeConcurrently(fw, "../testdata/semantic.amod", framework.InitialBuffers{}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestInstrumentation checks that the output gactar uses to collect the events of a run is only
+// added when we run the model.
+func TestInstrumentation(t *testing.T) {
+	model, _, err := amod.GenerateModelFromFile("../testdata/semantic.amod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen, err := newGenerator(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := gen.generateCode(framework.InitialBuffers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(code, []byte(framework.EventPrefix)) {
+		t.Errorf("expected generated code not to output events")
+	}
+
+	gen.instrument = true
+
+	code, err = gen.generateCode(framework.InitialBuffers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(code, []byte(framework.EventPrefix+"fired")) {
+		t.Errorf("expected the code we run to output events")
+	}
+}
@@ -1,15 +1,20 @@
 package defaultmode
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"sort"
+	"sync"
 
 	"github.com/asmaloney/gactar/actr"
 	"github.com/asmaloney/gactar/amod"
 	"github.com/asmaloney/gactar/framework"
 	"github.com/asmaloney/gactar/util/chalk"
 	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/executil"
 	"github.com/asmaloney/gactar/util/filesystem"
 	"github.com/asmaloney/gactar/util/validate"
 )
@@ -61,18 +66,24 @@ func Initialize(settings *cli.Settings, files []string, runAfterGenerate bool) (
 func (d *DefaultMode) Start() (err error) {
 	fmt.Printf("Intermediate file path: %q\n", d.settings.TempPath)
 
-	err = generateCode(d.settings.Frameworks, d.fileList, d.settings.TempPath)
+	runModels, err := generateCode(d.settings.Frameworks, d.fileList, d.settings.TempPath)
 	if err != nil {
 		return err
 	}
 
 	if d.runAfterGenerate {
-		runCode(d.settings.Frameworks)
+		// Kill any running models if the user interrupts us
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		runCode(ctx, d.settings, runModels)
 	}
 	return
 }
 
-func generateCode(frameworks framework.List, files []string, outputDir string) (err error) {
+// generateCode generates the code for each file using each framework. It returns the model to
+// run for each framework, which is the last one it successfully generated.
+func generateCode(frameworks framework.List, files []string, outputDir string) (runModels map[string]*actr.Model, err error) {
 	modelMap := map[string]*actr.Model{}
 
 	for _, file := range files {
@@ -92,12 +103,19 @@ func generateCode(frameworks framework.List, files []string, outputDir string) (
 	}
 
 	if len(modelMap) == 0 {
-		return ErrNoValidModels
+		return nil, ErrNoValidModels
 	}
 
+	runModels = map[string]*actr.Model{}
+
 	for _, f := range frameworks {
 		fmt.Printf(" %s\n", f.Info().Name)
-		for file, model := range modelMap {
+		for _, file := range files {
+			model, ok := modelMap[file]
+			if !ok {
+				continue
+			}
+
 			fmt.Printf("\t- generating code for %s\n", file)
 
 			log := f.ValidateModel(model)
@@ -106,34 +124,74 @@ func generateCode(frameworks framework.List, files []string, outputDir string) (
 				continue
 			}
 
-			err = f.SetModel(model)
-			if err != nil {
-				fmt.Println(err.Error())
-				continue
-			}
-
-			fileName, err := f.WriteModel(outputDir, framework.InitialBuffers{})
+			fileName, err := f.WriteModel(model, outputDir, framework.InitialBuffers{})
 			if err != nil {
 				fmt.Println(err.Error())
 				continue
 			}
 			fmt.Printf("\t- written to %s\n", fileName)
+
+			runModels[f.Info().Name] = model
 		}
 	}
 
 	return
 }
 
-func runCode(frameworks framework.List) {
-	for _, f := range frameworks {
-		result, err := f.Run(framework.InitialBuffers{})
-		if err != nil {
-			fmt.Println(err.Error())
+// runCode runs all the frameworks in parallel and outputs the results as they arrive.
+// Frameworks which do not have a model in runModels are skipped.
+func runCode(ctx context.Context, settings *cli.Settings, runModels map[string]*actr.Model) {
+	names := []string{}
+	for _, name := range settings.Frameworks.Names() {
+		if _, ok := runModels[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	ctx, cancel := settings.RunContext(ctx)
+	defer cancel()
+
+	// If there's only one framework, output a header. Otherwise the output lines are prefixed
+	// with the framework name.
+	if len(names) == 1 {
+		fmt.Printf("== %s ==\n", names[0])
+	}
+
+	output := cli.NewPrefixedOutput(os.Stdout, names)
+	runErrors := make([]error, len(names))
+	runResults := make([]*framework.RunResult, len(names))
+
+	var wg sync.WaitGroup
+
+	for i, name := range names {
+		wg.Add(1)
+
+		go func(i int, f framework.Framework, model *actr.Model) {
+			defer wg.Done()
+
+			runResults[i], runErrors[i] = f.RunStreaming(ctx, model, framework.InitialBuffers{}, output.Writer(f.Info().Name))
+		}(i, settings.Frameworks[name], runModels[name])
+	}
+
+	wg.Wait()
+
+	cli.WriteFinalStates(os.Stdout, names, runResults)
+
+	if settings.ShowStats {
+		cli.WriteStats(os.Stdout, names, runResults)
+	}
+
+	for i, err := range runErrors {
+		if err == nil {
 			continue
 		}
 
-		fmt.Printf("== %s ==\n", f.Info().Name)
-		fmt.Println(string(result.Output))
-		fmt.Println()
+		// The output has already been shown, so don't repeat it
+		if errors.Is(err, executil.ErrExecutionFailed) {
+			err = executil.ErrExecutionFailed
+		}
+
+		chalk.PrintErr(fmt.Errorf("%s: %w", names[i], err))
 	}
 }
@@ -0,0 +1,149 @@
+package fit
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/asmaloney/gactar/actr"
+	"github.com/asmaloney/gactar/amod"
+)
+
+var (
+	ErrNoObservations    = errors.New("no observations found in data file")
+	ErrNoMeasureColumns  = errors.New("data file must have a 'goal' column and at least one measure column")
+	ErrNoValidEvaluation = errors.New("no parameter values produced all of the measures")
+)
+
+type ErrMeasureNotFound struct {
+	Name string
+}
+
+func (e ErrMeasureNotFound) Error() string {
+	return fmt.Sprintf("measure %q not found in model output", e.Name)
+}
+
+type ErrConditionNotInExamples struct {
+	Condition string
+}
+
+func (e ErrConditionNotInExamples) Error() string {
+	return fmt.Sprintf("condition %q does not match any goal in the model's examples", e.Condition)
+}
+
+type ErrInvalidDataValue struct {
+	Line    int
+	Column  string
+	Message string
+}
+
+func (e ErrInvalidDataValue) Error() string {
+	return fmt.Sprintf("data file line %d, column %q: %s", e.Line, e.Column, e.Message)
+}
+
+// Observation holds the observed measures for one condition.
+type Observation struct {
+	Goal     *actr.Pattern      // initial goal (one of the model's examples)
+	Measures map[string]float64 // observed values by measure name
+}
+
+// LoadObservations reads a CSV file of observed measures per condition.
+//
+// The first column is named "goal" and identifies the condition. It is either a goal pattern which
+// matches one of the model's examples (e.g. "[countFrom: 2 5 'starting']") or the number of the
+// example (starting from 1). The remaining columns are measures. Empty cells are ignored.
+//
+//	goal,rt,accuracy
+//	1,0.35,0.9
+//	[countFrom: 1 7 'starting'],0.51,0.8
+func LoadObservations(fileName string, model *actr.Model) (observations []Observation, measureNames []string, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return
+	}
+
+	if len(records) < 2 {
+		return nil, nil, ErrNoObservations
+	}
+
+	header := records[0]
+	if len(header) < 2 || strings.TrimSpace(header[0]) != "goal" {
+		return nil, nil, ErrNoMeasureColumns
+	}
+
+	for _, name := range header[1:] {
+		measureNames = append(measureNames, strings.TrimSpace(name))
+	}
+
+	for i, record := range records[1:] {
+		line := i + 2
+
+		goal, goalErr := lookupExample(model, strings.TrimSpace(record[0]))
+		if goalErr != nil {
+			return nil, nil, goalErr
+		}
+
+		observation := Observation{
+			Goal:     goal,
+			Measures: map[string]float64{},
+		}
+
+		for col, cell := range record[1:] {
+			cell = strings.TrimSpace(cell)
+			if cell == "" {
+				continue
+			}
+
+			value, parseErr := strconv.ParseFloat(cell, 64)
+			if parseErr != nil {
+				err = &ErrInvalidDataValue{Line: line, Column: measureNames[col], Message: "not a number"}
+				return nil, nil, err
+			}
+
+			observation.Measures[measureNames[col]] = value
+		}
+
+		observations = append(observations, observation)
+	}
+
+	return
+}
+
+// lookupExample finds the example goal which matches the condition.
+func lookupExample(model *actr.Model, condition string) (goal *actr.Pattern, err error) {
+	// Allow examples to be referred to by number
+	index, convErr := strconv.Atoi(condition)
+	if convErr == nil {
+		if index < 1 || index > len(model.Examples) {
+			return nil, &ErrConditionNotInExamples{Condition: condition}
+		}
+
+		return model.Examples[index-1], nil
+	}
+
+	pattern, err := amod.ParseChunk(model, condition)
+	if err != nil {
+		return nil, err
+	}
+
+	if pattern == nil {
+		return nil, &ErrConditionNotInExamples{Condition: condition}
+	}
+
+	for _, example := range model.Examples {
+		if example.String() == pattern.String() {
+			return example, nil
+		}
+	}
+
+	return nil, &ErrConditionNotInExamples{Condition: condition}
+}
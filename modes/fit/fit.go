@@ -0,0 +1,341 @@
+// Package fit fits model parameters to observed data using repeated model runs.
+package fit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/asmaloney/gactar/actr"
+	"github.com/asmaloney/gactar/actr/params"
+	"github.com/asmaloney/gactar/amod"
+	"github.com/asmaloney/gactar/framework"
+
+	"github.com/asmaloney/gactar/util/batch"
+	"github.com/asmaloney/gactar/util/chalk"
+	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/numbers"
+	"github.com/asmaloney/gactar/util/optimize"
+)
+
+var (
+	ErrNoFreeParams     = errors.New("no parameters to fit - use --param")
+	ErrNeedOneFramework = errors.New("fitting requires exactly one framework - use --framework to choose one")
+)
+
+// Options control the fitting process.
+type Options struct {
+	DataFile   string      // CSV file of observed measures
+	FreeParams []FreeParam // parameters to fit
+	Objective  Objective   // function to minimize
+	Sigma      float64     // standard deviation of the error for log-likelihood (0 means estimate it)
+
+	RunsPerCondition int    // number of model runs per condition for each evaluation
+	MaxEvaluations   int    // maximum number of evaluations of the objective
+	NumWorkers       int    // number of runs to execute in parallel
+	OutputFile       string // where to write the fitted model (if empty, <model>_fit.amod)
+}
+
+type Fit struct {
+	settings *cli.Settings
+	options  Options
+
+	amodFile   string
+	amodSource string
+	model      *actr.Model
+
+	observations []Observation
+	measureNames []string
+
+	frameworkName string
+	pool          *batch.Pool
+	ctx           context.Context
+
+	numEvaluations int
+}
+
+func Initialize(settings *cli.Settings, amodFile string, options Options) (f *Fit, err error) {
+	if len(options.FreeParams) == 0 {
+		return nil, ErrNoFreeParams
+	}
+
+	if len(settings.Frameworks) != 1 {
+		return nil, ErrNeedOneFramework
+	}
+
+	if options.RunsPerCondition < 1 {
+		options.RunsPerCondition = 1
+	}
+
+	source, err := os.ReadFile(amodFile)
+	if err != nil {
+		return
+	}
+
+	model, log, err := amod.GenerateModel(string(source))
+	if err != nil {
+		fmt.Print(log)
+		return
+	}
+
+	// Check that the parameter paths are valid for this model
+	for _, param := range options.FreeParams {
+		start := param.Start
+
+		err = model.SetParamPath(param.Path, params.Value{Number: &start})
+		if err != nil {
+			return nil, fmt.Errorf("cannot fit %s: %w", param.Path, err)
+		}
+	}
+
+	observations, measureNames, err := LoadObservations(options.DataFile, model)
+	if err != nil {
+		return
+	}
+
+	if options.OutputFile == "" {
+		base := strings.TrimSuffix(filepath.Base(amodFile), filepath.Ext(amodFile))
+		options.OutputFile = base + "_fit.amod"
+	}
+
+	f = &Fit{
+		settings:      settings,
+		options:       options,
+		amodFile:      amodFile,
+		amodSource:    string(source),
+		model:         model,
+		observations:  observations,
+		measureNames:  measureNames,
+		frameworkName: settings.Frameworks.Names()[0],
+	}
+
+	return
+}
+
+func (f *Fit) Start() (err error) {
+	f.pool, err = batch.NewPool(f.settings, []string{f.frameworkName}, f.options.NumWorkers)
+	if err != nil {
+		return
+	}
+
+	// Kill any running models if the user interrupts us
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	f.ctx = ctx
+
+	fmt.Printf("Fitting %d parameter(s) to %d condition(s) on %s using %s (%d runs per condition)\n",
+		len(f.options.FreeParams), len(f.observations), f.frameworkName, f.options.Objective, f.options.RunsPerCondition)
+
+	start := make([]float64, len(f.options.FreeParams))
+	bounds := make([]optimize.Bounds, len(f.options.FreeParams))
+
+	for i, param := range f.options.FreeParams {
+		start[i] = param.Start
+		bounds[i] = param.Bounds
+	}
+
+	result, err := optimize.NelderMead(f.evaluate, start, bounds, optimize.Settings{
+		MaxEvaluations: f.options.MaxEvaluations,
+	})
+	if err != nil {
+		return
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if math.IsInf(result.F, 1) {
+		return ErrNoValidEvaluation
+	}
+
+	// Run once more at the best point to get the statistics & predictions to report
+	observed, predicted, err := f.predict(result.X)
+	if err != nil {
+		return
+	}
+
+	stats := computeStatistics(observed, predicted, len(result.X), f.options.Sigma)
+
+	f.outputResults(result, stats)
+
+	err = f.writeModel(result.X)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("Fitted model written to %s\n", f.options.OutputFile)
+
+	return
+}
+
+// evaluate is the function passed to the optimizer.
+func (f *Fit) evaluate(x []float64) float64 {
+	f.numEvaluations++
+
+	// If we were interrupted, don't bother running anything else
+	if f.ctx.Err() != nil {
+		return math.Inf(1)
+	}
+
+	observed, predicted, err := f.predict(x)
+	if err != nil {
+		fmt.Printf("> evaluation %d: %s: %s\n", f.numEvaluations, f.paramString(x), err.Error())
+		return math.Inf(1)
+	}
+
+	stats := computeStatistics(observed, predicted, len(x), f.options.Sigma)
+	value := f.options.Objective.value(stats)
+
+	fmt.Printf("> evaluation %d: %s: %s = %.6g\n", f.numEvaluations, f.paramString(x), f.options.Objective, value)
+
+	return value
+}
+
+// predict runs the model with the parameters "x" on each condition and returns the observed
+// and predicted values of each measure.
+func (f *Fit) predict(x []float64) (observed, predicted []float64, err error) {
+	model, err := f.generateModel(x)
+	if err != nil {
+		return
+	}
+
+	jobs := []*batch.Job{}
+
+	for _, observation := range f.observations {
+		for run := 0; run < f.options.RunsPerCondition; run++ {
+			jobs = append(jobs, &batch.Job{
+				Model:          model,
+				InitialBuffers: framework.InitialBuffers{"goal": observation.Goal.String()},
+				Framework:      f.frameworkName,
+			})
+		}
+	}
+
+	results := f.pool.Run(f.ctx, jobs, nil)
+
+	for i, observation := range f.observations {
+		conditionResults := results[i*f.options.RunsPerCondition : (i+1)*f.options.RunsPerCondition]
+
+		for _, name := range f.measureNames {
+			observedValue, ok := observation.Measures[name]
+			if !ok {
+				continue
+			}
+
+			predictedValue, predictErr := meanMeasure(conditionResults, name)
+			if predictErr != nil {
+				err = fmt.Errorf("%s: %w", observation.Goal, predictErr)
+				return
+			}
+
+			observed = append(observed, observedValue)
+			predicted = append(predicted, predictedValue)
+		}
+	}
+
+	return
+}
+
+// generateModel creates a new model from the amod source and applies the parameters to it.
+func (f Fit) generateModel(x []float64) (model *actr.Model, err error) {
+	model, log, err := amod.GenerateModel(f.amodSource)
+	if err != nil {
+		fmt.Print(log)
+		return
+	}
+
+	for i, param := range f.options.FreeParams {
+		value := x[i]
+
+		err = model.SetParamPath(param.Path, params.Value{Number: &value})
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// meanMeasure returns the mean of a measure over several runs.
+func meanMeasure(results []batch.Result, name string) (mean float64, err error) {
+	count := 0
+
+	for _, result := range results {
+		if result.Err != nil {
+			return 0, result.Err
+		}
+
+		measures := batch.ParseMeasures(result.RunResult.Output)
+
+		value, ok := measures[name]
+		if !ok {
+			continue
+		}
+
+		mean += value
+		count++
+	}
+
+	if count == 0 {
+		return 0, &ErrMeasureNotFound{Name: name}
+	}
+
+	return mean / float64(count), nil
+}
+
+func (f Fit) paramString(x []float64) string {
+	list := make([]string, len(x))
+	for i, param := range f.options.FreeParams {
+		list[i] = fmt.Sprintf("%s=%.6g", param.Path, x[i])
+	}
+
+	return strings.Join(list, " ")
+}
+
+func (f Fit) outputResults(result *optimize.Result, stats Statistics) {
+	fmt.Println()
+	fmt.Println(chalk.Header("Best parameters:"))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for i, param := range f.options.FreeParams {
+		fmt.Fprintf(w, "  %s\t%s\t[%s, %s]\n", param.Path, numbers.Float64Str(result.X[i]),
+			numbers.Float64Str(param.Bounds.Lower), numbers.Float64Str(param.Bounds.Upper))
+	}
+	w.Flush()
+
+	fmt.Println(chalk.Header("Fit statistics:"))
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "  observations\t%d\n", stats.N)
+	fmt.Fprintf(w, "  RMSE\t%.6g\n", stats.RMSE)
+	fmt.Fprintf(w, "  R²\t%.6g\n", stats.RSquared)
+	fmt.Fprintf(w, "  log-likelihood\t%.6g\n", stats.LogLikelihood)
+	fmt.Fprintf(w, "  AIC\t%.6g\n", stats.AIC)
+	fmt.Fprintf(w, "  BIC\t%.6g\n", stats.BIC)
+	fmt.Fprintf(w, "  evaluations\t%d\n", result.Evaluations)
+	fmt.Fprintf(w, "  converged\t%t\n", result.Converged)
+	w.Flush()
+}
+
+// writeModel writes a copy of the amod file with the fitted parameters set.
+func (f Fit) writeModel(x []float64) (err error) {
+	values := map[string]string{}
+	for i, param := range f.options.FreeParams {
+		values[param.Path] = numbers.Float64Str(x[i])
+	}
+
+	source, err := amod.SetParamsInSource(f.amodSource, values)
+	if err != nil {
+		return
+	}
+
+	return os.WriteFile(f.options.OutputFile, []byte(source), 0600)
+}
@@ -0,0 +1,78 @@
+package fit
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseFreeParam(t *testing.T) {
+	param, err := ParseFreeParam("memory.latency_factor=0.1:0.5")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if param.Path != "memory.latency_factor" || param.Bounds.Lower != 0.1 || param.Bounds.Upper != 0.5 {
+		t.Errorf("incorrect parameter: %+v", param)
+	}
+
+	if math.Abs(param.Start-0.3) > 1e-9 {
+		t.Errorf("expected start to be the midpoint, got %v", param.Start)
+	}
+
+	param, err = ParseFreeParam("memory.decay=0:1:0.25")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if param.Start != 0.25 {
+		t.Errorf("expected start 0.25, got %v", param.Start)
+	}
+}
+
+func TestParseFreeParamInvalid(t *testing.T) {
+	specs := []string{
+		"memory.decay",
+		"memory.decay=1",
+		"memory.decay=a:1",
+		"memory.decay=1:0",
+		"memory.decay=0:1:2",
+	}
+
+	for _, spec := range specs {
+		_, err := ParseFreeParam(spec)
+
+		var paramErr *ErrInvalidFreeParam
+		if !errors.As(err, &paramErr) {
+			t.Errorf("%q: expected ErrInvalidFreeParam, got %v", spec, err)
+		}
+	}
+}
+
+func TestComputeStatistics(t *testing.T) {
+	observed := []float64{1, 2, 3, 4}
+	predicted := []float64{1, 2, 3, 6}
+
+	stats := computeStatistics(observed, predicted, 1, 0)
+
+	if stats.N != 4 {
+		t.Errorf("expected N = 4, got %d", stats.N)
+	}
+
+	if math.Abs(stats.RMSE-1) > 1e-9 {
+		t.Errorf("expected RMSE = 1, got %v", stats.RMSE)
+	}
+
+	if math.Abs(stats.RSquared-0.2) > 1e-9 {
+		t.Errorf("expected R² = 0.2, got %v", stats.RSquared)
+	}
+
+	expectedLL := -2 * (math.Log(2*math.Pi) + 1)
+	if math.Abs(stats.LogLikelihood-expectedLL) > 1e-9 {
+		t.Errorf("expected log-likelihood = %v, got %v", expectedLL, stats.LogLikelihood)
+	}
+
+	if RMSE.value(stats) != stats.RMSE || LogLikelihood.value(stats) != -stats.LogLikelihood {
+		t.Error("objective values do not match statistics")
+	}
+}
@@ -0,0 +1,104 @@
+package fit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Objective is the function we minimize when fitting.
+type Objective string
+
+const (
+	RMSE          Objective = "rmse"   // root mean squared error
+	LogLikelihood Objective = "loglik" // (negative) log-likelihood assuming normally distributed error
+)
+
+var ValidObjectives = []string{string(RMSE), string(LogLikelihood)}
+
+type ErrInvalidObjective struct {
+	Name string
+}
+
+func (e ErrInvalidObjective) Error() string {
+	return fmt.Sprintf("invalid objective %q (expected one of: %s)", e.Name, strings.Join(ValidObjectives, ", "))
+}
+
+// ParseObjective converts a string to an Objective.
+func ParseObjective(name string) (Objective, error) {
+	switch Objective(name) {
+	case RMSE, LogLikelihood:
+		return Objective(name), nil
+	}
+
+	return "", &ErrInvalidObjective{Name: name}
+}
+
+// Statistics describe how well the predictions fit the observations.
+type Statistics struct {
+	N             int     // number of observed values
+	NumParams     int     // number of free parameters
+	RMSE          float64 // root mean squared error
+	RSquared      float64 // coefficient of determination
+	LogLikelihood float64 // log-likelihood assuming normally distributed error
+	AIC           float64 // Akaike information criterion
+	BIC           float64 // Bayesian information criterion
+}
+
+// computeStatistics compares observed & predicted values. If sigma is > 0, it is used as
+// the standard deviation of the error in the log-likelihood; otherwise the maximum likelihood
+// estimate (the RMSE) is used.
+func computeStatistics(observed, predicted []float64, numParams int, sigma float64) (stats Statistics) {
+	n := len(observed)
+
+	stats.N = n
+	stats.NumParams = numParams
+
+	if n == 0 {
+		return
+	}
+
+	mean := 0.0
+	for _, o := range observed {
+		mean += o / float64(n)
+	}
+
+	ssRes, ssTot := 0.0, 0.0
+	for i, o := range observed {
+		ssRes += math.Pow(o-predicted[i], 2)
+		ssTot += math.Pow(o-mean, 2)
+	}
+
+	stats.RMSE = math.Sqrt(ssRes / float64(n))
+
+	if ssTot > 0 {
+		stats.RSquared = 1 - ssRes/ssTot
+	}
+
+	if sigma > 0 {
+		variance := sigma * sigma
+		stats.LogLikelihood = -float64(n)/2*math.Log(2*math.Pi*variance) - ssRes/(2*variance)
+	} else {
+		variance := ssRes / float64(n)
+		if variance == 0 {
+			stats.LogLikelihood = math.Inf(1)
+		} else {
+			stats.LogLikelihood = -float64(n) / 2 * (math.Log(2*math.Pi*variance) + 1)
+		}
+	}
+
+	k := float64(numParams)
+	stats.AIC = 2*k - 2*stats.LogLikelihood
+	stats.BIC = k*math.Log(float64(n)) - 2*stats.LogLikelihood
+
+	return
+}
+
+// value returns the value of the objective to minimize.
+func (o Objective) value(stats Statistics) float64 {
+	if o == LogLikelihood {
+		return -stats.LogLikelihood
+	}
+
+	return stats.RMSE
+}
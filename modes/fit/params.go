@@ -0,0 +1,67 @@
+package fit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/asmaloney/gactar/util/optimize"
+)
+
+type ErrInvalidFreeParam struct {
+	Spec    string
+	Message string
+}
+
+func (e ErrInvalidFreeParam) Error() string {
+	return fmt.Sprintf("invalid parameter %q: %s (expected <module>.<param>=<min>:<max>[:<start>])", e.Spec, e.Message)
+}
+
+// FreeParam is a parameter which is free to vary during fitting.
+type FreeParam struct {
+	Path   string // e.g. "memory.latency_factor"
+	Bounds optimize.Bounds
+	Start  float64
+}
+
+// ParseFreeParam parses a parameter given as "path=min:max" or "path=min:max:start".
+// If the start value is not given, the midpoint of the bounds is used.
+func ParseFreeParam(spec string) (param FreeParam, err error) {
+	path, rangeStr, found := strings.Cut(spec, "=")
+	if !found || path == "" {
+		return param, &ErrInvalidFreeParam{Spec: spec, Message: "missing bounds"}
+	}
+
+	parts := strings.Split(rangeStr, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return param, &ErrInvalidFreeParam{Spec: spec, Message: "wrong number of values"}
+	}
+
+	values := make([]float64, len(parts))
+	for i, part := range parts {
+		values[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return param, &ErrInvalidFreeParam{Spec: spec, Message: fmt.Sprintf("%q is not a number", part)}
+		}
+	}
+
+	param = FreeParam{
+		Path:   strings.TrimSpace(path),
+		Bounds: optimize.Bounds{Lower: values[0], Upper: values[1]},
+		Start:  (values[0] + values[1]) / 2,
+	}
+
+	if param.Bounds.Lower >= param.Bounds.Upper {
+		return param, &ErrInvalidFreeParam{Spec: spec, Message: "min must be less than max"}
+	}
+
+	if len(values) == 3 {
+		param.Start = values[2]
+
+		if param.Start < param.Bounds.Lower || param.Start > param.Bounds.Upper {
+			return param, &ErrInvalidFreeParam{Spec: spec, Message: "start is outside of bounds"}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,197 @@
+package modeltest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/asmaloney/gactar/actr"
+	"github.com/asmaloney/gactar/framework"
+
+	"github.com/asmaloney/gactar/util/numbers"
+)
+
+// timeTolerance is how far outside the end time bounds we allow a run to end. Frameworks
+// round the time they output.
+const timeTolerance = 0.0005
+
+// Check compares the events from a run with what the test case expects and returns a
+// description of each difference.
+func Check(test *actr.TestCase, events *framework.Events) (failures []string) {
+	if events == nil {
+		events = &framework.Events{}
+	}
+
+	failures = append(failures, checkPrints(test, events)...)
+	failures = append(failures, checkBuffers(test, events)...)
+	failures = append(failures, checkEndTime(test, events)...)
+	failures = append(failures, checkFired(test, events)...)
+
+	return
+}
+
+func checkPrints(test *actr.TestCase, events *framework.Events) (failures []string) {
+	if test.Prints == nil {
+		return
+	}
+
+	for i, expected := range test.Prints {
+		if i >= len(events.Prints) {
+			failures = append(failures, fmt.Sprintf("print %d: expected %q, but nothing was printed", i+1, expected))
+			continue
+		}
+
+		actual := events.Prints[i]
+		if !valuesMatch(expected, actual) {
+			failures = append(failures, fmt.Sprintf("print %d: expected %q, got %q", i+1, expected, actual))
+		}
+	}
+
+	for i := len(test.Prints); i < len(events.Prints); i++ {
+		failures = append(failures, fmt.Sprintf("print %d: unexpected %q", i+1, events.Prints[i]))
+	}
+
+	return
+}
+
+func checkBuffers(test *actr.TestCase, events *framework.Events) (failures []string) {
+	for _, expected := range test.Buffers {
+		actual, reported := events.Buffers[expected.BufferName]
+		if !reported {
+			failures = append(failures, fmt.Sprintf("buffer '%s': contents not reported", expected.BufferName))
+			continue
+		}
+
+		if expected.Pattern == nil {
+			if actual != nil {
+				failures = append(failures, fmt.Sprintf("buffer '%s': expected empty, got %s", expected.BufferName, actual.String()))
+			}
+			continue
+		}
+
+		if actual == nil {
+			failures = append(failures, fmt.Sprintf("buffer '%s': expected %s, but it is empty", expected.BufferName, expected.Pattern))
+			continue
+		}
+
+		if !patternMatches(expected.Pattern, actual) {
+			failures = append(failures, fmt.Sprintf("buffer '%s': expected %s, got %s", expected.BufferName, expected.Pattern, actual.String()))
+		}
+	}
+
+	return
+}
+
+func checkEndTime(test *actr.TestCase, events *framework.Events) (failures []string) {
+	if test.MinEndTime == nil && test.MaxEndTime == nil {
+		return
+	}
+
+	if events.EndTime == nil {
+		failures = append(failures, "end time: not reported")
+		return
+	}
+
+	endTime := *events.EndTime
+
+	if test.MinEndTime != nil && endTime < *test.MinEndTime-timeTolerance {
+		failures = append(failures, fmt.Sprintf("end time: expected at least %s, got %s",
+			numbers.Float64Str(*test.MinEndTime), numbers.Float64Str(endTime)))
+	}
+
+	if test.MaxEndTime != nil && endTime > *test.MaxEndTime+timeTolerance {
+		failures = append(failures, fmt.Sprintf("end time: expected at most %s, got %s",
+			numbers.Float64Str(*test.MaxEndTime), numbers.Float64Str(endTime)))
+	}
+
+	return
+}
+
+func checkFired(test *actr.TestCase, events *framework.Events) (failures []string) {
+	fired := func(name string) bool {
+		for _, production := range events.Fired {
+			if strings.EqualFold(production.Name, name) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, name := range test.Fired {
+		if !fired(name) {
+			failures = append(failures, fmt.Sprintf("production '%s': expected to fire, but it did not", name))
+		}
+	}
+
+	for _, name := range test.NotFired {
+		if fired(name) {
+			failures = append(failures, fmt.Sprintf("production '%s': expected not to fire, but it did", name))
+		}
+	}
+
+	return
+}
+
+// patternMatches checks the buffer contents against a pattern slot-by-slot. The chunk type is
+// only compared if the framework reported it.
+func patternMatches(pattern *actr.Pattern, contents *framework.ChunkContents) bool {
+	if contents.ChunkType != "" && !strings.EqualFold(contents.ChunkType, pattern.Chunk.TypeName) {
+		return false
+	}
+
+	for i, slot := range pattern.Slots {
+		value, ok := contents.Lookup(pattern.Chunk.SlotName(i))
+		if !ok {
+			value = "nil"
+		}
+
+		if slotMatches(slot, value) == slot.Negated {
+			return false
+		}
+	}
+
+	return true
+}
+
+func slotMatches(slot *actr.PatternSlot, value string) bool {
+	switch {
+	case slot.Wildcard:
+		return true
+
+	case slot.Nil:
+		return value == "nil"
+
+	case slot.ID != nil:
+		return valuesMatch(*slot.ID, value)
+
+	case slot.Str != nil:
+		return valuesMatch(*slot.Str, value)
+
+	case slot.Num != nil:
+		expected, err := strconv.ParseFloat(*slot.Num, 64)
+		if err != nil {
+			return valuesMatch(*slot.Num, value)
+		}
+
+		actual, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+
+		return math.Abs(expected-actual) < 1e-9
+	}
+
+	return false
+}
+
+// valuesMatch compares an expected value with what a framework output. Frameworks change the
+// case of symbols (vanilla) or replace spaces with underscores (ccm), so we ignore those differences.
+func valuesMatch(expected, actual string) bool {
+	normalize := func(str string) string {
+		return strings.ReplaceAll(strings.TrimSpace(str), " ", "_")
+	}
+
+	return strings.EqualFold(normalize(expected), normalize(actual))
+}
@@ -0,0 +1,120 @@
+package modeltest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/asmaloney/gactar/actr"
+	"github.com/asmaloney/gactar/amod"
+	"github.com/asmaloney/gactar/framework"
+)
+
+const testModel = `
+~~ model ~~
+name: Test
+~~ config ~~
+chunks { [count: first second] }
+~~ init ~~
+~~ productions ~~
+start {
+	match { goal [count: 1 nil] }
+	do {
+		print 'Start'
+		set goal.second to 'two words'
+	}
+}
+finish {
+	match { goal [count: 1 *] }
+	do { stop }
+}
+~~ tests ~~
+counts {
+	goal [count: 1 nil]
+	prints { 'Start' }
+	buffers {
+		goal [count: 1 'two words']
+		retrieval nil
+	}
+	end_time { max: 0.1 }
+	fired { start finish }
+}`
+
+func generateTest(t *testing.T) *actr.TestCase {
+	t.Helper()
+
+	model, log, err := amod.GenerateModel(testModel)
+	if err != nil {
+		t.Fatalf("could not generate model: %s\n%s", err, log)
+	}
+
+	test := model.LookupTest("counts")
+	if test == nil {
+		t.Fatal("test 'counts' not found")
+	}
+
+	return test
+}
+
+func TestCheckPass(t *testing.T) {
+	test := generateTest(t)
+
+	// vanilla-style output: no chunk type, upper-case symbols
+	events, _ := framework.ParseEvents(`@gactar print Start
+@gactar fired 0.050 START
+@gactar fired 0.100 FINISH
+@gactar end 0.100
+@gactar buffer goal - first=1 second=TWO_WORDS
+@gactar buffer retrieval nil
+`)
+
+	failures := Check(test, events)
+	if len(failures) != 0 {
+		t.Errorf("expected test to pass, got failures:\n%s", strings.Join(failures, "\n"))
+	}
+}
+
+func TestCheckFail(t *testing.T) {
+	test := generateTest(t)
+
+	events, _ := framework.ParseEvents(`@gactar print Stop
+@gactar print Again
+@gactar fired 0.050 start
+@gactar end 0.250
+@gactar buffer goal count first=1 second=None
+@gactar buffer retrieval count first=2 second=3
+`)
+
+	failures := Check(test, events)
+
+	expected := []string{
+		`print 1: expected "Start", got "Stop"`,
+		`print 2: unexpected "Again"`,
+		`buffer 'goal': expected [count: 1 'two words'], got [count: first=1 second=nil]`,
+		`buffer 'retrieval': expected empty, got [count: first=2 second=3]`,
+		`end time: expected at most 0.1, got 0.25`,
+		`production 'finish': expected to fire, but it did not`,
+	}
+
+	if strings.Join(failures, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("incorrect failures:\n%s", strings.Join(failures, "\n"))
+	}
+}
+
+func TestCheckNotReported(t *testing.T) {
+	test := generateTest(t)
+
+	failures := Check(test, nil)
+
+	expected := []string{
+		`print 1: expected "Start", but nothing was printed`,
+		`buffer 'goal': contents not reported`,
+		`buffer 'retrieval': contents not reported`,
+		`end time: not reported`,
+		`production 'start': expected to fire, but it did not`,
+		`production 'finish': expected to fire, but it did not`,
+	}
+
+	if strings.Join(failures, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("incorrect failures:\n%s", strings.Join(failures, "\n"))
+	}
+}
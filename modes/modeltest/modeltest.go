@@ -0,0 +1,167 @@
+// Package modeltest runs the test cases from the "tests" section of amod files and reports
+// which pass and which fail.
+package modeltest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+
+	"github.com/asmaloney/gactar/actr"
+	"github.com/asmaloney/gactar/amod"
+	"github.com/asmaloney/gactar/framework"
+
+	"github.com/asmaloney/gactar/util/batch"
+	"github.com/asmaloney/gactar/util/chalk"
+	"github.com/asmaloney/gactar/util/cli"
+)
+
+var (
+	ErrNoTests = errors.New("no tests found - add a '~~ tests ~~' section to the amod file")
+)
+
+type ErrTestsFailed struct {
+	NumFailed int
+}
+
+func (e ErrTestsFailed) Error() string {
+	return fmt.Sprintf("%d test(s) failed", e.NumFailed)
+}
+
+type ModelTest struct {
+	settings *cli.Settings
+
+	models     []*actr.Model
+	amodFiles  []string
+	numWorkers int
+}
+
+// testRun tracks which file and test case a batch job belongs to.
+type testRun struct {
+	amodFile string
+	test     *actr.TestCase
+}
+
+func Initialize(settings *cli.Settings, amodFiles []string, numWorkers int) (m *ModelTest, err error) {
+	m = &ModelTest{
+		settings:   settings,
+		numWorkers: numWorkers,
+	}
+
+	for _, amodFile := range amodFiles {
+		model, log, err := amod.GenerateModelFromFile(amodFile)
+		if err != nil {
+			fmt.Print(log)
+			return nil, err
+		}
+
+		if !model.HasTests() {
+			chalk.PrintWarningStr(fmt.Sprintf("%s: no tests found", amodFile))
+			continue
+		}
+
+		m.models = append(m.models, model)
+		m.amodFiles = append(m.amodFiles, amodFile)
+	}
+
+	if len(m.models) == 0 {
+		return nil, ErrNoTests
+	}
+
+	return
+}
+
+func (m *ModelTest) Start() (err error) {
+	frameworkNames := m.settings.Frameworks.Names()
+	sort.Strings(frameworkNames)
+
+	jobs := []*batch.Job{}
+	runs := []testRun{}
+
+	for i, model := range m.models {
+		for _, test := range model.Tests {
+			for _, name := range frameworkNames {
+				jobs = append(jobs, &batch.Job{
+					Model:          model,
+					InitialBuffers: framework.InitialBuffers{"goal": test.Goal.String()},
+					Framework:      name,
+				})
+
+				runs = append(runs, testRun{
+					amodFile: m.amodFiles[i],
+					test:     test,
+				})
+			}
+		}
+	}
+
+	pool, err := batch.NewPool(m.settings, frameworkNames, m.numWorkers)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("Running %d test run(s) on %d framework(s) using %d worker(s)\n",
+		len(jobs), len(frameworkNames), pool.NumWorkers())
+
+	// Kill any running models if the user interrupts us
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	results := pool.Run(ctx, jobs, nil)
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	numFailed := 0
+	currentFile := ""
+
+	for i, result := range results {
+		run := runs[i]
+
+		if run.amodFile != currentFile {
+			currentFile = run.amodFile
+			fmt.Println(chalk.Header(currentFile))
+		}
+
+		failures := checkResult(run.test, result)
+
+		label := fmt.Sprintf("%s (%s)", run.test.Name, result.Job.Framework)
+
+		if len(failures) == 0 {
+			fmt.Printf("  %s %s\n", chalk.Success("PASS"), label)
+			continue
+		}
+
+		numFailed++
+
+		fmt.Printf("  %s %s (amod line %d)\n", chalk.ErrorBold("FAIL"), label, run.test.AMODLineNumber)
+		for _, failure := range failures {
+			fmt.Printf("       %s\n", failure)
+		}
+	}
+
+	fmt.Printf("%d passed, %d failed\n", len(results)-numFailed, numFailed)
+
+	if numFailed > 0 {
+		return &ErrTestsFailed{NumFailed: numFailed}
+	}
+
+	return
+}
+
+// checkResult checks the result of one run against the test case.
+func checkResult(test *actr.TestCase, result batch.Result) (failures []string) {
+	if result.Err != nil {
+		return []string{fmt.Sprintf("run failed: %s", result.Err.Error())}
+	}
+
+	if result.RunResult == nil {
+		return []string{"run failed: no result"}
+	}
+
+	return Check(test, result.RunResult.Events)
+}
@@ -2,12 +2,16 @@
 package shell
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
 
 	"golang.org/x/term"
 
@@ -17,8 +21,10 @@ import (
 
 	"github.com/asmaloney/gactar/util/chalk"
 	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/executil"
 	"github.com/asmaloney/gactar/util/issues"
 	"github.com/asmaloney/gactar/util/validate"
+	"github.com/asmaloney/gactar/util/workspace"
 )
 
 var (
@@ -35,6 +41,22 @@ func (e ErrInvalidFramework) Error() string {
 	return fmt.Sprintf("%q is not a valid framework", e.Name)
 }
 
+type ErrInvalidTimeout struct {
+	Value string
+}
+
+func (e ErrInvalidTimeout) Error() string {
+	return fmt.Sprintf("invalid timeout %q (expected a duration such as 30s or 5m)", e.Value)
+}
+
+type ErrInvalidStatsOption struct {
+	Value string
+}
+
+func (e ErrInvalidStatsOption) Error() string {
+	return fmt.Sprintf("invalid stats option %q (expected 'on' or 'off')", e.Value)
+}
+
 type ErrUnrecognizedCommand struct {
 	Command string
 }
@@ -54,12 +76,16 @@ type Shell struct {
 	currentModel     *actr.Model
 	activeFrameworks map[string]bool
 	commands         map[string]command
+	timeout          time.Duration // maximum time for each run (0 means no limit)
+	showStats        bool          // output statistics after each run
 }
 
 func Initialize(settings *cli.Settings) (s *Shell, err error) {
 	s = &Shell{
 		settings:         settings,
 		activeFrameworks: map[string]bool{},
+		timeout:          settings.Timeout,
+		showStats:        settings.ShowStats,
 	}
 
 	s.preamble()
@@ -74,6 +100,8 @@ func Initialize(settings *cli.Settings) (s *Shell, err error) {
 		"load":       {"loads a model: load [FILENAME]", s.cmdLoad},
 		"reset":      {"resets the current model", s.cmdReset},
 		"run":        {"runs the current model: run [INITIAL STATE]", s.cmdRun},
+		"stats":      {`turns statistics after each run on or off: stats [on|off]`, s.cmdStats},
+		"timeout":    {`sets the maximum time for each run (e.g. "30s", "0" for no limit): timeout [DURATION]`, s.cmdTimeout},
 		"version":    {"outputs version info", s.cmdVersion},
 
 		"help": {"exits the program", s.cmdHelp},
@@ -174,7 +202,7 @@ func (s *Shell) cmdFramework(fNames string) (err error) {
 	}
 
 	if len(s.activeFrameworks) == 0 {
-		err = fmt.Errorf("%w. Valid values: %v", ErrNoFrameworkSelected, framework.ValidFrameworks)
+		err = fmt.Errorf("%w. Valid values: %v", ErrNoFrameworkSelected, framework.ValidFrameworks())
 		return
 	}
 
@@ -248,32 +276,108 @@ func (s *Shell) cmdRun(initialGoal string) (err error) {
 	validate.Goal(s.currentModel, initialGoal, log)
 	fmt.Print(log)
 
-	for name, f := range s.settings.Frameworks {
-		if !s.activeFrameworks[name] {
-			continue
-		}
+	names := []string{}
+	for name := range s.activeFrameworks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	initialBuffers := framework.InitialBuffers{
+		"goal": strings.TrimSpace(initialGoal),
+	}
 
-		fmt.Printf("== %s ==\n", f.Info().Name)
+	// Interrupting with control-C will kill the runs without exiting the shell.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-		err = f.SetModel(s.currentModel)
-		if err != nil {
-			return err
-		}
+	ctx, cancel := cli.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ctx = workspace.WithKey(ctx, "shell")
+
+	// If there's only one framework, output a header. Otherwise the output lines are prefixed
+	// with the framework name.
+	if len(names) == 1 {
+		fmt.Printf("== %s ==\n", names[0])
+	}
+
+	model := s.currentModel
+	output := cli.NewPrefixedOutput(os.Stdout, names)
+	runErrors := make([]error, len(names))
+	runResults := make([]*framework.RunResult, len(names))
+
+	var wg sync.WaitGroup
+
+	for i, name := range names {
+		wg.Add(1)
 
-		initialBuffers := framework.InitialBuffers{
-			"goal": strings.TrimSpace(initialGoal),
+		go func(i int, name string) {
+			defer wg.Done()
+
+			runResults[i], runErrors[i] = s.settings.Frameworks[name].RunStreaming(ctx, model, initialBuffers, output.Writer(name))
+		}(i, name)
+	}
+
+	wg.Wait()
+
+	cli.WriteFinalStates(os.Stdout, names, runResults)
+
+	if s.showStats {
+		cli.WriteStats(os.Stdout, names, runResults)
+	}
+
+	for i, runErr := range runErrors {
+		if runErr == nil {
+			continue
 		}
 
-		result, err := f.Run(initialBuffers)
-		if err != nil {
-			return err
+		// The output has already been shown, so don't repeat it
+		if errors.Is(runErr, executil.ErrExecutionFailed) {
+			runErr = executil.ErrExecutionFailed
 		}
 
-		fmt.Print(string(result.Output))
+		chalk.PrintErr(fmt.Errorf("%s: %w", names[i], runErr))
+	}
+
+	err = s.settings.CleanWorkspaces()
+
+	return
+}
+
+func (s *Shell) cmdStats(value string) (err error) {
+	switch value {
+	case "":
+	case "on":
+		s.showStats = true
+	case "off":
+		s.showStats = false
+	default:
+		return &ErrInvalidStatsOption{Value: value}
+	}
+
+	if s.showStats {
+		fmt.Println(" stats: on")
+	} else {
+		fmt.Println(" stats: off")
+	}
 
-		if result.Output[len(result.Output)-1] != '\n' {
-			fmt.Println()
+	return
+}
+
+func (s *Shell) cmdTimeout(value string) (err error) {
+	if value != "" {
+		timeout, parseErr := time.ParseDuration(value)
+		if parseErr != nil || timeout < 0 {
+			return &ErrInvalidTimeout{Value: value}
 		}
+
+		s.timeout = timeout
+	}
+
+	if s.timeout == 0 {
+		fmt.Println(" timeout: none")
+	} else {
+		fmt.Printf(" timeout: %s\n", s.timeout)
 	}
 
 	return
@@ -0,0 +1,174 @@
+package sweep
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+var (
+	ErrNoParameters = errors.New("sweep specification does not contain any parameters")
+)
+
+type ErrInvalidParameter struct {
+	Path    string
+	Message string
+}
+
+func (e ErrInvalidParameter) Error() string {
+	return fmt.Sprintf("invalid sweep parameter %q: %s", e.Path, e.Message)
+}
+
+// Spec is a sweep specification. It is read from a JSON file like this:
+//
+//	{
+//	  "goal": "[countFrom: 2 5 'starting']",
+//	  "frameworks": ["ccm", "vanilla"],
+//	  "repetitions": 5,
+//	  "parameters": [
+//	    { "path": "memory.retrieval_threshold", "values": [-1.0, -0.5, 0.0] },
+//	    { "path": "memory.decay", "from": 0.3, "to": 0.7, "step": 0.1 }
+//	  ]
+//	}
+type Spec struct {
+	Goal        string      `json:"goal,omitempty"`        // initial goal (optional)
+	Frameworks  []string    `json:"frameworks,omitempty"`  // frameworks to run on (if empty, all active frameworks)
+	Repetitions int         `json:"repetitions,omitempty"` // number of times to run each combination (default 1)
+	Parameters  []Parameter `json:"parameters"`            // parameters to sweep
+}
+
+// Parameter is one parameter to sweep. Its values are either given as a list
+// or as a range using from/to/step.
+type Parameter struct {
+	Path   string    `json:"path"` // e.g. "memory.retrieval_threshold"
+	Values []float64 `json:"values,omitempty"`
+
+	From *float64 `json:"from,omitempty"`
+	To   *float64 `json:"to,omitempty"`
+	Step *float64 `json:"step,omitempty"`
+}
+
+// Setting is a single parameter value in a combination.
+type Setting struct {
+	Path  string
+	Value float64
+}
+
+// Combination is one point in the parameter grid.
+type Combination []Setting
+
+// LoadSpec reads a sweep specification from a JSON file.
+func LoadSpec(fileName string) (spec *Spec, err error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+
+	spec = &Spec{}
+
+	err = json.Unmarshal(data, spec)
+	if err != nil {
+		err = fmt.Errorf("cannot read sweep specification %q: %w", fileName, err)
+		return nil, err
+	}
+
+	err = spec.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func (s *Spec) validate() (err error) {
+	if len(s.Parameters) == 0 {
+		return ErrNoParameters
+	}
+
+	if s.Repetitions < 1 {
+		s.Repetitions = 1
+	}
+
+	for _, param := range s.Parameters {
+		_, err = param.expand()
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// expand returns the list of values for this parameter.
+func (p Parameter) expand() (values []float64, err error) {
+	if p.Path == "" {
+		return nil, &ErrInvalidParameter{Path: p.Path, Message: "missing path"}
+	}
+
+	if len(p.Values) > 0 {
+		if p.From != nil || p.To != nil || p.Step != nil {
+			return nil, &ErrInvalidParameter{Path: p.Path, Message: "use either 'values' or 'from'/'to'/'step', not both"}
+		}
+
+		return p.Values, nil
+	}
+
+	if p.From == nil || p.To == nil || p.Step == nil {
+		return nil, &ErrInvalidParameter{Path: p.Path, Message: "requires 'values' or all of 'from', 'to', and 'step'"}
+	}
+
+	from, to, step := *p.From, *p.To, *p.Step
+
+	if step <= 0 {
+		return nil, &ErrInvalidParameter{Path: p.Path, Message: "'step' must be greater than 0"}
+	}
+
+	if to < from {
+		return nil, &ErrInvalidParameter{Path: p.Path, Message: "'to' must not be less than 'from'"}
+	}
+
+	// Use a small epsilon so floating point error doesn't drop the last value.
+	num := int(math.Floor((to-from)/step+1e-9)) + 1
+
+	values = make([]float64, num)
+	for i := range values {
+		values[i] = roundValue(from + float64(i)*step)
+	}
+
+	return
+}
+
+// Combinations returns the full grid of parameter values.
+func (s Spec) Combinations() (combinations []Combination) {
+	combinations = []Combination{{}}
+
+	for _, param := range s.Parameters {
+		values, err := param.expand()
+		if err != nil {
+			// This was checked in validate()
+			continue
+		}
+
+		next := make([]Combination, 0, len(combinations)*len(values))
+
+		for _, combination := range combinations {
+			for _, value := range values {
+				c := make(Combination, len(combination), len(combination)+1)
+				copy(c, combination)
+
+				next = append(next, append(c, Setting{Path: param.Path, Value: value}))
+			}
+		}
+
+		combinations = next
+	}
+
+	return
+}
+
+// roundValue removes floating point noise from generated values (e.g. 0.30000000000000004).
+func roundValue(value float64) float64 {
+	return math.Round(value*1e9) / 1e9
+}
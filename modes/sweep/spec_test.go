@@ -0,0 +1,79 @@
+package sweep
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeSpec(t *testing.T, contents string) string {
+	t.Helper()
+
+	fileName := filepath.Join(t.TempDir(), "sweep.json")
+
+	err := os.WriteFile(fileName, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fileName
+}
+
+func TestSpecCombinations(t *testing.T) {
+	fileName := writeSpec(t, `{
+		"parameters": [
+			{ "path": "memory.retrieval_threshold", "values": [-1, 0] },
+			{ "path": "memory.decay", "from": 0.3, "to": 0.5, "step": 0.1 }
+		]
+	}`)
+
+	spec, err := LoadSpec(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spec.Repetitions != 1 {
+		t.Errorf("expected repetitions to default to 1, got %d", spec.Repetitions)
+	}
+
+	combinations := spec.Combinations()
+
+	expected := []Combination{
+		{{"memory.retrieval_threshold", -1}, {"memory.decay", 0.3}},
+		{{"memory.retrieval_threshold", -1}, {"memory.decay", 0.4}},
+		{{"memory.retrieval_threshold", -1}, {"memory.decay", 0.5}},
+		{{"memory.retrieval_threshold", 0}, {"memory.decay", 0.3}},
+		{{"memory.retrieval_threshold", 0}, {"memory.decay", 0.4}},
+		{{"memory.retrieval_threshold", 0}, {"memory.decay", 0.5}},
+	}
+
+	if !reflect.DeepEqual(combinations, expected) {
+		t.Errorf("unexpected combinations:\n expected %v\n got      %v", expected, combinations)
+	}
+}
+
+func TestSpecNoParameters(t *testing.T) {
+	fileName := writeSpec(t, `{ "repetitions": 2 }`)
+
+	_, err := LoadSpec(fileName)
+	if !errors.Is(err, ErrNoParameters) {
+		t.Errorf("expected ErrNoParameters, got %v", err)
+	}
+}
+
+func TestSpecInvalidRange(t *testing.T) {
+	fileName := writeSpec(t, `{
+		"parameters": [
+			{ "path": "memory.decay", "from": 0.3, "to": 0.5 }
+		]
+	}`)
+
+	var paramErr *ErrInvalidParameter
+
+	_, err := LoadSpec(fileName)
+	if !errors.As(err, &paramErr) {
+		t.Errorf("expected ErrInvalidParameter, got %v", err)
+	}
+}
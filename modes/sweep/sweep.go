@@ -0,0 +1,327 @@
+// Package sweep runs a model over a grid of parameter values and tabulates the results.
+package sweep
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/asmaloney/gactar/actr"
+	"github.com/asmaloney/gactar/actr/params"
+	"github.com/asmaloney/gactar/amod"
+	"github.com/asmaloney/gactar/framework"
+
+	"github.com/asmaloney/gactar/util/batch"
+	"github.com/asmaloney/gactar/util/chalk"
+	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/container"
+	"github.com/asmaloney/gactar/util/issues"
+	"github.com/asmaloney/gactar/util/numbers"
+	"github.com/asmaloney/gactar/util/validate"
+)
+
+// maxOutputColumnLen is the maximum length of the output shown in the table.
+const maxOutputColumnLen = 40
+
+type ErrFrameworkNotActive struct {
+	Name string
+}
+
+func (e ErrFrameworkNotActive) Error() string {
+	return fmt.Sprintf("framework %q is not active", e.Name)
+}
+
+type Sweep struct {
+	settings *cli.Settings
+
+	amodFile   string
+	amodSource string
+	spec       *Spec
+
+	numWorkers int
+	csvFile    string // if set, write the results to this file as CSV
+}
+
+// run tracks which combination and repetition a batch job belongs to.
+type run struct {
+	combination Combination
+	repetition  int
+}
+
+func Initialize(settings *cli.Settings, amodFile, specFile string, numWorkers int, csvFile string) (s *Sweep, err error) {
+	source, err := os.ReadFile(amodFile)
+	if err != nil {
+		return
+	}
+
+	spec, err := LoadSpec(specFile)
+	if err != nil {
+		return
+	}
+
+	s = &Sweep{
+		settings:   settings,
+		amodFile:   amodFile,
+		amodSource: string(source),
+		spec:       spec,
+		numWorkers: numWorkers,
+		csvFile:    csvFile,
+	}
+
+	return
+}
+
+func (s *Sweep) Start() (err error) {
+	frameworkNames, err := s.frameworkNames()
+	if err != nil {
+		return
+	}
+
+	combinations := s.spec.Combinations()
+
+	jobs := []*batch.Job{}
+	runs := []run{}
+
+	for index, combination := range combinations {
+		model, err := s.generateModel(combination)
+		if err != nil {
+			return err
+		}
+
+		// Only output the goal info once
+		if index == 0 {
+			log := issues.New()
+			validate.Goal(model, s.spec.Goal, log)
+			fmt.Print(log)
+		}
+
+		for _, name := range frameworkNames {
+			for rep := 1; rep <= s.spec.Repetitions; rep++ {
+				jobs = append(jobs, &batch.Job{
+					Model:          model,
+					InitialBuffers: framework.InitialBuffers{"goal": s.spec.Goal},
+					Framework:      name,
+				})
+
+				runs = append(runs, run{
+					combination: combination,
+					repetition:  rep,
+				})
+			}
+		}
+	}
+
+	pool, err := batch.NewPool(s.settings, frameworkNames, s.numWorkers)
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("Running %d combinations on %d framework(s) with %d repetition(s) using %d worker(s)\n",
+		len(combinations), len(frameworkNames), s.spec.Repetitions, pool.NumWorkers())
+
+	// Kill any running models if the user interrupts us
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	results := pool.Run(ctx, jobs, func(done, total int) {
+		fmt.Printf("\r> finished %d/%d runs", done, total)
+	})
+	fmt.Println()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	t := newTable(s.spec, runs, results)
+
+	t.write(os.Stdout)
+
+	if s.csvFile != "" {
+		err = t.writeCSV(s.csvFile)
+		if err != nil {
+			return
+		}
+
+		fmt.Printf("Results written to %s\n", s.csvFile)
+	}
+
+	return
+}
+
+// frameworkNames returns a sorted list of frameworks to run on.
+func (s Sweep) frameworkNames() (names []string, err error) {
+	// copy so sorting does not change the spec
+	names = append([]string{}, s.spec.Frameworks...)
+	if len(names) == 0 {
+		names = s.settings.Frameworks.Names()
+	}
+
+	for _, name := range names {
+		if !s.settings.Frameworks.Exists(name) {
+			return nil, &ErrFrameworkNotActive{Name: name}
+		}
+	}
+
+	sort.Strings(names)
+	return
+}
+
+// generateModel creates a new model from the amod source and applies the combination to it.
+func (s Sweep) generateModel(combination Combination) (model *actr.Model, err error) {
+	model, log, err := amod.GenerateModel(s.amodSource)
+	if err != nil {
+		fmt.Print(log)
+		return
+	}
+
+	for _, setting := range combination {
+		value := setting.Value
+
+		err = model.SetParamPath(setting.Path, params.Value{Number: &value})
+		if err != nil {
+			err = fmt.Errorf("cannot set %s to %s: %w", setting.Path, numbers.Float64Str(value), err)
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// table holds the tabulated results of a sweep.
+type table struct {
+	header []string
+	rows   [][]string
+
+	outputs []string // full output of each run for CSV output
+}
+
+func newTable(spec *Spec, runs []run, results []batch.Result) (t *table) {
+	t = &table{}
+
+	measures := make([]batch.Measures, len(results))
+	measureNames := []string{}
+
+	for i, result := range results {
+		if result.RunResult != nil {
+			measures[i] = batch.ParseMeasures(result.RunResult.Output)
+
+			for name := range measures[i] {
+				measureNames = append(measureNames, name)
+			}
+		}
+	}
+
+	measureNames = container.UniqueAndSorted(measureNames)
+
+	for _, param := range spec.Parameters {
+		t.header = append(t.header, param.Path)
+	}
+
+	t.header = append(t.header, "framework", "run", "status", "time")
+
+	if len(measureNames) > 0 {
+		t.header = append(t.header, measureNames...)
+	} else {
+		t.header = append(t.header, "output")
+	}
+
+	for i, result := range results {
+		row := []string{}
+
+		for _, setting := range runs[i].combination {
+			row = append(row, numbers.Float64Str(setting.Value))
+		}
+
+		status := "ok"
+		output := ""
+
+		if result.RunResult != nil {
+			output = string(result.RunResult.Output)
+		}
+
+		if result.Err != nil {
+			status = "error"
+			output = result.Err.Error()
+		}
+
+		row = append(row,
+			result.Job.Framework,
+			fmt.Sprintf("%d", runs[i].repetition),
+			status,
+			fmt.Sprintf("%.3fs", result.Elapsed.Seconds()),
+		)
+
+		if len(measureNames) > 0 {
+			for _, name := range measureNames {
+				value, ok := measures[i][name]
+				if ok {
+					row = append(row, numbers.Float64Str(value))
+				} else {
+					row = append(row, "-")
+				}
+			}
+		} else {
+			row = append(row, lastLine(output))
+		}
+
+		t.rows = append(t.rows, row)
+		t.outputs = append(t.outputs, output)
+	}
+
+	return
+}
+
+func (t table) write(file *os.File) {
+	w := tabwriter.NewWriter(file, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, chalk.Bold(strings.Join(t.header, "\t")))
+
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	w.Flush()
+}
+
+func (t table) writeCSV(fileName string) (err error) {
+	file, err := os.Create(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+
+	err = w.Write(append(t.header, "full_output"))
+	if err != nil {
+		return
+	}
+
+	for i, row := range t.rows {
+		err = w.Write(append(row, t.outputs[i]))
+		if err != nil {
+			return
+		}
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
+
+// lastLine returns the last non-empty line of the output, truncated so it fits in a table.
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	line := strings.TrimSpace(lines[len(lines)-1])
+
+	if len(line) > maxOutputColumnLen {
+		line = line[:maxOutputColumnLen-3] + "..."
+	}
+
+	return line
+}
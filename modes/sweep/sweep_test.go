@@ -0,0 +1,32 @@
+package sweep
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/asmaloney/gactar/framework"
+
+	"github.com/asmaloney/gactar/util/cli"
+)
+
+func TestFrameworkNamesKeepsSpecOrder(t *testing.T) {
+	settings := &cli.Settings{Frameworks: framework.List{"vanilla": nil, "ccm": nil}}
+
+	s := Sweep{
+		settings: settings,
+		spec:     &Spec{Frameworks: []string{"vanilla", "ccm"}},
+	}
+
+	names, err := s.frameworkNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(names, []string{"ccm", "vanilla"}) {
+		t.Errorf("expected sorted names, got %v", names)
+	}
+
+	if !reflect.DeepEqual(s.spec.Frameworks, []string{"vanilla", "ccm"}) {
+		t.Errorf("expected spec to be unchanged, got %v", s.spec.Frameworks)
+	}
+}
@@ -0,0 +1,341 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/asmaloney/gactar/framework"
+)
+
+// apiPrefix is the prefix of the versioned API endpoints.
+const apiPrefix = "/api/v1"
+
+// legacyAPIPrefix is the prefix of the original, unversioned API. It is deprecated and only serves
+// the endpoints which existed before the API was versioned.
+const legacyAPIPrefix = "/api"
+
+// apiRoute describes an endpoint. It is used both to route requests and to generate the OpenAPI
+// document, so the two cannot disagree.
+type apiRoute struct {
+	path    string   // path after the prefix - a final "{param}" matches any name (e.g. "/jobs/{jobID}")
+	methods []string // allowed methods
+	summary string
+
+	request     interface{} // value of the request body type (nil if there is no body)
+	response    interface{} // value of the response body type (nil if it is not JSON)
+	contentType string      // content type of the response if it is not JSON
+	status      int         // status of a successful response (0 means http.StatusOK)
+
+	legacy bool // also served using legacyAPIPrefix
+
+	handler http.HandlerFunc
+}
+
+// routes returns all the API endpoints.
+func (w *Web) routes() []apiRoute {
+	routes := []apiRoute{
+		{
+			path: "/version", methods: []string{http.MethodGet},
+			summary:  "Get the version of gactar",
+			response: versionResponse{},
+			legacy:   true,
+			handler:  w.getVersionHandler,
+		},
+		{
+			path: "/frameworks", methods: []string{http.MethodGet},
+			summary:  "List the frameworks available on the server",
+			response: frameworksResponse{},
+			legacy:   true,
+			handler:  w.getFrameworksHandler,
+		},
+		{
+			path: "/run", methods: []string{http.MethodPost},
+			summary: "Run a model",
+			request: runRequest{}, response: runResult{},
+			legacy:  true,
+			handler: w.runModelHandler,
+		},
+		{
+			path: "/run/stream", methods: []string{http.MethodPost},
+			summary: "Run a model and stream the output using Server-Sent Events",
+			request: runRequest{}, contentType: "text/event-stream",
+			handler: w.runModelStreamHandler,
+		},
+		{
+			path: "/validate", methods: []string{http.MethodPost},
+			summary: "Check a model using amod & each framework's validation",
+			request: codeRequest{}, response: codeResult{},
+			handler: w.validateHandler,
+		},
+		{
+			path: "/generate", methods: []string{http.MethodPost},
+			summary: "Generate each framework's code for a model without running it",
+			request: codeRequest{}, response: codeResult{},
+			handler: w.generateHandler,
+		},
+		{
+			path: "/session/begin", methods: []string{http.MethodGet, http.MethodPost},
+			summary:  "Begin a session",
+			response: sessionResponse{},
+			legacy:   true,
+			handler:  w.beginSessionHandler,
+		},
+		{
+			path: "/session/end", methods: []string{http.MethodPost, http.MethodPut},
+			summary: "End a session",
+			request: sessionRequest{}, response: emptyResponse{},
+			legacy:  true,
+			handler: w.endSessionHandler,
+		},
+		{
+			path: "/session/runModel", methods: []string{http.MethodPost},
+			summary: "Run a model which was loaded in a session",
+			request: sessionRunRequest{}, response: sessionRunResponse{},
+			legacy:  true,
+			handler: w.runModelSessionHandler,
+		},
+		{
+			path: "/session/list", methods: []string{http.MethodGet},
+			summary:  "List the sessions (requires the admin token)",
+			response: sessionListResponse{},
+			handler:  w.listSessionsHandler,
+		},
+		{
+			path: "/session/models", methods: []string{http.MethodPost},
+			summary: "List the models loaded in a session",
+			request: sessionRequest{}, response: modelListResponse{},
+			handler: w.listModelsHandler,
+		},
+		{
+			path: "/session/runs", methods: []string{http.MethodPost},
+			summary: "List the history of the runs in a session",
+			request: sessionRequest{}, response: runHistoryResponse{},
+			handler: w.listRunsHandler,
+		},
+		{
+			path: "/session/diff", methods: []string{http.MethodPost},
+			summary: "Compare the output of two runs in a session",
+			request: runDiffRequest{}, response: runDiffResponse{},
+			handler: w.runDiffHandler,
+		},
+		{
+			path: "/model/load", methods: []string{http.MethodPost, http.MethodPut},
+			summary: "Load a model in a session",
+			request: loadModelRequest{}, response: loadModelResponse{},
+			legacy:  true,
+			handler: w.loadModelHandler,
+		},
+		{
+			path: "/model/source", methods: []string{http.MethodPost},
+			summary: "Get the amod code of a model in a session",
+			request: modelRequest{}, response: modelSourceResponse{},
+			handler: w.modelSourceHandler,
+		},
+		{
+			path: "/jobs", methods: []string{http.MethodPost},
+			summary: "Create a job to run a model in the background",
+			request: runRequest{}, response: jobCreatedResponse{},
+			status:  http.StatusAccepted,
+			handler: w.jobsHandler,
+		},
+		{
+			path: "/jobs/{jobID}", methods: []string{http.MethodGet, http.MethodDelete},
+			summary:  "Get the status & results of a job or cancel it",
+			response: jobStatus{},
+			handler:  w.jobHandler,
+		},
+	}
+
+	if w.examples != nil {
+		routes = append(routes,
+			apiRoute{
+				path: "/examples/list", methods: []string{http.MethodGet},
+				summary:  "List the examples built into the server & loaded from the example directories",
+				response: exampleListResponse{},
+				legacy:   true,
+				handler:  w.listExamples,
+			},
+			apiRoute{
+				path: "/examples/{name}", methods: []string{http.MethodGet},
+				summary:     "Get the amod code of an example",
+				contentType: "text/plain",
+				legacy:      true,
+				handler:     w.exampleHandler,
+			},
+		)
+	}
+
+	return routes
+}
+
+// apiHandler returns the handler for all the API endpoints.
+func (w *Web) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	for _, route := range w.routes() {
+		handler := allowMethods(route.methods, limitBody(w.maxRequestSize, route.handler))
+
+		mux.HandleFunc(apiPrefix+route.pattern(), handler)
+
+		if route.legacy {
+			mux.HandleFunc(legacyAPIPrefix+route.pattern(), legacyStatus(handler))
+		}
+	}
+
+	mux.HandleFunc(apiPrefix+"/openapi.json", allowMethods([]string{http.MethodGet}, w.openAPIHandler))
+
+	mux.HandleFunc(legacyAPIPrefix+"/", func(rw http.ResponseWriter, req *http.Request) {
+		encodeErrorResponse(rw, &ErrEndpointNotFound{Path: req.URL.Path})
+	})
+
+	return mux
+}
+
+// pattern returns the pattern used to route the requests. Paths ending in a parameter match
+// anything after the final "/".
+func (r apiRoute) pattern() string {
+	if i := strings.Index(r.path, "{"); i >= 0 {
+		return r.path[:i]
+	}
+
+	return r.path
+}
+
+// pathParam returns the value of the parameter at the end of the request's path.
+func pathParam(req *http.Request) string {
+	return req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+}
+
+// allowMethods returns an error if the request's method is not one of the methods.
+func allowMethods(methods []string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		for _, method := range methods {
+			if req.Method == method {
+				handler(rw, req)
+				return
+			}
+		}
+
+		rw.Header().Set("Allow", strings.Join(methods, ", "))
+		encodeErrorResponse(rw, &ErrMethodNotAllowed{Method: req.Method})
+	}
+}
+
+// legacyStatus makes the unversioned API behave as it did before it was versioned: client errors
+// are returned with http.StatusOK and the issues in the body. Requests which are over a limit
+// (http.StatusTooManyRequests and http.StatusRequestEntityTooLarge) keep their status so clients
+// can tell they should back off.
+func legacyStatus(handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		handler(legacyStatusWriter{rw}, req)
+	}
+}
+
+type legacyStatusWriter struct {
+	http.ResponseWriter
+}
+
+func (w legacyStatusWriter) WriteHeader(status int) {
+	if status >= http.StatusBadRequest && status < http.StatusInternalServerError &&
+		status != http.StatusTooManyRequests && status != http.StatusRequestEntityTooLarge {
+		status = http.StatusOK
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets streaming responses work through the legacy API.
+func (w legacyStatusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// errorStatus returns the HTTP status to use for an error.
+func errorStatus(err error) int {
+	var (
+		invalidRequest   *ErrInvalidRequest
+		invalidFramework *ErrInvalidFrameworkName
+		notActive        *ErrFrameworkNotActive
+		invalidTimeout   *ErrInvalidTimeout
+		invalidSession   *ErrInvalidSessionID
+		invalidModel     *ErrInvalidModelID
+		invalidJob       *ErrInvalidJobID
+		invalidRun       *ErrInvalidRunID
+		invalidExample   *ErrInvalidExample
+		notFound         *ErrEndpointNotFound
+		notAllowed       *ErrMethodNotAllowed
+		tooManySessions  *ErrTooManySessions
+		tooManyModels    *ErrTooManyModels
+		tooManyJobs      *ErrTooManyJobs
+		rateLimited      *ErrRateLimited
+		serverBusy       *ErrServerBusy
+		tooLarge         *ErrRequestTooLarge
+		generationFailed *framework.ErrModelGenerationFailed
+	)
+
+	switch {
+	case errors.Is(err, ErrEmptyRequestBody),
+		errors.As(err, &invalidRequest),
+		errors.As(err, &invalidFramework),
+		errors.As(err, &notActive),
+		errors.As(err, &invalidTimeout):
+		return http.StatusBadRequest
+
+	case errors.Is(err, ErrAdminOnly):
+		return http.StatusUnauthorized
+
+	case errors.Is(err, ErrAdminDisabled),
+		errors.As(err, &invalidSession),
+		errors.As(err, &invalidModel),
+		errors.As(err, &invalidJob),
+		errors.As(err, &invalidRun),
+		errors.As(err, &invalidExample),
+		errors.As(err, &notFound):
+		return http.StatusNotFound
+
+	case errors.As(err, &notAllowed):
+		return http.StatusMethodNotAllowed
+
+	case errors.As(err, &tooManyModels):
+		return http.StatusConflict
+
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+
+	case errors.As(err, &generationFailed):
+		return http.StatusUnprocessableEntity
+
+	case errors.As(err, &tooManySessions),
+		errors.As(err, &tooManyJobs),
+		errors.As(err, &rateLimited),
+		errors.As(err, &serverBusy):
+		return http.StatusTooManyRequests
+
+	case errors.Is(err, ErrShuttingDown):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+// retryAfter returns how long the client should wait before trying the request again (0 if there is
+// no point in trying again).
+func retryAfter(err error) time.Duration {
+	var (
+		rateLimited *ErrRateLimited
+		serverBusy  *ErrServerBusy
+	)
+
+	switch {
+	case errors.As(err, &rateLimited):
+		return rateLimited.RetryAfter
+
+	case errors.As(err, &serverBusy):
+		return serverBusy.RetryAfter
+	}
+
+	return 0
+}
@@ -3,11 +3,20 @@ package web
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 var (
 	ErrEmptyRequestBody = errors.New("empty request body")
 	ErrNoModel          = errors.New("no model loaded")
+
+	ErrStreamingNotSupported = errors.New("streaming not supported")
+
+	ErrAdminOnly     = errors.New("requires the admin token")
+	ErrAdminDisabled = errors.New("admin endpoints are not enabled (see --admin-token)")
+
+	ErrTLSIncomplete = errors.New("both a certificate and a key are required to use TLS")
+	ErrShuttingDown  = errors.New("server is shutting down")
 )
 
 type ErrFrameworkNotActive struct {
@@ -26,6 +35,14 @@ func (e ErrInvalidFrameworkName) Error() string {
 	return fmt.Sprintf("invalid framework name: %q", e.Name)
 }
 
+type ErrInvalidTimeout struct {
+	Timeout float64
+}
+
+func (e ErrInvalidTimeout) Error() string {
+	return fmt.Sprintf("invalid timeout: %g (must be >= 0)", e.Timeout)
+}
+
 type ErrInvalidModelID struct {
 	ID int
 }
@@ -34,10 +51,126 @@ func (e ErrInvalidModelID) Error() string {
 	return fmt.Sprintf("invalid model id: %d", e.ID)
 }
 
-type ErrInvalidSessionID struct {
+type ErrInvalidBasePath struct {
+	Path string
+}
+
+func (e ErrInvalidBasePath) Error() string {
+	return fmt.Sprintf("invalid base path: %q", e.Path)
+}
+
+type ErrInvalidTrustedProxy struct {
+	Proxy string
+}
+
+func (e ErrInvalidTrustedProxy) Error() string {
+	return fmt.Sprintf("invalid trusted proxy: %q (must be an IP address or CIDR network)", e.Proxy)
+}
+
+type ErrEndpointNotFound struct {
+	Path string
+}
+
+func (e ErrEndpointNotFound) Error() string {
+	return fmt.Sprintf("endpoint not found: %q", e.Path)
+}
+
+type ErrInvalidExample struct {
+	Name string
+}
+
+func (e ErrInvalidExample) Error() string {
+	return fmt.Sprintf("example not found: %q", e.Name)
+}
+
+type ErrInvalidJobID struct {
+	ID string
+}
+
+func (e ErrInvalidJobID) Error() string {
+	return fmt.Sprintf("invalid job id: %q (it may have expired)", e.ID)
+}
+
+type ErrInvalidRunID struct {
 	ID int
 }
 
+func (e ErrInvalidRunID) Error() string {
+	return fmt.Sprintf("invalid run id: %d (it may have been removed from the history)", e.ID)
+}
+
+type ErrInvalidRequest struct {
+	Err error
+}
+
+func (e ErrInvalidRequest) Error() string {
+	return fmt.Sprintf("invalid request: %s", e.Err.Error())
+}
+
+func (e ErrInvalidRequest) Unwrap() error {
+	return e.Err
+}
+
+type ErrInvalidSessionID struct {
+	ID string
+}
+
 func (e ErrInvalidSessionID) Error() string {
-	return fmt.Sprintf("invalid session id: %d", e.ID)
+	return fmt.Sprintf("invalid session id: %q (it may have expired)", e.ID)
+}
+
+type ErrMethodNotAllowed struct {
+	Method string
+}
+
+func (e ErrMethodNotAllowed) Error() string {
+	return fmt.Sprintf("method not allowed: %s", e.Method)
+}
+
+type ErrTooManySessions struct {
+	Max int
+}
+
+func (e ErrTooManySessions) Error() string {
+	return fmt.Sprintf("too many sessions (maximum is %d) - try again later", e.Max)
+}
+
+type ErrTooManyModels struct {
+	Max int
+}
+
+func (e ErrTooManyModels) Error() string {
+	return fmt.Sprintf("too many models in session (maximum is %d)", e.Max)
+}
+
+type ErrTooManyJobs struct {
+	Max int
+}
+
+func (e ErrTooManyJobs) Error() string {
+	return fmt.Sprintf("too many jobs (maximum is %d) - try again later", e.Max)
+}
+
+type ErrRateLimited struct {
+	RetryAfter time.Duration
+}
+
+func (e ErrRateLimited) Error() string {
+	return fmt.Sprintf("too many runs - try again in %ds", retryAfterSeconds(e.RetryAfter))
+}
+
+type ErrServerBusy struct {
+	RetryAfter time.Duration
+}
+
+func (e ErrServerBusy) Error() string {
+	return fmt.Sprintf("server is busy running other models - try again in %ds", retryAfterSeconds(e.RetryAfter))
+}
+
+type ErrRequestTooLarge struct {
+	Max int64
+}
+
+func (e ErrRequestTooLarge) Error() string {
+	return fmt.Sprintf("request is too large (maximum is %d bytes)", e.Max)
 }
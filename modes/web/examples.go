@@ -1,32 +1,330 @@
 package web
 
-import "net/http"
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
-func initExamples(w *Web) {
-	exampleHandler := assetHandler(w.examples, "/api/examples/", "")
-	http.HandleFunc("/api/examples/", exampleHandler.ServeHTTP)
-	http.HandleFunc("/api/examples/list", w.listExamples)
+	"github.com/asmaloney/gactar/amod"
+
+	"github.com/asmaloney/gactar/util/chalk"
+	"github.com/asmaloney/gactar/util/filesystem"
+)
+
+// builtInExampleGroup is the name of the group of examples which are built into gactar.
+const builtInExampleGroup = "gactar"
+
+// exampleReloadInterval is how often we check the example directories for changes when
+// reloading is turned on.
+const exampleReloadInterval = 5 * time.Second
+
+// exampleSet holds the examples we serve: the ones built into gactar and the ones loaded from the
+// directories given using --examples. It is safe for concurrent use.
+type exampleSet struct {
+	builtIn *embed.FS // nil if there are no built-in examples
+
+	mutex sync.RWMutex
+	dirs  []*exampleDir // in the order they were given
 }
 
-// listExamples simply returns a list of the examples included in the build.
-func (w *Web) listExamples(rw http.ResponseWriter, req *http.Request) {
-	type response struct {
-		List []string `json:"exampleList"`
+// exampleDir is a group of examples loaded from the amod files in a directory.
+type exampleDir struct {
+	name      string            // name of the group (the directory's name - made unique)
+	path      string            // absolute path of the directory
+	files     map[string]string // amod code by file name
+	signature string            // names, sizes & modification times of the files to tell if they changed
+}
+
+// exampleGroup is a group of examples in the example list.
+type exampleGroup struct {
+	Name     string   `json:"name"`     // "gactar" for the built-in examples or the name of the directory
+	Examples []string `json:"examples"` // names to use with /examples/{name}
+}
+
+type exampleListResponse struct {
+	List   []string       `json:"exampleList"` // names of all the examples
+	Groups []exampleGroup `json:"groups"`
+}
+
+// newExampleSet loads the examples from the directories. Problems with the amod files are output as
+// warnings - the files are still served. It returns nil if there are no examples.
+func newExampleSet(builtIn *embed.FS, dirs []string) (set *exampleSet, err error) {
+	if builtIn == nil && len(dirs) == 0 {
+		return
+	}
+
+	set = &exampleSet{builtIn: builtIn}
+
+	names := map[string]bool{}
+	if builtIn != nil {
+		names[builtInExampleGroup] = true
+	}
+
+	for _, dir := range dirs {
+		path, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		if !filesystem.DirExists(path) {
+			return nil, &filesystem.ErrDirDoesNotExist{DirName: dir}
+		}
+
+		exampleDir := &exampleDir{
+			name: uniqueGroupName(filepath.Base(path), names),
+			path: path,
+		}
+
+		err = exampleDir.load()
+		if err != nil {
+			return nil, err
+		}
+
+		set.dirs = append(set.dirs, exampleDir)
+	}
+
+	return
+}
+
+// uniqueGroupName returns the name or, if it is already used, the name with a number added.
+func uniqueGroupName(name string, used map[string]bool) string {
+	unique := name
+
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+
+	used[unique] = true
+
+	return unique
+}
+
+// load reads the amod files in the directory and checks that each one generates a model.
+func (d *exampleDir) load() (err error) {
+	signature, err := dirSignature(d.path)
+	if err != nil {
+		return
 	}
 
-	entries, err := w.examples.ReadDir(".")
+	entries, err := os.ReadDir(d.path)
 	if err != nil {
-		encodeErrorResponse(rw, err)
 		return
 	}
 
-	list := []string{}
+	files := map[string]string{}
+
+	for _, entry := range entries {
+		if !isExampleFile(entry) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(d.path, entry.Name()))
+		if err != nil {
+			chalk.PrintWarningStr(fmt.Sprintf("warning: could not read example %s/%s: %s", d.name, entry.Name(), err.Error()))
+			continue
+		}
+
+		source := string(data)
+
+		_, log, err := amod.GenerateModel(source)
+		if err != nil {
+			chalk.PrintWarningStr(fmt.Sprintf("warning: example %s/%s has errors:", d.name, entry.Name()))
+			fmt.Print(log)
+		}
+
+		files[entry.Name()] = source
+	}
+
+	d.files = files
+	d.signature = signature
+
+	return
+}
+
+// dirSignature returns a string which changes if any of the amod files in the directory change.
+func dirSignature(path string) (signature string, err error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return
+	}
+
+	var b strings.Builder
 
 	for _, entry := range entries {
-		list = append(list, entry.Name())
+		if !isExampleFile(entry) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// removed since we read the directory
+			continue
+		}
+
+		fmt.Fprintf(&b, "%s:%d:%d\n", entry.Name(), info.Size(), info.ModTime().UnixNano())
 	}
 
-	encodeResponse(rw, response{
-		List: list,
+	return b.String(), nil
+}
+
+func isExampleFile(entry fs.DirEntry) bool {
+	return entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".amod")
+}
+
+// reload loads the directories which changed since they were last loaded.
+func (s *exampleSet) reload() {
+	s.mutex.RLock()
+	dirs := make([]exampleDir, len(s.dirs))
+	for i, dir := range s.dirs {
+		dirs[i] = *dir
+	}
+	s.mutex.RUnlock()
+
+	for i := range dirs {
+		signature, err := dirSignature(dirs[i].path)
+		if err != nil {
+			chalk.PrintWarningStr(fmt.Sprintf("warning: could not reload examples from %q: %s", dirs[i].path, err.Error()))
+			continue
+		}
+
+		if signature == dirs[i].signature {
+			continue
+		}
+
+		err = dirs[i].load()
+		if err != nil {
+			chalk.PrintWarningStr(fmt.Sprintf("warning: could not reload examples from %q: %s", dirs[i].path, err.Error()))
+			continue
+		}
+
+		fmt.Printf("Reloaded %d example(s) from %q\n", len(dirs[i].files), dirs[i].path)
+
+		s.mutex.Lock()
+		s.dirs[i] = &dirs[i]
+		s.mutex.Unlock()
+	}
+}
+
+// watch reloads the example directories when they change.
+func (s *exampleSet) watch() {
+	ticker := time.NewTicker(exampleReloadInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.reload()
+	}
+}
+
+// groups returns the examples grouped by where they came from: the built-in examples first, then
+// each directory in the order they were given.
+func (s *exampleSet) groups() (groups []exampleGroup, err error) {
+	groups = []exampleGroup{}
+
+	if s.builtIn != nil {
+		entries, err := s.builtIn.ReadDir(".")
+		if err != nil {
+			return nil, err
+		}
+
+		group := exampleGroup{Name: builtInExampleGroup, Examples: []string{}}
+		for _, entry := range entries {
+			group.Examples = append(group.Examples, entry.Name())
+		}
+
+		groups = append(groups, group)
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, dir := range s.dirs {
+		group := exampleGroup{Name: dir.name, Examples: []string{}}
+		for fileName := range dir.files {
+			group.Examples = append(group.Examples, dir.name+"/"+fileName)
+		}
+
+		sort.Strings(group.Examples)
+
+		groups = append(groups, group)
+	}
+
+	return
+}
+
+// source returns the amod code of an example. The names of examples from directories start with
+// their group's name (e.g. "assignments/count.amod").
+func (s *exampleSet) source(name string) (source []byte, err error) {
+	groupName, fileName, inGroup := strings.Cut(name, "/")
+
+	if !inGroup {
+		if s.builtIn == nil {
+			return nil, &ErrInvalidExample{Name: name}
+		}
+
+		source, err = s.builtIn.ReadFile(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			err = &ErrInvalidExample{Name: name}
+		}
+
+		return
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, dir := range s.dirs {
+		if dir.name != groupName {
+			continue
+		}
+
+		code, ok := dir.files[fileName]
+		if ok {
+			return []byte(code), nil
+		}
+	}
+
+	return nil, &ErrInvalidExample{Name: name}
+}
+
+// listExamples returns the examples built into the server along with those from the example
+// directories.
+func (w *Web) listExamples(rw http.ResponseWriter, req *http.Request) {
+	groups, err := w.examples.groups()
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	list := []string{}
+	for _, group := range groups {
+		list = append(list, group.Examples...)
+	}
+
+	encodeResponse(rw, exampleListResponse{
+		List:   list,
+		Groups: groups,
 	})
 }
+
+// exampleHandler returns the amod code of an example.
+func (w *Web) exampleHandler(rw http.ResponseWriter, req *http.Request) {
+	// the name may include the example's group, so use everything after "/examples/"
+	_, name, _ := strings.Cut(req.URL.Path, "/examples/")
+
+	data, err := w.examples.source(name)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = rw.Write(data)
+}
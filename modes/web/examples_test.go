@@ -0,0 +1,154 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/asmaloney/gactar/examples"
+
+	"github.com/asmaloney/gactar/util/filesystem"
+)
+
+// writeExampleFiles creates a directory with the files in it and returns its path.
+func writeExampleFiles(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+
+	err := os.MkdirAll(dir, 0750)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, contents := range files {
+		err = os.WriteFile(filepath.Join(dir, name), []byte(contents), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestExampleDirs(t *testing.T) {
+	tempDir := t.TempDir()
+
+	dir1 := writeExampleFiles(t, filepath.Join(tempDir, "one", "assignments"), map[string]string{
+		"good.amod":   testSource,
+		"broken.amod": "~~ model ~~\n",
+		"notes.txt":   "not an example",
+	})
+
+	dir2 := writeExampleFiles(t, filepath.Join(tempDir, "two", "assignments"), map[string]string{
+		"other.amod": testSource,
+	})
+
+	set, err := newExampleSet(&examples.AMODExamples, []string{dir1, dir2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := newTestWeb(t, DefaultOptions)
+	w.examples = set
+
+	var list exampleListResponse
+
+	testRequest(t, w.apiHandler(), http.MethodGet, "/api/v1/examples/list", nil, &list)
+
+	if len(list.Groups) != 3 || list.Groups[0].Name != builtInExampleGroup {
+		t.Fatalf("expected the built-in group & a group for each directory, got %+v", list.Groups)
+	}
+
+	// broken files are still served
+	expected := []exampleGroup{
+		{Name: "assignments", Examples: []string{"assignments/broken.amod", "assignments/good.amod"}},
+		{Name: "assignments-2", Examples: []string{"assignments-2/other.amod"}},
+	}
+
+	if !reflect.DeepEqual(list.Groups[1:], expected) {
+		t.Errorf("expected groups %+v, got %+v", expected, list.Groups[1:])
+	}
+
+	if len(list.List) != len(list.Groups[0].Examples)+3 {
+		t.Errorf("expected the list to include every example, got %v", list.List)
+	}
+
+	tests := []struct {
+		target   string
+		status   int
+		contents string
+	}{
+		{"/api/v1/examples/assignments/good.amod", http.StatusOK, testSource},
+		{"/api/examples/assignments-2/other.amod", http.StatusOK, testSource},
+		{"/api/v1/examples/count.amod", http.StatusOK, ""},
+		{"/api/v1/examples/assignments/notes.txt", http.StatusNotFound, ""},
+		{"/api/v1/examples/nope/good.amod", http.StatusNotFound, ""},
+		{"/api/v1/examples/nope.amod", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		response := testRequest(t, w.apiHandler(), http.MethodGet, tt.target, nil, nil)
+
+		if response.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.target, tt.status, response.Code)
+			continue
+		}
+
+		if tt.contents != "" && response.Body.String() != tt.contents {
+			t.Errorf("%s: unexpected contents %q", tt.target, response.Body.String())
+		}
+	}
+}
+
+func TestExampleReload(t *testing.T) {
+	dir := writeExampleFiles(t, filepath.Join(t.TempDir(), "assignments"), map[string]string{
+		"good.amod": testSource,
+	})
+
+	set, err := newExampleSet(nil, []string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := testSource + "\n// changed"
+
+	writeExampleFiles(t, dir, map[string]string{
+		"good.amod": changed,
+		"new.amod":  testSource,
+	})
+
+	set.reload()
+
+	source, err := set.source("assignments/good.amod")
+	if err != nil || string(source) != changed {
+		t.Errorf("expected changed example after reload, got %q (%v)", source, err)
+	}
+
+	_, err = set.source("assignments/new.amod")
+	if err != nil {
+		t.Errorf("expected new example after reload: %s", err.Error())
+	}
+
+	var invalid *ErrInvalidExample
+
+	_, err = set.source("count.amod")
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected ErrInvalidExample without built-in examples, got %v", err)
+	}
+}
+
+func TestExampleDirNotFound(t *testing.T) {
+	_, err := newExampleSet(nil, []string{filepath.Join(t.TempDir(), "missing")})
+
+	var notFound *filesystem.ErrDirDoesNotExist
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected ErrDirDoesNotExist, got %v", err)
+	}
+
+	set, err := newExampleSet(nil, nil)
+	if set != nil || err != nil {
+		t.Errorf("expected no example set, got %v (%v)", set, err)
+	}
+}
@@ -2,10 +2,14 @@ import axios, { AxiosInstance } from 'axios'
 
 let gactarHTTP: AxiosInstance
 
-function init(port: number) {
+// init sets up the API using the URL of the page so it works with any host, port, or base path.
+function init(pageURL: string) {
   gactarHTTP = axios.create({
     headers: { 'Content-Type': 'application/json' },
-    baseURL: `http://localhost:${port}`,
+    baseURL: new URL('api/v1', pageURL).href,
+
+    // Client errors (4xx) return the issues in the body, so let the caller handle them.
+    validateStatus: (status) => status < 500,
   })
 }
 
@@ -18,7 +22,7 @@ export interface VersionResponse {
 }
 
 async function getVersion(): Promise<Version> {
-  const response = await gactarHTTP.get<VersionResponse>('/api/version')
+  const response = await gactarHTTP.get<VersionResponse>('/version')
   return response.data.version
 }
 
@@ -48,7 +52,7 @@ export interface FrameworkInfoResponse {
 
 async function getFrameworks(): Promise<FrameworkInfoList> {
   const response = await gactarHTTP.get<FrameworkInfoResponse>(
-    '/api/frameworks'
+    '/frameworks'
   )
   return response.data.frameworks
 }
@@ -110,38 +114,56 @@ export interface RunResult {
 }
 
 async function run(params: RunParams): Promise<RunResult> {
-  const response = await gactarHTTP.post<RunResult>('/api/run', params)
+  const response = await gactarHTTP.post<RunResult>('/run', params)
   return response.data
 }
 
 // examples
-// List of example names which are built into the webserver.
+// List of example names which are built into the webserver or loaded from the
+// example directories. Names of examples from directories start with their group.
 export type ExampleList = string[]
 
+export interface ExampleGroup {
+  // "gactar" for the built-in examples or the name of the example directory.
+  name: string
+
+  examples: ExampleList
+}
+
+export type ExampleGroupList = ExampleGroup[]
+
 export interface ExampleListResponse {
   exampleList: ExampleList
+  groups: ExampleGroupList
 }
 
 async function getExampleList(): Promise<ExampleList> {
   const response = await gactarHTTP.get<ExampleListResponse>(
-    '/api/examples/list'
+    '/examples/list'
   )
   return response.data.exampleList
 }
 
+async function getExampleGroups(): Promise<ExampleGroupList> {
+  const response = await gactarHTTP.get<ExampleListResponse>(
+    '/examples/list'
+  )
+  return response.data.groups
+}
+
 async function getExample(name: string): Promise<string> {
-  const response = await gactarHTTP.get<string>('/api/examples/' + name)
+  const response = await gactarHTTP.get<string>('/examples/' + name)
   return response.data
 }
 
 // sessions
 export interface Session {
-  sessionID: number
+  sessionID: string
 }
 
 export interface SessionRunParams {
   // The id of the session.
-  sessionID: number
+  sessionID: string
 
   // The ID of the model to run.
   modelID: number
@@ -158,7 +180,7 @@ export interface SessionRunParams {
 
 export interface SessionRunResult extends FrameworkResult {
   // The id of the session.
-  sessionID: number
+  sessionID: string
 
   // The ID of the model which was run.
   modelID: number
@@ -171,12 +193,12 @@ export interface SessionRunResults {
 }
 
 async function sessionBegin(): Promise<Session> {
-  const response = await gactarHTTP.get<Session>('/api/session/begin')
+  const response = await gactarHTTP.post<Session>('/session/begin')
   return response.data
 }
 
 async function sessionEnd(session: Session): Promise<void> {
-  await gactarHTTP.put<Session>('/api/session/end', session)
+  await gactarHTTP.post<Session>('/session/end', session)
   return
 }
 
@@ -184,7 +206,7 @@ async function sessionRun(
   params: SessionRunParams
 ): Promise<SessionRunResults> {
   const response = await gactarHTTP.post<SessionRunResults>(
-    '/api/session/runModel',
+    '/session/runModel',
     params
   )
   return response.data
@@ -196,7 +218,7 @@ export interface ModelParams {
   amod: string
 
   // The id of the session to load this model in.
-  sessionID: number
+  sessionID: string
 }
 
 export interface ModelLoadResult {
@@ -207,12 +229,12 @@ export interface ModelLoadResult {
   modelName: string
 
   // The id of the session.
-  sessionID: number
+  sessionID: string
 }
 
 async function modelLoad(params: ModelParams): Promise<ModelLoadResult> {
-  const response = await gactarHTTP.put<ModelLoadResult>(
-    '/api/session/begin',
+  const response = await gactarHTTP.post<ModelLoadResult>(
+    '/model/load',
     params
   )
   return response.data
@@ -220,6 +242,7 @@ async function modelLoad(params: ModelParams): Promise<ModelLoadResult> {
 
 export default {
   getExample,
+  getExampleGroups,
   getExampleList,
   getFrameworks,
   getVersion,
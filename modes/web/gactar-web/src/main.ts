@@ -60,7 +60,7 @@ import './app.scss'
 // Our internal API
 import api from './api'
 
-api.init(parseInt(window.location.port))
+api.init(document.baseURI)
 
 new Vue({
   render: (h) => h(App),
@@ -5,6 +5,9 @@ import vue from '@vitejs/plugin-vue2'
 
 // https://vitejs.dev/config/
 export default defineConfig({
+  // Use relative paths so the UI may be served using a base path (see "gactar web --base-path").
+  base: './',
+
   plugins: [compress({ algorithm: 'brotliCompress' }), vue()],
 
   server: {
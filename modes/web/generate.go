@@ -0,0 +1,184 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/asmaloney/gactar/actr"
+	"github.com/asmaloney/gactar/amod"
+	"github.com/asmaloney/gactar/framework"
+
+	"github.com/asmaloney/gactar/util/issues"
+	"github.com/asmaloney/gactar/util/validate"
+)
+
+// codeRequest is the request for the /api/validate and /api/generate endpoints.
+type codeRequest struct {
+	AMODFile   string                   `json:"amod"`                 // text of an amod file
+	Goal       string                   `json:"goal"`                 // initial goal
+	Buffers    framework.InitialBuffers `json:"buffers,omitempty"`    // initial contents of other buffers
+	Frameworks []string                 `json:"frameworks,omitempty"` // list of frameworks to use (if empty, "all")
+}
+
+// frameworkCodeResult is the result of validating a model or generating its code for one framework.
+type frameworkCodeResult struct {
+	ModelName string            `json:"modelName"`        // name of the model (from the amod file)
+	Issues    *issues.IssueList `json:"issues,omitempty"` // issues specific to this framework
+	Code      *string           `json:"code,omitempty"`   // generated code (only for /api/generate)
+}
+
+type codeResult struct {
+	Issues  issues.IssueList               `json:"issues,omitempty"`
+	Results map[string]frameworkCodeResult `json:"results,omitempty"`
+}
+
+// validateHandler returns the issues with the model along with each framework's issues with it.
+func (w Web) validateHandler(rw http.ResponseWriter, req *http.Request) {
+	data, model, _, log, ok := w.prepareCode(rw, req)
+	if !ok {
+		return
+	}
+
+	results := map[string]frameworkCodeResult{}
+
+	for _, name := range data.Frameworks {
+		frameworkLog := w.settings.Frameworks[name].ValidateModel(model)
+
+		results[name] = newFrameworkCodeResult(model, frameworkLog, nil)
+	}
+
+	encodeResponse(rw, codeResult{
+		Issues:  log.AllIssues(),
+		Results: results,
+	})
+}
+
+// generateHandler returns the code each framework generates for the model without running it.
+// Since generating code may run an external generator, it is limited like running a model.
+func (w Web) generateHandler(rw http.ResponseWriter, req *http.Request) {
+	err := w.rateLimit.allow(w.clientKey(req, ""))
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	data, model, initialBuffers, log, ok := w.prepareCode(rw, req)
+	if !ok {
+		return
+	}
+
+	ctx, cancel, err := w.runContext(req, 0)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+	defer cancel()
+
+	reservation, err := w.runs.admit(len(data.Frameworks))
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+	defer reservation.cancel()
+
+	results := map[string]frameworkCodeResult{}
+
+	for _, name := range data.Frameworks {
+		f := w.settings.Frameworks[name]
+
+		var code []byte
+
+		frameworkLog := f.ValidateModel(model)
+		if !frameworkLog.HasError() {
+			code, err = w.generateFrameworkCode(ctx, f, model, initialBuffers, reservation)
+			if err != nil {
+				frameworkLog.Error(nil, err.Error())
+			}
+		}
+
+		results[name] = newFrameworkCodeResult(model, frameworkLog, code)
+	}
+
+	encodeResponse(rw, codeResult{
+		Issues:  log.AllIssues(),
+		Results: results,
+	})
+}
+
+// generateFrameworkCode waits for its turn to run using a place from reservation and generates the
+// framework's code.
+func (w Web) generateFrameworkCode(ctx context.Context, f framework.Framework, model *actr.Model, initialBuffers framework.InitialBuffers, reservation *runReservation) (code []byte, err error) {
+	err = w.runs.acquire(ctx, reservation)
+	if err != nil {
+		err = fmt.Errorf("code generation did not start: %w", err)
+		return
+	}
+	defer w.runs.release()
+
+	return f.GenerateCode(ctx, model, initialBuffers)
+}
+
+// prepareCode decodes and checks a validate or generate request, generates the model, and checks
+// the initial buffers. If there is a problem, the error response is written and ok is false.
+func (w Web) prepareCode(rw http.ResponseWriter, req *http.Request) (data codeRequest, model *actr.Model, initialBuffers framework.InitialBuffers, log *issues.Log, ok bool) {
+	err := decodeBody(req, &data)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	data.Frameworks = w.normalizeFrameworkList(data.Frameworks)
+
+	err = w.verifyFrameworkList(data.Frameworks)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	model, log, err = amod.GenerateModel(data.AMODFile)
+	if err != nil {
+		encodeIssueResponse(rw, log)
+		return
+	}
+
+	initialBuffers = framework.InitialBuffers{}
+	for name, contents := range data.Buffers {
+		initialBuffers[name] = strings.TrimSpace(contents)
+	}
+
+	goal := strings.TrimSpace(data.Goal)
+	if goal != "" {
+		initialBuffers["goal"] = goal
+	}
+
+	validate.Goal(model, initialBuffers["goal"], log)
+
+	_, err = framework.ParseInitialBuffers(model, initialBuffers)
+	if err != nil {
+		log.Error(nil, err.Error())
+		encodeIssueResponse(rw, log)
+		return
+	}
+
+	ok = true
+	return
+}
+
+// newFrameworkCodeResult returns the result for a framework from its issues & generated code.
+func newFrameworkCodeResult(model *actr.Model, log *issues.Log, code []byte) (result frameworkCodeResult) {
+	result.ModelName = model.Name
+
+	if log.HasIssues() {
+		all := log.AllIssues()
+		result.Issues = &all
+	}
+
+	if len(code) > 0 {
+		codeStr := string(code)
+		result.Code = &codeStr
+	}
+
+	return
+}
@@ -0,0 +1,123 @@
+//go:build !windows
+
+package web
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateHandler(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+
+	var result codeResult
+
+	testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/generate", codeRequest{
+		AMODFile:   testSource,
+		Goal:       "[count: 1]",
+		Frameworks: []string{"text"},
+	}, &result)
+
+	text, ok := result.Results["text"]
+	if !ok {
+		t.Fatalf("missing result for framework: %+v", result)
+	}
+
+	expected := "model: Test\nproduction: START"
+	if text.Code == nil || !strings.Contains(*text.Code, expected) {
+		t.Errorf("expected code to contain %q, got %+v", expected, text)
+	}
+
+	// An invalid initial buffer is reported using the model's issues
+	result = codeResult{}
+
+	testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/generate", codeRequest{
+		AMODFile:   testSource,
+		Buffers:    map[string]string{"goal": "[nope: 1]"},
+		Frameworks: []string{"text"},
+	}, &result)
+
+	if len(result.Issues) == 0 || result.Results != nil {
+		t.Errorf("expected only issues for invalid buffer, got %+v", result)
+	}
+}
+
+func TestGenerateLimits(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+	w.settings.Timeout = 10 * time.Millisecond
+	w.runs = newRunLimiter(1, 1)
+
+	err := w.runs.acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the only slot is in use, so code generation times out waiting for it
+	var result codeResult
+
+	testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/generate", codeRequest{
+		AMODFile:   testSource,
+		Goal:       "[count: 1]",
+		Frameworks: []string{"text"},
+	}, &result)
+
+	text := result.Results["text"]
+	if text.Code != nil || text.Issues == nil || !strings.Contains((*text.Issues)[0].Text, "did not start") {
+		t.Errorf("expected code generation not to start, got %+v", text)
+	}
+
+	w.runs.release()
+
+	// each client may only generate code a limited number of times - the first request is allowed
+	// (but is not valid)
+	w.rateLimit = newRateLimiter(1, 1)
+
+	body := `{"amod": "~~ model ~~", "frameworks": ["text"]}`
+
+	for _, expected := range []int{http.StatusUnprocessableEntity, http.StatusTooManyRequests} {
+		status := testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/generate", body, nil).Code
+		if status != expected {
+			t.Errorf("expected status %d, got %d", expected, status)
+		}
+	}
+}
+
+func TestValidateHandler(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+
+	var result codeResult
+
+	testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/validate", codeRequest{
+		AMODFile:   testSource,
+		Frameworks: []string{"text"},
+	}, &result)
+
+	// no goal, so we get a warning from the amod validation
+	if len(result.Issues) != 1 || !strings.Contains(result.Issues[0].Text, "initial goal not provided") {
+		t.Errorf("expected missing goal warning, got %+v", result.Issues)
+	}
+
+	text, ok := result.Results["text"]
+	if !ok {
+		t.Fatalf("missing result for framework: %+v", result)
+	}
+
+	if text.Code != nil {
+		t.Errorf("expected validate not to generate code")
+	}
+
+	// amod errors are returned without framework results
+	result = codeResult{}
+
+	testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/validate", codeRequest{
+		AMODFile:   "~~ model ~~",
+		Frameworks: []string{"text"},
+	}, &result)
+
+	if len(result.Issues) == 0 || result.Issues[0].Level != "error" || result.Results != nil {
+		t.Errorf("expected amod errors, got %+v", result)
+	}
+}
@@ -0,0 +1,139 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asmaloney/gactar/framework"
+	"github.com/asmaloney/gactar/framework/plugin"
+
+	"github.com/asmaloney/gactar/util/executil"
+	"github.com/asmaloney/gactar/util/filesystem"
+	"github.com/asmaloney/gactar/util/python"
+)
+
+// healthCacheDuration is how long we reuse the result of a health check so frequent probes do not
+// keep starting the frameworks' executables.
+const healthCacheDuration = 10 * time.Second
+
+// healthCheckTimeout is how long each framework's executable has to start.
+const healthCheckTimeout = 10 * time.Second
+
+// Status of the server in the health response.
+const (
+	healthOK        = "ok"
+	healthUnhealthy = "unhealthy"
+)
+
+// frameworkHealth is the result of checking that a framework can start.
+type frameworkHealth struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status     string                     `json:"status"`
+	Checked    time.Time                  `json:"checked"`
+	Frameworks map[string]frameworkHealth `json:"frameworks"`
+}
+
+// healthChecker checks the frameworks and caches the result. It is safe for concurrent use.
+type healthChecker struct {
+	mutex  sync.Mutex
+	result *healthResponse
+}
+
+// healthHandler returns http.StatusOK if every framework can start and
+// http.StatusServiceUnavailable if any cannot.
+func (w *Web) healthHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		rw.Header().Set("Allow", "GET, HEAD")
+		encodeErrorResponse(rw, &ErrMethodNotAllowed{Method: req.Method})
+		return
+	}
+
+	result := w.health.check(req.Context(), w.settings.Frameworks)
+
+	status := http.StatusOK
+	if result.Status != healthOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	encodeResponseStatus(rw, status, result)
+}
+
+// check checks each framework unless it was done recently. Requests which arrive while the check
+// is happening wait for it and share the result.
+func (h *healthChecker) check(ctx context.Context, frameworks framework.List) healthResponse {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.result != nil && time.Since(h.result.Checked) < healthCacheDuration {
+		return *h.result
+	}
+
+	result := healthResponse{
+		Status:     healthOK,
+		Checked:    time.Now(),
+		Frameworks: map[string]frameworkHealth{},
+	}
+
+	names := frameworks.Names()
+	sort.Strings(names)
+
+	for _, name := range names {
+		health := frameworkHealth{OK: true}
+
+		err := checkFrameworkStarts(ctx, frameworks[name].Info())
+		if err != nil {
+			health = frameworkHealth{Error: err.Error()}
+			result.Status = healthUnhealthy
+		}
+
+		result.Frameworks[name] = health
+	}
+
+	// don't keep the result if the client went away before we were done
+	if ctx.Err() == nil {
+		h.result = &result
+	}
+
+	return result
+}
+
+// checkFrameworkStarts checks that the framework's executable is available and can be started the
+// same way as when the framework was set up: using its version arguments and checking its python
+// packages.
+func checkFrameworkStarts(ctx context.Context, info *framework.Info) (err error) {
+	_, err = filesystem.CheckForExecutable(info.ExecutableName)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	versionArgs := []string{"--version"}
+	if manifest := plugin.Lookup(info.Name); manifest != nil {
+		versionArgs = manifest.VersionArgs
+	}
+
+	if len(versionArgs) > 0 {
+		_, err = executil.ExecCommandContext(ctx, info.ExecutableName, versionArgs...)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, packageName := range info.PythonRequiredPackages {
+		err = python.CheckForPackage(info.ExecutableName, packageName)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,49 @@
+//go:build !windows
+
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/asmaloney/gactar/framework"
+)
+
+func TestHealthHandler(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+	w.health = &healthChecker{}
+
+	responseRecorder := httptest.NewRecorder()
+
+	w.healthHandler(responseRecorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, responseRecorder.Code, responseRecorder.Body.String())
+	}
+
+	var response healthResponse
+
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Status != healthOK || !response.Frameworks["text"].OK {
+		t.Errorf("expected framework to be healthy: %+v", response)
+	}
+}
+
+func TestCheckFrameworkStarts(t *testing.T) {
+	err := checkFrameworkStarts(context.Background(), &framework.Info{Name: "missing", ExecutableName: "gactar-no-such-executable"})
+	if err == nil {
+		t.Errorf("expected an error for a missing executable")
+	}
+
+	err = checkFrameworkStarts(context.Background(), &framework.Info{Name: "sh", ExecutableName: "false"})
+	if err == nil {
+		t.Errorf("expected an error for an executable which fails")
+	}
+}
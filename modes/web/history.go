@@ -0,0 +1,225 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/kylelemons/godebug/diff"
+
+	"github.com/asmaloney/gactar/framework"
+
+	"github.com/asmaloney/gactar/util/issues"
+)
+
+// runRecord is a run of a model in a session which is kept in the session's history. It is not
+// changed once it is added, so it may be shared without locking.
+type runRecord struct {
+	RunID          int                        `json:"runID"`
+	ModelID        int                        `json:"modelID"`
+	ModelName      string                     `json:"modelName"`
+	ModelHash      string                     `json:"modelHash"` // SHA-256 of the amod code (hex)
+	InitialBuffers framework.InitialBuffers   `json:"buffers"`
+	Frameworks     []string                   `json:"frameworks"`
+	Started        time.Time                  `json:"started"`
+	Finished       time.Time                  `json:"finished"`
+	Results        map[string]runRecordResult `json:"results"`
+}
+
+// runRecordResult is the part of a framework's result we keep in the history.
+type runRecordResult struct {
+	Output   string           `json:"output"`
+	Issues   issues.IssueList `json:"issues,omitempty"`
+	TimedOut bool             `json:"timedOut,omitempty"`
+}
+
+// newRunRecord creates the record of a run from its results.
+func newRunRecord(model *Model, initialBuffers framework.InitialBuffers, frameworkNames []string, started, finished time.Time, resultMap frameworkRunResultMap) *runRecord {
+	hash := sha256.Sum256([]byte(model.source))
+
+	record := &runRecord{
+		ModelID:        model.id,
+		ModelName:      model.actrModel.Name,
+		ModelHash:      hex.EncodeToString(hash[:]),
+		InitialBuffers: initialBuffers,
+		Frameworks:     frameworkNames,
+		Started:        started,
+		Finished:       finished,
+		Results:        map[string]runRecordResult{},
+	}
+
+	for name, result := range resultMap {
+		recordResult := runRecordResult{
+			TimedOut: result.TimedOut,
+		}
+
+		if result.Output != nil {
+			recordResult.Output = *result.Output
+		}
+
+		if result.Issues != nil {
+			recordResult.Issues = *result.Issues
+		}
+
+		record.Results[name] = recordResult
+	}
+
+	return record
+}
+
+// addRun adds the run to the session's history and assigns its ID. If the history has more than
+// maxRuns runs, the oldest ones are removed. It returns false if the history is turned off.
+func (s *Session) addRun(record *runRecord, maxRuns int) (added bool) {
+	if maxRuns <= 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.nextRunID++
+	record.RunID = s.nextRunID
+
+	s.runs = append(s.runs, record)
+
+	if len(s.runs) > maxRuns {
+		s.runs = append([]*runRecord{}, s.runs[len(s.runs)-maxRuns:]...)
+	}
+
+	return true
+}
+
+func (s *Session) lookupRun(runID int) *runRecord {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, run := range s.runs {
+		if run.RunID == runID {
+			return run
+		}
+	}
+
+	return nil
+}
+
+// runRecords returns the session's history, oldest first.
+func (s *Session) runRecords() (runs []runRecord) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	runs = []runRecord{}
+	for _, run := range s.runs {
+		runs = append(runs, *run)
+	}
+
+	return
+}
+
+type runHistoryResponse struct {
+	SessionID string      `json:"sessionID"`
+	Runs      []runRecord `json:"runs"`
+	MaxRuns   int         `json:"maxRuns"` // number of runs which are kept
+}
+
+// listRunsHandler returns the history of the runs in a session.
+func (w *Web) listRunsHandler(rw http.ResponseWriter, req *http.Request) {
+	var data sessionRequest
+	err := decodeBody(req, &data)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	session := w.sessions.lookup(data.SessionID)
+	if session == nil {
+		encodeErrorResponse(rw, &ErrInvalidSessionID{ID: data.SessionID})
+		return
+	}
+
+	encodeResponse(rw, runHistoryResponse{
+		SessionID: session.id,
+		Runs:      session.runRecords(),
+		MaxRuns:   w.sessions.maxRuns,
+	})
+}
+
+type runDiffRequest struct {
+	SessionID string `json:"sessionID"`
+	FromRunID int    `json:"fromRunID"`
+	ToRunID   int    `json:"toRunID"`
+}
+
+// frameworkDiff compares the output of two runs on a framework.
+type frameworkDiff struct {
+	Identical bool   `json:"identical"`
+	Diff      string `json:"diff"` // each line starts with " " (in both), "-" (only in "from"), or "+" (only in "to")
+}
+
+type runDiffResponse struct {
+	SessionID string                   `json:"sessionID"`
+	FromRunID int                      `json:"fromRunID"`
+	ToRunID   int                      `json:"toRunID"`
+	SameModel bool                     `json:"sameModel"` // true if the amod code of the runs was the same
+	Diffs     map[string]frameworkDiff `json:"diffs"`
+}
+
+// runDiffHandler compares the output of two runs in a session for each framework. A framework
+// which was only used in one of the runs is compared with empty output.
+func (w *Web) runDiffHandler(rw http.ResponseWriter, req *http.Request) {
+	var data runDiffRequest
+	err := decodeBody(req, &data)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	session := w.sessions.lookup(data.SessionID)
+	if session == nil {
+		encodeErrorResponse(rw, &ErrInvalidSessionID{ID: data.SessionID})
+		return
+	}
+
+	from := session.lookupRun(data.FromRunID)
+	if from == nil {
+		encodeErrorResponse(rw, &ErrInvalidRunID{ID: data.FromRunID})
+		return
+	}
+
+	to := session.lookupRun(data.ToRunID)
+	if to == nil {
+		encodeErrorResponse(rw, &ErrInvalidRunID{ID: data.ToRunID})
+		return
+	}
+
+	encodeResponse(rw, runDiffResponse{
+		SessionID: session.id,
+		FromRunID: from.RunID,
+		ToRunID:   to.RunID,
+		SameModel: from.ModelHash == to.ModelHash,
+		Diffs:     diffRuns(from, to),
+	})
+}
+
+// diffRuns returns a line diff of the output of each framework used in either run.
+func diffRuns(from, to *runRecord) (diffs map[string]frameworkDiff) {
+	diffs = map[string]frameworkDiff{}
+
+	for _, results := range []map[string]runRecordResult{from.Results, to.Results} {
+		for name := range results {
+			if _, done := diffs[name]; done {
+				continue
+			}
+
+			fromOutput := from.Results[name].Output
+			toOutput := to.Results[name].Output
+
+			diffs[name] = frameworkDiff{
+				Identical: fromOutput == toOutput,
+				Diff:      diff.Diff(fromOutput, toOutput),
+			}
+		}
+	}
+
+	return
+}
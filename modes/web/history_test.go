@@ -0,0 +1,160 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asmaloney/gactar/framework"
+)
+
+// addTestRun adds a run to the session's history with the output for each framework.
+func addTestRun(t *testing.T, session *Session, model *Model, maxRuns int, outputs map[string]string) *runRecord {
+	t.Helper()
+
+	resultMap := frameworkRunResultMap{}
+	for name, output := range outputs {
+		output := output
+		resultMap[name] = frameworkRunResult{ModelName: model.actrModel.Name, Output: &output}
+	}
+
+	now := time.Now()
+
+	record := newRunRecord(model, framework.InitialBuffers{"goal": "countFrom 2 5"}, []string{"ccm", "pyactr"}, now, now, resultMap)
+	session.addRun(record, maxRuns)
+
+	return record
+}
+
+func TestRunHistory(t *testing.T) {
+	options := DefaultOptions
+	options.DataDir = t.TempDir()
+
+	w := newTestWeb(t, options)
+
+	session, err := w.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := w.loadModel(session.id, testSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const maxRuns = 3
+
+	for i := 1; i <= 5; i++ {
+		record := addTestRun(t, session, model, maxRuns, map[string]string{"ccm": fmt.Sprintf("run %d\n", i)})
+
+		if record.RunID != i {
+			t.Errorf("expected run id %d, got %d", i, record.RunID)
+		}
+	}
+
+	runs := session.runRecords()
+	if len(runs) != maxRuns || runs[0].RunID != 3 || runs[2].RunID != 5 {
+		t.Fatalf("expected runs 3 to 5, got %+v", runs)
+	}
+
+	if session.lookupRun(2) != nil {
+		t.Errorf("expected run 2 to be removed from the history")
+	}
+
+	if len(runs[0].ModelHash) != 64 || runs[0].ModelHash != runs[2].ModelHash {
+		t.Errorf("expected the same SHA-256 hash for each run, got %q & %q", runs[0].ModelHash, runs[2].ModelHash)
+	}
+
+	// The history is stored with the session
+	w.sessions.persist(session)
+
+	restarted := newTestWeb(t, options)
+
+	restoredSession := restarted.sessions.lookup(session.id)
+	if restoredSession == nil {
+		t.Fatalf("session %q was not restored", session.id)
+	}
+
+	restoredRun := restoredSession.lookupRun(5)
+	if restoredRun == nil || restoredRun.Results["ccm"].Output != "run 5\n" {
+		t.Fatalf("expected run 5 to be restored, got %+v", restoredRun)
+	}
+
+	record := addTestRun(t, restoredSession, model, maxRuns, nil)
+	if record.RunID != 6 {
+		t.Errorf("expected run ids to continue from 6, got %d", record.RunID)
+	}
+
+	// No history
+	if session.addRun(&runRecord{}, 0) {
+		t.Errorf("expected run not to be added if the history is off")
+	}
+}
+
+func TestRunHistoryHandlers(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+
+	session, err := w.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := w.loadModel(session.id, testSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addTestRun(t, session, model, w.sessions.maxRuns, map[string]string{"ccm": "a\nb\nc\n", "pyactr": "same\n"})
+	addTestRun(t, session, model, w.sessions.maxRuns, map[string]string{"ccm": "a\nB\nc\n", "pyactr": "same\n", "vanilla": "new\n"})
+
+	var history runHistoryResponse
+
+	status := testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/session/runs", sessionRequest{SessionID: session.id}, &history).Code
+	if status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+
+	if len(history.Runs) != 2 || history.MaxRuns != DefaultOptions.RunHistory {
+		t.Fatalf("expected 2 runs, got %+v", history)
+	}
+
+	if history.Runs[0].InitialBuffers["goal"] != "countFrom 2 5" || history.Runs[0].Results["ccm"].Output != "a\nb\nc\n" {
+		t.Errorf("unexpected run in history: %+v", history.Runs[0])
+	}
+
+	var diffs runDiffResponse
+
+	status = testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/session/diff", runDiffRequest{SessionID: session.id, FromRunID: 1, ToRunID: 2}, &diffs).Code
+	if status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+
+	if !diffs.SameModel {
+		t.Errorf("expected runs to use the same model")
+	}
+
+	ccm := diffs.Diffs["ccm"]
+	if ccm.Identical || !strings.Contains(ccm.Diff, "-b\n") || !strings.Contains(ccm.Diff, "+B\n") || !strings.Contains(ccm.Diff, " a\n") {
+		t.Errorf("unexpected diff for ccm: %+v", ccm)
+	}
+
+	if !diffs.Diffs["pyactr"].Identical {
+		t.Errorf("expected pyactr output to be identical: %+v", diffs.Diffs["pyactr"])
+	}
+
+	if vanilla := diffs.Diffs["vanilla"]; vanilla.Identical || !strings.Contains(vanilla.Diff, "+new") {
+		t.Errorf("expected vanilla to be compared with no output: %+v", vanilla)
+	}
+
+	status = testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/session/diff", runDiffRequest{SessionID: session.id, FromRunID: 1, ToRunID: 3}, nil).Code
+	if status != http.StatusNotFound {
+		t.Errorf("expected status %d for unknown run, got %d", http.StatusNotFound, status)
+	}
+
+	status = testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/session/runs", sessionRequest{SessionID: "nope"}, nil).Code
+	if status != http.StatusNotFound {
+		t.Errorf("expected status %d for unknown session, got %d", http.StatusNotFound, status)
+	}
+}
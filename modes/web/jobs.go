@@ -0,0 +1,489 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asmaloney/gactar/actr"
+	"github.com/asmaloney/gactar/framework"
+
+	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/issues"
+)
+
+// jobRetention is how long we keep a finished job so the client can get its results.
+const jobRetention = time.Hour
+
+// Status of a job & of each framework's run within a job.
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobCancelled = "cancelled"
+)
+
+// job is a run of a model on one or more frameworks which happens in the background.
+type job struct {
+	id      string
+	created time.Time
+
+	model          *actr.Model
+	initialBuffers framework.InitialBuffers
+	timeout        time.Duration // maximum time for each run
+	issues         issues.IssueList
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex    sync.Mutex
+	finished time.Time // zero until every run is done or cancelled
+	runs     map[string]*jobRun
+}
+
+// jobRun is the run of a job on one framework.
+type jobRun struct {
+	status string
+	output strings.Builder // output so far
+	result *frameworkRunResult
+}
+
+// jobTask is one framework's run of a job for a worker.
+type jobTask struct {
+	job       *job
+	framework string
+}
+
+// jobManager runs jobs on a fixed number of workers. It is safe for concurrent use.
+type jobManager struct {
+	web     *Web
+	maxJobs int // maximum number of jobs which are queued or running (0 means no limit)
+
+	workers sync.WaitGroup
+
+	mutex   sync.Mutex
+	pending *sync.Cond // signalled when a task is added to the queue or the manager is shut down
+	queue   []jobTask
+	jobs    map[string]*job
+	closed  bool // set when the server is shutting down
+}
+
+func newJobManager(w *Web, options Options) *jobManager {
+	numWorkers := options.JobWorkers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	m := &jobManager{
+		web:     w,
+		maxJobs: options.MaxJobs,
+		jobs:    map[string]*job{},
+	}
+
+	m.pending = sync.NewCond(&m.mutex)
+
+	for i := 0; i < numWorkers; i++ {
+		m.workers.Add(1)
+		go m.worker()
+	}
+
+	return m
+}
+
+type jobCreatedResponse struct {
+	JobID  string           `json:"jobID"`
+	Issues issues.IssueList `json:"issues,omitempty"`
+}
+
+// jobsHandler handles "POST /jobs" to create a job. It takes the same request as /run.
+func (w *Web) jobsHandler(rw http.ResponseWriter, req *http.Request) {
+	data, model, log, ok := w.prepareRun(rw, req)
+	if !ok {
+		return
+	}
+
+	timeout, err := w.runTimeout(data.Timeout)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	initialBuffers := framework.InitialBuffers{
+		"goal": strings.TrimSpace(data.Goal),
+	}
+
+	j, err := w.jobs.submit(model, initialBuffers, data.Frameworks, timeout, log.AllIssues())
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	encodeResponseStatus(rw, http.StatusAccepted, jobCreatedResponse{
+		JobID:  j.id,
+		Issues: j.issues,
+	})
+}
+
+// jobHandler handles "GET /jobs/{id}" to get the status & results of a job and
+// "DELETE /jobs/{id}" to cancel it.
+func (w *Web) jobHandler(rw http.ResponseWriter, req *http.Request) {
+	id := pathParam(req)
+
+	j := w.jobs.lookup(id)
+	if j == nil {
+		encodeErrorResponse(rw, &ErrInvalidJobID{ID: id})
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		encodeResponse(rw, j.status())
+
+	case http.MethodDelete:
+		j.stop()
+		encodeResponse(rw, j.status())
+
+	default:
+		rw.Header().Set("Allow", "GET, DELETE")
+		encodeErrorResponse(rw, &ErrMethodNotAllowed{Method: req.Method})
+	}
+}
+
+// submit creates a job and queues a task for each framework.
+func (m *jobManager) submit(model *actr.Model, initialBuffers framework.InitialBuffers, frameworkNames []string, timeout time.Duration, issues issues.IssueList) (j *job, err error) {
+	id, err := newRandomID()
+	if err != nil {
+		return
+	}
+
+	// ensure temp dir exists
+	// https://github.com/asmaloney/gactar/issues/103
+	_, err = cli.CreateTempDir(m.web.settings)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	j = &job{
+		id:             id,
+		created:        time.Now(),
+		model:          model,
+		initialBuffers: initialBuffers,
+		timeout:        timeout,
+		issues:         issues,
+		ctx:            ctx,
+		cancel:         cancel,
+		runs:           map[string]*jobRun{},
+	}
+
+	for _, name := range frameworkNames {
+		j.runs[name] = &jobRun{status: jobQueued}
+	}
+
+	// if there are no frameworks to run on, there's nothing to do
+	j.updateFinishedLocked()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		cancel()
+		return nil, ErrShuttingDown
+	}
+
+	m.removeOldJobsLocked()
+
+	if m.maxJobs > 0 && m.numActiveLocked() >= m.maxJobs {
+		cancel()
+		return nil, &ErrTooManyJobs{Max: m.maxJobs}
+	}
+
+	m.jobs[id] = j
+
+	for _, name := range frameworkNames {
+		m.queue = append(m.queue, jobTask{job: j, framework: name})
+	}
+
+	m.pending.Broadcast()
+
+	return
+}
+
+// shutdown stops accepting jobs, cancels the runs which have not started, and waits for the
+// running ones to finish.
+func (m *jobManager) shutdown() {
+	m.mutex.Lock()
+
+	m.closed = true
+
+	for _, task := range m.queue {
+		task.job.cancelRun(task.framework)
+	}
+
+	m.queue = nil
+
+	m.pending.Broadcast()
+	m.mutex.Unlock()
+
+	m.workers.Wait()
+}
+
+// numQueued returns the number of framework runs waiting for a worker.
+func (m *jobManager) numQueued() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return len(m.queue)
+}
+
+func (m *jobManager) lookup(id string) *job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.jobs[id]
+}
+
+// numActiveLocked returns the number of jobs which are not finished. m.mutex must be held.
+func (m *jobManager) numActiveLocked() (count int) {
+	for _, j := range m.jobs {
+		if !j.isFinished() {
+			count++
+		}
+	}
+
+	return
+}
+
+// removeOldJobsLocked removes jobs which finished more than jobRetention ago. m.mutex must be held.
+func (m *jobManager) removeOldJobsLocked() {
+	for id, j := range m.jobs {
+		j.mutex.Lock()
+		expired := !j.finished.IsZero() && time.Since(j.finished) > jobRetention
+		j.mutex.Unlock()
+
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// worker runs tasks from the queue in the order they were submitted until the manager is shut down.
+func (m *jobManager) worker() {
+	defer m.workers.Done()
+
+	for {
+		m.mutex.Lock()
+		for len(m.queue) == 0 && !m.closed {
+			m.pending.Wait()
+		}
+
+		if m.closed {
+			m.mutex.Unlock()
+			return
+		}
+
+		task := m.queue[0]
+		m.queue = m.queue[1:]
+		m.mutex.Unlock()
+
+		m.runTask(task)
+	}
+}
+
+func (m *jobManager) runTask(task jobTask) {
+	j := task.job
+
+	if !j.start(task.framework) {
+		return
+	}
+
+	ctx, cancel := cli.WithTimeout(j.ctx, j.timeout)
+	defer cancel()
+
+	result := m.web.runFramework(ctx, j.model, j.initialBuffers, task.framework, nil, j.outputWriter(task.framework))
+
+	j.finish(task.framework, result)
+}
+
+// start marks the framework's run as running. If the job was cancelled, it returns false.
+func (j *job) start(frameworkName string) bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	run := j.runs[frameworkName]
+
+	if run.status != jobQueued || j.ctx.Err() != nil {
+		run.status = jobCancelled
+		j.updateFinishedLocked()
+		return false
+	}
+
+	run.status = jobRunning
+	return true
+}
+
+// cancelRun marks the framework's run as cancelled if it has not started.
+func (j *job) cancelRun(frameworkName string) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	run := j.runs[frameworkName]
+	if run.status == jobQueued {
+		run.status = jobCancelled
+	}
+
+	j.updateFinishedLocked()
+}
+
+func (j *job) finish(frameworkName string, result frameworkRunResult) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	run := j.runs[frameworkName]
+
+	run.status = jobDone
+	if j.ctx.Err() != nil {
+		run.status = jobCancelled
+	}
+
+	run.result = &result
+
+	j.updateFinishedLocked()
+}
+
+// outputWriter returns a writer which collects the framework's output as it runs.
+func (j *job) outputWriter(frameworkName string) *jobOutputWriter {
+	return &jobOutputWriter{job: j, framework: frameworkName}
+}
+
+// stop cancels the job. Running processes are killed and queued runs will not be started.
+func (j *job) stop() {
+	j.cancel()
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	for _, run := range j.runs {
+		if run.status == jobQueued {
+			run.status = jobCancelled
+		}
+	}
+
+	j.updateFinishedLocked()
+}
+
+// updateFinishedLocked sets the finished time once every run is done. j.mutex must be held.
+func (j *job) updateFinishedLocked() {
+	if !j.finished.IsZero() {
+		return
+	}
+
+	for _, run := range j.runs {
+		if run.status == jobQueued || run.status == jobRunning {
+			return
+		}
+	}
+
+	j.finished = time.Now()
+
+	// release the context's resources
+	j.cancel()
+}
+
+func (j *job) isFinished() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return !j.finished.IsZero()
+}
+
+// jobRunStatus is the status of one framework's run in a job.
+type jobRunStatus struct {
+	Status        string              `json:"status"`
+	PartialOutput *string             `json:"partialOutput,omitempty"` // output so far (only while it is running)
+	Result        *frameworkRunResult `json:"result,omitempty"`        // only once it is done
+}
+
+// jobStatus is returned by the job endpoints.
+type jobStatus struct {
+	JobID    string                  `json:"jobID"`
+	Status   string                  `json:"status"`
+	Created  time.Time               `json:"created"`
+	Finished *time.Time              `json:"finished,omitempty"`
+	Issues   issues.IssueList        `json:"issues,omitempty"`
+	Results  map[string]jobRunStatus `json:"results"`
+}
+
+func (j *job) status() (status jobStatus) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	status = jobStatus{
+		JobID:   j.id,
+		Created: j.created,
+		Issues:  j.issues,
+		Results: map[string]jobRunStatus{},
+	}
+
+	names := []string{}
+	for name := range j.runs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	counts := map[string]int{}
+
+	for _, name := range names {
+		run := j.runs[name]
+		counts[run.status]++
+
+		runStatus := jobRunStatus{
+			Status: run.status,
+			Result: run.result,
+		}
+
+		if run.status == jobRunning {
+			output := run.output.String()
+			runStatus.PartialOutput = &output
+		}
+
+		status.Results[name] = runStatus
+	}
+
+	switch {
+	case !j.finished.IsZero():
+		finished := j.finished
+		status.Finished = &finished
+
+		status.Status = jobDone
+		if counts[jobCancelled] > 0 {
+			status.Status = jobCancelled
+		}
+
+	case counts[jobQueued] == len(j.runs):
+		status.Status = jobQueued
+
+	default:
+		status.Status = jobRunning
+	}
+
+	return
+}
+
+// jobOutputWriter collects a framework's output in its job.
+type jobOutputWriter struct {
+	job       *job
+	framework string
+}
+
+func (w *jobOutputWriter) Write(p []byte) (n int, err error) {
+	w.job.mutex.Lock()
+	defer w.job.mutex.Unlock()
+
+	return w.job.runs[w.framework].output.Write(p)
+}
@@ -0,0 +1,142 @@
+//go:build !windows
+
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJobsAPI(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+
+	body, err := json.Marshal(runRequest{AMODFile: testSource, Frameworks: []string{"text"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var created struct {
+		JobID string `json:"jobID"`
+	}
+
+	status := testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/jobs", string(body), &created).Code
+	if status != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, status)
+	}
+
+	if created.JobID == "" {
+		t.Fatal("expected a job ID")
+	}
+
+	var job jobStatus
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for {
+		status = testRequest(t, w.apiHandler(), http.MethodGet, "/api/v1/jobs/"+created.JobID, nil, &job).Code
+		if status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+		}
+
+		if job.Status == jobDone || job.Status == jobCancelled {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not finish: %+v", job)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if job.Status != jobDone || job.Finished == nil {
+		t.Fatalf("expected job to be done, got %q", job.Status)
+	}
+
+	run, ok := job.Results["text"]
+	if !ok || run.Result == nil {
+		t.Fatalf("missing result for framework: %+v", job.Results)
+	}
+
+	if run.Result.Output == nil || run.Result.Code == nil || *run.Result.Output != *run.Result.Code {
+		t.Errorf("expected output to match generated code")
+	}
+
+	// Unknown jobs & unsupported methods
+	status = testRequest(t, w.apiHandler(), http.MethodGet, "/api/v1/jobs/nope", nil, nil).Code
+	if status != http.StatusNotFound {
+		t.Errorf("expected status %d for unknown job, got %d", http.StatusNotFound, status)
+	}
+
+	status = testRequest(t, w.apiHandler(), http.MethodPut, "/api/v1/jobs/"+created.JobID, nil, nil).Code
+	if status != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, status)
+	}
+
+	status = testRequest(t, w.apiHandler(), http.MethodGet, "/api/v1/jobs", nil, nil).Code
+	if status != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, status)
+	}
+}
+
+func TestJobCancelAndLimit(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+
+	// Use a manager without workers so the jobs stay queued
+	w.jobs = &jobManager{web: w, maxJobs: 1, jobs: map[string]*job{}}
+	w.jobs.pending = sync.NewCond(&w.jobs.mutex)
+
+	j, err := w.jobs.submit(nil, nil, []string{"text"}, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = w.jobs.submit(nil, nil, []string{"text"}, time.Second, nil)
+
+	var tooManyJobs *ErrTooManyJobs
+	if !errors.As(err, &tooManyJobs) {
+		t.Errorf("expected too many jobs error, got %v", err)
+	}
+
+	var job jobStatus
+
+	status := testRequest(t, w.apiHandler(), http.MethodGet, "/api/v1/jobs/"+j.id, nil, &job).Code
+	if status != http.StatusOK || job.Status != jobQueued {
+		t.Fatalf("expected job to be queued, got %d %+v", status, job)
+	}
+
+	status = testRequest(t, w.apiHandler(), http.MethodDelete, "/api/v1/jobs/"+j.id, nil, &job).Code
+	if status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, status)
+	}
+
+	if job.Status != jobCancelled || job.Results["text"].Status != jobCancelled {
+		t.Errorf("expected job to be cancelled, got %+v", job)
+	}
+
+	// A cancelled job no longer counts against the limit
+	_, err = w.jobs.submit(nil, nil, []string{"text"}, time.Second, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestJobShutdown(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+
+	w.jobs.shutdown()
+
+	body, err := json.Marshal(runRequest{AMODFile: testSource, Frameworks: []string{"text"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := testRequest(t, w.apiHandler(), http.MethodPost, "/api/v1/jobs", string(body), nil).Code
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d after shutdown, got %d", http.StatusServiceUnavailable, status)
+	}
+}
@@ -0,0 +1,381 @@
+package web
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// busyRetryAfter is how long we ask clients to wait when the run queue is full.
+const busyRetryAfter = 10 * time.Second
+
+// rateCleanupInterval is how often we remove the rate limits of clients which have not been seen
+// for a while.
+const rateCleanupInterval = time.Minute
+
+// runLimiter limits the number of framework runs which happen at once. Runs which cannot start
+// wait in a queue. It is safe for concurrent use. A nil *runLimiter does not limit anything.
+type runLimiter struct {
+	slots     chan struct{} // holds a value for each run which is happening
+	maxQueued int           // maximum number of runs waiting to start (0 means no limit)
+
+	mutex    sync.Mutex
+	queued   int // number of runs waiting to start
+	reserved int // number of runs admitted which have not called acquire yet
+}
+
+// runReservation holds the places in the queue which admit reserved for a request's runs. Each
+// call to acquire with it uses one of them. A nil *runReservation does not hold anything.
+type runReservation struct {
+	limiter   *runLimiter
+	remaining int // guarded by limiter.mutex
+}
+
+func newRunLimiter(maxRuns, maxQueued int) *runLimiter {
+	if maxRuns <= 0 {
+		return nil
+	}
+
+	return &runLimiter{
+		slots:     make(chan struct{}, maxRuns),
+		maxQueued: maxQueued,
+	}
+}
+
+// admit reserves room to start or queue n runs. It is called before a request starts running
+// anything so it may be rejected as a whole instead of having some of its runs fail. The
+// reservation must be passed to acquire for each run and cancelled when the request is done so
+// any places it did not use are given back.
+func (l *runLimiter) admit(n int) (reservation *runReservation, err error) {
+	if l == nil || l.maxQueued <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	free := cap(l.slots) - len(l.slots)
+
+	if l.reserved+l.queued+n > free+l.maxQueued {
+		err = &ErrServerBusy{RetryAfter: busyRetryAfter}
+		return
+	}
+
+	l.reserved += n
+
+	reservation = &runReservation{limiter: l, remaining: n}
+	return
+}
+
+// cancel gives back the places in the queue which have not been used.
+func (r *runReservation) cancel() {
+	if r == nil {
+		return
+	}
+
+	l := r.limiter
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.reserved -= r.remaining
+	r.remaining = 0
+}
+
+// acquire waits until a run may start. If the context is done first, it returns its error.
+// If reservation is not nil, the run uses one of its places. If it returns nil, release must be
+// called when the run is done.
+func (l *runLimiter) acquire(ctx context.Context, reservation *runReservation) (err error) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	if reservation != nil && reservation.remaining > 0 {
+		reservation.remaining--
+		l.reserved--
+	}
+	l.queued++
+	l.mutex.Unlock()
+
+	defer func() {
+		l.mutex.Lock()
+		l.queued--
+		l.mutex.Unlock()
+	}()
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// numQueued returns the number of runs waiting to start.
+func (l *runLimiter) numQueued() int {
+	if l == nil {
+		return 0
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.queued
+}
+
+func (l *runLimiter) release() {
+	if l == nil {
+		return
+	}
+
+	<-l.slots
+}
+
+// rateLimiter limits how often each client may run models using a token bucket for each client.
+// It is safe for concurrent use. A nil *rateLimiter does not limit anything.
+type rateLimiter struct {
+	rate  float64 // tokens added to each bucket per second
+	burst float64 // size of each bucket
+
+	now func() time.Time // so tests can control the clock
+
+	mutex       sync.Mutex
+	clients     map[string]*rateBucket
+	lastCleanup time.Time
+}
+
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newRateLimiter returns a limiter which allows perMinute requests per minute for each client with
+// bursts of up to burst requests.
+func newRateLimiter(perMinute float64, burst int) *rateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:    perMinute / 60,
+		burst:   float64(burst),
+		now:     time.Now,
+		clients: map[string]*rateBucket{},
+	}
+}
+
+// allow takes a token from the client's bucket. If it is empty, it returns ErrRateLimited with the
+// time until the next token is available.
+func (l *rateLimiter) allow(client string) (err error) {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+
+	if now.Sub(l.lastCleanup) >= rateCleanupInterval {
+		l.removeFullLocked(now)
+		l.lastCleanup = now
+	}
+
+	bucket, ok := l.clients[client]
+	if !ok {
+		bucket = &rateBucket{tokens: l.burst, updated: now}
+		l.clients[client] = bucket
+	}
+
+	bucket.tokens = l.tokensAt(bucket, now)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		wait := (1 - bucket.tokens) / l.rate
+		return &ErrRateLimited{RetryAfter: time.Duration(wait * float64(time.Second))}
+	}
+
+	bucket.tokens--
+
+	return
+}
+
+// tokensAt returns the number of tokens in the bucket at the time.
+func (l *rateLimiter) tokensAt(bucket *rateBucket, now time.Time) float64 {
+	elapsed := now.Sub(bucket.updated).Seconds()
+
+	return math.Min(l.burst, bucket.tokens+elapsed*l.rate)
+}
+
+// removeFullLocked removes the buckets which have filled up again since they are the same as new
+// ones. l.mutex must be held.
+func (l *rateLimiter) removeFullLocked(now time.Time) {
+	for client, bucket := range l.clients {
+		if l.tokensAt(bucket, now) >= l.burst {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// clientKey identifies the client for rate limiting. Requests which use a session are limited per
+// session, others by the client's IP address (see clientIP).
+func (w Web) clientKey(req *http.Request, sessionID string) string {
+	if sessionID != "" {
+		return "session:" + sessionID
+	}
+
+	return "ip:" + clientIP(req, w.trustedProxies)
+}
+
+// clientIP returns the IP address of the client which made the request. If the request comes from
+// a trusted proxy, the X-Forwarded-For header is used to find the client - it is the right-most
+// address which is not a trusted proxy since the ones to the left of it may be made up by the client.
+func clientIP(req *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	if !isTrustedProxy(host, trustedProxies) {
+		return host
+	}
+
+	var forwarded []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(address) == nil {
+			break
+		}
+
+		host = address
+
+		if !isTrustedProxy(host, trustedProxies) {
+			break
+		}
+	}
+
+	return host
+}
+
+// isTrustedProxy returns true if the address is in one of the trusted networks.
+func isTrustedProxy(address string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseTrustedProxies parses the IP addresses & CIDR networks of trusted proxies.
+func parseTrustedProxies(list []string) (networks []*net.IPNet, err error) {
+	for _, entry := range list {
+		entry = strings.TrimSpace(entry)
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, &ErrInvalidTrustedProxy{Proxy: entry}
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, parseErr := net.ParseCIDR(entry)
+		if parseErr != nil {
+			return nil, &ErrInvalidTrustedProxy{Proxy: entry}
+		}
+
+		networks = append(networks, network)
+	}
+
+	return
+}
+
+// retryAfterSeconds returns the value of the Retry-After header for the duration. It is rounded up
+// so the client does not try again too early.
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return seconds
+}
+
+// limitBody rejects requests with bodies larger than max bytes (0 means no limit).
+func limitBody(max int64, handler http.HandlerFunc) http.HandlerFunc {
+	if max <= 0 {
+		return handler
+	}
+
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if req.ContentLength > max {
+			encodeErrorResponse(rw, &ErrRequestTooLarge{Max: max})
+			return
+		}
+
+		if req.Body != nil {
+			req.Body = &limitedBody{ReadCloser: req.Body, max: max, remaining: max}
+		}
+
+		handler(rw, req)
+	}
+}
+
+// limitedBody returns ErrRequestTooLarge from Read once more than max bytes have been read. We
+// support Go 1.18, where http.MaxBytesReader's error can only be told apart from other decoding
+// errors by its message (http.MaxBytesError was added in Go 1.19).
+type limitedBody struct {
+	io.ReadCloser
+
+	max       int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (n int, err error) {
+	// read one byte more than we allow so we can tell if the body is too large
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err = b.ReadCloser.Read(p)
+
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return
+	}
+
+	n = int(b.remaining)
+	b.remaining = 0
+
+	return n, &ErrRequestTooLarge{Max: b.max}
+}
@@ -0,0 +1,309 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asmaloney/gactar/util/cli"
+)
+
+func TestRunLimiter(t *testing.T) {
+	limiter := newRunLimiter(1, 1)
+
+	err := limiter.acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one run may wait, but not two
+	reservation, err := limiter.admit(1)
+	if err != nil {
+		t.Errorf("expected run to be admitted: %s", err.Error())
+	}
+
+	var busy *ErrServerBusy
+
+	_, err = limiter.admit(2)
+	if !errors.As(err, &busy) {
+		t.Errorf("expected ErrServerBusy, got %v", err)
+	}
+
+	// the place is reserved, so another request is not admitted until it is given back
+	_, err = limiter.admit(1)
+	if !errors.As(err, &busy) {
+		t.Errorf("expected ErrServerBusy while the place is reserved, got %v", err)
+	}
+
+	reservation.cancel()
+
+	reservation, err = limiter.admit(1)
+	if err != nil {
+		t.Errorf("expected run to be admitted after cancel: %s", err.Error())
+	}
+
+	// waiting for a slot stops when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = limiter.acquire(ctx, reservation)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// acquire used the reservation, so cancelling it does not give back anything more
+	reservation.cancel()
+
+	if limiter.reserved != 0 || limiter.numQueued() != 0 {
+		t.Errorf("expected nothing reserved or queued, got %d & %d", limiter.reserved, limiter.numQueued())
+	}
+
+	limiter.release()
+
+	err = limiter.acquire(context.Background(), nil)
+	if err != nil {
+		t.Errorf("expected slot after release: %s", err.Error())
+	}
+
+	limiter.release()
+
+	if newRunLimiter(0, 1) != nil {
+		t.Errorf("expected no limiter if there is no maximum")
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	tests := []struct {
+		server    time.Duration
+		requested float64
+		expected  time.Duration
+	}{
+		{0, 0, 0},
+		{0, 5, 5 * time.Second},
+		{time.Minute, 0, time.Minute},
+		{time.Minute, 5, 5 * time.Second},
+		{time.Minute, 600, time.Minute}, // clients may not go over the server's timeout
+	}
+
+	for _, test := range tests {
+		w := &Web{settings: &cli.Settings{Timeout: test.server}}
+
+		duration, err := w.runTimeout(test.requested)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if duration != test.expected {
+			t.Errorf("server %s, requested %gs: expected %s, got %s", test.server, test.requested, test.expected, duration)
+		}
+	}
+
+	var invalid *ErrInvalidTimeout
+
+	_, err := (&Web{settings: &cli.Settings{}}).runTimeout(-1)
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected ErrInvalidTimeout, got %v", err)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+
+	limiter := newRateLimiter(6, 2) // one every 10 seconds
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		err := limiter.allow("a")
+		if err != nil {
+			t.Fatalf("request %d: expected burst to be allowed: %s", i, err.Error())
+		}
+	}
+
+	var limited *ErrRateLimited
+
+	err := limiter.allow("a")
+	if !errors.As(err, &limited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+
+	if limited.RetryAfter != 10*time.Second {
+		t.Errorf("expected to retry after 10s, got %s", limited.RetryAfter)
+	}
+
+	// other clients have their own limit
+	err = limiter.allow("b")
+	if err != nil {
+		t.Errorf("expected other client to be allowed: %s", err.Error())
+	}
+
+	now = now.Add(10 * time.Second)
+
+	err = limiter.allow("a")
+	if err != nil {
+		t.Errorf("expected request to be allowed after waiting: %s", err.Error())
+	}
+
+	// full buckets are removed
+	now = now.Add(time.Hour)
+
+	_ = limiter.allow("c")
+
+	if len(limiter.clients) != 1 {
+		t.Errorf("expected idle clients to be removed, got %d", len(limiter.clients))
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	trustedProxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		{"direct", "192.0.2.1:1234", nil, "ip:192.0.2.1"},
+		{"untrusted proxy", "192.0.2.1:1234", []string{"198.51.100.7"}, "ip:192.0.2.1"},
+		{"trusted proxy", "10.1.2.3:1234", []string{"198.51.100.7"}, "ip:198.51.100.7"},
+		{"trusted IPv6 proxy", "[::1]:1234", []string{"198.51.100.7"}, "ip:198.51.100.7"},
+		{"made up by client", "10.1.2.3:1234", []string{"203.0.113.9, 198.51.100.7"}, "ip:198.51.100.7"},
+		{"chain of proxies", "10.1.2.3:1234", []string{"198.51.100.7, 10.9.9.9", "10.0.0.1"}, "ip:198.51.100.7"},
+		{"only proxies", "10.1.2.3:1234", []string{"10.0.0.1"}, "ip:10.0.0.1"},
+		{"invalid header", "10.1.2.3:1234", []string{"nope"}, "ip:10.1.2.3"},
+	}
+
+	w := &Web{trustedProxies: trustedProxies}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/run", nil)
+		request.RemoteAddr = test.remoteAddr
+
+		for _, value := range test.forwarded {
+			request.Header.Add("X-Forwarded-For", value)
+		}
+
+		key := w.clientKey(request, "")
+		if key != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, key)
+		}
+	}
+
+	if w.clientKey(httptest.NewRequest(http.MethodPost, "/api/v1/run", nil), "abc") != "session:abc" {
+		t.Errorf("expected session key")
+	}
+
+	_, err = parseTrustedProxies([]string{"10.0.0.0/33"})
+
+	var invalid *ErrInvalidTrustedProxy
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected ErrInvalidTrustedProxy, got %v", err)
+	}
+}
+
+func TestRateLimitResponse(t *testing.T) {
+	w := &Web{
+		settings:  &cli.Settings{},
+		rateLimit: newRateLimiter(1, 1),
+	}
+
+	// the first request is allowed (but is not valid)
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/run", strings.NewReader("{"))
+	responseRecorder := httptest.NewRecorder()
+
+	w.apiHandler().ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, responseRecorder.Code)
+	}
+
+	request = httptest.NewRequest(http.MethodPost, "/api/v1/run", strings.NewReader("{"))
+	responseRecorder = httptest.NewRecorder()
+
+	w.apiHandler().ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, responseRecorder.Code)
+	}
+
+	if responseRecorder.Header().Get("Retry-After") != "60" {
+		t.Errorf("expected Retry-After of 60, got %q", responseRecorder.Header().Get("Retry-After"))
+	}
+
+	var response errorResponse
+
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.RetryAfter != 60 || len(response.Issues) != 1 {
+		t.Errorf("expected retryAfter & an issue, got %+v", response)
+	}
+}
+
+func TestLegacyLimitStatus(t *testing.T) {
+	w := &Web{
+		settings:       &cli.Settings{},
+		rateLimit:      newRateLimiter(1, 1),
+		maxRequestSize: 10,
+	}
+
+	// other client errors are still returned with http.StatusOK using the legacy API
+	tests := []struct {
+		body     string
+		expected int
+	}{
+		{"{", http.StatusOK},
+		{"{", http.StatusTooManyRequests},
+		{strings.Repeat(" ", 20), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/api/run", strings.NewReader(test.body))
+		responseRecorder := httptest.NewRecorder()
+
+		w.apiHandler().ServeHTTP(responseRecorder, request)
+
+		if responseRecorder.Code != test.expected {
+			t.Errorf("expected status %d, got %d", test.expected, responseRecorder.Code)
+		}
+	}
+}
+
+func TestRequestTooLarge(t *testing.T) {
+	w := &Web{
+		settings:       &cli.Settings{},
+		maxRequestSize: 32,
+	}
+
+	body := `{"amod": "` + strings.Repeat("x", 64) + `"}`
+
+	tests := []struct {
+		name          string
+		contentLength int64
+	}{
+		{"content length", int64(len(body))},
+		{"unknown length", -1},
+	}
+
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/validate", strings.NewReader(body))
+		request.ContentLength = tt.contentLength
+
+		responseRecorder := httptest.NewRecorder()
+
+		w.apiHandler().ServeHTTP(responseRecorder, request)
+
+		if responseRecorder.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected status %d, got %d", tt.name, http.StatusRequestEntityTooLarge, responseRecorder.Code)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asmaloney/gactar/util/executil"
+	"github.com/asmaloney/gactar/util/version"
+)
+
+// Results of framework runs used to label the run metrics.
+const (
+	runSuccess   = "success"
+	runError     = "error"
+	runTimeout   = "timeout"
+	runCancelled = "cancelled"
+)
+
+// runDurationBuckets are the upper bounds (in seconds) of the run duration histogram's buckets.
+var runDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// metrics collects statistics about the framework runs. It is safe for concurrent use. A nil
+// *metrics does not collect anything.
+type metrics struct {
+	mutex     sync.Mutex
+	runs      map[runMetricKey]uint64
+	durations map[string]*histogram // by framework name
+	running   map[string]int        // by framework name
+}
+
+type runMetricKey struct {
+	framework string
+	result    string
+}
+
+// histogram counts observations in cumulative buckets like a Prometheus histogram.
+type histogram struct {
+	counts []uint64 // one for each of runDurationBuckets
+	count  uint64
+	sum    float64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		runs:      map[runMetricKey]uint64{},
+		durations: map[string]*histogram{},
+		running:   map[string]int{},
+	}
+}
+
+// runStarted records that a framework has started running a model.
+func (m *metrics) runStarted(frameworkName string) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.running[frameworkName]++
+}
+
+// runFinished records the result & duration of a run which was recorded using runStarted.
+func (m *metrics) runFinished(frameworkName string, result string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.running[frameworkName]--
+
+	m.runs[runMetricKey{framework: frameworkName, result: result}]++
+
+	h, ok := m.durations[frameworkName]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(runDurationBuckets))}
+		m.durations[frameworkName] = h
+	}
+
+	h.observe(duration.Seconds())
+}
+
+// runNotStarted records the result of a run which did not start (e.g. the model was not valid for
+// the framework or it timed out while waiting in the queue).
+func (m *metrics) runNotStarted(frameworkName string, result string) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.runs[runMetricKey{framework: frameworkName, result: result}]++
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range runDurationBuckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.count++
+	h.sum += value
+}
+
+// runResultLabel returns the label for the result of a run using the error it returned.
+func runResultLabel(err error) string {
+	var (
+		timeoutErr   *executil.ErrTimeout
+		cancelledErr *executil.ErrCancelled
+	)
+
+	switch {
+	case err == nil:
+		return runSuccess
+
+	case errors.As(err, &timeoutErr):
+		return runTimeout
+
+	case errors.As(err, &cancelledErr):
+		return runCancelled
+	}
+
+	return runError
+}
+
+// metricsHandler returns the metrics using the Prometheus text format. See:
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+func (w *Web) metricsHandler(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		rw.Header().Set("Allow", "GET, HEAD")
+		encodeErrorResponse(rw, &ErrMethodNotAllowed{Method: req.Method})
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	w.writeMetrics(rw)
+}
+
+// writeMetrics writes the run metrics along with the current state of the server.
+func (w *Web) writeMetrics(out io.Writer) {
+	writeMetricHeader(out, "gactar_build_info", "gauge", "Version of gactar which is running.")
+	fmt.Fprintf(out, "gactar_build_info{version=%s} 1\n", quoteLabel(version.BuildVersion))
+
+	w.metrics.write(out)
+
+	writeMetricHeader(out, "gactar_sessions_active", "gauge", "Number of web sessions.")
+	fmt.Fprintf(out, "gactar_sessions_active %d\n", w.sessions.count())
+
+	writeMetricHeader(out, "gactar_run_queue_length", "gauge", "Number of framework runs waiting to start because of --max-runs.")
+	fmt.Fprintf(out, "gactar_run_queue_length %d\n", w.runs.numQueued())
+
+	writeMetricHeader(out, "gactar_job_queue_length", "gauge", "Number of framework runs from the jobs API waiting for a worker.")
+	fmt.Fprintf(out, "gactar_job_queue_length %d\n", w.jobs.numQueued())
+}
+
+func (m *metrics) write(out io.Writer) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	writeMetricHeader(out, "gactar_runs_total", "counter", "Number of framework runs by result (success, error, timeout, or cancelled).")
+
+	keys := make([]runMetricKey, 0, len(m.runs))
+	for key := range m.runs {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].framework != keys[j].framework {
+			return keys[i].framework < keys[j].framework
+		}
+
+		return keys[i].result < keys[j].result
+	})
+
+	for _, key := range keys {
+		fmt.Fprintf(out, "gactar_runs_total{framework=%s,result=%s} %d\n", quoteLabel(key.framework), quoteLabel(key.result), m.runs[key])
+	}
+
+	writeMetricHeader(out, "gactar_runs_in_progress", "gauge", "Number of framework runs which are running.")
+
+	for _, name := range sortedKeys(m.running) {
+		fmt.Fprintf(out, "gactar_runs_in_progress{framework=%s} %d\n", quoteLabel(name), m.running[name])
+	}
+
+	writeMetricHeader(out, "gactar_run_duration_seconds", "histogram", "Time taken by framework runs which started.")
+
+	for _, name := range sortedKeys(m.durations) {
+		h := m.durations[name]
+		label := quoteLabel(name)
+
+		for i, bound := range runDurationBuckets {
+			le := quoteLabel(strconv.FormatFloat(bound, 'g', -1, 64))
+			fmt.Fprintf(out, "gactar_run_duration_seconds_bucket{framework=%s,le=%s} %d\n", label, le, h.counts[i])
+		}
+
+		fmt.Fprintf(out, "gactar_run_duration_seconds_bucket{framework=%s,le=\"+Inf\"} %d\n", label, h.count)
+		fmt.Fprintf(out, "gactar_run_duration_seconds_sum{framework=%s} %s\n", label, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(out, "gactar_run_duration_seconds_count{framework=%s} %d\n", label, h.count)
+	}
+}
+
+func writeMetricHeader(out io.Writer, name, metricType, help string) {
+	fmt.Fprintf(out, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(out, "# TYPE %s %s\n", name, metricType)
+}
+
+// labelEscaper escapes label values as required by the text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+func sortedKeys[V any](m map[string]V) (keys []string) {
+	keys = make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return
+}
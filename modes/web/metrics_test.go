@@ -0,0 +1,109 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/executil"
+)
+
+func TestRunResultLabel(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{nil, runSuccess},
+		{errors.New("failed"), runError},
+		{&executil.ErrTimeout{}, runTimeout},
+		{&executil.ErrCancelled{}, runCancelled},
+	}
+
+	for _, tt := range tests {
+		label := runResultLabel(tt.err)
+		if label != tt.expected {
+			t.Errorf("%v: expected %q, got %q", tt.err, tt.expected, label)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics()
+
+	m.runStarted("ccm")
+	m.runFinished("ccm", runSuccess, 200*time.Millisecond)
+
+	m.runStarted("ccm")
+	m.runFinished("ccm", runTimeout, 2*time.Second)
+
+	m.runStarted("vanilla")
+	m.runNotStarted("pyactr", runError)
+
+	var out bytes.Buffer
+	m.write(&out)
+
+	expected := []string{
+		`gactar_runs_total{framework="ccm",result="success"} 1`,
+		`gactar_runs_total{framework="ccm",result="timeout"} 1`,
+		`gactar_runs_total{framework="pyactr",result="error"} 1`,
+		`gactar_runs_in_progress{framework="ccm"} 0`,
+		`gactar_runs_in_progress{framework="vanilla"} 1`,
+		`gactar_run_duration_seconds_bucket{framework="ccm",le="0.1"} 0`,
+		`gactar_run_duration_seconds_bucket{framework="ccm",le="0.25"} 1`,
+		`gactar_run_duration_seconds_bucket{framework="ccm",le="2.5"} 2`,
+		`gactar_run_duration_seconds_bucket{framework="ccm",le="+Inf"} 2`,
+		`gactar_run_duration_seconds_sum{framework="ccm"} 2.2`,
+		`gactar_run_duration_seconds_count{framework="ccm"} 2`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	w, err := Initialize(&cli.Settings{}, DefaultOptions, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.jobs.shutdown()
+
+	_, err = w.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.runs.acquire(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.runs.release()
+
+	responseRecorder := httptest.NewRecorder()
+
+	w.handler.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, responseRecorder.Code)
+	}
+
+	if !strings.HasPrefix(responseRecorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type: %q", responseRecorder.Header().Get("Content-Type"))
+	}
+
+	body := responseRecorder.Body.String()
+
+	for _, line := range []string{"gactar_sessions_active 1\n", "gactar_run_queue_length 0\n", "gactar_job_queue_length 0\n", "# TYPE gactar_runs_total counter\n"} {
+		if !strings.Contains(body, line) {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+}
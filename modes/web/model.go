@@ -2,35 +2,40 @@ package web
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/asmaloney/gactar/actr"
 	"github.com/asmaloney/gactar/amod"
 	"github.com/asmaloney/gactar/framework"
 )
 
-var currentModelID = 1
-
 type Model struct {
 	id        int
 	actrModel *actr.Model
+	source    string    // amod source (so we can store it & give it back)
+	loaded    time.Time // when it was loaded
 }
 
-func initModels(w *Web) {
-	http.HandleFunc("/api/model/load", w.loadModelHandler)
+// modelInfo describes a model in a session.
+type modelInfo struct {
+	ModelID   int       `json:"modelID"`
+	ModelName string    `json:"modelName"`
+	Loaded    time.Time `json:"loaded"`
 }
 
-func (w *Web) loadModelHandler(rw http.ResponseWriter, req *http.Request) {
-	type request struct {
-		SessionID int    `json:"sessionID"`
-		AMODFile  string `json:"amod"`
-	}
-	type response struct {
-		ModelID   int    `json:"modelID"`
-		ModelName string `json:"modelName"`
-		SessionID int    `json:"sessionID"`
-	}
+type loadModelRequest struct {
+	SessionID string `json:"sessionID"`
+	AMODFile  string `json:"amod"`
+}
+
+type loadModelResponse struct {
+	ModelID   int    `json:"modelID"`
+	ModelName string `json:"modelName"`
+	SessionID string `json:"sessionID"`
+}
 
-	var data request
+func (w *Web) loadModelHandler(rw http.ResponseWriter, req *http.Request) {
+	var data loadModelRequest
 	err := decodeBody(req, &data)
 	if err != nil {
 		encodeErrorResponse(rw, err)
@@ -43,15 +48,89 @@ func (w *Web) loadModelHandler(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	encodeResponse(rw, response{
+	encodeResponse(rw, loadModelResponse{
 		ModelID:   model.id,
 		ModelName: model.actrModel.Name,
 		SessionID: data.SessionID,
 	})
 }
 
-func (w *Web) loadModel(sessionID int, amodFile string) (model *Model, err error) {
-	session := w.lookupSession(sessionID)
+type modelListResponse struct {
+	SessionID string      `json:"sessionID"`
+	Models    []modelInfo `json:"models"`
+}
+
+// listModelsHandler returns the models loaded in a session in the order they were loaded.
+func (w *Web) listModelsHandler(rw http.ResponseWriter, req *http.Request) {
+	var data sessionRequest
+	err := decodeBody(req, &data)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	session := w.sessions.lookup(data.SessionID)
+	if session == nil {
+		encodeErrorResponse(rw, &ErrInvalidSessionID{ID: data.SessionID})
+		return
+	}
+
+	encodeResponse(rw, modelListResponse{
+		SessionID: session.id,
+		Models:    session.modelInfos(),
+	})
+}
+
+// modelRequest is the request for endpoints which need a model in a session.
+type modelRequest struct {
+	SessionID string `json:"sessionID"`
+	ModelID   int    `json:"modelID"`
+}
+
+type modelSourceResponse struct {
+	modelInfo
+	SessionID string `json:"sessionID"`
+	AMODFile  string `json:"amod"`
+}
+
+// modelSourceHandler returns the amod source of a model in a session.
+func (w *Web) modelSourceHandler(rw http.ResponseWriter, req *http.Request) {
+	var data modelRequest
+	err := decodeBody(req, &data)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	session := w.sessions.lookup(data.SessionID)
+	if session == nil {
+		encodeErrorResponse(rw, &ErrInvalidSessionID{ID: data.SessionID})
+		return
+	}
+
+	model := session.lookupModel(data.ModelID)
+	if model == nil {
+		encodeErrorResponse(rw, &ErrInvalidModelID{ID: data.ModelID})
+		return
+	}
+
+	encodeResponse(rw, modelSourceResponse{
+		modelInfo: model.info(),
+		SessionID: session.id,
+		AMODFile:  model.source,
+	})
+}
+
+func (m Model) info() modelInfo {
+	return modelInfo{
+		ModelID:   m.id,
+		ModelName: m.actrModel.Name,
+		Loaded:    m.loaded,
+	}
+}
+
+func (w *Web) loadModel(sessionID string, amodFile string) (model *Model, err error) {
+	session := w.sessions.lookup(sessionID)
 	if session == nil {
 		err = &ErrInvalidSessionID{ID: sessionID}
 		return
@@ -63,12 +142,17 @@ func (w *Web) loadModel(sessionID int, amodFile string) (model *Model, err error
 	}
 
 	model = &Model{
-		id:        currentModelID,
 		actrModel: actrModel,
+		source:    amodFile,
+		loaded:    w.sessions.now(),
+	}
+
+	err = session.addModel(model, w.sessions.maxModels)
+	if err != nil {
+		return nil, err
 	}
-	currentModelID++
 
-	session.addModel(model)
+	w.sessions.persist(session)
 
 	return
 }
@@ -10,25 +10,31 @@ import (
 )
 
 func TestAddModel(t *testing.T) {
-	session := webTest.newSession()
+	session, err := webTest.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if session == nil {
 		t.Fatalf("Could not create session")
 	}
 
-	err := webTest.endSession(session.id)
+	err = webTest.sessions.end(session.id)
 
 	if err != nil {
 		t.Errorf("Unexpected error: %s", err.Error())
 	}
 
-	if webTest.hasSessions() {
+	if webTest.sessions.hasSessions() {
 		t.Errorf("Did not remove session from list")
 	}
 }
 
 func TestLoadModelHandler(t *testing.T) {
-	session := webTest.newSession()
+	session, err := webTest.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	src := `~~ model ~~
 	name: Test
@@ -41,7 +47,7 @@ func TestLoadModelHandler(t *testing.T) {
 	)
 	src = replacer.Replace(src)
 
-	data := []byte(fmt.Sprintf(`{"sessionID":%d, "amod":"%s"}`, session.id, src))
+	data := []byte(fmt.Sprintf(`{"sessionID":%q, "amod":"%s"}`, session.id, src))
 
 	request, err := http.NewRequest("PUT", "/model/load", bytes.NewBuffer(data))
 	if err != nil {
@@ -69,5 +75,5 @@ func TestLoadModelHandler(t *testing.T) {
 		t.Errorf("Model not loaded")
 	}
 
-	webTest.clearSessions()
+	webTest.sessions.clear()
 }
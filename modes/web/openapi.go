@@ -0,0 +1,265 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asmaloney/gactar/util/version"
+)
+
+// openAPIVersion is the version of the OpenAPI specification the document uses.
+// See: https://spec.openapis.org/oas/v3.0.3
+const openAPIVersion = "3.0.3"
+
+// jsonObject is used to build the OpenAPI document.
+type jsonObject = map[string]interface{}
+
+func (w *Web) openAPIHandler(rw http.ResponseWriter, req *http.Request) {
+	encodeResponse(rw, w.openAPIDocument())
+}
+
+// openAPIDocument describes the API using the routes and the types of their requests & responses.
+func (w *Web) openAPIDocument() jsonObject {
+	builder := newSchemaBuilder()
+
+	errorContent := jsonObject{
+		"application/json": jsonObject{"schema": builder.schema(reflect.TypeOf(errorResponse{}))},
+	}
+
+	paths := jsonObject{}
+
+	for _, route := range w.routes() {
+		item, ok := paths[route.path].(jsonObject)
+		if !ok {
+			item = jsonObject{}
+			paths[route.path] = item
+		}
+
+		for _, method := range route.methods {
+			item[strings.ToLower(method)] = route.operation(builder, errorContent)
+		}
+	}
+
+	return jsonObject{
+		"openapi": openAPIVersion,
+		"info": jsonObject{
+			"title":       "gactar",
+			"description": "Run amod models on ACT-R frameworks. Errors are returned with a 4xx or 5xx status and a list of issues.",
+			"version":     version.BuildVersion,
+		},
+		"servers": []jsonObject{
+			{"url": w.basePath + apiPrefix},
+		},
+		"paths": paths,
+		"components": jsonObject{
+			"schemas": builder.schemas,
+		},
+	}
+}
+
+// operation returns the OpenAPI operation for one of the route's methods.
+func (r apiRoute) operation(builder *schemaBuilder, errorContent jsonObject) jsonObject {
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	success := jsonObject{"description": http.StatusText(status)}
+
+	switch {
+	case r.response != nil:
+		success["content"] = jsonObject{
+			"application/json": jsonObject{"schema": builder.schema(reflect.TypeOf(r.response))},
+		}
+
+	case r.contentType != "":
+		success["content"] = jsonObject{
+			r.contentType: jsonObject{"schema": jsonObject{"type": "string"}},
+		}
+	}
+
+	operation := jsonObject{
+		"summary": r.summary,
+		"responses": jsonObject{
+			strconv.Itoa(status): success,
+			"default": jsonObject{
+				"description": "Error",
+				"content":     errorContent,
+			},
+		},
+	}
+
+	if r.request != nil {
+		operation["requestBody"] = jsonObject{
+			"required": true,
+			"content": jsonObject{
+				"application/json": jsonObject{"schema": builder.schema(reflect.TypeOf(r.request))},
+			},
+		}
+	}
+
+	if i := strings.Index(r.path, "{"); i >= 0 {
+		operation["parameters"] = []jsonObject{
+			{
+				"name":     strings.Trim(r.path[i:], "{}"),
+				"in":       "path",
+				"required": true,
+				"schema":   jsonObject{"type": "string"},
+			},
+		}
+	}
+
+	return operation
+}
+
+// schemaBuilder creates JSON schemas from Go types the same way encoding/json encodes them.
+// Named structs are added to the document's components and referenced.
+type schemaBuilder struct {
+	schemas jsonObject
+	names   map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		schemas: jsonObject{},
+		names:   map[reflect.Type]string{},
+	}
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) jsonObject {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return jsonObject{"type": "string", "format": "date-time"}
+
+	case reflect.TypeOf(json.RawMessage{}):
+		return jsonObject{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem := b.schema(t.Elem())
+		if _, isRef := elem["$ref"]; isRef {
+			return jsonObject{"allOf": []jsonObject{elem}, "nullable": true}
+		}
+
+		elem["nullable"] = true
+		return elem
+
+	case reflect.Bool:
+		return jsonObject{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return jsonObject{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return jsonObject{"type": "number"}
+
+	case reflect.String:
+		return jsonObject{"type": "string"}
+
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return jsonObject{"type": "string", "format": "byte"}
+		}
+
+		return jsonObject{"type": "array", "items": b.schema(t.Elem())}
+
+	case reflect.Map:
+		return jsonObject{"type": "object", "additionalProperties": b.schema(t.Elem())}
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+
+		return b.ref(t)
+	}
+
+	// interfaces may hold anything
+	return jsonObject{}
+}
+
+// ref adds the named type to the components (if it isn't already) and returns a reference to it.
+func (b *schemaBuilder) ref(t reflect.Type) jsonObject {
+	name, ok := b.names[t]
+	if !ok {
+		name = b.componentName(t)
+		b.names[t] = name
+
+		// add a placeholder first in case the type refers to itself
+		b.schemas[name] = jsonObject{}
+		b.schemas[name] = b.structSchema(t)
+	}
+
+	return jsonObject{"$ref": "#/components/schemas/" + name}
+}
+
+// componentName returns a unique name for the type in the components.
+func (b *schemaBuilder) componentName(t reflect.Type) string {
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+
+	if _, exists := b.schemas[name]; exists {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	return name
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) jsonObject {
+	properties := jsonObject{}
+	required := []string{}
+
+	b.addFields(t, properties, &required)
+
+	schema := jsonObject{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+// addFields adds the struct's fields (including those of embedded structs) as properties.
+func (b *schemaBuilder) addFields(t reflect.Type, properties jsonObject, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			b.addFields(field.Type, properties, required)
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = b.schema(field.Type)
+
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
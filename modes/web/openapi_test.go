@@ -0,0 +1,365 @@
+//go:build !windows
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadOpenAPIDocument gets the document from the server the same way a client would.
+func loadOpenAPIDocument(t *testing.T, w *Web) (doc map[string]interface{}) {
+	t.Helper()
+
+	responseRecorder := httptest.NewRecorder()
+	w.apiHandler().ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, apiPrefix+"/openapi.json", nil))
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Fatalf("could not get OpenAPI document: status %d", responseRecorder.Code)
+	}
+
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+
+	doc := loadOpenAPIDocument(t, w)
+
+	if doc["openapi"] != openAPIVersion {
+		t.Errorf("expected openapi %q, got %v", openAPIVersion, doc["openapi"])
+	}
+
+	paths := doc["paths"].(map[string]interface{})
+
+	for _, route := range w.routes() {
+		for _, method := range route.methods {
+			if lookupOperation(doc, route.path, method) == nil {
+				t.Errorf("missing operation %s %s", method, route.path)
+			}
+		}
+	}
+
+	if len(paths) != len(w.routes()) {
+		t.Errorf("expected %d paths, got %d", len(w.routes()), len(paths))
+	}
+
+	// every reference must resolve
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+
+	for _, part := range strings.Split(string(encoded), `"$ref":"#/components/schemas/`)[1:] {
+		name := part[:strings.Index(part, `"`)]
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("reference to missing schema %q", name)
+		}
+	}
+}
+
+// specTest is a request to the API whose response must match the OpenAPI document.
+type specTest struct {
+	method string
+	path   string // path after apiPrefix
+	body   string
+	status int
+}
+
+// TestHandlersMatchSpec calls each endpoint and checks the status and response against the document.
+func TestHandlersMatchSpec(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+
+	doc := loadOpenAPIDocument(t, w)
+
+	runBody := fmt.Sprintf(`{"amod":%q, "goal":"[count: 1]", "frameworks":["text"]}`, testSource)
+
+	tests := []specTest{
+		{http.MethodGet, "/version", "", http.StatusOK},
+		{http.MethodGet, "/frameworks", "", http.StatusOK},
+		{http.MethodPost, "/run", runBody, http.StatusOK},
+		{http.MethodPost, "/run", `{"amod":"~~ model ~~"}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "/run", `{"amod":`, http.StatusBadRequest},
+		{http.MethodPost, "/run", `{"amod":"", "frameworks":["nope"]}`, http.StatusBadRequest},
+		{http.MethodGet, "/run", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/validate", runBody, http.StatusOK},
+		{http.MethodPost, "/generate", runBody, http.StatusOK},
+		{http.MethodPost, "/session/end", `{"sessionID":"nope"}`, http.StatusNotFound},
+		{http.MethodGet, "/session/list", "", http.StatusNotFound},
+		{http.MethodGet, "/jobs/nope", "", http.StatusNotFound},
+		{http.MethodGet, "/nope", "", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		checkSpec(t, w, doc, test)
+	}
+
+	// A session with a model
+	var session sessionResponse
+	checkSpec(t, w, doc, specTest{http.MethodPost, "/session/begin", "", http.StatusOK}, &session)
+
+	var model loadModelResponse
+	checkSpec(t, w, doc, specTest{http.MethodPost, "/model/load", fmt.Sprintf(`{"sessionID":%q, "amod":%q}`, session.SessionID, testSource), http.StatusOK}, &model)
+
+	modelBody := fmt.Sprintf(`{"sessionID":%q, "modelID":%d}`, session.SessionID, model.ModelID)
+
+	sessionTests := []specTest{
+		{http.MethodPost, "/model/load", fmt.Sprintf(`{"sessionID":%q, "amod":"~~ model ~~"}`, session.SessionID), http.StatusUnprocessableEntity},
+		{http.MethodPost, "/session/models", fmt.Sprintf(`{"sessionID":%q}`, session.SessionID), http.StatusOK},
+		{http.MethodPost, "/model/source", modelBody, http.StatusOK},
+		{http.MethodPost, "/session/runModel", fmt.Sprintf(`{"sessionID":%q, "modelID":%d, "buffers":{"goal":"[count: 1]"}, "frameworks":["text"]}`, session.SessionID, model.ModelID), http.StatusOK},
+		{http.MethodPost, "/model/source", fmt.Sprintf(`{"sessionID":%q, "modelID":%d}`, session.SessionID, model.ModelID+1), http.StatusNotFound},
+		{http.MethodPost, "/session/end", fmt.Sprintf(`{"sessionID":%q}`, session.SessionID), http.StatusOK},
+	}
+
+	for _, test := range sessionTests {
+		checkSpec(t, w, doc, test)
+	}
+
+	// A job
+	var job jobCreatedResponse
+	checkSpec(t, w, doc, specTest{http.MethodPost, "/jobs", runBody, http.StatusAccepted}, &job)
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for !w.jobs.lookup(job.JobID).isFinished() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	checkSpec(t, w, doc, specTest{http.MethodGet, "/jobs/" + job.JobID, "", http.StatusOK})
+	checkSpec(t, w, doc, specTest{http.MethodDelete, "/jobs/" + job.JobID, "", http.StatusOK})
+}
+
+func TestLegacyAPI(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+
+	// client errors use the old behaviour of returning the issues with http.StatusOK
+	request := httptest.NewRequest(http.MethodPost, legacyAPIPrefix+"/run", bytes.NewBufferString(`{"amod":"~~ model ~~"}`))
+	responseRecorder := httptest.NewRecorder()
+
+	w.apiHandler().ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, responseRecorder.Code)
+	}
+
+	if !strings.HasPrefix(responseRecorder.Body.String(), `{"issues":[{"level":"error"`) {
+		t.Errorf("expected issues, got %q", responseRecorder.Body.String())
+	}
+
+	// endpoints added since the API was versioned are not available
+	request = httptest.NewRequest(http.MethodGet, legacyAPIPrefix+"/jobs/nope", nil)
+	responseRecorder = httptest.NewRecorder()
+
+	w.apiHandler().ServeHTTP(responseRecorder, request)
+
+	if !strings.Contains(responseRecorder.Body.String(), "endpoint not found") {
+		t.Errorf("expected endpoint not found, got %q", responseRecorder.Body.String())
+	}
+}
+
+// checkSpec sends the request and checks the response against the document. If v is not nil,
+// the response is decoded into it.
+func checkSpec(t *testing.T, w *Web, doc map[string]interface{}, test specTest, v ...interface{}) {
+	t.Helper()
+
+	name := test.method + " " + test.path
+
+	request := httptest.NewRequest(test.method, apiPrefix+test.path, bytes.NewBufferString(test.body))
+	request.RemoteAddr = "192.0.2.1:1234"
+
+	responseRecorder := httptest.NewRecorder()
+	w.apiHandler().ServeHTTP(responseRecorder, request)
+
+	if responseRecorder.Code != test.status {
+		t.Errorf("%s: expected status %d, got %d: %s", name, test.status, responseRecorder.Code, responseRecorder.Body.String())
+		return
+	}
+
+	if contentType := responseRecorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+		t.Errorf("%s: expected JSON, got %q", name, contentType)
+		return
+	}
+
+	var schema map[string]interface{}
+
+	if operation := lookupOperation(doc, test.path, test.method); operation != nil {
+		responses := operation["responses"].(map[string]interface{})
+
+		response, ok := responses[strconv.Itoa(test.status)]
+		if !ok && test.status >= http.StatusBadRequest {
+			response, ok = responses["default"]
+		}
+
+		if !ok {
+			t.Errorf("%s: status %d is not in the document", name, test.status)
+			return
+		}
+
+		schema = response.(map[string]interface{})["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+	} else {
+		// unknown endpoints & methods must still return errors the same way
+		if test.status != http.StatusNotFound && test.status != http.StatusMethodNotAllowed {
+			t.Errorf("%s: not in the document", name)
+			return
+		}
+
+		schema = map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"}
+	}
+
+	var body interface{}
+
+	err := json.Unmarshal(responseRecorder.Body.Bytes(), &body)
+	if err != nil {
+		t.Errorf("%s: invalid JSON: %s", name, err.Error())
+		return
+	}
+
+	for _, problem := range validateSchema(doc, schema, body, "response") {
+		t.Errorf("%s: %s", name, problem)
+	}
+
+	if len(v) > 0 {
+		err = json.Unmarshal(responseRecorder.Body.Bytes(), v[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// lookupOperation finds the operation for the path (which may match a "{param}").
+func lookupOperation(doc map[string]interface{}, path, method string) map[string]interface{} {
+	paths := doc["paths"].(map[string]interface{})
+
+	for pattern, item := range paths {
+		prefix, _, hasParam := strings.Cut(pattern, "{")
+
+		matches := pattern == path
+		if hasParam {
+			matches = strings.HasPrefix(path, prefix) && !strings.Contains(path[len(prefix):], "/")
+		}
+
+		if matches {
+			operation, _ := item.(map[string]interface{})[strings.ToLower(method)].(map[string]interface{})
+			return operation
+		}
+	}
+
+	return nil
+}
+
+// validateSchema is a minimal JSON schema validator for the parts of OpenAPI the document uses.
+func validateSchema(doc map[string]interface{}, schema map[string]interface{}, value interface{}, location string) (problems []string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		resolved := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})[name]
+
+		return validateSchema(doc, resolved.(map[string]interface{}), value, location)
+	}
+
+	if value == nil {
+		if schema["nullable"] == true || len(schema) == 0 {
+			return nil
+		}
+
+		return []string{fmt.Sprintf("%s: unexpected null", location)}
+	}
+
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range allOf {
+			problems = append(problems, validateSchema(doc, sub.(map[string]interface{}), value, location)...)
+		}
+
+		return
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected object, got %T", location, value)}
+		}
+
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, name := range required {
+				if _, ok := object[name.(string)]; !ok {
+					problems = append(problems, fmt.Sprintf("%s: missing required property %q", location, name))
+				}
+			}
+		}
+
+		properties, _ := schema["properties"].(map[string]interface{})
+
+		names := []string{}
+		for name := range object {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		for _, name := range names {
+			propertyLocation := location + "." + name
+
+			if property, ok := properties[name]; ok {
+				problems = append(problems, validateSchema(doc, property.(map[string]interface{}), object[name], propertyLocation)...)
+				continue
+			}
+
+			switch additional := schema["additionalProperties"].(type) {
+			case map[string]interface{}:
+				problems = append(problems, validateSchema(doc, additional, object[name], propertyLocation)...)
+
+			case bool:
+				if !additional {
+					problems = append(problems, fmt.Sprintf("%s: not in the document", propertyLocation))
+				}
+			}
+		}
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected array, got %T", location, value)}
+		}
+
+		for i, item := range array {
+			problems = append(problems, validateSchema(doc, schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", location, i))...)
+		}
+
+	case "string":
+		if _, ok := value.(string); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected string, got %T", location, value))
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected boolean, got %T", location, value))
+		}
+
+	case "number", "integer":
+		number, ok := value.(float64)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: expected number, got %T", location, value))
+		} else if schema["type"] == "integer" && number != math.Trunc(number) {
+			problems = append(problems, fmt.Sprintf("%s: expected integer, got %v", location, number))
+		}
+	}
+
+	return
+}
@@ -0,0 +1,56 @@
+//go:build !windows
+
+package web
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/asmaloney/gactar/amod"
+	"github.com/asmaloney/gactar/framework"
+)
+
+// TestConcurrentRunModel runs a model from several goroutines (like concurrent requests) using the
+// same framework instance. Run it with "-race" to check for data races.
+func TestConcurrentRunModel(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+
+	model, _, err := amod.GenerateModelFromFile("../../framework/testdata/semantic.amod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const numRuns = 8
+
+	results := make([]frameworkRunResultMap, numRuns)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < numRuns; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			results[i] = w.runModel(context.Background(), model, framework.InitialBuffers{}, []string{"text"}, nil, nil)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, resultMap := range results {
+		result, ok := resultMap["text"]
+		if !ok {
+			t.Fatalf("run %d: missing result", i)
+		}
+
+		if result.Issues != nil {
+			t.Errorf("run %d: unexpected issues: %v", i, *result.Issues)
+		}
+
+		if result.Code == nil || result.Output == nil || *result.Output != *result.Code {
+			t.Errorf("run %d: expected output to match generated code", i)
+		}
+	}
+}
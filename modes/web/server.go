@@ -0,0 +1,116 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// corsMaxAge is how long (in seconds) browsers may cache the result of a CORS preflight request.
+const corsMaxAge = "3600"
+
+// newHandler returns the handler for the API, the UI, and the health & metrics endpoints using the
+// base path.
+func (w *Web) newHandler(corsOrigins []string) (handler http.Handler, err error) {
+	ui, err := w.uiHandler()
+	if err != nil {
+		return
+	}
+
+	mux := http.NewServeMux()
+
+	mux.Handle(w.basePath+legacyAPIPrefix+"/", http.StripPrefix(w.basePath, allowCORS(corsOrigins, w.apiHandler())))
+	mux.Handle(w.basePath+"/", http.StripPrefix(w.basePath, ui))
+
+	mux.HandleFunc(w.basePath+"/healthz", w.healthHandler)
+	mux.HandleFunc(w.basePath+"/metrics", w.metricsHandler)
+
+	if w.basePath != "" {
+		mux.Handle(w.basePath, http.RedirectHandler(w.basePath+"/", http.StatusMovedPermanently))
+	}
+
+	return mux, nil
+}
+
+// normalizeBasePath returns the path with a leading "/" and without a trailing one. The root
+// ("/" or "") is returned as "".
+func normalizeBasePath(basePath string) (normalized string, err error) {
+	normalized = strings.Trim(basePath, "/")
+	if normalized == "" {
+		return
+	}
+
+	parsed, err := url.Parse(normalized)
+	if err != nil || parsed.Path != normalized || strings.Contains(normalized, "//") {
+		return "", &ErrInvalidBasePath{Path: basePath}
+	}
+
+	return "/" + normalized, nil
+}
+
+// uiHandler serves the embedded web UI. If there is a base path, a base element is added to the
+// index page so the UI's relative paths use it, and any absolute paths are changed to use it.
+func (w *Web) uiHandler() (handler http.Handler, err error) {
+	assets := compressedAssetHandler(&mainAssets, "build")
+
+	index, err := mainAssets.ReadFile("build/index.html")
+	if err != nil {
+		return
+	}
+
+	if w.basePath != "" {
+		index = bytes.ReplaceAll(index, []byte(`="/`), []byte(`="`+w.basePath+`/`))
+		index = bytes.Replace(index, []byte("<head>"), []byte(`<head>`+"\n"+`    <base href="`+w.basePath+`/" />`), 1)
+	}
+
+	handler = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/" || req.URL.Path == "/index.html" {
+			rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = rw.Write(index)
+			return
+		}
+
+		assets.ServeHTTP(rw, req)
+	})
+
+	return
+}
+
+// allowCORS lets browsers use the API from pages served by the origins. See:
+// https://developer.mozilla.org/en-US/docs/Web/HTTP/CORS
+func allowCORS(origins []string, handler http.Handler) http.Handler {
+	if len(origins) == 0 {
+		return handler
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+
+		if origin != "" && isAllowedOrigin(origins, origin) {
+			rw.Header().Set("Access-Control-Allow-Origin", origin)
+			rw.Header().Add("Vary", "Origin")
+
+			// preflight request
+			if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+				rw.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE")
+				rw.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+				rw.Header().Set("Access-Control-Max-Age", corsMaxAge)
+				rw.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+func isAllowedOrigin(origins []string, origin string) bool {
+	for _, allowed := range origins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
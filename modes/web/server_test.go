@@ -0,0 +1,149 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/asmaloney/gactar/util/cli"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		basePath string
+		expected string
+		invalid  bool
+	}{
+		{"", "", false},
+		{"/", "", false},
+		{"gactar", "/gactar", false},
+		{"/gactar/", "/gactar", false},
+		{"/tools/gactar", "/tools/gactar", false},
+		{"/gactar?x=1", "", true},
+		{"/tools//gactar", "", true},
+	}
+
+	for _, tt := range tests {
+		normalized, err := normalizeBasePath(tt.basePath)
+
+		var invalid *ErrInvalidBasePath
+		if tt.invalid {
+			if !errors.As(err, &invalid) {
+				t.Errorf("%q: expected ErrInvalidBasePath, got %v", tt.basePath, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.basePath, err.Error())
+			continue
+		}
+
+		if normalized != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.basePath, tt.expected, normalized)
+		}
+	}
+}
+
+func TestTLSOptions(t *testing.T) {
+	options := DefaultOptions
+	options.CertFile = "cert.pem"
+
+	_, err := Initialize(&cli.Settings{}, options, nil)
+	if !errors.Is(err, ErrTLSIncomplete) {
+		t.Errorf("expected ErrTLSIncomplete, got %v", err)
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	options := DefaultOptions
+	options.BasePath = "/gactar/"
+
+	w, err := Initialize(&cli.Settings{}, options, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := testRequest(t, w.handler, http.MethodGet, "/gactar/api/v1/version", nil, nil)
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"version"`) {
+		t.Errorf("expected version using base path, got %d: %s", response.Code, response.Body.String())
+	}
+
+	response = testRequest(t, w.handler, http.MethodGet, "/api/v1/version", nil, nil)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("expected status %d without base path, got %d", http.StatusNotFound, response.Code)
+	}
+
+	response = testRequest(t, w.handler, http.MethodGet, "/gactar", nil, nil)
+	if response.Code != http.StatusMovedPermanently || response.Header().Get("Location") != "/gactar/" {
+		t.Errorf("expected redirect to /gactar/, got %d %q", response.Code, response.Header().Get("Location"))
+	}
+
+	response = testRequest(t, w.handler, http.MethodGet, "/gactar/", nil, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("expected status %d for the UI, got %d", http.StatusOK, response.Code)
+	}
+
+	index := response.Body.String()
+
+	if !strings.Contains(index, `<base href="/gactar/" />`) {
+		t.Errorf("expected base element in index: %s", index)
+	}
+
+	// the UI's paths are relative so they use the base element
+	if !strings.Contains(index, `href="./favicon.ico"`) || strings.Contains(index, `="/assets`) {
+		t.Errorf("expected paths in index to be relative: %s", index)
+	}
+
+	response = testRequest(t, w.handler, http.MethodGet, "/gactar/favicon.ico", nil, nil)
+	if response.Code != http.StatusOK {
+		t.Errorf("expected status %d for an asset, got %d", http.StatusOK, response.Code)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	options := DefaultOptions
+	options.CORSOrigins = []string{"https://example.com"}
+
+	w, err := Initialize(&cli.Settings{}, options, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// allowed origin
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/version", nil)
+	request.Header.Set("Origin", "https://example.com")
+
+	response := httptest.NewRecorder()
+	w.handler.ServeHTTP(response, request)
+	if response.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+		t.Errorf("expected origin to be allowed, got %q", response.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// other origin
+	request = httptest.NewRequest(http.MethodGet, "/api/v1/version", nil)
+	request.Header.Set("Origin", "https://example.org")
+
+	response = httptest.NewRecorder()
+	w.handler.ServeHTTP(response, request)
+	if response.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected origin not to be allowed, got %q", response.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// preflight
+	request = httptest.NewRequest(http.MethodOptions, "/api/v1/run", nil)
+	request.Header.Set("Origin", "https://example.com")
+	request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+	response = httptest.NewRecorder()
+	w.handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNoContent {
+		t.Errorf("expected status %d for preflight, got %d", http.StatusNoContent, response.Code)
+	}
+
+	if !strings.Contains(response.Header().Get("Access-Control-Allow-Methods"), http.MethodPost) {
+		t.Errorf("expected POST to be allowed, got %q", response.Header().Get("Access-Control-Allow-Methods"))
+	}
+}
@@ -1,65 +1,136 @@
 package web
 
 import (
-	"encoding/json"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/asmaloney/gactar/framework"
 
 	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/workspace"
 )
 
+// sessionExpireInterval is how often we remove idle sessions.
+const sessionExpireInterval = time.Minute
+
+// randomIDBytes is the number of random bytes in session & job IDs.
+const randomIDBytes = 16
+
 type Session struct {
-	id     int
-	models []*Model
+	id      string
+	created time.Time
+
+	mutex       sync.Mutex
+	lastUsed    time.Time
+	lastSaved   time.Time // lastUsed when the session was last saved to the backend
+	models      []*Model
+	nextModelID int
+	runs        []*runRecord // history of runs, oldest first
+	nextRunID   int
+	ended       bool // the session was ended or expired & removed from the store
 }
 
-type SessionList []*Session
+// sessionStore holds the sessions. It is safe for concurrent use.
+type sessionStore struct {
+	idleTimeout time.Duration // sessions unused for this long are removed (0 means never)
+	maxSessions int           // maximum number of sessions (0 means no limit)
+	maxModels   int           // maximum number of models per session (0 means no limit)
+	maxRuns     int           // number of runs kept in each session's history (0 means none)
+
+	now func() time.Time // so tests can control the clock
 
-func initSessions(w *Web) {
-	http.HandleFunc("/api/session/begin", w.beginSessionHandler)
-	http.HandleFunc("/api/session/runModel", w.runModelSessionHandler)
-	http.HandleFunc("/api/session/end", w.endSessionHandler)
+	backend sessionBackend // nil if sessions are only kept in memory
+
+	mutex    sync.Mutex
+	sessions map[string]*Session
 }
 
-func (w *Web) beginSessionHandler(rw http.ResponseWriter, req *http.Request) {
-	type response struct {
-		SessionID int `json:"session_id"`
+func newSessionStore(options Options) *sessionStore {
+	return &sessionStore{
+		idleTimeout: options.SessionIdleTimeout,
+		maxSessions: options.MaxSessions,
+		maxModels:   options.MaxModelsPerSession,
+		maxRuns:     options.RunHistory,
+		now:         time.Now,
+		sessions:    map[string]*Session{},
 	}
+}
+
+// sessionRequest is the request for endpoints which only need the session.
+type sessionRequest struct {
+	SessionID string `json:"sessionID"`
+}
+
+// sessionResponse is returned when a session begins.
+type sessionResponse struct {
+	SessionID string `json:"sessionID"`
+}
 
-	session := w.newSession()
+func (w *Web) beginSessionHandler(rw http.ResponseWriter, req *http.Request) {
+	session, err := w.sessions.newSession()
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
 
-	encodeResponse(rw, response{
+	encodeResponse(rw, sessionResponse{
 		SessionID: session.id,
 	})
 }
 
+type sessionRunRequest struct {
+	SessionID   string                   `json:"sessionID"`
+	ModelID     int                      `json:"modelID"`
+	Buffers     framework.InitialBuffers `json:"buffers"`              // set the initial buffers
+	Frameworks  []string                 `json:"frameworks,omitempty"` // list of frameworks to run on (if empty, "all")
+	IncludeCode bool                     `json:"includeCode"`          // include generated code in the result
+	Timeout     float64                  `json:"timeout,omitempty"`    // maximum time for each run in seconds (if 0, use the server's default)
+}
+
+type sessionRunResponse struct {
+	RunID   int                   `json:"runID,omitempty"` // ID of the run in the session's history (0 if the history is off)
+	Results frameworkRunResultMap `json:"results"`
+}
+
 func (w *Web) runModelSessionHandler(rw http.ResponseWriter, req *http.Request) {
-	type request struct {
-		SessionID   int                      `json:"sessionID"`
-		ModelID     int                      `json:"modelID"`
-		Buffers     framework.InitialBuffers `json:"buffers"`              // set the initial buffers
-		Frameworks  []string                 `json:"frameworks,omitempty"` // list of frameworks to run on (if empty, "all")
-		IncludeCode bool                     `json:"includeCode"`          // include generated code in the result
-	}
-	type response struct {
-		Results json.RawMessage `json:"results"`
+	var data sessionRunRequest
+	err := decodeBody(req, &data)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
 	}
 
-	var data request
-	err := decodeBody(req, &data)
+	ctx, cancel, err := w.runContext(req, data.Timeout)
 	if err != nil {
 		encodeErrorResponse(rw, err)
 		return
 	}
+	defer cancel()
 
-	session := w.lookupSession(data.SessionID)
+	session := w.sessions.lookup(data.SessionID)
 	if session == nil {
 		err = &ErrInvalidSessionID{ID: data.SessionID}
 		encodeErrorResponse(rw, err)
 		return
 	}
 
+	err = w.rateLimit.allow(w.clientKey(req, session.id))
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	// Keep each session's files together
+	ctx = workspace.WithKey(ctx, fmt.Sprintf("session-%s", session.id))
+
 	model := session.lookupModel(data.ModelID)
 	if model == nil {
 		err = &ErrInvalidModelID{ID: data.ModelID}
@@ -83,7 +154,21 @@ func (w *Web) runModelSessionHandler(rw http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	resultMap := w.runModel(model.actrModel, data.Buffers, data.Frameworks)
+	reservation, err := w.runs.admit(len(data.Frameworks))
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+	defer reservation.cancel()
+
+	started := time.Now()
+
+	resultMap := w.runModel(ctx, model.actrModel, data.Buffers, data.Frameworks, reservation, nil)
+
+	record := newRunRecord(model, data.Buffers, data.Frameworks, started, time.Now(), resultMap)
+	if session.addRun(record, w.sessions.maxRuns) {
+		w.sessions.persist(session)
+	}
 
 	for key := range resultMap {
 		result := resultMap[key]
@@ -99,45 +184,116 @@ func (w *Web) runModelSessionHandler(rw http.ResponseWriter, req *http.Request)
 		resultMap[key] = result
 	}
 
-	results, err := json.Marshal(resultMap)
+	encodeResponse(rw, sessionRunResponse{
+		RunID:   record.RunID,
+		Results: resultMap,
+	})
+}
+
+func (w *Web) endSessionHandler(rw http.ResponseWriter, req *http.Request) {
+	var data sessionRequest
+	err := decodeBody(req, &data)
 	if err != nil {
 		encodeErrorResponse(rw, err)
 		return
 	}
 
-	encodeResponse(rw, response{
-		Results: json.RawMessage(string(results)),
-	})
+	err = w.sessions.end(data.SessionID)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	encodeResponse(rw, emptyResponse{})
 }
 
-func (w *Web) endSessionHandler(rw http.ResponseWriter, req *http.Request) {
-	type request struct {
-		SessionID int `json:"sessionID"`
-	}
-	type response struct {
-	}
+// sessionInfo describes a session for the session list.
+type sessionInfo struct {
+	SessionID string     `json:"sessionID"`
+	Created   time.Time  `json:"created"`
+	LastUsed  time.Time  `json:"lastUsed"`
+	Expires   *time.Time `json:"expires,omitempty"` // nil if sessions do not expire
+	NumModels int        `json:"numModels"`
+}
 
-	var data request
-	err := decodeBody(req, &data)
+type sessionListResponse struct {
+	Sessions    []sessionInfo `json:"sessions"`
+	MaxSessions int           `json:"maxSessions"`
+	MaxModels   int           `json:"maxModelsPerSession"`
+	IdleTimeout float64       `json:"idleTimeout"` // in seconds
+}
+
+// listSessionsHandler is an admin view of the current sessions. Since the list includes the
+// session IDs, it is only available when the server has an admin token and the client sends it.
+func (w *Web) listSessionsHandler(rw http.ResponseWriter, req *http.Request) {
+	err := w.checkAdmin(req)
 	if err != nil {
+		if errors.Is(err, ErrAdminOnly) {
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="gactar"`)
+		}
+
 		encodeErrorResponse(rw, err)
 		return
 	}
 
-	err = w.endSession(data.SessionID)
+	encodeResponse(rw, sessionListResponse{
+		Sessions:    w.sessions.list(),
+		MaxSessions: w.sessions.maxSessions,
+		MaxModels:   w.sessions.maxModels,
+		IdleTimeout: w.sessions.idleTimeout.Seconds(),
+	})
+}
+
+// checkAdmin checks that the admin endpoints are turned on and the request has the admin token
+// in an "Authorization: Bearer <token>" header.
+func (w *Web) checkAdmin(req *http.Request) (err error) {
+	if w.adminToken == "" {
+		return ErrAdminDisabled
+	}
+
+	authorization := req.Header.Get("Authorization")
+	token := strings.TrimPrefix(authorization, "Bearer ")
+
+	if token == authorization || subtle.ConstantTimeCompare([]byte(token), []byte(w.adminToken)) != 1 {
+		return ErrAdminOnly
+	}
+
+	return
+}
+
+// newRandomID returns a random, hex-encoded ID for a session or job.
+func newRandomID() (id string, err error) {
+	b := make([]byte, randomIDBytes)
+
+	_, err = rand.Read(b)
 	if err != nil {
-		encodeErrorResponse(rw, err)
 		return
 	}
 
-	encodeResponse(rw, response{})
+	return hex.EncodeToString(b), nil
 }
 
-func (s *Session) addModel(model *Model) {
+// addModel adds the model to the session and assigns its ID.
+func (s *Session) addModel(model *Model, maxModels int) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if maxModels > 0 && len(s.models) >= maxModels {
+		return &ErrTooManyModels{Max: maxModels}
+	}
+
+	s.nextModelID++
+	model.id = s.nextModelID
+
 	s.models = append(s.models, model)
+
+	return
 }
 
 func (s *Session) lookupModel(modelID int) (model *Model) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	for _, model := range s.models {
 		if model.id == modelID {
 			return model
@@ -147,55 +303,228 @@ func (s *Session) lookupModel(modelID int) (model *Model) {
 	return nil
 }
 
+func (s *Session) modelInfos() (infos []modelInfo) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	infos = []modelInfo{}
+	for _, model := range s.models {
+		infos = append(infos, model.info())
+	}
+
+	return
+}
+
+func (s *Session) numModels() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.models)
+}
+
 func (s *Session) end() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
 	s.models = []*Model{}
+	s.runs = nil
+	s.ended = true
+}
+
+// newSession creates a session with a new ID and saves it to the backend.
+func (s *sessionStore) newSession() (session *Session, err error) {
+	session, err = s.createSession()
+	if err != nil {
+		return
+	}
+
+	s.persist(session)
+
+	return
+}
+
+// createSession creates a session with a new ID. Idle sessions are removed first so they do not
+// count against the maximum.
+func (s *sessionStore) createSession() (session *Session, err error) {
+	id, err := newRandomID()
+	if err != nil {
+		return
+	}
+
+	s.expire()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.maxSessions > 0 && len(s.sessions) >= s.maxSessions {
+		return nil, &ErrTooManySessions{Max: s.maxSessions}
+	}
+
+	now := s.now()
+
+	session = &Session{
+		id:       id,
+		created:  now,
+		lastUsed: now,
+	}
+
+	s.sessions[id] = session
+
+	return
 }
 
-func (w *Web) newSession() *Session {
-	session := &Session{
-		id: w.currentSessionID,
+// lookup returns the session with the id (nil if there isn't one) and marks it as used.
+func (s *sessionStore) lookup(id string) *Session {
+	s.mutex.Lock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		s.mutex.Unlock()
+		return nil
 	}
-	w.currentSessionID++
 
-	w.sessionList = append(w.sessionList, session)
+	if s.isExpired(session) {
+		session.end()
+		delete(s.sessions, id)
+		s.mutex.Unlock()
+
+		s.unpersist(id)
+		return nil
+	}
+
+	needsSave := session.touch(s.now())
+	s.mutex.Unlock()
+
+	if needsSave && s.backend != nil {
+		s.persist(session)
+	}
 
 	return session
 }
 
-func (w *Web) endSession(id int) error {
-	for index, session := range w.sessionList {
-		if session.id == id {
-			session.end()
-			w.sessionList = removeSession(w.sessionList, index)
-			return nil
-		}
+// touch marks the session as used and returns whether it is time to save it again.
+func (s *Session) touch(now time.Time) (needsSave bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.lastUsed = now
+
+	return now.Sub(s.lastSaved) >= sessionSaveInterval
+}
+
+// idleSince returns the time the session was last used.
+func (s *Session) idleSince() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.lastUsed
+}
+
+func (s *sessionStore) end(id string) error {
+	s.mutex.Lock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		s.mutex.Unlock()
+		return &ErrInvalidSessionID{ID: id}
 	}
 
-	return &ErrInvalidSessionID{ID: id}
+	session.end()
+	delete(s.sessions, id)
+	s.mutex.Unlock()
+
+	s.unpersist(id)
+
+	return nil
+}
+
+// count returns the number of sessions which have not expired.
+func (s *sessionStore) count() int {
+	s.expire()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.sessions)
 }
 
-func (w Web) hasSessions() bool {
-	return len(w.sessionList) > 0
+func (s *sessionStore) hasSessions() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.sessions) > 0
 }
 
-func (w Web) lookupSession(id int) *Session {
-	for _, session := range w.sessionList {
-		if session.id == id {
-			return session
+// list returns information about each session, oldest first.
+func (s *sessionStore) list() (infos []sessionInfo) {
+	s.expire()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	infos = []sessionInfo{}
+
+	for _, session := range s.sessions {
+		info := sessionInfo{
+			SessionID: session.id,
+			Created:   session.created,
+			LastUsed:  session.idleSince(),
+			NumModels: session.numModels(),
 		}
+
+		if s.idleTimeout > 0 {
+			expires := info.LastUsed.Add(s.idleTimeout)
+			info.Expires = &expires
+		}
+
+		infos = append(infos, info)
 	}
 
-	return nil
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Created.Before(infos[j].Created)
+	})
+
+	return
 }
 
-func (w *Web) clearSessions() {
-	for _, session := range w.sessionList {
-		session.end()
+// expire removes the sessions which have been idle for too long. They are removed from the
+// backend after we release the lock so we do not hold it during file I/O.
+func (s *sessionStore) expire() {
+	expired := []string{}
+
+	s.mutex.Lock()
+
+	for id, session := range s.sessions {
+		if s.isExpired(session) {
+			session.end()
+			delete(s.sessions, id)
+
+			expired = append(expired, id)
+		}
 	}
 
-	w.sessionList = SessionList{}
+	s.mutex.Unlock()
+
+	for _, id := range expired {
+		s.unpersist(id)
+	}
+}
+
+func (s *sessionStore) isExpired(session *Session) bool {
+	return s.idleTimeout > 0 && s.now().Sub(session.idleSince()) >= s.idleTimeout
 }
 
-func removeSession(s SessionList, index int) SessionList {
-	return append(s[:index], s[index+1:]...)
+func (s *sessionStore) clear() {
+	s.mutex.Lock()
+
+	sessions := s.sessions
+	s.sessions = map[string]*Session{}
+
+	s.mutex.Unlock()
+
+	for id, session := range sessions {
+		session.end()
+
+		s.unpersist(id)
+	}
 }
@@ -2,37 +2,45 @@ package web
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewSession(t *testing.T) {
-	session := webTest.newSession()
+	session, err := webTest.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if session == nil {
 		t.Errorf("Could not create session")
 	}
 
-	webTest.clearSessions()
+	webTest.sessions.clear()
 }
 
 func TestEndSession(t *testing.T) {
-	session := webTest.newSession()
+	session, err := webTest.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if session == nil {
 		t.Fatalf("Could not create session")
 	}
 
-	err := webTest.endSession(session.id)
+	err = webTest.sessions.end(session.id)
 
 	if err != nil {
 		t.Errorf("Unexpected error: %s", err.Error())
 	}
 
-	if webTest.hasSessions() {
+	if webTest.sessions.hasSessions() {
 		t.Errorf("Did not remove session from list")
 	}
 }
@@ -53,20 +61,23 @@ func TestBeginSessionHandler(t *testing.T) {
 			http.StatusOK, status)
 	}
 
-	expected := `{"session_id":`
+	expected := `{"sessionID":`
 	responseStr := strings.TrimSpace(responseRecorder.Body.String())
 	if !strings.HasPrefix(responseStr, expected) {
 		t.Errorf("handler returned unexpected body: expected to start with '%v' got '%v'",
 			expected, responseStr)
 	}
 
-	webTest.clearSessions()
+	webTest.sessions.clear()
 }
 
 func TestEndSessionHandler(t *testing.T) {
-	session := webTest.newSession()
+	session, err := webTest.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	data := []byte(fmt.Sprintf(`{"sessionID":%d}`, session.id))
+	data := []byte(fmt.Sprintf(`{"sessionID":%q}`, session.id))
 
 	request, err := http.NewRequest("PUT", "/session/end", bytes.NewBuffer(data))
 	if err != nil {
@@ -90,7 +101,7 @@ func TestEndSessionHandler(t *testing.T) {
 			expected, responseStr)
 	}
 
-	if webTest.hasSessions() {
+	if webTest.sessions.hasSessions() {
 		t.Errorf("Did not remove session from list")
 	}
 }
@@ -98,7 +109,10 @@ func TestEndSessionHandler(t *testing.T) {
 // Commented out for now since the CI does not install any frameworks.
 
 // func TestRunModelSessionHandler(t *testing.T) {
-// 	session := webTest.newSession()
+// 	session, err := webTest.sessions.newSession()
+// 	if err != nil {
+// 		t.Fatal(err)
+// 	}
 
 // 	src := `==model==
 // 	name: Test
@@ -156,7 +170,7 @@ func TestEndSessionHandler(t *testing.T) {
 // 		return
 // 	}
 
-// 	data := []byte(fmt.Sprintf(`{"sessionID":%d, "modelID":%d, "buffers":{ "goal":"[countFrom: 2 5 starting]" }}`, session.id, model.id))
+// 	data := []byte(fmt.Sprintf(`{"sessionID":%q, "modelID":%d, "buffers":{ "goal":"[countFrom: 2 5 starting]" }}`, session.id, model.id))
 
 // 	request, err := http.NewRequest("PUT", "/session/run", bytes.NewBuffer(data))
 // 	if err != nil {
@@ -180,9 +194,182 @@ func TestEndSessionHandler(t *testing.T) {
 // 			expected, responseStr)
 // 	}
 
-// 	webTest.endSession(session.id)
+// 	webTest.sessions.end(session.id)
 
-// 	if webTest.hasSessions() {
+// 	if webTest.sessions.hasSessions() {
 // 		t.Errorf("Did not remove session from list")
 // 	}
 // }
+
+func TestSessionStoreExpiry(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store := newSessionStore(Options{SessionIdleTimeout: time.Minute})
+	store.now = func() time.Time { return now }
+
+	session, err := store.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(50 * time.Second)
+	if store.lookup(session.id) == nil {
+		t.Fatalf("session expired too soon")
+	}
+
+	// lookup marks it as used, so this is 50s after it was last used
+	now = now.Add(50 * time.Second)
+	if store.lookup(session.id) == nil {
+		t.Fatalf("session expired even though it was used")
+	}
+
+	now = now.Add(time.Minute)
+	store.expire()
+
+	if store.hasSessions() {
+		t.Errorf("idle session was not removed")
+	}
+
+	if store.lookup(session.id) != nil {
+		t.Errorf("expected expired session to be gone")
+	}
+}
+
+func TestSessionStoreListExpires(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store := newSessionStore(Options{SessionIdleTimeout: time.Minute})
+	store.now = func() time.Time { return now }
+
+	_, err := store.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infos := store.list()
+	if len(infos) != 1 || infos[0].Expires == nil || !infos[0].Expires.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected session to expire in a minute, got %+v", infos)
+	}
+
+	// sessions which do not expire do not have an expiry time
+	store = newSessionStore(Options{})
+
+	_, err = store.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infos = store.list()
+	if len(infos) != 1 || infos[0].Expires != nil {
+		t.Errorf("expected session not to expire, got %+v", infos)
+	}
+}
+
+func TestSessionStoreLimits(t *testing.T) {
+	store := newSessionStore(Options{MaxSessions: 2, MaxModelsPerSession: 1})
+
+	first, err := store.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := store.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.id == second.id {
+		t.Errorf("expected unique session IDs, got %q twice", first.id)
+	}
+
+	if len(first.id) != randomIDBytes*2 {
+		t.Errorf("expected a random hex ID, got %q", first.id)
+	}
+
+	_, err = store.newSession()
+
+	var tooManySessions *ErrTooManySessions
+	if !errors.As(err, &tooManySessions) {
+		t.Errorf("expected too many sessions error, got %v", err)
+	}
+
+	err = first.addModel(&Model{}, store.maxModels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = first.addModel(&Model{}, store.maxModels)
+
+	var tooManyModels *ErrTooManyModels
+	if !errors.As(err, &tooManyModels) {
+		t.Errorf("expected too many models error, got %v", err)
+	}
+
+	// Ending a session makes room for another
+	err = store.end(second.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.newSession()
+	if err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestListSessionsHandler(t *testing.T) {
+	session, err := webTest.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer webTest.sessions.clear()
+
+	// the admin endpoints are off unless there is a token
+	request := httptest.NewRequest("GET", "/api/session/list", nil)
+	request.RemoteAddr = "127.0.0.1:5555"
+
+	responseRecorder := httptest.NewRecorder()
+	http.HandlerFunc(webTest.listSessionsHandler).ServeHTTP(responseRecorder, request)
+
+	if status := responseRecorder.Code; status != http.StatusNotFound {
+		t.Errorf("expected the endpoint to be off without an admin token, got status '%v'", status)
+	}
+
+	webTest.adminToken = "secret"
+	defer func() { webTest.adminToken = "" }()
+
+	// requests from the server's machine (e.g. through a reverse proxy) need the token too
+	responseRecorder = httptest.NewRecorder()
+	http.HandlerFunc(webTest.listSessionsHandler).ServeHTTP(responseRecorder, request)
+
+	if status := responseRecorder.Code; status != http.StatusUnauthorized {
+		t.Errorf("expected requests without the token to be unauthorized, got status '%v'", status)
+	}
+
+	request.Header.Set("Authorization", "Bearer wrong")
+
+	responseRecorder = httptest.NewRecorder()
+	http.HandlerFunc(webTest.listSessionsHandler).ServeHTTP(responseRecorder, request)
+
+	if status := responseRecorder.Code; status != http.StatusUnauthorized {
+		t.Errorf("expected requests with the wrong token to be unauthorized, got status '%v'", status)
+	}
+
+	request.Header.Set("Authorization", "Bearer secret")
+
+	responseRecorder = httptest.NewRecorder()
+	http.HandlerFunc(webTest.listSessionsHandler).ServeHTTP(responseRecorder, request)
+
+	if status := responseRecorder.Code; status != http.StatusOK {
+		t.Fatalf("handler returned incorrect status code: expected '%v' got '%v'",
+			http.StatusOK, status)
+	}
+
+	expected := fmt.Sprintf(`{"sessions":[{"sessionID":%q,`, session.id)
+	responseStr := strings.TrimSpace(responseRecorder.Body.String())
+	if !strings.HasPrefix(responseStr, expected) {
+		t.Errorf("handler returned unexpected body: expected to start with '%v' got '%v'",
+			expected, responseStr)
+	}
+}
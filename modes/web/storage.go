@@ -0,0 +1,282 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionSaveInterval limits how often we save a session just because it was used.
+const sessionSaveInterval = time.Minute
+
+// sessionBackend stores sessions & their models so they survive a server restart.
+type sessionBackend interface {
+	save(session storedSession) error
+	remove(id string) error
+	loadAll() ([]storedSession, error)
+}
+
+// storedModel is a model as it is stored. We keep the amod source and compile it again when
+// it is loaded.
+type storedModel struct {
+	ID     int       `json:"id"`
+	Name   string    `json:"name"`
+	Loaded time.Time `json:"loaded"`
+	Source string    `json:"amod"`
+}
+
+// storedSession is a session as it is stored.
+type storedSession struct {
+	ID          string        `json:"id"`
+	Created     time.Time     `json:"created"`
+	LastUsed    time.Time     `json:"lastUsed"`
+	NextModelID int           `json:"nextModelID"`
+	Models      []storedModel `json:"models"`
+	NextRunID   int           `json:"nextRunID,omitempty"`
+	Runs        []runRecord   `json:"runs,omitempty"` // history of runs, oldest first
+}
+
+// fileBackend stores each session as a JSON file in a directory.
+type fileBackend struct {
+	dir   string
+	mutex sync.Mutex
+}
+
+func newFileBackend(dataDir string) (backend *fileBackend, err error) {
+	dir := filepath.Join(dataDir, "sessions")
+
+	err = os.MkdirAll(dir, 0750)
+	if err != nil {
+		return
+	}
+
+	return &fileBackend{dir: dir}, nil
+}
+
+func (b *fileBackend) fileName(id string) string {
+	return filepath.Join(b.dir, id+".json")
+}
+
+// save writes the session to a temporary file and renames it so we never leave a partial file.
+func (b *fileBackend) save(session storedSession) (err error) {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	tempName := b.fileName(session.ID) + ".tmp"
+
+	err = os.WriteFile(tempName, data, 0600)
+	if err != nil {
+		return
+	}
+
+	return os.Rename(tempName, b.fileName(session.ID))
+}
+
+func (b *fileBackend) remove(id string) (err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	err = os.Remove(b.fileName(id))
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+
+	return
+}
+
+// loadAll reads all the sessions. Files we cannot read are skipped with a warning.
+func (b *fileBackend) loadAll() (sessions []storedSession, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		fileName := filepath.Join(b.dir, entry.Name())
+
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			log.Printf("could not read session %q: %s", fileName, err.Error())
+			continue
+		}
+
+		var session storedSession
+
+		err = json.Unmarshal(data, &session)
+		if err != nil || session.ID+".json" != entry.Name() {
+			log.Printf("could not read session %q: invalid session file", fileName)
+			continue
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return
+}
+
+// snapshot returns a copy of the session in the form we store it. ok is false if the session has
+// ended, in which case it should not be stored.
+func (s *Session) snapshot() (stored storedSession, ok bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	stored = storedSession{
+		ID:          s.id,
+		Created:     s.created,
+		LastUsed:    s.lastUsed,
+		NextModelID: s.nextModelID,
+		Models:      []storedModel{},
+		NextRunID:   s.nextRunID,
+	}
+
+	for _, model := range s.models {
+		stored.Models = append(stored.Models, storedModel{
+			ID:     model.id,
+			Name:   model.actrModel.Name,
+			Loaded: model.loaded,
+			Source: model.source,
+		})
+	}
+
+	for _, run := range s.runs {
+		stored.Runs = append(stored.Runs, *run)
+	}
+
+	return stored, true
+}
+
+// persist saves the session if we have a backend. The server still works if this fails,
+// so problems are only logged. Sessions which were ended or expired (e.g. while one of their
+// runs was happening) are not saved so they do not come back when the server restarts.
+func (s *sessionStore) persist(session *Session) {
+	if s.backend == nil {
+		return
+	}
+
+	// copy the session so we do not hold any locks while writing the file
+	stored, ok := session.snapshot()
+	if !ok {
+		return
+	}
+
+	err := s.backend.save(stored)
+	if err != nil {
+		log.Printf("could not save session: %s", err.Error())
+		return
+	}
+
+	session.mutex.Lock()
+	ended := session.ended
+	session.lastSaved = stored.LastUsed
+	session.mutex.Unlock()
+
+	// if the session ended while we were saving it, its file may have been removed before we wrote
+	// it, so remove it again
+	if ended {
+		s.unpersist(session.id)
+	}
+}
+
+// unpersist removes the session from the backend if we have one.
+func (s *sessionStore) unpersist(id string) {
+	if s.backend == nil {
+		return
+	}
+
+	err := s.backend.remove(id)
+	if err != nil {
+		log.Printf("could not remove session: %s", err.Error())
+	}
+}
+
+// restore loads the sessions from the backend and compiles their models. Sessions which expired
+// while the server was down are removed. Models which no longer compile are dropped.
+func (s *sessionStore) restore() (err error) {
+	if s.backend == nil {
+		return
+	}
+
+	stored, err := s.backend.loadAll()
+	if err != nil {
+		return
+	}
+
+	numSessions := 0
+	numModels := 0
+
+	for _, storedSession := range stored {
+		session := &Session{
+			id:          storedSession.ID,
+			created:     storedSession.Created,
+			lastUsed:    storedSession.LastUsed,
+			lastSaved:   storedSession.LastUsed,
+			nextModelID: storedSession.NextModelID,
+			nextRunID:   storedSession.NextRunID,
+		}
+
+		if s.isExpired(session) {
+			s.unpersist(session.id)
+			continue
+		}
+
+		for _, storedModel := range storedSession.Models {
+			actrModel, err := generateModel(storedModel.Source)
+			if err != nil {
+				log.Printf("session %s: dropping model %d (%s): %s", session.id, storedModel.ID, storedModel.Name, err.Error())
+				continue
+			}
+
+			session.models = append(session.models, &Model{
+				id:        storedModel.ID,
+				actrModel: actrModel,
+				source:    storedModel.Source,
+				loaded:    storedModel.Loaded,
+			})
+		}
+
+		// keep the most recent runs in case the history is smaller than it was
+		runs := storedSession.Runs
+		if len(runs) > s.maxRuns {
+			runs = runs[len(runs)-s.maxRuns:]
+		}
+
+		for i := range runs {
+			session.runs = append(session.runs, &runs[i])
+		}
+
+		numSessions++
+		numModels += len(session.models)
+
+		s.mutex.Lock()
+		s.sessions[session.id] = session
+		s.mutex.Unlock()
+	}
+
+	if len(stored) > 0 {
+		fmt.Printf("Restored %d session(s) with %d model(s)\n", numSessions, numModels)
+	}
+
+	return
+}
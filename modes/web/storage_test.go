@@ -0,0 +1,195 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionRestore(t *testing.T) {
+	options := DefaultOptions
+	options.DataDir = t.TempDir()
+
+	w := newTestWeb(t, options)
+
+	session, err := w.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := w.loadModel(session.id, testSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A new server using the same directory should have the session & model
+	restarted := newTestWeb(t, options)
+
+	restoredSession := restarted.sessions.lookup(session.id)
+	if restoredSession == nil {
+		t.Fatalf("session %q was not restored", session.id)
+	}
+
+	restoredModel := restoredSession.lookupModel(model.id)
+	if restoredModel == nil {
+		t.Fatalf("model %d was not restored", model.id)
+	}
+
+	if restoredModel.actrModel == nil || restoredModel.actrModel.Name != "Test" {
+		t.Errorf("model was not compiled when it was restored")
+	}
+
+	if restoredModel.source != testSource {
+		t.Errorf("expected restored source %q, got %q", testSource, restoredModel.source)
+	}
+
+	if !restoredModel.loaded.Equal(model.loaded) {
+		t.Errorf("expected load time %v, got %v", model.loaded, restoredModel.loaded)
+	}
+
+	// Model IDs continue from where they left off
+	next, err := restarted.loadModel(session.id, testSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next.id != model.id+1 {
+		t.Errorf("expected next model id %d, got %d", model.id+1, next.id)
+	}
+
+	// Ending the session removes it from storage
+	err = restarted.sessions.end(session.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = os.Stat(filepath.Join(options.DataDir, "sessions", session.id+".json")); !os.IsNotExist(err) {
+		t.Errorf("expected session file to be removed, got %v", err)
+	}
+}
+
+func TestSessionRestoreExpired(t *testing.T) {
+	options := DefaultOptions
+	options.DataDir = t.TempDir()
+
+	w := newTestWeb(t, options)
+
+	session, err := w.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := newSessionStore(options)
+	later.now = func() time.Time { return time.Now().Add(options.SessionIdleTimeout) }
+	later.backend = w.sessions.backend
+
+	err = later.restore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if later.hasSessions() {
+		t.Errorf("expected expired session %q not to be restored", session.id)
+	}
+
+	if _, err = os.Stat(filepath.Join(options.DataDir, "sessions", session.id+".json")); !os.IsNotExist(err) {
+		t.Errorf("expected expired session file to be removed, got %v", err)
+	}
+}
+
+func TestPersistEndedSession(t *testing.T) {
+	options := DefaultOptions
+	options.DataDir = t.TempDir()
+
+	w := newTestWeb(t, options)
+
+	session, err := w.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// e.g. the session ends while one of its runs is happening and the run saves it when it finishes
+	err = w.sessions.end(session.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.sessions.persist(session)
+
+	if _, err = os.Stat(filepath.Join(options.DataDir, "sessions", session.id+".json")); !os.IsNotExist(err) {
+		t.Errorf("expected ended session not to be saved, got %v", err)
+	}
+
+	restarted := newTestWeb(t, options)
+
+	if restarted.sessions.hasSessions() {
+		t.Errorf("expected ended session %q not to be restored", session.id)
+	}
+}
+
+func TestListModelsAndSourceHandlers(t *testing.T) {
+	w := newTestWeb(t, DefaultOptions)
+
+	session, err := w.sessions.newSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := w.loadModel(session.id, testSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(fmt.Sprintf(`{"sessionID":%q}`, session.id))
+
+	request, err := http.NewRequest("PUT", "/api/session/models", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responseRecorder := httptest.NewRecorder()
+	http.HandlerFunc(w.listModelsHandler).ServeHTTP(responseRecorder, request)
+
+	expected := fmt.Sprintf(`{"sessionID":%q,"models":[{"modelID":%d,"modelName":"Test","loaded":`, session.id, model.id)
+	responseStr := strings.TrimSpace(responseRecorder.Body.String())
+	if !strings.HasPrefix(responseStr, expected) {
+		t.Errorf("handler returned unexpected body: expected to start with '%v' got '%v'",
+			expected, responseStr)
+	}
+
+	data = []byte(fmt.Sprintf(`{"sessionID":%q, "modelID":%d}`, session.id, model.id))
+
+	request, err = http.NewRequest("PUT", "/api/model/source", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responseRecorder = httptest.NewRecorder()
+	http.HandlerFunc(w.modelSourceHandler).ServeHTTP(responseRecorder, request)
+
+	responseStr = strings.TrimSpace(responseRecorder.Body.String())
+	if !strings.Contains(responseStr, `"amod":"~~ model ~~\nname: Test`) {
+		t.Errorf("handler did not return the source: got '%v'", responseStr)
+	}
+
+	data = []byte(fmt.Sprintf(`{"sessionID":%q, "modelID":%d}`, session.id, model.id+1))
+
+	request, err = http.NewRequest("PUT", "/api/model/source", bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responseRecorder = httptest.NewRecorder()
+	http.HandlerFunc(w.modelSourceHandler).ServeHTTP(responseRecorder, request)
+
+	responseStr = strings.TrimSpace(responseRecorder.Body.String())
+	if !strings.Contains(responseStr, "invalid model id") {
+		t.Errorf("expected invalid model error, got '%v'", responseStr)
+	}
+}
@@ -0,0 +1,144 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/asmaloney/gactar/framework"
+	"github.com/asmaloney/gactar/util/issues"
+)
+
+// runStream is used by runModel to pass on output and results as they happen.
+type runStream interface {
+	output(frameworkName string) io.Writer
+	result(frameworkName string, result frameworkRunResult)
+}
+
+// sseStream sends run output & results to the client using Server-Sent Events.
+// See: https://html.spec.whatwg.org/multipage/server-sent-events.html
+type sseStream struct {
+	rw      http.ResponseWriter
+	flusher http.Flusher
+	mutex   sync.Mutex
+}
+
+// Event names used in the stream.
+const (
+	sseEventIssues = "issues" // issues with the model itself (sent first)
+	sseEventOutput = "output" // a line of output from a framework
+	sseEventResult = "result" // the result of a framework's run (sent when it finishes)
+	sseEventDone   = "done"   // all runs are finished
+)
+
+func newSSEStream(rw http.ResponseWriter) (stream *sseStream, err error) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		return nil, ErrStreamingNotSupported
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.WriteHeader(http.StatusOK)
+
+	stream = &sseStream{
+		rw:      rw,
+		flusher: flusher,
+	}
+
+	return
+}
+
+// send writes an event with the data encoded as JSON.
+func (s *sseStream) send(event string, data interface{}) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	fmt.Fprintf(s.rw, "event: %s\ndata: %s\n\n", event, encoded)
+	s.flusher.Flush()
+}
+
+func (s *sseStream) output(frameworkName string) io.Writer {
+	type outputEvent struct {
+		Framework string `json:"framework"`
+		Line      string `json:"line"`
+	}
+
+	return framework.NewLineWriter(func(line string) {
+		s.send(sseEventOutput, outputEvent{
+			Framework: frameworkName,
+			Line:      line,
+		})
+	})
+}
+
+func (s *sseStream) result(frameworkName string, result frameworkRunResult) {
+	type resultEvent struct {
+		Framework string             `json:"framework"`
+		Result    frameworkRunResult `json:"result"`
+	}
+
+	s.send(sseEventResult, resultEvent{
+		Framework: frameworkName,
+		Result:    result,
+	})
+}
+
+// runModelStreamHandler takes the same request as runModelHandler, but streams the output
+// of each framework as it runs using Server-Sent Events.
+func (w Web) runModelStreamHandler(rw http.ResponseWriter, req *http.Request) {
+	data, model, log, ok := w.prepareRun(rw, req)
+	if !ok {
+		return
+	}
+
+	ctx, cancel, err := w.runContext(req, data.Timeout)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+	defer cancel()
+
+	reservation, err := w.runs.admit(len(data.Frameworks))
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+	defer reservation.cancel()
+
+	stream, err := newSSEStream(rw)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
+	}
+
+	type issuesEvent struct {
+		Issues     issues.IssueList `json:"issues"`
+		Frameworks []string         `json:"frameworks"`
+	}
+
+	sort.Strings(data.Frameworks)
+
+	stream.send(sseEventIssues, issuesEvent{
+		Issues:     log.AllIssues(),
+		Frameworks: data.Frameworks,
+	})
+
+	initialBuffers := framework.InitialBuffers{
+		"goal": strings.TrimSpace(data.Goal),
+	}
+
+	w.runModel(ctx, model, initialBuffers, data.Frameworks, reservation, stream)
+
+	stream.send(sseEventDone, struct{}{})
+}
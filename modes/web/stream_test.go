@@ -0,0 +1,78 @@
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSSEStreamOutput(t *testing.T) {
+	responseRecorder := httptest.NewRecorder()
+
+	stream, err := newSSEStream(responseRecorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = stream.output("ccm").Write([]byte("first line\nsecond"))
+
+	if contentType := responseRecorder.Header().Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("unexpected content type: %q", contentType)
+	}
+
+	expected := "event: output\ndata: {\"framework\":\"ccm\",\"line\":\"first line\"}\n\n"
+	if responseRecorder.Body.String() != expected {
+		t.Errorf("unexpected body: expected %q got %q", expected, responseRecorder.Body.String())
+	}
+}
+
+func TestRunModelStreamHandler(t *testing.T) {
+	src := `~~ model ~~\nname: Test\n~~ config ~~\n~~ init ~~\n~~ productions ~~`
+
+	body := []byte(`{"amod": "` + src + `", "frameworks": []}`)
+
+	request, err := http.NewRequest("POST", "/api/run/stream", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responseRecorder := httptest.NewRecorder()
+	handler := http.HandlerFunc(webTest.runModelStreamHandler)
+
+	handler.ServeHTTP(responseRecorder, request)
+
+	if status := responseRecorder.Code; status != http.StatusOK {
+		t.Errorf("handler returned incorrect status code: expected '%v' got '%v'", http.StatusOK, status)
+	}
+
+	response := responseRecorder.Body.String()
+
+	if !strings.HasPrefix(response, "event: issues\n") {
+		t.Errorf("expected stream to start with issues event, got %q", response)
+	}
+
+	if !strings.HasSuffix(response, "event: done\ndata: {}\n\n") {
+		t.Errorf("expected stream to end with done event, got %q", response)
+	}
+}
+
+func TestRunModelStreamHandlerInvalidFramework(t *testing.T) {
+	body := []byte(`{"amod": "", "frameworks": ["foo"]}`)
+
+	request, err := http.NewRequest("POST", "/api/run/stream", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responseRecorder := httptest.NewRecorder()
+	handler := http.HandlerFunc(webTest.runModelStreamHandler)
+
+	handler.ServeHTTP(responseRecorder, request)
+
+	expected := `{"issues":[{"level":"error","text":"invalid framework name: \"foo\""`
+	if !strings.HasPrefix(responseRecorder.Body.String(), expected) {
+		t.Errorf("unexpected body: expected to start with %q got %q", expected, responseRecorder.Body.String())
+	}
+}
@@ -2,17 +2,25 @@
 package web
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"path"
+	"os/signal"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/jwalton/gchalk"
 	"github.com/vearutop/statigz"
@@ -24,6 +32,7 @@ import (
 
 	"github.com/asmaloney/gactar/util/cli"
 	"github.com/asmaloney/gactar/util/container"
+	"github.com/asmaloney/gactar/util/executil"
 	"github.com/asmaloney/gactar/util/issues"
 	"github.com/asmaloney/gactar/util/validate"
 	"github.com/asmaloney/gactar/util/version"
@@ -32,32 +41,104 @@ import (
 //go:embed build/*
 var mainAssets embed.FS
 
-// fsFunc is used to access embedded files in assetHandler()
-type fsFunc func(name string) (fs.File, error)
+// workspaceCleanInterval is how often we remove old run workspaces.
+const workspaceCleanInterval = 10 * time.Minute
 
-func (f fsFunc) Open(name string) (fs.File, error) {
-	return f(name)
+// Options configure the web server.
+type Options struct {
+	Host     string // interface to listen on ("" means all interfaces)
+	Port     int
+	BasePath string // prefix for all routes & the UI (e.g. "/gactar" when behind a reverse proxy)
+
+	CertFile string // TLS certificate - if this & KeyFile are set, the server uses HTTPS
+	KeyFile  string // TLS private key
+
+	CORSOrigins []string // origins which may use the API from a browser ("*" allows any)
+
+	AdminToken string // token clients must send to use the admin endpoints ("" turns them off)
+
+	SessionIdleTimeout  time.Duration // sessions unused for this long are removed (0 means never)
+	MaxSessions         int           // maximum number of sessions (0 means no limit)
+	MaxModelsPerSession int           // maximum number of models loaded in each session (0 means no limit)
+
+	DataDir string // directory to store sessions & models in so they survive a restart ("" keeps them in memory)
+
+	RunHistory int // number of runs kept in each session's history (0 means none)
+
+	ExampleDirs    []string // directories of amod files to serve as examples along with the built-in ones
+	ReloadExamples bool     // reload the example directories when they change
+
+	JobWorkers int // number of framework runs from the jobs API which may happen at once
+	MaxJobs    int // maximum number of jobs which are queued or running (0 means no limit)
+
+	MaxRuns       int // maximum number of framework runs which may happen at once (0 means no limit)
+	MaxQueuedRuns int // maximum number of framework runs waiting to start (0 means no limit)
+
+	RateLimit float64 // runs each client may request per minute (0 means no limit)
+	RateBurst int     // runs each client may request at once before RateLimit applies
+
+	TrustedProxies []string // IP addresses or CIDR networks of proxies whose X-Forwarded-For header identifies the client
+
+	MaxRequestSize int64 // maximum size of a request body in bytes (0 means no limit)
+}
+
+// DefaultOptions are used for any options which are not set on the command line.
+var DefaultOptions = Options{
+	Port:                8181,
+	SessionIdleTimeout:  30 * time.Minute,
+	MaxSessions:         100,
+	MaxModelsPerSession: 20,
+	RunHistory:          20,
+	JobWorkers:          runtime.NumCPU(),
+	MaxJobs:             100,
+	MaxRuns:             runtime.NumCPU(),
+	MaxQueuedRuns:       100,
+	RateLimit:           30,
+	RateBurst:           10,
+	MaxRequestSize:      1 << 20,
 }
 
 type Web struct {
 	settings *cli.Settings
-	examples *embed.FS
+	examples *exampleSet
+
+	host     string
 	port     int
+	basePath string
+	certFile string
+	keyFile  string
+
+	adminToken string
+
+	trustedProxies []*net.IPNet
 
-	sessionList      SessionList
-	currentSessionID int
+	handler        http.Handler
+	maxRequestSize int64
+	reloadExamples bool
+
+	sessions  *sessionStore
+	jobs      *jobManager
+	runs      *runLimiter
+	rateLimit *rateLimiter
+	metrics   *metrics
+	health    *healthChecker
 }
 
 type frameworkRunResult struct {
 	ModelName string            `json:"modelName"`        // name of the model (from the amod file)
 	Issues    *issues.IssueList `json:"issues,omitempty"` // issues specific to this framework
 
-	FilePath *string `json:"filePath,omitempty"` // intermediate code file (full path)
-	Code     *string `json:"code,omitempty"`     // actual code which was run
-	Output   *string `json:"output,omitempty"`   // output of run (stdout + stderr)
+	FilePath      *string `json:"filePath,omitempty"`      // intermediate code file (full path)
+	WorkspacePath *string `json:"workspacePath,omitempty"` // directory containing the files for this run (full path)
+	Code          *string `json:"code,omitempty"`          // actual code which was run
+	Output        *string `json:"output,omitempty"`        // output of run (stdout + stderr)
+	TimedOut      bool    `json:"timedOut,omitempty"`      // true if the run was killed because it took too long
+
+	FinalState *framework.FinalState `json:"finalState,omitempty"` // state of the buffers (and optionally memory) at the end of the run
+	Stats      *framework.RunStats   `json:"stats,omitempty"`      // production firing & retrieval statistics
 
-	SessionID *int `json:"sessionID,omitempty"`
-	ModelID   *int `json:"modelID,omitempty"`
+	SessionID *string `json:"sessionID,omitempty"`
+	ModelID   *int    `json:"modelID,omitempty"`
 }
 
 type frameworkRunResultMap map[string]frameworkRunResult
@@ -67,59 +148,178 @@ type runResult struct {
 	Results frameworkRunResultMap `json:"results,omitempty"`
 }
 
-func Initialize(settings *cli.Settings, port int, examples *embed.FS) (w *Web, err error) {
+func Initialize(settings *cli.Settings, options Options, examples *embed.FS) (w *Web, err error) {
+	if (options.CertFile == "") != (options.KeyFile == "") {
+		return nil, ErrTLSIncomplete
+	}
+
+	basePath, err := normalizeBasePath(options.BasePath)
+	if err != nil {
+		return nil, err
+	}
+
+	trustedProxies, err := parseTrustedProxies(options.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
 	w = &Web{
-		settings:         settings,
-		examples:         examples,
-		port:             port,
-		sessionList:      SessionList{},
-		currentSessionID: 1,
+		settings: settings,
+		host:     options.Host,
+		port:     options.Port,
+		basePath: basePath,
+		certFile: options.CertFile,
+		keyFile:  options.KeyFile,
+
+		adminToken: options.AdminToken,
+
+		trustedProxies: trustedProxies,
+
+		maxRequestSize: options.MaxRequestSize,
+
+		sessions:  newSessionStore(options),
+		runs:      newRunLimiter(options.MaxRuns, options.MaxQueuedRuns),
+		rateLimit: newRateLimiter(options.RateLimit, options.RateBurst),
+		metrics:   newMetrics(),
+		health:    &healthChecker{},
 	}
 
-	http.HandleFunc("/api/version", w.getVersionHandler)
-	http.HandleFunc("/api/frameworks", w.getFrameworksHandler)
-	http.HandleFunc("/api/run", w.runModelHandler)
-	http.HandleFunc("/api/", http.NotFound)
+	w.jobs = newJobManager(w, options)
 
-	if examples != nil {
-		initExamples(w)
+	w.examples, err = newExampleSet(examples, options.ExampleDirs)
+	if err != nil {
+		return nil, err
 	}
 
-	initSessions(w)
-	initModels(w)
+	w.reloadExamples = options.ReloadExamples && len(options.ExampleDirs) > 0
 
-	mainHandler := compressedAssetHandler(&mainAssets, "build")
-	http.HandleFunc("/", mainHandler.ServeHTTP)
+	if options.DataDir != "" {
+		w.sessions.backend, err = newFileBackend(options.DataDir)
+		if err != nil {
+			return nil, err
+		}
+
+		err = w.sessions.restore()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w.handler, err = w.newHandler(options.CORSOrigins)
+	if err != nil {
+		return nil, err
+	}
 
 	return
 }
 
+// Start runs the server until it gets SIGINT or SIGTERM. It then stops accepting requests and waits
+// for the requests & jobs which are running to finish. A second signal exits immediately.
 func (w Web) Start() (err error) {
+	go w.cleanWorkspaces()
+	go w.expireSessions()
+
+	if w.reloadExamples {
+		go w.examples.watch()
+	}
+
+	server := &http.Server{
+		Addr:    net.JoinHostPort(w.host, strconv.Itoa(w.port)),
+		Handler: w.handler,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+
+	go func() {
+		if w.certFile != "" {
+			serverErr <- server.ListenAndServeTLS(w.certFile, w.keyFile)
+		} else {
+			serverErr <- server.ListenAndServe()
+		}
+	}()
+
 	fmt.Printf("Serving gactar on ")
-	fmt.Println(gchalk.WithBlue().Underline(fmt.Sprintf("http://localhost:%d", w.port)))
+	fmt.Println(gchalk.WithBlue().Underline(w.url()))
 
-	err = http.ListenAndServe(fmt.Sprintf(":%d", w.port), nil)
+	select {
+	case err = <-serverErr:
+		return
+
+	case <-ctx.Done():
+	}
+
+	// restore the default behaviour so another signal exits immediately
+	stop()
+
+	fmt.Println("Shutting down - waiting for runs to finish...")
+
+	err = server.Shutdown(context.Background())
 	if err != nil {
 		return
 	}
 
+	w.jobs.shutdown()
+
 	return
 }
 
-func (Web) getVersionHandler(rw http.ResponseWriter, req *http.Request) {
-	type response struct {
-		Version string `json:"version"`
+// url returns the URL to use to connect to the server.
+func (w Web) url() string {
+	scheme := "http"
+	if w.certFile != "" {
+		scheme = "https"
 	}
 
-	encodeResponse(rw, response{
+	host := w.host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+
+	return fmt.Sprintf("%s://%s%s/", scheme, net.JoinHostPort(host, strconv.Itoa(w.port)), w.basePath)
+}
+
+// cleanWorkspaces periodically removes old run workspaces based on the retention policy.
+func (w Web) cleanWorkspaces() {
+	ticker := time.NewTicker(workspaceCleanInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		err := w.settings.CleanWorkspaces()
+		if err != nil {
+			log.Printf("error cleaning workspaces: %s", err.Error())
+		}
+	}
+}
+
+// expireSessions periodically removes sessions which have been idle for too long.
+func (w Web) expireSessions() {
+	ticker := time.NewTicker(sessionExpireInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		w.sessions.expire()
+	}
+}
+
+type versionResponse struct {
+	Version string `json:"version"`
+}
+
+func (Web) getVersionHandler(rw http.ResponseWriter, req *http.Request) {
+	encodeResponse(rw, versionResponse{
 		Version: version.BuildVersion,
 	})
 }
 
+type frameworksResponse struct {
+	Frameworks framework.InfoList  `json:"frameworks"`
+	Features   []framework.Feature `json:"features"` // features listed in each framework's capabilities
+}
+
 func (w Web) getFrameworksHandler(rw http.ResponseWriter, req *http.Request) {
-	type response struct {
-		Frameworks framework.InfoList `json:"frameworks"`
-	}
 
 	frameworks := framework.InfoList{}
 
@@ -132,68 +332,93 @@ func (w Web) getFrameworksHandler(rw http.ResponseWriter, req *http.Request) {
 		return frameworks[i].Name < frameworks[j].Name
 	})
 
-	encodeResponse(rw, response{
+	encodeResponse(rw, frameworksResponse{
 		Frameworks: frameworks,
+		Features:   framework.Features,
 	})
 }
 
+// runRequest is the request for the /api/run and /api/run/stream endpoints.
+type runRequest struct {
+	AMODFile   string   `json:"amod"`                 // text of an amod file
+	Goal       string   `json:"goal"`                 // initial goal
+	Frameworks []string `json:"frameworks,omitempty"` // list of frameworks to run on (if empty, "all")
+	Timeout    float64  `json:"timeout,omitempty"`    // maximum time for each run in seconds (if 0, use the server's default)
+}
+
 func (w Web) runModelHandler(rw http.ResponseWriter, req *http.Request) {
-	type request struct {
-		AMODFile   string   `json:"amod"`                 // text of an amod file
-		Goal       string   `json:"goal"`                 // initial goal
-		Frameworks []string `json:"frameworks,omitempty"` // list of frameworks to run on (if empty, "all")
+	data, model, log, ok := w.prepareRun(rw, req)
+	if !ok {
+		return
 	}
 
-	var data request
-	err := decodeBody(req, &data)
+	ctx, cancel, err := w.runContext(req, data.Timeout)
 	if err != nil {
 		encodeErrorResponse(rw, err)
 		return
 	}
+	defer cancel()
 
-	data.Frameworks = w.normalizeFrameworkList(data.Frameworks)
-
-	err = w.verifyFrameworkList(data.Frameworks)
+	reservation, err := w.runs.admit(len(data.Frameworks))
 	if err != nil {
 		encodeErrorResponse(rw, err)
 		return
 	}
+	defer reservation.cancel()
+
+	initialBuffers := framework.InitialBuffers{
+		"goal": strings.TrimSpace(data.Goal),
+	}
+
+	resultMap := w.runModel(ctx, model, initialBuffers, data.Frameworks, reservation, nil)
 
-	model, log, err := amod.GenerateModel(data.AMODFile)
+	encodeResponse(rw, runResult{
+		Issues:  log.AllIssues(),
+		Results: resultMap,
+	})
+}
+
+// prepareRun checks the client's rate limit, decodes and checks a run request, and generates the
+// model. If there is a problem, the error response is written and ok is false.
+func (w Web) prepareRun(rw http.ResponseWriter, req *http.Request) (data runRequest, model *actr.Model, log *issues.Log, ok bool) {
+	err := w.rateLimit.allow(w.clientKey(req, ""))
 	if err != nil {
-		encodeIssueResponse(rw, log)
+		encodeErrorResponse(rw, err)
 		return
 	}
 
-	initialGoal := strings.TrimSpace(data.Goal)
-	initialBuffers := framework.InitialBuffers{
-		"goal": initialGoal,
+	err = decodeBody(req, &data)
+	if err != nil {
+		encodeErrorResponse(rw, err)
+		return
 	}
 
-	validate.Goal(model, initialGoal, log)
+	data.Frameworks = w.normalizeFrameworkList(data.Frameworks)
 
-	// ensure temp dir exists
-	// https://github.com/asmaloney/gactar/issues/103
-	_, err = cli.CreateTempDir(w.settings)
+	err = w.verifyFrameworkList(data.Frameworks)
 	if err != nil {
 		encodeErrorResponse(rw, err)
 		return
 	}
 
-	resultMap := w.runModel(model, initialBuffers, data.Frameworks)
-
-	rr := runResult{
-		Issues:  log.AllIssues(),
-		Results: resultMap,
+	model, log, err = amod.GenerateModel(data.AMODFile)
+	if err != nil {
+		encodeIssueResponse(rw, log)
+		return
 	}
 
-	results, err := json.Marshal(rr)
+	validate.Goal(model, strings.TrimSpace(data.Goal), log)
+
+	// ensure temp dir exists
+	// https://github.com/asmaloney/gactar/issues/103
+	_, err = cli.CreateTempDir(w.settings)
 	if err != nil {
 		encodeErrorResponse(rw, err)
 		return
 	}
 
-	encodeResponse(rw, json.RawMessage(string(results)))
+	ok = true
+	return
 }
 
 // normalizeFrameworkList will look for "all" and replace it with all available
@@ -228,81 +453,164 @@ func (w Web) verifyFrameworkList(list []string) (err error) {
 	return
 }
 
-func (w Web) runModel(model *actr.Model, initialBuffers framework.InitialBuffers, frameworkNames []string) (resultMap frameworkRunResultMap) {
+// runContext returns the context to use when running models for a request. The run is cancelled if
+// the client goes away or the timeout expires (see runTimeout).
+func (w Web) runContext(req *http.Request, timeout float64) (ctx context.Context, cancel context.CancelFunc, err error) {
+	duration, err := w.runTimeout(timeout)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel = cli.WithTimeout(req.Context(), duration)
+	return
+}
+
+// runTimeout returns the timeout for a run requested by a client. The timeout is in seconds - if it
+// is 0, the server's timeout is used. If the server has a timeout, clients may not go over it.
+func (w Web) runTimeout(timeout float64) (duration time.Duration, err error) {
+	if timeout < 0 {
+		err = &ErrInvalidTimeout{Timeout: timeout}
+		return
+	}
+
+	duration = w.settings.Timeout
+
+	if timeout > 0 {
+		requested := time.Duration(timeout * float64(time.Second))
+
+		if duration <= 0 || requested < duration {
+			duration = requested
+		}
+	}
+
+	return
+}
+
+// runModel runs the model on the frameworks in parallel using the places in the run queue from
+// reservation (which may be nil). If stream is not nil, the output and results are passed to it as
+// they happen.
+func (w Web) runModel(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers, frameworkNames []string, reservation *runReservation, stream runStream) (resultMap frameworkRunResultMap) {
 	resultMap = make(frameworkRunResultMap, len(frameworkNames))
 
 	var wg sync.WaitGroup
 	var mutex = &sync.Mutex{}
 
 	for _, name := range frameworkNames {
-		f := w.settings.Frameworks[name]
-
 		wg.Add(1)
 
-		go func(wg *sync.WaitGroup, name string, f framework.Framework) {
+		go func(wg *sync.WaitGroup, name string) {
 			defer wg.Done()
 
-			result := &framework.RunResult{}
-
-			log := f.ValidateModel(model)
-			if !log.HasError() {
-				r, err := runModelOnFramework(model, initialBuffers, f)
-				if err != nil {
-					log.Error(nil, err.Error())
-				}
-				if r != nil {
-					result = r
-				}
+			var output io.Writer
+			if stream != nil {
+				output = stream.output(name)
 			}
 
-			frameworkResult := frameworkRunResult{
-				ModelName: model.Name,
-			}
+			frameworkResult := w.runFramework(ctx, model, initialBuffers, name, reservation, output)
 
 			mutex.Lock()
+			resultMap[name] = frameworkResult
+			mutex.Unlock()
 
-			if log.HasIssues() {
-				all := log.AllIssues()
-				frameworkResult.Issues = &all
+			if stream != nil {
+				stream.result(name, frameworkResult)
 			}
+		}(&wg, name)
+	}
+	wg.Wait()
 
-			if result.FileName != "" {
-				frameworkResult.FilePath = &result.FileName
-			}
+	return
+}
+
+// runFramework runs the model on one framework. If output is not nil, the output of the run is
+// written to it as it happens.
+func (w Web) runFramework(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers, name string, reservation *runReservation, output io.Writer) (frameworkResult frameworkRunResult) {
+	f := w.settings.Frameworks[name]
 
-			if len(result.GeneratedCode) > 0 {
-				codeStr := string(result.GeneratedCode)
-				frameworkResult.Code = &codeStr
+	result := &framework.RunResult{}
+	timedOut := false
 
+	log := f.ValidateModel(model)
+	if !log.HasError() {
+		err := w.runs.acquire(ctx, reservation)
+		if err != nil {
+			log.Error(nil, fmt.Sprintf("run did not start: %s", err.Error()))
+			timedOut = errors.Is(err, context.DeadlineExceeded)
+
+			if timedOut {
+				w.metrics.runNotStarted(name, runTimeout)
+			} else {
+				w.metrics.runNotStarted(name, runCancelled)
 			}
-			if len(result.Output) > 0 {
-				outputStr := string(result.Output)
-				frameworkResult.Output = &outputStr
+		} else {
+			w.metrics.runStarted(name)
+			start := time.Now()
+
+			r, err := runModelOnFramework(ctx, model, initialBuffers, f, output)
+			w.runs.release()
 
+			w.metrics.runFinished(name, runResultLabel(err), time.Since(start))
+
+			if err != nil {
+				log.Error(nil, err.Error())
+
+				var timeoutErr *executil.ErrTimeout
+				timedOut = errors.As(err, &timeoutErr)
+			}
+			if r != nil {
+				result = r
 			}
+		}
+	} else {
+		w.metrics.runNotStarted(name, runError)
+	}
 
-			resultMap[name] = frameworkResult
+	frameworkResult = frameworkRunResult{
+		ModelName: model.Name,
+		TimedOut:  timedOut,
+	}
 
-			mutex.Unlock()
-		}(&wg, name, f)
+	if log.HasIssues() {
+		all := log.AllIssues()
+		frameworkResult.Issues = &all
+	}
+
+	if result.FileName != "" {
+		frameworkResult.FilePath = &result.FileName
+	}
+
+	if result.WorkspacePath != "" {
+		frameworkResult.WorkspacePath = &result.WorkspacePath
 	}
-	wg.Wait()
+
+	if len(result.GeneratedCode) > 0 {
+		codeStr := string(result.GeneratedCode)
+		frameworkResult.Code = &codeStr
+
+	}
+	if len(result.Output) > 0 {
+		outputStr := string(result.Output)
+		frameworkResult.Output = &outputStr
+
+	}
+
+	if result.Events != nil && result.Events.HasState() {
+		finalState := result.Events.FinalState
+		frameworkResult.FinalState = &finalState
+	}
+
+	frameworkResult.Stats = result.Stats
 
 	return
 }
 
-func runModelOnFramework(model *actr.Model, initialBuffers framework.InitialBuffers, f framework.Framework) (result *framework.RunResult, err error) {
+func runModelOnFramework(ctx context.Context, model *actr.Model, initialBuffers framework.InitialBuffers, f framework.Framework, output io.Writer) (result *framework.RunResult, err error) {
 	if model == nil {
 		err = ErrNoModel
 		return
 	}
 
-	err = f.SetModel(model)
-	if err != nil {
-		return
-	}
-
-	result, err = f.Run(initialBuffers)
+	result, err = f.RunStreaming(ctx, model, initialBuffers, output)
 	if err != nil {
 		return
 	}
@@ -319,22 +627,53 @@ func decodeBody(req *http.Request, v interface{}) (err error) {
 
 	err = decoder.Decode(&v)
 	if err != nil {
-		return err
+		var tooLarge *ErrRequestTooLarge
+		if errors.As(err, &tooLarge) {
+			return
+		}
+
+		return &ErrInvalidRequest{Err: err}
 	}
 
 	return
 }
 
+// errorResponse is returned by all endpoints when there is a problem with the request.
+type errorResponse struct {
+	Issues     issues.IssueList `json:"issues"`
+	RetryAfter int              `json:"retryAfter,omitempty"` // seconds to wait before trying again (also in the Retry-After header)
+}
+
+// emptyResponse is returned by endpoints which have nothing to return.
+type emptyResponse struct {
+}
+
 func encodeResponse(rw http.ResponseWriter, v interface{}) {
-	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
-	encodeErr := json.NewEncoder(rw).Encode(v)
+	encodeResponseStatus(rw, http.StatusOK, v)
+}
+
+func encodeResponseStatus(rw http.ResponseWriter, status int, v interface{}) {
+	data, encodeErr := json.Marshal(v)
 	if encodeErr != nil {
 		http.Error(rw, encodeErr.Error(), http.StatusInternalServerError)
+		return
 	}
+
+	rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+	rw.WriteHeader(status)
+
+	_, _ = rw.Write(append(data, '\n'))
 }
 
+// encodeErrorResponse returns the error using the HTTP status for its type (see errorStatus).
 func encodeErrorResponse(rw http.ResponseWriter, err error) {
-	errResponse := runResult{
+	var generationFailed *framework.ErrModelGenerationFailed
+	if errors.As(err, &generationFailed) {
+		encodeResponseStatus(rw, http.StatusUnprocessableEntity, errorResponse{Issues: generationFailed.Log.AllIssues()})
+		return
+	}
+
+	response := errorResponse{
 		Issues: issues.IssueList{
 			{
 				Level: "error",
@@ -343,38 +682,17 @@ func encodeErrorResponse(rw http.ResponseWriter, err error) {
 		},
 	}
 
-	encodeErr := json.NewEncoder(rw).Encode(errResponse)
-	if encodeErr != nil {
-		http.Error(rw, encodeErr.Error(), http.StatusInternalServerError)
+	if wait := retryAfter(err); wait > 0 {
+		response.RetryAfter = retryAfterSeconds(wait)
+		rw.Header().Set("Retry-After", strconv.Itoa(response.RetryAfter))
 	}
-}
-
-func encodeIssueResponse(rw http.ResponseWriter, log *issues.Log) {
-	errResponse := runResult{Issues: log.AllIssues()}
 
-	encodeErr := json.NewEncoder(rw).Encode(errResponse)
-	if encodeErr != nil {
-		http.Error(rw, encodeErr.Error(), http.StatusInternalServerError)
-	}
+	encodeResponseStatus(rw, errorStatus(err), response)
 }
 
-// assetHandler returns an http.Handler that will serve files from
-// the given embed.FS.  When locating a file, it will optionally strip
-// and append a prefix to the filesystem lookup.
-// Adapted from https://blog.lawrencejones.dev/golang-embed/
-func assetHandler(assets *embed.FS, stripPrefix, prepend string) http.Handler {
-	handler := fsFunc(func(name string) (fs.File, error) {
-		assetPath := path.Join(prepend, name)
-
-		f, err := assets.Open(assetPath)
-		if os.IsNotExist(err) {
-			return assets.Open("build/index.html")
-		}
-
-		return f, err
-	})
-
-	return http.StripPrefix(stripPrefix, http.FileServer(http.FS(handler)))
+// encodeIssueResponse returns the issues from a model which could not be generated.
+func encodeIssueResponse(rw http.ResponseWriter, log *issues.Log) {
+	encodeResponseStatus(rw, http.StatusUnprocessableEntity, errorResponse{Issues: log.AllIssues()})
 }
 
 // compressedAssetHandler returns an http.Handler that will serve files from
@@ -1,15 +1,35 @@
 package web
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/asmaloney/gactar/framework"
+	"github.com/asmaloney/gactar/framework/plugin"
 	"github.com/asmaloney/gactar/util/cli"
 	"github.com/asmaloney/gactar/util/frameworkutil"
 )
 
 var webTest *Web = nil
 
+// testSource is a small model used by the tests. Its production prints the goal.
+const testSource = `~~ model ~~
+name: Test
+~~ config ~~
+chunks { [count: first] }
+~~ init ~~
+~~ productions ~~
+start {
+	match { goal [count: ?x] }
+	do { print ?x }
+}`
+
 func TestMain(m *testing.M) {
 	settings := &cli.Settings{}
 
@@ -17,9 +37,89 @@ func TestMain(m *testing.M) {
 
 	settings.Frameworks = frameworks
 
-	webTest, _ = Initialize(settings, 8181, nil)
+	webTest, _ = Initialize(settings, DefaultOptions, nil)
 
 	exitVal := m.Run()
 
 	os.Exit(exitVal)
 }
+
+// newTestWeb returns a Web for testing with the sessions & jobs set up using the options. If
+// options.DataDir is set, the sessions are stored there and any which are already there are
+// restored. Its only framework is the "text" plugin, which runs "cat", so it is not available on
+// Windows - tests which run models are built with "!windows".
+func newTestWeb(t *testing.T, options Options) *Web {
+	t.Helper()
+
+	manifest, err := plugin.LoadManifest("../../framework/plugin/testdata/template.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// register it once so it is a valid framework name for requests
+	if plugin.Lookup(manifest.Name) == nil {
+		err = plugin.Register(manifest)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings := &cli.Settings{TempPath: t.TempDir(), Frameworks: framework.List{}}
+
+	p, err := plugin.New(settings, manifest)
+	if err == nil {
+		settings.Frameworks[manifest.Name] = p
+	}
+
+	w := &Web{settings: settings, sessions: newSessionStore(options)}
+	w.jobs = newJobManager(w, options)
+
+	if options.DataDir != "" {
+		w.sessions.backend, err = newFileBackend(options.DataDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = w.sessions.restore()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return w
+}
+
+// testRequest sends a request to handler and returns the response. The body may be nil, a string
+// which is sent as is, or a value which is sent as JSON. If v is not nil, the response is decoded
+// into it.
+func testRequest(t *testing.T, handler http.Handler, method, target string, body interface{}, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+
+	switch body := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(body)
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	responseRecorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(responseRecorder, httptest.NewRequest(method, target, reader))
+
+	if v != nil {
+		err := json.Unmarshal(responseRecorder.Body.Bytes(), v)
+		if err != nil {
+			t.Fatalf("could not decode response %q: %s", responseRecorder.Body.String(), err.Error())
+		}
+	}
+
+	return responseRecorder
+}
@@ -0,0 +1,176 @@
+// Package batch provides a worker pool to run many models on several frameworks in parallel.
+// It is used for parameter sweeps and fitting.
+package batch
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asmaloney/gactar/actr"
+	"github.com/asmaloney/gactar/framework"
+
+	"github.com/asmaloney/gactar/util/cli"
+	"github.com/asmaloney/gactar/util/workspace"
+)
+
+// Job is a single run of a model on one framework.
+type Job struct {
+	Model          *actr.Model
+	InitialBuffers framework.InitialBuffers
+	Framework      string // name of the framework to run on
+}
+
+// Result is the result of running a Job.
+type Result struct {
+	Job       *Job
+	RunResult *framework.RunResult
+	Err       error
+	Elapsed   time.Duration // wall-clock time of the run
+}
+
+// Measures is a map of measure names to values parsed from a run's output.
+type Measures map[string]float64
+
+// Pool runs jobs on a fixed number of workers. The workers share the framework instances
+// and each run gets its own workspace so runs do not interfere.
+type Pool struct {
+	frameworks framework.List
+	numWorkers int
+	timeout    time.Duration // maximum time for each run (0 means no limit)
+}
+
+// NewPool creates a pool of "numWorkers" workers for the named frameworks, which must
+// already have been created in "settings".
+func NewPool(settings *cli.Settings, frameworkNames []string, numWorkers int) (pool *Pool, err error) {
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+
+	for _, name := range frameworkNames {
+		if !settings.Frameworks.Exists(name) {
+			return nil, &ErrFrameworkNotCreated{Name: name}
+		}
+	}
+
+	pool = &Pool{
+		frameworks: settings.Frameworks,
+		numWorkers: numWorkers,
+		timeout:    settings.Timeout,
+	}
+
+	return
+}
+
+// NumWorkers returns the number of workers in the pool.
+func (p Pool) NumWorkers() int {
+	return p.numWorkers
+}
+
+// Run runs all the jobs using the workers and returns the results in the same order as the jobs.
+// The optional "progress" function is called after each job finishes. If the context is cancelled,
+// running jobs are killed and the remaining jobs return the context's error.
+func (p Pool) Run(ctx context.Context, jobs []*Job, progress func(done, total int)) (results []Result) {
+	results = make([]Result, len(jobs))
+
+	jobIndices := make(chan int)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+
+	done := 0
+
+	for i := 0; i < p.numWorkers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for index := range jobIndices {
+				results[index] = p.runJob(ctx, jobs[index])
+
+				if progress != nil {
+					mutex.Lock()
+					done++
+					progress(done, len(jobs))
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for index := range jobs {
+		jobIndices <- index
+	}
+
+	close(jobIndices)
+
+	wg.Wait()
+
+	return
+}
+
+func (p Pool) runJob(ctx context.Context, job *Job) (result Result) {
+	result.Job = job
+
+	if ctx.Err() != nil {
+		result.Err = ctx.Err()
+		return
+	}
+
+	f, ok := p.frameworks[job.Framework]
+	if !ok {
+		result.Err = &ErrFrameworkNotCreated{Name: job.Framework}
+		return
+	}
+
+	log := f.ValidateModel(job.Model)
+	if log.HasError() {
+		result.Err = &framework.ErrModelValidationFailed{Log: log}
+		return
+	}
+
+	start := time.Now()
+
+	ctx, cancel := cli.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	result.RunResult, result.Err = f.Run(workspace.WithKey(ctx, "batch"), job.Model, job.InitialBuffers)
+	result.Elapsed = time.Since(start)
+
+	// We only need the output, so don't keep the workspace around
+	if result.RunResult != nil && result.RunResult.WorkspacePath != "" {
+		_ = os.RemoveAll(result.RunResult.WorkspacePath)
+	}
+
+	return
+}
+
+// measureRegex matches lines like "rt: 0.35" or "rt = 0.35".
+var measureRegex = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*[:=]\s*([-+]?[0-9]*\.?[0-9]+(?:[eE][-+]?[0-9]+)?)\s*$`)
+
+// ParseMeasures looks for lines in the output of the form "name: number" or "name=number"
+// and returns them as measures. If a measure is output more than once, the last value is used.
+func ParseMeasures(output []byte) (measures Measures) {
+	measures = Measures{}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		match := measureRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			continue
+		}
+
+		measures[match[1]] = value
+	}
+
+	return
+}
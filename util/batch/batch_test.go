@@ -0,0 +1,31 @@
+package batch
+
+import (
+	"testing"
+)
+
+func TestParseMeasures(t *testing.T) {
+	output := []byte(`   0.000 production_time 0.05
+rt: 0.35
+accuracy = 1
+not a measure: foo
+rt: 0.4e-1
+`)
+
+	measures := ParseMeasures(output)
+
+	expected := Measures{
+		"rt":       0.04,
+		"accuracy": 1,
+	}
+
+	if len(measures) != len(expected) {
+		t.Fatalf("expected %d measures, got %d: %v", len(expected), len(measures), measures)
+	}
+
+	for name, value := range expected {
+		if measures[name] != value {
+			t.Errorf("measure %q: expected %v, got %v", name, value, measures[name])
+		}
+	}
+}
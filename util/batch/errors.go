@@ -0,0 +1,11 @@
+package batch
+
+import "fmt"
+
+type ErrFrameworkNotCreated struct {
+	Name string
+}
+
+func (e ErrFrameworkNotCreated) Error() string {
+	return fmt.Sprintf("could not create framework %q", e.Name)
+}
@@ -0,0 +1,98 @@
+//go:build !windows
+
+package batch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/asmaloney/gactar/amod"
+	"github.com/asmaloney/gactar/framework"
+	"github.com/asmaloney/gactar/framework/plugin"
+	"github.com/asmaloney/gactar/util/cli"
+)
+
+// newTestSettings creates settings with a plugin framework ("text") which uses "cat" to run the code.
+func newTestSettings(t *testing.T) *cli.Settings {
+	t.Helper()
+
+	manifest, err := plugin.LoadManifest("../../framework/plugin/testdata/template.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings := &cli.Settings{TempPath: t.TempDir()}
+
+	p, err := plugin.New(settings, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settings.Frameworks = framework.List{"text": p}
+
+	return settings
+}
+
+func TestPoolRun(t *testing.T) {
+	settings := newTestSettings(t)
+
+	pool, err := NewPool(settings, []string{"text"}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pool.NumWorkers() != 4 {
+		t.Errorf("expected 4 workers, got %d", pool.NumWorkers())
+	}
+
+	model, _, err := amod.GenerateModelFromFile("../../framework/testdata/semantic.amod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := make([]*Job, 16)
+	for i := range jobs {
+		jobs[i] = &Job{
+			Model:          model,
+			InitialBuffers: framework.InitialBuffers{},
+			Framework:      "text",
+		}
+	}
+
+	numProgress := 0
+
+	results := pool.Run(context.Background(), jobs, func(done, total int) {
+		numProgress++
+	})
+
+	if numProgress != len(jobs) {
+		t.Errorf("expected progress to be called %d times, got %d", len(jobs), numProgress)
+	}
+
+	for i, result := range results {
+		if result.Job != jobs[i] {
+			t.Errorf("result %d: results are not in the same order as the jobs", i)
+		}
+
+		if result.Err != nil {
+			t.Fatalf("result %d: %s", i, result.Err)
+		}
+
+		// The executable is "cat" so the output is the generated code
+		if string(result.RunResult.Output) != string(result.RunResult.GeneratedCode) {
+			t.Errorf("result %d: expected output to match generated code, got:\n%s", i, result.RunResult.Output)
+		}
+	}
+}
+
+func TestNewPoolMissingFramework(t *testing.T) {
+	settings := newTestSettings(t)
+
+	_, err := NewPool(settings, []string{"text", "ccm"}, 2)
+
+	var notCreated *ErrFrameworkNotCreated
+	if !errors.As(err, &notCreated) || notCreated.Name != "ccm" {
+		t.Errorf("expected ErrFrameworkNotCreated for ccm, got %v", err)
+	}
+}
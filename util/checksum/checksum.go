@@ -0,0 +1,125 @@
+// Package checksum verifies files against a manifest of pinned SHA-256 hashes.
+package checksum
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type ErrInvalidManifestLine struct {
+	FileName   string
+	LineNumber int
+}
+
+func (e ErrInvalidManifestLine) Error() string {
+	return fmt.Sprintf("%s:%d: expected '<sha256>  <artifact>'", e.FileName, e.LineNumber)
+}
+
+type ErrUnknownArtifact struct {
+	Artifact string
+}
+
+func (e ErrUnknownArtifact) Error() string {
+	return fmt.Sprintf("%q has no pinned checksum - it is not a version gactar knows about", e.Artifact)
+}
+
+type ErrMismatch struct {
+	Artifact string
+	Expected string
+	Actual   string
+}
+
+func (e ErrMismatch) Error() string {
+	return fmt.Sprintf("checksum of %q does not match:\n  expected sha256 %s\n       got sha256 %s", e.Artifact, e.Expected, e.Actual)
+}
+
+// Manifest maps the file name of each artifact to its expected SHA-256 hash (lowercase hex).
+type Manifest map[string]string
+
+// Load reads a manifest in the format output by "sha256sum": one "<hash>  <artifact>" per line.
+// Blank lines and lines starting with '#' are ignored.
+func Load(fileName string) (manifest Manifest, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	return Parse(file, fileName)
+}
+
+// Parse reads a manifest from r. fileName is only used in error messages.
+func Parse(r io.Reader, fileName string) (manifest Manifest, err error) {
+	manifest = Manifest{}
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			return nil, &ErrInvalidManifestLine{FileName: fileName, LineNumber: lineNumber}
+		}
+
+		// sha256sum marks binary files with a '*'
+		artifact := strings.TrimPrefix(fields[1], "*")
+
+		manifest[artifact] = strings.ToLower(fields[0])
+	}
+
+	err = scanner.Err()
+	return
+}
+
+// Verify checks the hash of the file against the manifest. The artifact is identified by the
+// base name of the file.
+func (m Manifest) Verify(filePath string) (err error) {
+	artifact := filepath.Base(filePath)
+
+	expected, ok := m[artifact]
+	if !ok {
+		return &ErrUnknownArtifact{Artifact: artifact}
+	}
+
+	actual, err := FileHash(filePath)
+	if err != nil {
+		return
+	}
+
+	if actual != expected {
+		return &ErrMismatch{Artifact: artifact, Expected: expected, Actual: actual}
+	}
+
+	return
+}
+
+// FileHash returns the SHA-256 hash of the file as lowercase hex.
+func FileHash(filePath string) (hash string, err error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+
+	_, err = io.Copy(hasher, file)
+	if err != nil {
+		return
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,73 @@
+package checksum
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sha256 of "hello\n"
+const helloHash = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+func TestParse(t *testing.T) {
+	input := `# pinned artifacts
+
+` + helloHash + `  hello.txt
+` + strings.ToUpper(helloHash) + ` *binary.zip
+`
+
+	manifest, err := Parse(strings.NewReader(input), "checksums.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest) != 2 {
+		t.Fatalf("expected 2 artifacts, got %d", len(manifest))
+	}
+
+	if manifest["binary.zip"] != helloHash {
+		t.Errorf("expected binary marker to be removed and hash lowercased, got %v", manifest)
+	}
+
+	_, err = Parse(strings.NewReader("abc hello.txt\n"), "checksums.txt")
+
+	var invalid *ErrInvalidManifestLine
+	if !errors.As(err, &invalid) || invalid.LineNumber != 1 {
+		t.Errorf("expected invalid line error for line 1, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+
+	filePath := filepath.Join(dir, "hello.txt")
+	err := os.WriteFile(filePath, []byte("hello\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Manifest{"hello.txt": helloHash}.Verify(filePath)
+	if err != nil {
+		t.Errorf("expected file to verify, got %v", err)
+	}
+
+	err = Manifest{"hello.txt": strings.Repeat("0", 64)}.Verify(filePath)
+
+	var mismatch *ErrMismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected mismatch, got %v", err)
+	}
+
+	if mismatch.Artifact != "hello.txt" || mismatch.Actual != helloHash {
+		t.Errorf("unexpected mismatch: %+v", mismatch)
+	}
+
+	err = Manifest{}.Verify(filePath)
+
+	var unknown *ErrUnknownArtifact
+	if !errors.As(err, &unknown) || unknown.Artifact != "hello.txt" {
+		t.Errorf("expected unknown artifact error, got %v", err)
+	}
+}
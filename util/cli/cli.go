@@ -8,9 +8,11 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/asmaloney/gactar/framework"
 	"github.com/asmaloney/gactar/util/filesystem"
+	"github.com/asmaloney/gactar/util/workspace"
 )
 
 var (
@@ -25,7 +27,36 @@ type Settings struct {
 
 	Version string // the version string for output to command line
 	Debug   bool   // is debug enabled?
+
+	Timeout time.Duration // maximum time to allow for each model run (0 means no limit)
+
+	ShowStats bool // output production & retrieval statistics after each run
+
+	WorkspaceRetention workspace.Retention // how long to keep the workspace of each run
 }
+
+// CleanWorkspaces removes the run workspaces which should no longer be kept.
+func (s Settings) CleanWorkspaces() (err error) {
+	_, err = workspace.Clean(s.TempPath, s.WorkspaceRetention)
+	return
+}
+
+// RunContext returns a context to use when running a model. If the settings have a timeout,
+// the context will be cancelled when it expires.
+func (s Settings) RunContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return WithTimeout(parent, s.Timeout)
+}
+
+// WithTimeout returns a context which is cancelled after "timeout". If timeout is 0,
+// the context is only cancelled when the returned function is called.
+func WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+
+	return context.WithTimeout(parent, timeout)
+}
+
 type settingsKey string
 
 var contextKey settingsKey = "cli"
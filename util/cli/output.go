@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/asmaloney/gactar/framework"
+	"github.com/asmaloney/gactar/util/chalk"
+)
+
+// PrefixedOutput is used to write the output of several frameworks running in parallel to
+// the same place. Each line is written whole and is prefixed by the framework name if there
+// is more than one framework.
+type PrefixedOutput struct {
+	output io.Writer
+	width  int // width of the prefix (0 means no prefix)
+	mutex  sync.Mutex
+}
+
+// NewPrefixedOutput creates a PrefixedOutput for the named frameworks.
+func NewPrefixedOutput(output io.Writer, names []string) (p *PrefixedOutput) {
+	p = &PrefixedOutput{output: output}
+
+	if len(names) > 1 {
+		for _, name := range names {
+			if len(name) > p.width {
+				p.width = len(name)
+			}
+		}
+	}
+
+	return
+}
+
+// Writer returns an io.Writer for the named framework.
+func (p *PrefixedOutput) Writer(name string) io.Writer {
+	return framework.NewLineWriter(func(line string) {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+
+		if p.width > 0 {
+			fmt.Fprint(p.output, chalk.Header(fmt.Sprintf("%-*s | ", p.width, name)))
+		}
+
+		fmt.Fprintln(p.output, line)
+	})
+}
+
+// WriteFinalStates outputs the final state reported by each framework's run. The names and
+// results are in the same order. Results which are nil or have no final state are skipped.
+func WriteFinalStates(output io.Writer, names []string, results []*framework.RunResult) {
+	for i, result := range results {
+		if result == nil || result.Events == nil || !result.Events.HasState() {
+			continue
+		}
+
+		fmt.Fprintln(output, chalk.Header(fmt.Sprintf("== %s: final state ==", names[i])))
+		result.Events.FinalState.Write(output)
+	}
+}
+
+// WriteStats outputs the statistics of each framework's run. The names and results are in the
+// same order. Results which are nil or have no statistics are skipped.
+func WriteStats(output io.Writer, names []string, results []*framework.RunResult) {
+	for i, result := range results {
+		if result == nil || result.Stats == nil {
+			continue
+		}
+
+		fmt.Fprintln(output, chalk.Header(fmt.Sprintf("== %s: statistics ==", names[i])))
+		result.Stats.Write(output)
+	}
+}
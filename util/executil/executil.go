@@ -1,26 +1,187 @@
 package executil
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os/exec"
 )
 
+var (
+	ErrExecutionFailed = errors.New("execution failed")
+)
+
 type ErrExecuteCommand struct {
 	Output string
 }
 
 func (e ErrExecuteCommand) Error() string {
-	return fmt.Sprintf("execution failed:\n%s", e.Output)
+	return fmt.Sprintf("%s:\n%s", ErrExecutionFailed, e.Output)
+}
+
+// Unwrap allows errors.Is(err, ErrExecutionFailed) so callers which have already shown
+// the output (e.g. by streaming it) can report the failure without repeating it.
+func (e ErrExecuteCommand) Unwrap() error {
+	return ErrExecutionFailed
+}
+
+// ErrTimeout is returned when a command is killed because its context's deadline passed.
+// Output contains whatever the command wrote before it was killed.
+type ErrTimeout struct {
+	Output string
+}
+
+func (e ErrTimeout) Error() string {
+	return "execution timed out"
+}
+
+// ErrCancelled is returned when a command is killed because its context was cancelled.
+// Output contains whatever the command wrote before it was killed.
+type ErrCancelled struct {
+	Output string
+}
+
+func (e ErrCancelled) Error() string {
+	return "execution cancelled"
+}
+
+// IsInterrupted returns true if the error is an ErrTimeout or an ErrCancelled.
+func IsInterrupted(err error) bool {
+	var timeoutErr *ErrTimeout
+	var cancelledErr *ErrCancelled
+
+	return errors.As(err, &timeoutErr) || errors.As(err, &cancelledErr)
 }
 
 func ExecCommand(name string, arg ...string) (output string, err error) {
+	return ExecCommandContext(context.Background(), name, arg...)
+}
+
+// ExecCommandContext runs the command and returns its output (stdout + stderr).
+// The command is run in its own process group. If the context is done before the command
+// finishes, the whole process group is killed and ErrTimeout or ErrCancelled is returned
+// along with the output so far.
+func ExecCommandContext(ctx context.Context, name string, arg ...string) (output string, err error) {
+	return ExecCommandStream(ctx, nil, name, arg...)
+}
+
+// ExecCommandStream is the same as ExecCommandContext, but it also writes the output (stdout + stderr)
+// to "stream" as it arrives. If stream is nil, it is ignored.
+func ExecCommandStream(ctx context.Context, stream io.Writer, name string, arg ...string) (output string, err error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = interruptedError(ctxErr, "")
+		return
+	}
+
+	var outputBuffer bytes.Buffer
+
+	var outputWriter io.Writer = &outputBuffer
+	if stream != nil {
+		outputWriter = io.MultiWriter(&outputBuffer, stream)
+	}
+
+	// Use the same writer for both so the output is combined in the order it is written
 	cmd := exec.Command(name, arg...)
-	outputBytes, err := cmd.CombinedOutput()
-	output = string(outputBytes)
+	cmd.Stdout = outputWriter
+	cmd.Stderr = outputWriter
+
+	setProcessGroup(cmd)
+
+	err = cmd.Start()
 	if err != nil {
+		err = &ErrExecuteCommand{Output: err.Error()}
+		return
+	}
+
+	interrupted, waitErr := wait(ctx, cmd)
+
+	output = outputBuffer.String()
+
+	if interrupted {
+		err = interruptedError(ctx.Err(), output)
+		return
+	}
+
+	if waitErr != nil {
 		err = &ErrExecuteCommand{Output: output}
 		return
 	}
 
 	return
 }
+
+// wait waits for the command to finish and kills its process group if the context is done first.
+// The command was only interrupted if it failed once the context was done - if it finished on its
+// own just as the context was done, we use its result.
+func wait(ctx context.Context, cmd *exec.Cmd) (interrupted bool, err error) {
+	finished := make(chan struct{})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = killProcessGroup(cmd)
+
+		case <-finished:
+		}
+	}()
+
+	err = cmd.Wait()
+	close(finished)
+
+	interrupted = err != nil && ctx.Err() != nil
+	return
+}
+
+func interruptedError(ctxErr error, output string) error {
+	if errors.Is(ctxErr, context.DeadlineExceeded) {
+		return &ErrTimeout{Output: output}
+	}
+
+	return &ErrCancelled{Output: output}
+}
+
+// ExecCommandWithInput runs the command with "input" on its stdin and returns what it wrote to stdout.
+// If the command fails, the error contains what it wrote to stderr. Like ExecCommandContext, the
+// command's process group is killed if the context is done before it finishes.
+func ExecCommandWithInput(ctx context.Context, input []byte, name string, arg ...string) (output string, err error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = interruptedError(ctxErr, "")
+		return
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(name, arg...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	setProcessGroup(cmd)
+
+	err = cmd.Start()
+	if err != nil {
+		err = &ErrExecuteCommand{Output: err.Error()}
+		return
+	}
+
+	interrupted, waitErr := wait(ctx, cmd)
+
+	if interrupted {
+		err = interruptedError(ctx.Err(), stderr.String())
+		return
+	}
+
+	if waitErr != nil {
+		errOutput := stderr.String()
+		if errOutput == "" {
+			errOutput = waitErr.Error()
+		}
+
+		err = &ErrExecuteCommand{Output: errOutput}
+		return
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,86 @@
+//go:build !windows
+
+package executil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecCommand(t *testing.T) {
+	output, err := ExecCommand("sh", "-c", "echo hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if output != "hello\n" {
+		t.Errorf("unexpected output: %q", output)
+	}
+
+	_, err = ExecCommand("sh", "-c", "exit 1")
+
+	var execErr *ErrExecuteCommand
+	if !errors.As(err, &execErr) {
+		t.Errorf("expected ErrExecuteCommand, got %v", err)
+	}
+}
+
+func TestExecCommandTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	// The background child keeps the output pipe open, so this only returns quickly
+	// if the whole process group is killed.
+	output, err := ExecCommandContext(ctx, "sh", "-c", "echo started; sleep 30 & wait")
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("command was not killed in time (took %s)", elapsed)
+	}
+
+	var timeoutErr *ErrTimeout
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	if output != "started\n" || timeoutErr.Output != output {
+		t.Errorf("expected partial output, got %q", output)
+	}
+
+	if !IsInterrupted(err) {
+		t.Error("expected IsInterrupted to be true")
+	}
+}
+
+func TestExecCommandCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := ExecCommandContext(ctx, "sh", "-c", "sleep 30")
+
+	var cancelledErr *ErrCancelled
+	if !errors.As(err, &cancelledErr) {
+		t.Fatalf("expected ErrCancelled, got %v", err)
+	}
+}
+
+func TestExecCommandStream(t *testing.T) {
+	var stream bytes.Buffer
+
+	output, err := ExecCommandStream(context.Background(), &stream, "sh", "-c", "echo one; echo two >&2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if stream.String() != output || output != "one\ntwo\n" {
+		t.Errorf("unexpected output: %q (streamed %q)", output, stream.String())
+	}
+}
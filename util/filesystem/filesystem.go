@@ -49,13 +49,31 @@ func CreateDir(path string) (err error) {
 	return
 }
 
+type ErrDownloadFailed struct {
+	URL    string
+	Status string
+}
+
+func (e ErrDownloadFailed) Error() string {
+	return fmt.Sprintf("could not download %q: %s", e.URL, e.Status)
+}
+
+// DownloadFile gets the file at url and writes it to filePath. file:// URLs are copied.
 func DownloadFile(url *url.URL, filePath string) (err error) {
+	if url.Scheme == "file" {
+		return CopyFile(url.Path, filePath)
+	}
+
 	resp, err := http.Get(url.String())
 	if err != nil {
 		return
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return &ErrDownloadFailed{URL: url.String(), Status: resp.Status}
+	}
+
 	out, err := os.Create(filePath)
 	if err != nil {
 		return
@@ -91,3 +109,28 @@ func RemoveFile(filePath string) error {
 
 	return err
 }
+
+// CopyFile copies the file at "source" to "destination", replacing it if it exists.
+func CopyFile(source, destination string) (err error) {
+	in, err := os.Open(source)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = &ErrFileDoesNotExist{FileName: source}
+		}
+		return
+	}
+	defer in.Close()
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return
+	}
+
+	return out.Close()
+}
@@ -3,8 +3,11 @@
 package frameworkutil
 
 import (
+	"sort"
+
 	"github.com/asmaloney/gactar/framework"
 	"github.com/asmaloney/gactar/framework/ccm_pyactr"
+	"github.com/asmaloney/gactar/framework/plugin"
 	"github.com/asmaloney/gactar/framework/pyactr"
 	"github.com/asmaloney/gactar/framework/vanilla_actr"
 
@@ -37,8 +40,13 @@ func CreateFrameworks(settings *cli.Settings, names []string) (list framework.Li
 			fw, err = vanilla_actr.New(settings)
 
 		default:
-			chalk.PrintErrStr("unknown framework:", f)
-			continue
+			manifest := plugin.Lookup(f)
+			if manifest == nil {
+				chalk.PrintErrStr("unknown framework:", f)
+				continue
+			}
+
+			fw, err = plugin.New(settings, manifest)
 		}
 
 		if err != nil {
@@ -51,3 +59,19 @@ func CreateFrameworks(settings *cli.Settings, names []string) (list framework.Li
 
 	return
 }
+
+// InfoList returns the info for all the built-in frameworks and registered plugins sorted by name.
+// The frameworks do not need to be installed.
+func InfoList() (list framework.InfoList) {
+	list = framework.InfoList{ccm_pyactr.Info, pyactr.Info, vanilla_actr.Info}
+
+	for _, manifest := range plugin.Registered() {
+		list = append(list, manifest.Info())
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return
+}
@@ -0,0 +1,22 @@
+package frameworkutil
+
+import (
+	"testing"
+
+	"github.com/asmaloney/gactar/framework"
+)
+
+// TestCapabilitiesComplete checks that each built-in framework lists every feature.
+func TestCapabilitiesComplete(t *testing.T) {
+	for _, info := range InfoList() {
+		for _, feature := range framework.Features {
+			if _, ok := info.Capabilities[feature.Name]; !ok {
+				t.Errorf("%s: missing capability for %q", info.Name, feature.Name)
+			}
+		}
+
+		if len(info.Capabilities) != len(framework.Features) {
+			t.Errorf("%s: expected %d capabilities, got %d", info.Name, len(framework.Features), len(info.Capabilities))
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Package optimize provides derivative-free minimization of functions.
+package optimize
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+var (
+	ErrNoParameters     = errors.New("no parameters to optimize")
+	ErrInvalidBounds    = errors.New("lower bound must be less than upper bound")
+	ErrStartOutOfBounds = errors.New("start value is outside of bounds")
+)
+
+// Bounds are the lower and upper limits of a parameter.
+type Bounds struct {
+	Lower float64
+	Upper float64
+}
+
+// Settings control the Nelder-Mead optimizer.
+type Settings struct {
+	MaxEvaluations int     // maximum number of function evaluations (default 200)
+	Tolerance      float64 // stop when the spread of function values in the simplex is below this (default 1e-6)
+}
+
+// Result is the outcome of a minimization.
+type Result struct {
+	X           []float64 // best parameters found
+	F           float64   // function value at X
+	Evaluations int       // number of function evaluations
+	Converged   bool      // whether we met the tolerance before running out of evaluations
+}
+
+// Function is a function to minimize. It may return +Inf (or NaN) for invalid points.
+type Function func(x []float64) float64
+
+type vertex struct {
+	x []float64
+	f float64
+}
+
+// NelderMead minimizes "fn" starting at "start" using the Nelder-Mead simplex method.
+// Points are clamped to the bounds, so the function is only ever evaluated inside them.
+func NelderMead(fn Function, start []float64, bounds []Bounds, settings Settings) (result *Result, err error) {
+	const (
+		reflection  = 1.0
+		expansion   = 2.0
+		contraction = 0.5
+		shrink      = 0.5
+	)
+
+	n := len(start)
+	if n == 0 {
+		return nil, ErrNoParameters
+	}
+
+	for i, b := range bounds {
+		if b.Lower >= b.Upper {
+			return nil, ErrInvalidBounds
+		}
+
+		if start[i] < b.Lower || start[i] > b.Upper {
+			return nil, ErrStartOutOfBounds
+		}
+	}
+
+	if settings.MaxEvaluations <= 0 {
+		settings.MaxEvaluations = 200
+	}
+
+	if settings.Tolerance <= 0 {
+		settings.Tolerance = 1e-6
+	}
+
+	result = &Result{}
+
+	evaluate := func(x []float64) vertex {
+		clamp(x, bounds)
+		result.Evaluations++
+
+		f := fn(x)
+		if math.IsNaN(f) {
+			f = math.Inf(1)
+		}
+
+		return vertex{x: x, f: f}
+	}
+
+	// Initial simplex: the start point plus a step of 10% of the range along each axis.
+	simplex := make([]vertex, n+1)
+	simplex[0] = evaluate(copyOf(start))
+
+	for i := 0; i < n; i++ {
+		x := copyOf(start)
+
+		step := 0.1 * (bounds[i].Upper - bounds[i].Lower)
+		if x[i]+step > bounds[i].Upper {
+			step = -step
+		}
+
+		x[i] += step
+		simplex[i+1] = evaluate(x)
+	}
+
+	for result.Evaluations < settings.MaxEvaluations {
+		sort.SliceStable(simplex, func(i, j int) bool {
+			return simplex[i].f < simplex[j].f
+		})
+
+		best, worst := simplex[0], simplex[n]
+
+		if math.Abs(worst.f-best.f) < settings.Tolerance {
+			result.Converged = true
+			break
+		}
+
+		// Centroid of all but the worst point
+		centroid := make([]float64, n)
+		for _, v := range simplex[:n] {
+			for i := range centroid {
+				centroid[i] += v.x[i] / float64(n)
+			}
+		}
+
+		reflected := evaluate(along(centroid, worst.x, -reflection))
+
+		switch {
+		case reflected.f < best.f:
+			expanded := evaluate(along(centroid, worst.x, -expansion))
+			if expanded.f < reflected.f {
+				simplex[n] = expanded
+			} else {
+				simplex[n] = reflected
+			}
+
+		case reflected.f < simplex[n-1].f:
+			simplex[n] = reflected
+
+		default:
+			var contracted vertex
+			if reflected.f < worst.f {
+				// outside contraction
+				contracted = evaluate(along(centroid, reflected.x, contraction))
+			} else {
+				// inside contraction
+				contracted = evaluate(along(centroid, worst.x, contraction))
+			}
+
+			if contracted.f < math.Min(reflected.f, worst.f) {
+				simplex[n] = contracted
+				continue
+			}
+
+			// shrink towards the best point
+			for i := 1; i <= n; i++ {
+				simplex[i] = evaluate(along(best.x, simplex[i].x, shrink))
+			}
+		}
+	}
+
+	sort.SliceStable(simplex, func(i, j int) bool {
+		return simplex[i].f < simplex[j].f
+	})
+
+	result.X = simplex[0].x
+	result.F = simplex[0].f
+
+	return
+}
+
+// along returns the point centroid + t * (x - centroid).
+func along(centroid, x []float64, t float64) []float64 {
+	point := make([]float64, len(x))
+	for i := range x {
+		point[i] = centroid[i] + t*(x[i]-centroid[i])
+	}
+
+	return point
+}
+
+func clamp(x []float64, bounds []Bounds) {
+	for i, b := range bounds {
+		x[i] = math.Max(b.Lower, math.Min(b.Upper, x[i]))
+	}
+}
+
+func copyOf(x []float64) []float64 {
+	return append([]float64(nil), x...)
+}
@@ -0,0 +1,66 @@
+package optimize
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNelderMeadQuadratic(t *testing.T) {
+	fn := func(x []float64) float64 {
+		return math.Pow(x[0]-0.3, 2) + math.Pow(x[1]+1.5, 2)
+	}
+
+	bounds := []Bounds{{-5, 5}, {-5, 5}}
+
+	result, err := NelderMead(fn, []float64{1, 1}, bounds, Settings{MaxEvaluations: 500, Tolerance: 1e-12})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(result.X[0]-0.3) > 1e-3 || math.Abs(result.X[1]+1.5) > 1e-3 {
+		t.Errorf("expected minimum near (0.3, -1.5), got %v", result.X)
+	}
+
+	if !result.Converged {
+		t.Errorf("expected to converge in %d evaluations", result.Evaluations)
+	}
+}
+
+func TestNelderMeadRespectsBounds(t *testing.T) {
+	fn := func(x []float64) float64 {
+		if x[0] < 0 || x[0] > 1 {
+			t.Fatalf("evaluated outside bounds: %v", x[0])
+		}
+
+		return math.Pow(x[0]+2, 2)
+	}
+
+	result, err := NelderMead(fn, []float64{0.5}, []Bounds{{0, 1}}, Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(result.X[0]) > 1e-3 {
+		t.Errorf("expected minimum at lower bound 0, got %v", result.X[0])
+	}
+}
+
+func TestNelderMeadInvalidInput(t *testing.T) {
+	fn := func(x []float64) float64 { return 0 }
+
+	_, err := NelderMead(fn, []float64{}, nil, Settings{})
+	if !errors.Is(err, ErrNoParameters) {
+		t.Errorf("expected ErrNoParameters, got %v", err)
+	}
+
+	_, err = NelderMead(fn, []float64{0.5}, []Bounds{{1, 0}}, Settings{})
+	if !errors.Is(err, ErrInvalidBounds) {
+		t.Errorf("expected ErrInvalidBounds, got %v", err)
+	}
+
+	_, err = NelderMead(fn, []float64{2}, []Bounds{{0, 1}}, Settings{})
+	if !errors.Is(err, ErrStartOutOfBounds) {
+		t.Errorf("expected ErrStartOutOfBounds, got %v", err)
+	}
+}
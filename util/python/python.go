@@ -4,6 +4,7 @@ package python
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -26,17 +27,19 @@ func (e *ErrPythonPackageNotFound) Error() string {
 	return fmt.Sprintf("python package %q not found. Please ensure it is installed with pip or is in your PYTHONPATH env variable", e.PackageName)
 }
 
-func FindPython3(outputDetails bool) (path string, err error) {
+// FindPython3 looks for python 3 in the PATH and returns its path. If out is not nil, the path
+// and version of the python found are written to it.
+func FindPython3(out io.Writer) (path string, err error) {
 	defer func() {
-		if outputDetails && err == nil {
-			fmt.Printf("> Found python: %s\n", path)
+		if out != nil && err == nil {
+			fmt.Fprintf(out, "> Found python: %s\n", path)
 
 			output, execErr := executil.ExecCommand(path, "--version")
 			if execErr != nil {
 				return
 			}
 
-			fmt.Printf(">   %s", output)
+			fmt.Fprintf(out, ">   %s", output)
 		}
 	}()
 
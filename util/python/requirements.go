@@ -0,0 +1,143 @@
+package python
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/asmaloney/gactar/util/executil"
+)
+
+// Requirement is one package from a pip requirements file.
+type Requirement struct {
+	Name    string
+	Version string   // pinned version ("" if it is not pinned using "==")
+	Hashes  []string // hashes of the package files from "--hash" options (e.g. "sha256:...")
+}
+
+// Spec returns the requirement in the form pip takes on the command line.
+func (r Requirement) Spec() string {
+	if r.Version == "" {
+		return r.Name
+	}
+
+	return r.Name + "==" + r.Version
+}
+
+var nameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// NormalizePackageName returns the name pip uses to compare packages (PEP 503), so that
+// "python_actr" and "Python-ACTR" are the same package.
+func NormalizePackageName(name string) string {
+	return nameSeparators.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+// ReadRequirements reads the packages from a pip requirements file, including any files it
+// includes using "-r". Lines may be continued using "\". Environment markers and options other
+// than "--hash" are ignored.
+func ReadRequirements(fileName string) (requirements []Requirement, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	continued := ""
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if index := strings.Index(line, "#"); index != -1 {
+			line = line[:index]
+		}
+
+		line = strings.TrimSpace(line)
+
+		if strings.HasSuffix(line, "\\") {
+			continued += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+
+		line = strings.TrimSpace(continued + line)
+		continued = ""
+
+		switch {
+		case line == "":
+			continue
+
+		case strings.HasPrefix(line, "-r "), strings.HasPrefix(line, "--requirement "):
+			fields := strings.Fields(line)
+			included := filepath.Join(filepath.Dir(fileName), fields[len(fields)-1])
+
+			more, err := ReadRequirements(included)
+			if err != nil {
+				return nil, err
+			}
+
+			requirements = append(requirements, more...)
+
+		case strings.HasPrefix(line, "-"):
+			continue
+
+		default:
+			requirements = append(requirements, parseRequirement(line))
+		}
+	}
+
+	err = scanner.Err()
+	return
+}
+
+// parseRequirement parses a line such as "pyactr == 0.3.1 ; python_version >= '3.8' --hash=sha256:...".
+func parseRequirement(line string) (requirement Requirement) {
+	spec, options := line, ""
+	if index := strings.Index(line, " --"); index != -1 {
+		spec, options = line[:index], line[index:]
+	}
+
+	// leave out any environment markers
+	spec, _, _ = strings.Cut(spec, ";")
+
+	name, version, _ := strings.Cut(spec, "==")
+
+	requirement.Name = strings.TrimSpace(name)
+	requirement.Version = strings.TrimSpace(version)
+
+	for _, option := range strings.Fields(options) {
+		if strings.HasPrefix(option, "--hash=") {
+			requirement.Hashes = append(requirement.Hashes, strings.TrimPrefix(option, "--hash="))
+		}
+	}
+
+	return
+}
+
+// InstalledPackages returns the version of each package installed for the python executable,
+// keyed by the normalized package name.
+func InstalledPackages(python string) (packages map[string]string, err error) {
+	output, err := executil.ExecCommand(python, "-m", "pip", "list", "--format=json", "--disable-pip-version-check")
+	if err != nil {
+		return
+	}
+
+	list := []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}{}
+
+	err = json.Unmarshal([]byte(output), &list)
+	if err != nil {
+		return
+	}
+
+	packages = map[string]string{}
+	for _, p := range list {
+		packages[NormalizePackageName(p.Name)] = p.Version
+	}
+
+	return
+}
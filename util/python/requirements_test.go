@@ -0,0 +1,87 @@
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadRequirements(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, contents string) string {
+		fileName := filepath.Join(dir, name)
+
+		err := os.WriteFile(fileName, []byte(contents), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return fileName
+	}
+
+	write("requirements.txt", "# comment\npyactr == 0.3.1\n\n--no-binary :all:\npython-actr==1.9.2 # pinned\n")
+	dev := write("requirements-dev.txt", "-r requirements.txt\npylint\n")
+
+	requirements, err := ReadRequirements(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Requirement{
+		{Name: "pyactr", Version: "0.3.1"},
+		{Name: "python-actr", Version: "1.9.2"},
+		{Name: "pylint"},
+	}
+
+	if !reflect.DeepEqual(requirements, expected) {
+		t.Errorf("expected %v, got %v", expected, requirements)
+	}
+
+	if requirements[2].Spec() != "pylint" || requirements[0].Spec() != "pyactr==0.3.1" {
+		t.Errorf("unexpected specs: %q %q", requirements[0].Spec(), requirements[2].Spec())
+	}
+}
+
+func TestReadHashedRequirements(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "requirements-hashed.txt")
+
+	contents := `# generated
+pyactr==0.3.1 \
+    --hash=sha256:aaaa \
+    --hash=sha256:bbbb
+    # via -r requirements.txt
+numpy==1.23.5 ; python_version >= "3.8" \
+    --hash=sha256:cccc
+`
+
+	err := os.WriteFile(fileName, []byte(contents), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requirements, err := ReadRequirements(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Requirement{
+		{Name: "pyactr", Version: "0.3.1", Hashes: []string{"sha256:aaaa", "sha256:bbbb"}},
+		{Name: "numpy", Version: "1.23.5", Hashes: []string{"sha256:cccc"}},
+	}
+
+	if !reflect.DeepEqual(requirements, expected) {
+		t.Errorf("expected %v, got %v", expected, requirements)
+	}
+}
+
+func TestNormalizePackageName(t *testing.T) {
+	if NormalizePackageName("Python_ACTR") != NormalizePackageName("python-actr") {
+		t.Errorf("expected names to match: %q %q", NormalizePackageName("Python_ACTR"), NormalizePackageName("python-actr"))
+	}
+
+	if NormalizePackageName("zope.interface") != "zope-interface" {
+		t.Errorf("unexpected name: %q", NormalizePackageName("zope.interface"))
+	}
+}
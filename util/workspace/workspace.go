@@ -0,0 +1,179 @@
+// Package workspace manages the directories where the files for each model run are written.
+// Each run gets its own workspace so concurrent runs of models with the same name do not
+// overwrite each other's files.
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asmaloney/gactar/util/filesystem"
+)
+
+// DirName is the directory (in the temp directory) which contains the workspaces.
+const DirName = "runs"
+
+// minAge is the minimum age of a workspace before it is removed because we have too many.
+// This avoids removing workspaces which are still being used by a run in another process.
+const minAge = time.Minute
+
+// DefaultKey is used to name workspaces if the context does not contain a key.
+const DefaultKey = "run"
+
+// Retention controls how long workspaces are kept.
+type Retention struct {
+	MaxAge   time.Duration // remove workspaces older than this (0 means no limit)
+	MaxCount int           // keep at most this many workspaces (0 means no limit)
+}
+
+// DefaultRetention is used if the retention policy is not set on the command line.
+var DefaultRetention = Retention{
+	MaxAge:   24 * time.Hour,
+	MaxCount: 100,
+}
+
+// invalidKeyChars matches characters we do not want in directory names.
+var invalidKeyChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// inUse holds the paths of the workspaces created by this process which have not been released.
+// Clean never removes them.
+var (
+	inUse      = map[string]bool{}
+	inUseMutex sync.Mutex
+)
+
+type keyType string
+
+var contextKey keyType = "workspace"
+
+// WithKey returns a context which names the workspaces for runs using it (e.g. "session-3").
+func WithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, contextKey, key)
+}
+
+// KeyFromContext returns the workspace key from the context or DefaultKey if it was not set.
+func KeyFromContext(ctx context.Context) string {
+	key, ok := ctx.Value(contextKey).(string)
+	if !ok || key == "" {
+		return DefaultKey
+	}
+
+	return key
+}
+
+// Create creates a new workspace in <basePath>/runs and returns its full path. The name of
+// the workspace starts with the key from the context followed by the time and a unique suffix.
+// The workspace is in use until Release is called, so Clean will not remove it.
+func Create(ctx context.Context, basePath string) (path string, err error) {
+	dir := filepath.Join(basePath, DirName)
+
+	err = filesystem.CreateDir(dir)
+	if err != nil {
+		return
+	}
+
+	key := invalidKeyChars.ReplaceAllString(KeyFromContext(ctx), "_")
+	pattern := fmt.Sprintf("%s-%s-", key, time.Now().Format("20060102-150405"))
+
+	path, err = os.MkdirTemp(dir, pattern)
+	if err != nil {
+		return
+	}
+
+	inUseMutex.Lock()
+	inUse[path] = true
+	inUseMutex.Unlock()
+
+	return
+}
+
+// Release marks the workspace as no longer in use once its run is finished. It is kept until Clean
+// removes it according to the retention policy.
+func Release(path string) {
+	inUseMutex.Lock()
+	defer inUseMutex.Unlock()
+
+	delete(inUse, path)
+}
+
+func isInUse(path string) bool {
+	inUseMutex.Lock()
+	defer inUseMutex.Unlock()
+
+	return inUse[path]
+}
+
+// Clean removes the workspaces in <basePath>/runs which should not be kept according to the
+// retention policy. Workspaces are removed oldest first. Workspaces which are in use are never
+// removed. It returns the number removed.
+func Clean(basePath string, retention Retention) (numRemoved int, err error) {
+	dir := filepath.Join(basePath, DirName)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	type workspace struct {
+		path    string
+		modTime time.Time
+	}
+
+	workspaces := []workspace{}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		info, infoErr := entry.Info()
+		if infoErr != nil {
+			continue
+		}
+
+		workspaces = append(workspaces, workspace{
+			path:    filepath.Join(dir, entry.Name()),
+			modTime: info.ModTime(),
+		})
+	}
+
+	// newest first
+	sort.Slice(workspaces, func(i, j int) bool {
+		return workspaces[i].modTime.After(workspaces[j].modTime)
+	})
+
+	now := time.Now()
+
+	for i, ws := range workspaces {
+		age := now.Sub(ws.modTime)
+
+		tooOld := retention.MaxAge > 0 && age > retention.MaxAge
+		tooMany := retention.MaxCount > 0 && i >= retention.MaxCount && age > minAge
+
+		if !tooOld && !tooMany {
+			continue
+		}
+
+		if isInUse(ws.path) {
+			continue
+		}
+
+		err = os.RemoveAll(ws.path)
+		if err != nil {
+			return
+		}
+
+		numRemoved++
+	}
+
+	return
+}
@@ -0,0 +1,172 @@
+package workspace
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreate(t *testing.T) {
+	base := t.TempDir()
+
+	ctx := WithKey(context.Background(), "session/3")
+
+	first, err := Create(ctx, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := Create(ctx, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == second {
+		t.Errorf("expected unique workspaces, got %q twice", first)
+	}
+
+	if filepath.Dir(first) != filepath.Join(base, DirName) {
+		t.Errorf("expected workspace in %q, got %q", filepath.Join(base, DirName), first)
+	}
+
+	if !strings.HasPrefix(filepath.Base(first), "session_3-") {
+		t.Errorf("expected workspace name to start with the sanitized key, got %q", filepath.Base(first))
+	}
+
+	defaultPath, err := Create(context.Background(), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(filepath.Base(defaultPath), DefaultKey+"-") {
+		t.Errorf("expected workspace name to start with %q, got %q", DefaultKey, filepath.Base(defaultPath))
+	}
+}
+
+func TestClean(t *testing.T) {
+	base := t.TempDir()
+	ctx := context.Background()
+
+	now := time.Now()
+
+	// Create workspaces which are 1, 2, 3, & 4 hours old
+	paths := []string{}
+	for i := 1; i <= 4; i++ {
+		path, err := Create(ctx, base)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		modTime := now.Add(-time.Duration(i) * time.Hour)
+
+		err = os.Chtimes(path, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// its run is finished
+		Release(path)
+
+		paths = append(paths, path)
+	}
+
+	// A new one which should never be removed
+	newest, err := Create(ctx, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Release(newest)
+
+	numRemoved, err := Clean(base, Retention{MaxAge: 150 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if numRemoved != 2 {
+		t.Errorf("expected 2 workspaces removed by age, got %d", numRemoved)
+	}
+
+	numRemoved, err = Clean(base, Retention{MaxCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if numRemoved != 1 {
+		t.Errorf("expected 1 workspace removed by count, got %d", numRemoved)
+	}
+
+	for _, path := range []string{newest, paths[0]} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %q to be kept", path)
+		}
+	}
+
+	for _, path := range paths[1:] {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %q to be removed", path)
+		}
+	}
+}
+
+func TestCleanSkipsWorkspacesInUse(t *testing.T) {
+	base := t.TempDir()
+
+	path, err := Create(context.Background(), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Now().Add(-48 * time.Hour)
+
+	err = os.Chtimes(path, modTime, modTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// newer workspaces so the count limit applies too
+	for i := 0; i < 2; i++ {
+		newer, err := Create(context.Background(), base)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		Release(newer)
+	}
+
+	retention := Retention{MaxAge: time.Hour, MaxCount: 1}
+
+	numRemoved, err := Clean(base, retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if numRemoved != 0 {
+		t.Errorf("expected nothing to be removed, got %d", numRemoved)
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected workspace in use to be kept")
+	}
+
+	Release(path)
+
+	numRemoved, err = Clean(base, retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if numRemoved != 1 {
+		t.Errorf("expected the released workspace to be removed, got %d removed", numRemoved)
+	}
+}
+
+func TestCleanMissingDir(t *testing.T) {
+	numRemoved, err := Clean(filepath.Join(t.TempDir(), "missing"), DefaultRetention)
+	if err != nil || numRemoved != 0 {
+		t.Errorf("expected nothing to be removed without error, got %d, %v", numRemoved, err)
+	}
+}